@@ -0,0 +1,227 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"educational-platform/models"
+)
+
+// announcementColumns selects an announcement joined with its teacher
+// (aliases a and t) in the order scanAnnouncement reads them
+const announcementColumns = `a.id, a.teacher_id, t.name, a.title, a.body, a.pinned, a.email, a.send_at, a.sent_at,
+		       a.created_at, a.updated_at`
+
+// announcementStatsColumns counts an announcement's recipients and readers
+const announcementStatsColumns = `
+		       (SELECT COUNT(*) FROM announcement_recipients r WHERE r.announcement_id = a.id),
+		       (SELECT COUNT(*) FROM announcement_recipients r WHERE r.announcement_id = a.id AND r.read_at IS NOT NULL)`
+
+func scanAnnouncement(row interface{ Scan(...interface{}) error }, a *models.Announcement, extra ...interface{}) error {
+	var sendAt, sentAt sql.NullTime
+	dest := []interface{}{&a.ID, &a.TeacherID, &a.TeacherName, &a.Title, &a.Body, &a.Pinned, &a.Email, &sendAt,
+		&sentAt, &a.CreatedAt, &a.UpdatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+	if sendAt.Valid {
+		a.SendAt = &sendAt.Time
+	}
+	if sentAt.Valid {
+		a.SentAt = &sentAt.Time
+	}
+	return nil
+}
+
+func scanTeacherAnnouncement(row interface{ Scan(...interface{}) error }) (*models.Announcement, error) {
+	a := &models.Announcement{Stats: &models.AnnouncementStats{}}
+	err := scanAnnouncement(row, a, &a.Stats.Recipients, &a.Stats.Read)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func CreateAnnouncement(a *models.Announcement) (int, error) {
+	query := `
+		INSERT INTO announcements (teacher_id, title, body, pinned, email, send_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := DB.Exec(query, a.TeacherID, a.Title, a.Body, a.Pinned, a.Email, a.SendAt, a.CreatedAt,
+		a.UpdatedAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetAnnouncement returns an announcement with its recipient counts
+func GetAnnouncement(announcementID int) (*models.Announcement, error) {
+	query := `
+		SELECT ` + announcementColumns + `,` + announcementStatsColumns + `
+		FROM announcements a
+		JOIN teachers t ON a.teacher_id = t.id
+		WHERE a.id = ?
+	`
+	return scanTeacherAnnouncement(DB.QueryRow(query, announcementID))
+}
+
+// GetTeacherAnnouncements lists a teacher's announcements, pinned first and
+// then newest first, with their recipient counts
+func GetTeacherAnnouncements(teacherID int) ([]models.Announcement, error) {
+	query := `
+		SELECT ` + announcementColumns + `,` + announcementStatsColumns + `
+		FROM announcements a
+		JOIN teachers t ON a.teacher_id = t.id
+		WHERE a.teacher_id = ?
+		ORDER BY a.pinned DESC, COALESCE(a.sent_at, a.send_at, a.created_at) DESC, a.id DESC
+	`
+	rows, err := DB.Query(query, teacherID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	announcements := []models.Announcement{}
+	for rows.Next() {
+		a, err := scanTeacherAnnouncement(rows)
+		if err != nil {
+			return nil, err
+		}
+		announcements = append(announcements, *a)
+	}
+	return announcements, nil
+}
+
+func UpdateAnnouncement(a *models.Announcement) error {
+	query := `
+		UPDATE announcements
+		SET title = ?, body = ?, pinned = ?, email = ?, send_at = ?, updated_at = ?
+		WHERE id = ?
+	`
+	_, err := DB.Exec(query, a.Title, a.Body, a.Pinned, a.Email, a.SendAt, a.UpdatedAt, a.ID)
+	return err
+}
+
+func DeleteAnnouncement(announcementID int) error {
+	query := `DELETE FROM announcements WHERE id = ?`
+	_, err := DB.Exec(query, announcementID)
+	return err
+}
+
+// GetDueAnnouncements returns the unsent announcements whose time has come
+func GetDueAnnouncements(now time.Time) ([]int, error) {
+	query := `SELECT id FROM announcements WHERE sent_at IS NULL AND (send_at IS NULL OR send_at <= ?) ORDER BY id`
+	rows, err := DB.Query(query, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// DeliverAnnouncement marks an announcement sent and records its recipients.
+// It reports false, changing nothing, if the announcement was already sent.
+func DeliverAnnouncement(announcementID int, studentIDs []int, sentAt time.Time) (bool, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE announcements SET sent_at = ? WHERE id = ? AND sent_at IS NULL`, sentAt,
+		announcementID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	for _, studentID := range studentIDs {
+		_, err := tx.Exec(`INSERT OR IGNORE INTO announcement_recipients (announcement_id, student_id) VALUES (?, ?)`,
+			announcementID, studentID)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// GetStudentAnnouncements lists the announcements delivered to a student,
+// pinned first and then newest first
+func GetStudentAnnouncements(studentID int, unreadOnly bool) ([]models.Announcement, error) {
+	query := `
+		SELECT ` + announcementColumns + `, r.read_at
+		FROM announcement_recipients r
+		JOIN announcements a ON r.announcement_id = a.id
+		JOIN teachers t ON a.teacher_id = t.id
+		WHERE r.student_id = ? AND (? = 0 OR r.read_at IS NULL)
+		ORDER BY a.pinned DESC, a.sent_at DESC, a.id DESC
+	`
+	rows, err := DB.Query(query, studentID, unreadOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	announcements := []models.Announcement{}
+	for rows.Next() {
+		var a models.Announcement
+		var readAt sql.NullTime
+		err := scanAnnouncement(rows, &a, &readAt)
+		if err != nil {
+			return nil, err
+		}
+		if readAt.Valid {
+			a.ReadAt = &readAt.Time
+		}
+		announcements = append(announcements, a)
+	}
+	return announcements, nil
+}
+
+// SetAnnouncementRead marks an announcement delivered to a student as read
+// or unread. It returns sql.ErrNoRows if it was not delivered to them.
+func SetAnnouncementRead(announcementID, studentID int, read bool) error {
+	var readAt interface{}
+	if read {
+		readAt = time.Now().UTC()
+	}
+	query := `
+		UPDATE announcement_recipients SET read_at = CASE WHEN ? IS NULL THEN NULL ELSE COALESCE(read_at, ?) END
+		WHERE announcement_id = ? AND student_id = ?
+	`
+	result, err := DB.Exec(query, readAt, readAt, announcementID, studentID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err == nil && n == 0 {
+		err = sql.ErrNoRows
+	}
+	return err
+}
+
+// MarkAllAnnouncementsRead marks every announcement delivered to a student as
+// read and returns how many were unread
+func MarkAllAnnouncementsRead(studentID int) (int, error) {
+	query := `UPDATE announcement_recipients SET read_at = ? WHERE student_id = ? AND read_at IS NULL`
+	result, err := DB.Exec(query, time.Now().UTC(), studentID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
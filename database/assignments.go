@@ -0,0 +1,408 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"educational-platform/models"
+)
+
+// ErrNoSubmissionsLeft is returned when a student has used every submission
+// an assignment allows
+var ErrNoSubmissionsLeft = errors.New("no submissions left")
+
+// latestSubmissionCondition matches submissions (alias s) that are their
+// student's newest for the assignment
+const latestSubmissionCondition = `s.number = (SELECT MAX(s2.number) FROM assignment_submissions s2
+	WHERE s2.assignment_id = s.assignment_id AND s2.student_id = s.student_id)`
+
+const assignmentColumns = `a.id, a.teacher_id, COALESCE(a.course_id, 0), COALESCE(a.category_id, 0), a.title, COALESCE(a.description, ''), a.status,
+	a.due_at, a.late_policy, a.late_penalty, a.late_until, a.submission_type, a.max_submissions,
+	a.resubmit_after_grading, a.rubric, a.max_points,
+	(SELECT COUNT(DISTINCT s.student_id) FROM assignment_submissions s WHERE s.assignment_id = a.id),
+	(SELECT COUNT(*) FROM assignment_submissions s
+	 WHERE s.assignment_id = a.id AND s.graded_at IS NOT NULL AND ` + latestSubmissionCondition + `),
+	a.created_at, a.updated_at, t.name, COALESCE(c.title, '')`
+
+const assignmentTables = `assignments a
+	JOIN teachers t ON a.teacher_id = t.id
+	LEFT JOIN courses c ON a.course_id = c.id`
+
+// studentAssignmentCondition matches published assignments (alias a) a
+// student is set: those for a teacher's subscribers, and those of published
+// courses the student is actively enrolled in. It takes the student ID
+// twice and the current time.
+const studentAssignmentCondition = `a.status = 'published'
+	AND ((a.course_id IS NULL AND a.teacher_id IN (SELECT teacher_id FROM subscriptions WHERE student_id = ?))
+	     OR (c.status = 'published' AND EXISTS (
+	         SELECT 1 FROM enrollments e WHERE e.course_id = a.course_id AND e.student_id = ?
+	           AND ` + activeEnrollmentCondition + `)))`
+
+func scanAssignment(row interface{ Scan(...interface{}) error }, assignment *models.Assignment) error {
+	var dueAt, lateUntil sql.NullTime
+	var rubric string
+	err := row.Scan(&assignment.ID, &assignment.TeacherID, &assignment.CourseID, &assignment.CategoryID, &assignment.Title,
+		&assignment.Description, &assignment.Status, &dueAt, &assignment.LatePolicy, &assignment.LatePenalty,
+		&lateUntil, &assignment.SubmissionType, &assignment.MaxSubmissions, &assignment.ResubmitAfterGrading,
+		&rubric, &assignment.MaxPoints, &assignment.SubmittedCount, &assignment.GradedCount,
+		&assignment.CreatedAt, &assignment.UpdatedAt, &assignment.TeacherName, &assignment.CourseTitle)
+	if err != nil {
+		return err
+	}
+	if dueAt.Valid {
+		assignment.DueAt = &dueAt.Time
+	}
+	if lateUntil.Valid {
+		assignment.LateUntil = &lateUntil.Time
+	}
+	return json.Unmarshal([]byte(rubric), &assignment.Rubric)
+}
+
+func scanAssignments(rows *sql.Rows) ([]models.Assignment, error) {
+	defer rows.Close()
+
+	assignments := []models.Assignment{}
+	for rows.Next() {
+		var assignment models.Assignment
+		err := scanAssignment(rows, &assignment)
+		if err != nil {
+			return nil, err
+		}
+		assignments = append(assignments, assignment)
+	}
+	return assignments, nil
+}
+
+// assignmentValues returns the stored values of an assignment's settings
+func assignmentValues(assignment *models.Assignment) ([]interface{}, error) {
+	rubric, err := json.Marshal(assignment.Rubric)
+	if err != nil {
+		return nil, err
+	}
+	if assignment.Rubric == nil {
+		rubric = []byte("[]")
+	}
+	var courseID, categoryID interface{}
+	if assignment.CourseID != 0 {
+		courseID = assignment.CourseID
+	}
+	if assignment.CategoryID != 0 {
+		categoryID = assignment.CategoryID
+	}
+	return []interface{}{courseID, categoryID, assignment.Title, assignment.Description, assignment.Status, assignment.DueAt,
+		assignment.LatePolicy, assignment.LatePenalty, assignment.LateUntil, assignment.SubmissionType,
+		assignment.MaxSubmissions, assignment.ResubmitAfterGrading, string(rubric), assignment.MaxPoints}, nil
+}
+
+// Assignment queries
+
+func CreateAssignment(assignment *models.Assignment) (int, error) {
+	values, err := assignmentValues(assignment)
+	if err != nil {
+		return 0, err
+	}
+	query := `
+		INSERT INTO assignments (teacher_id, course_id, category_id, title, description, status, due_at,
+		                         late_policy, late_penalty, late_until, submission_type, max_submissions,
+		                         resubmit_after_grading, rubric, max_points)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := DB.Exec(query, append([]interface{}{assignment.TeacherID}, values...)...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func GetAssignmentByID(assignmentID int) (*models.Assignment, error) {
+	query := `SELECT ` + assignmentColumns + ` FROM ` + assignmentTables + ` WHERE a.id = ?`
+	assignment := &models.Assignment{}
+	err := scanAssignment(DB.QueryRow(query, assignmentID), assignment)
+	if err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+// GetAssignmentsByTeacherID lists a teacher's assignments by due date, those
+// without one last; courseID limits them to one course
+func GetAssignmentsByTeacherID(teacherID, courseID int) ([]models.Assignment, error) {
+	query := `
+		SELECT ` + assignmentColumns + `
+		FROM ` + assignmentTables + `
+		WHERE a.teacher_id = ? AND (? = 0 OR a.course_id = ?)
+		ORDER BY a.due_at IS NULL, a.due_at, a.id
+	`
+	rows, err := DB.Query(query, teacherID, courseID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	return scanAssignments(rows)
+}
+
+// GetAssignmentsForStudent lists the published assignments a student is set,
+// by due date
+func GetAssignmentsForStudent(studentID int) ([]models.Assignment, error) {
+	query := `
+		SELECT ` + assignmentColumns + `
+		FROM ` + assignmentTables + `
+		WHERE ` + studentAssignmentCondition + `
+		ORDER BY a.due_at IS NULL, a.due_at, a.id
+	`
+	rows, err := DB.Query(query, studentID, studentID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scanAssignments(rows)
+}
+
+// GetStudentAssignment returns an assignment if the student is set it, and
+// sql.ErrNoRows otherwise
+func GetStudentAssignment(assignmentID, studentID int) (*models.Assignment, error) {
+	query := `
+		SELECT ` + assignmentColumns + `
+		FROM ` + assignmentTables + `
+		WHERE a.id = ? AND ` + studentAssignmentCondition + `
+	`
+	assignment := &models.Assignment{}
+	err := scanAssignment(DB.QueryRow(query, assignmentID, studentID, studentID, time.Now().UTC()), assignment)
+	if err != nil {
+		return nil, err
+	}
+	return assignment, nil
+}
+
+// UpdateAssignment stores an assignment's title, description, settings and category
+func UpdateAssignment(assignment *models.Assignment) error {
+	values, err := assignmentValues(assignment)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE assignments
+		SET course_id = ?, category_id = ?, title = ?, description = ?, status = ?, due_at = ?, late_policy = ?,
+		    late_penalty = ?, late_until = ?, submission_type = ?, max_submissions = ?,
+		    resubmit_after_grading = ?, rubric = ?, max_points = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+	_, err = DB.Exec(query, append(values, assignment.ID)...)
+	return err
+}
+
+// DeleteAssignment removes an assignment with its submissions; callers
+// remove the submitted files
+func DeleteAssignment(assignmentID int) error {
+	query := `DELETE FROM assignments WHERE id = ?`
+	_, err := DB.Exec(query, assignmentID)
+	return err
+}
+
+// GetAssignmentStudents lists the students an assignment is set for: the
+// course's active enrollments, or the teacher's subscribers when it has no
+// course. Students who submitted before losing access are included too.
+func GetAssignmentStudents(assignment *models.Assignment) ([]models.AssignmentStudentStatus, error) {
+	query := `
+		SELECT s.id, s.name
+		FROM students s
+		WHERE (? != 0 AND s.id IN (SELECT e.student_id FROM enrollments e
+		                           WHERE e.course_id = ? AND ` + activeEnrollmentCondition + `))
+		   OR (? = 0 AND s.id IN (SELECT student_id FROM subscriptions WHERE teacher_id = ?))
+		   OR s.id IN (SELECT student_id FROM assignment_submissions WHERE assignment_id = ?)
+		ORDER BY s.name, s.id
+	`
+	rows, err := DB.Query(query, assignment.CourseID, assignment.CourseID, time.Now().UTC(),
+		assignment.CourseID, assignment.TeacherID, assignment.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	students := []models.AssignmentStudentStatus{}
+	for rows.Next() {
+		var student models.AssignmentStudentStatus
+		err := rows.Scan(&student.StudentID, &student.StudentName)
+		if err != nil {
+			return nil, err
+		}
+		students = append(students, student)
+	}
+	return students, nil
+}
+
+// Submission queries
+
+const submissionColumns = `s.id, s.assignment_id, s.student_id, s.number, s.text, s.file_name, s.file_size,
+	s.file_path, s.submitted_at, s.late, s.rubric_scores, s.score, s.penalty, s.final_score, s.feedback,
+	s.graded_at, st.name`
+
+func scanSubmission(row interface{ Scan(...interface{}) error }, sub *models.AssignmentSubmission) error {
+	var rubricScores string
+	var score, finalScore sql.NullFloat64
+	var gradedAt sql.NullTime
+	err := row.Scan(&sub.ID, &sub.AssignmentID, &sub.StudentID, &sub.Number, &sub.Text, &sub.FileName,
+		&sub.FileSize, &sub.FilePath, &sub.SubmittedAt, &sub.Late, &rubricScores, &score, &sub.Penalty,
+		&finalScore, &sub.Feedback, &gradedAt, &sub.StudentName)
+	if err != nil {
+		return err
+	}
+	if score.Valid {
+		sub.Score = &score.Float64
+	}
+	if finalScore.Valid {
+		sub.FinalScore = &finalScore.Float64
+	}
+	if gradedAt.Valid {
+		sub.Graded = true
+		sub.GradedAt = &gradedAt.Time
+	}
+	return json.Unmarshal([]byte(rubricScores), &sub.RubricScores)
+}
+
+func scanSubmissions(rows *sql.Rows) ([]models.AssignmentSubmission, error) {
+	defer rows.Close()
+
+	submissions := []models.AssignmentSubmission{}
+	for rows.Next() {
+		var sub models.AssignmentSubmission
+		err := scanSubmission(rows, &sub)
+		if err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, sub)
+	}
+	return submissions, nil
+}
+
+// CreateSubmission numbers and stores a student's submission, refusing with
+// ErrNoSubmissionsLeft when they already have maxSubmissions of them (0 for
+// no limit)
+func CreateSubmission(sub *models.AssignmentSubmission, maxSubmissions int) (int, error) {
+	query := `
+		INSERT INTO assignment_submissions (assignment_id, student_id, number, text, file_name, file_size,
+		                                    file_path, submitted_at, late, penalty)
+		SELECT ?, ?, (SELECT COALESCE(MAX(number), 0) + 1 FROM assignment_submissions
+		              WHERE assignment_id = ? AND student_id = ?),
+		       ?, ?, ?, ?, ?, ?, ?
+		WHERE ? = 0 OR (SELECT COUNT(*) FROM assignment_submissions WHERE assignment_id = ? AND student_id = ?) < ?
+	`
+	result, err := DB.Exec(query, sub.AssignmentID, sub.StudentID, sub.AssignmentID, sub.StudentID,
+		sub.Text, sub.FileName, sub.FileSize, sub.FilePath, sub.SubmittedAt, sub.Late, sub.Penalty,
+		maxSubmissions, sub.AssignmentID, sub.StudentID, maxSubmissions)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err == nil && n == 0 {
+		return 0, ErrNoSubmissionsLeft
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetSubmission returns a submission to an assignment; studentID 0 matches
+// any student
+func GetSubmission(assignmentID, submissionID, studentID int) (*models.AssignmentSubmission, error) {
+	query := `
+		SELECT ` + submissionColumns + `
+		FROM assignment_submissions s
+		JOIN students st ON s.student_id = st.id
+		WHERE s.id = ? AND s.assignment_id = ? AND (? = 0 OR s.student_id = ?)
+	`
+	sub := &models.AssignmentSubmission{}
+	err := scanSubmission(DB.QueryRow(query, submissionID, assignmentID, studentID, studentID), sub)
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// GetAssignmentSubmissions lists the submissions to an assignment, newest
+// first; studentID 0 lists every student's
+func GetAssignmentSubmissions(assignmentID, studentID int) ([]models.AssignmentSubmission, error) {
+	query := `
+		SELECT ` + submissionColumns + `
+		FROM assignment_submissions s
+		JOIN students st ON s.student_id = st.id
+		WHERE s.assignment_id = ? AND (? = 0 OR s.student_id = ?)
+		ORDER BY s.submitted_at DESC, s.id DESC
+	`
+	rows, err := DB.Query(query, assignmentID, studentID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSubmissions(rows)
+}
+
+// GetStudentSubmissions lists all of a student's submissions, newest first
+func GetStudentSubmissions(studentID int) ([]models.AssignmentSubmission, error) {
+	query := `
+		SELECT ` + submissionColumns + `
+		FROM assignment_submissions s
+		JOIN students st ON s.student_id = st.id
+		WHERE s.student_id = ?
+		ORDER BY s.submitted_at DESC, s.id DESC
+	`
+	rows, err := DB.Query(query, studentID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSubmissions(rows)
+}
+
+// GradeSubmission stores a submission's scores and feedback; grading again
+// replaces them
+func GradeSubmission(sub *models.AssignmentSubmission) error {
+	rubricScores, err := json.Marshal(sub.RubricScores)
+	if err != nil {
+		return err
+	}
+	if sub.RubricScores == nil {
+		rubricScores = []byte("[]")
+	}
+	query := `
+		UPDATE assignment_submissions
+		SET rubric_scores = ?, score = ?, final_score = ?, feedback = ?, graded_at = ?
+		WHERE id = ?
+	`
+	_, err = DB.Exec(query, string(rubricScores), sub.Score, sub.FinalScore, sub.Feedback, sub.GradedAt, sub.ID)
+	return err
+}
+
+// HasGradedSubmissions reports whether any submission to an assignment has
+// been graded
+func HasGradedSubmissions(assignmentID int) (bool, error) {
+	var graded bool
+	query := `SELECT EXISTS (SELECT 1 FROM assignment_submissions WHERE assignment_id = ? AND graded_at IS NOT NULL)`
+	err := DB.QueryRow(query, assignmentID).Scan(&graded)
+	return graded, err
+}
+
+// GetSubmissionFiles returns the storage keys of the files submitted to an
+// assignment, or to every assignment of a course when courseID is set
+func GetSubmissionFiles(assignmentID, courseID int) ([]string, error) {
+	query := `
+		SELECT s.file_path
+		FROM assignment_submissions s
+		JOIN assignments a ON s.assignment_id = a.id
+		WHERE s.file_path != '' AND ((? != 0 AND a.id = ?) OR (? != 0 AND a.course_id = ?))
+	`
+	rows, err := DB.Query(query, assignmentID, assignmentID, courseID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		err := rows.Scan(&key)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
@@ -0,0 +1,174 @@
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"time"
+
+	"educational-platform/models"
+)
+
+// newCertificateCode returns a verification code such as ABCD-EFGH-JKLM
+// drawn from the invite code alphabet
+func newCertificateCode() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	code := make([]byte, 0, 14)
+	for i := range b {
+		if i > 0 && i%4 == 0 {
+			code = append(code, '-')
+		}
+		code = append(code, inviteCodeAlphabet[int(b[i])%len(inviteCodeAlphabet)])
+	}
+	return string(code)
+}
+
+const certificateColumns = `id, code, COALESCE(student_id, 0), COALESCE(course_id, 0), student_name, course_title,
+	teacher_name, issued_at, signature, revoked_at`
+
+func scanCertificate(row interface{ Scan(...interface{}) error }, certificate *models.Certificate) error {
+	var revokedAt sql.NullTime
+	err := row.Scan(&certificate.ID, &certificate.Code, &certificate.StudentID, &certificate.CourseID,
+		&certificate.StudentName, &certificate.CourseTitle, &certificate.TeacherName, &certificate.IssuedAt,
+		&certificate.Signature, &revokedAt)
+	if err != nil {
+		return err
+	}
+	if revokedAt.Valid {
+		certificate.RevokedAt = &revokedAt.Time
+	}
+	return nil
+}
+
+func scanCertificates(rows *sql.Rows) ([]models.Certificate, error) {
+	defer rows.Close()
+
+	certificates := []models.Certificate{}
+	for rows.Next() {
+		var certificate models.Certificate
+		err := scanCertificate(rows, &certificate)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates, nil
+}
+
+// NewCertificateCode returns a verification code that no certificate uses yet
+func NewCertificateCode() (string, error) {
+	for {
+		code := newCertificateCode()
+		var exists bool
+		err := DB.QueryRow(`SELECT EXISTS(SELECT 1 FROM certificates WHERE code = ?)`, code).Scan(&exists)
+		if err != nil || !exists {
+			return code, err
+		}
+	}
+}
+
+// CreateCertificate stores a signed certificate. A student has at most one
+// per course; a second one for the same course fails the unique constraint.
+func CreateCertificate(certificate *models.Certificate) (int, error) {
+	query := `
+		INSERT INTO certificates (code, student_id, course_id, student_name, course_title, teacher_name,
+		                          issued_at, signature)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := DB.Exec(query, certificate.Code, certificate.StudentID, certificate.CourseID,
+		certificate.StudentName, certificate.CourseTitle, certificate.TeacherName, certificate.IssuedAt,
+		certificate.Signature)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func GetCertificateByID(certificateID int) (*models.Certificate, error) {
+	query := `SELECT ` + certificateColumns + ` FROM certificates WHERE id = ?`
+	certificate := &models.Certificate{}
+	err := scanCertificate(DB.QueryRow(query, certificateID), certificate)
+	if err != nil {
+		return nil, err
+	}
+	return certificate, nil
+}
+
+func GetCertificateByCode(code string) (*models.Certificate, error) {
+	query := `SELECT ` + certificateColumns + ` FROM certificates WHERE code = ?`
+	certificate := &models.Certificate{}
+	err := scanCertificate(DB.QueryRow(query, code), certificate)
+	if err != nil {
+		return nil, err
+	}
+	return certificate, nil
+}
+
+// GetCourseCertificate returns the certificate a student holds for a course
+func GetCourseCertificate(courseID, studentID int) (*models.Certificate, error) {
+	query := `SELECT ` + certificateColumns + ` FROM certificates WHERE course_id = ? AND student_id = ?`
+	certificate := &models.Certificate{}
+	err := scanCertificate(DB.QueryRow(query, courseID, studentID), certificate)
+	if err != nil {
+		return nil, err
+	}
+	return certificate, nil
+}
+
+// GetStudentCertificates lists a student's certificates, newest first
+func GetStudentCertificates(studentID int) ([]models.Certificate, error) {
+	query := `SELECT ` + certificateColumns + ` FROM certificates WHERE student_id = ? ORDER BY issued_at DESC, id DESC`
+	rows, err := DB.Query(query, studentID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCertificates(rows)
+}
+
+// GetCourseCertificates lists the certificates issued for a course, newest first
+func GetCourseCertificates(courseID int) ([]models.Certificate, error) {
+	query := `SELECT ` + certificateColumns + ` FROM certificates WHERE course_id = ? ORDER BY issued_at DESC, id DESC`
+	rows, err := DB.Query(query, courseID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCertificates(rows)
+}
+
+// RevokeCertificate marks a certificate as no longer valid. It stays on
+// record so verifying its code reports the revocation.
+func RevokeCertificate(certificateID int) error {
+	query := `UPDATE certificates SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`
+	_, err := DB.Exec(query, time.Now().UTC(), certificateID)
+	return err
+}
+
+// GetCourseQuizIDs returns the published quizzes on the lessons of a course
+// that students can see
+func GetCourseQuizIDs(courseID int) ([]int, error) {
+	query := `
+		SELECT q.id
+		FROM quizzes q
+		JOIN lessons l ON q.lesson_id = l.id
+		JOIN videos v ON l.video_id = v.id
+		WHERE l.course_id = ? AND q.status = 'published' AND ` + liveVideoCondition + `
+		ORDER BY q.id
+	`
+	rows, err := DB.Query(query, courseID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []int{}
+	for rows.Next() {
+		var id int
+		err := rows.Scan(&id)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"educational-platform/models"
+)
+
+// commentColumns selects a comment (alias c) in the order scanComment reads
+// them. The author's name comes from whichever table they are in, and the
+// last two parameters are the user type and ID whose upvote is reported.
+const commentColumns = `c.id, c.video_id, COALESCE(c.parent_id, 0), c.author_type, c.author_id,
+		       COALESCE(CASE c.author_type
+		                    WHEN 'teacher' THEN (SELECT name FROM teachers WHERE id = c.author_id)
+		                    ELSE (SELECT name FROM students WHERE id = c.author_id)
+		                END, 'Deleted user'),
+		       c.timestamp, c.body, c.accepted, c.hidden, c.created_at, c.edited_at,
+		       (SELECT COUNT(*) FROM comment_votes cv WHERE cv.comment_id = c.id),
+		       EXISTS(SELECT 1 FROM comment_votes cv WHERE cv.comment_id = c.id AND cv.user_type = ? AND cv.user_id = ?)`
+
+func scanComment(row interface{ Scan(...interface{}) error }, comment *models.Comment, extra ...interface{}) error {
+	var timestamp sql.NullInt64
+	var editedAt sql.NullTime
+	dest := []interface{}{&comment.ID, &comment.VideoID, &comment.ParentID, &comment.AuthorType, &comment.AuthorID,
+		&comment.AuthorName, &timestamp, &comment.Body, &comment.Accepted, &comment.Hidden, &comment.CreatedAt,
+		&editedAt, &comment.Upvotes, &comment.Voted}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+	if timestamp.Valid {
+		t := int(timestamp.Int64)
+		comment.Timestamp = &t
+	}
+	if editedAt.Valid {
+		comment.EditedAt = &editedAt.Time
+	}
+	return nil
+}
+
+func CreateComment(comment *models.Comment) (int, error) {
+	var parentID, timestamp interface{}
+	if comment.ParentID != 0 {
+		parentID = comment.ParentID
+	}
+	if comment.Timestamp != nil {
+		timestamp = *comment.Timestamp
+	}
+	query := `
+		INSERT INTO video_comments (video_id, parent_id, author_type, author_id, timestamp, body, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := DB.Exec(query, comment.VideoID, parentID, comment.AuthorType, comment.AuthorID, timestamp,
+		comment.Body, comment.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetComment returns a comment with the viewer's upvote
+func GetComment(commentID int, userType string, userID int) (*models.Comment, error) {
+	query := `SELECT ` + commentColumns + ` FROM video_comments c WHERE c.id = ?`
+	comment := &models.Comment{}
+	err := scanComment(DB.QueryRow(query, userType, userID, commentID), comment)
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// GetVideoComments returns every comment on a video, oldest first, with the
+// viewer's upvotes. Hidden comments are left out unless includeHidden.
+func GetVideoComments(videoID int, userType string, userID int, includeHidden bool) ([]models.Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `
+		FROM video_comments c
+		WHERE c.video_id = ? AND (? OR c.hidden = 0)
+		ORDER BY c.created_at, c.id
+	`
+	rows, err := DB.Query(query, userType, userID, videoID, includeHidden)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	for rows.Next() {
+		var comment models.Comment
+		err := scanComment(rows, &comment)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, nil
+}
+
+// GetTeacherQuestions lists the comments that start threads on a teacher's
+// videos, newest first. With unanswered, only those without an accepted
+// reply or a reply from the teacher.
+func GetTeacherQuestions(teacherID int, unanswered bool, limit int) ([]models.Comment, error) {
+	query := `
+		SELECT ` + commentColumns + `, v.title,
+		       EXISTS(SELECT 1 FROM video_comments r
+		              WHERE r.parent_id = c.id AND r.hidden = 0
+		                AND (r.accepted = 1 OR (r.author_type = 'teacher' AND r.author_id = v.teacher_id)))
+		FROM video_comments c
+		JOIN videos v ON c.video_id = v.id
+		WHERE v.teacher_id = ? AND c.parent_id IS NULL
+		ORDER BY c.created_at DESC, c.id DESC
+	`
+	rows, err := DB.Query(query, "teacher", teacherID, teacherID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []models.Comment{}
+	for rows.Next() {
+		var comment models.Comment
+		err := scanComment(rows, &comment, &comment.VideoTitle, &comment.Answered)
+		if err != nil {
+			return nil, err
+		}
+		if unanswered && comment.Answered {
+			continue
+		}
+		comments = append(comments, comment)
+		if len(comments) == limit {
+			break
+		}
+	}
+	return comments, nil
+}
+
+func UpdateCommentBody(commentID int, body string) error {
+	query := `UPDATE video_comments SET body = ?, edited_at = ? WHERE id = ?`
+	_, err := DB.Exec(query, body, time.Now().UTC(), commentID)
+	return err
+}
+
+// DeleteComment removes a comment; deleting one that starts a thread
+// removes its replies too
+func DeleteComment(commentID int) error {
+	query := `DELETE FROM video_comments WHERE id = ?`
+	_, err := DB.Exec(query, commentID)
+	return err
+}
+
+func SetCommentHidden(commentID int, hidden bool) error {
+	query := `UPDATE video_comments SET hidden = ? WHERE id = ?`
+	_, err := DB.Exec(query, hidden, commentID)
+	return err
+}
+
+// AcceptReply marks a reply as the accepted answer to its thread, replacing
+// any reply accepted before; accepted false clears it
+func AcceptReply(reply *models.Comment, accepted bool) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if accepted {
+		_, err = tx.Exec(`UPDATE video_comments SET accepted = 0 WHERE parent_id = ? AND accepted = 1`, reply.ParentID)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(`UPDATE video_comments SET accepted = ? WHERE id = ?`, accepted, reply.ID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddCommentVote upvotes a comment; voting twice counts once
+func AddCommentVote(commentID int, userType string, userID int) error {
+	query := `INSERT OR IGNORE INTO comment_votes (comment_id, user_type, user_id) VALUES (?, ?, ?)`
+	_, err := DB.Exec(query, commentID, userType, userID)
+	return err
+}
+
+func RemoveCommentVote(commentID int, userType string, userID int) error {
+	query := `DELETE FROM comment_votes WHERE comment_id = ? AND user_type = ? AND user_id = ?`
+	_, err := DB.Exec(query, commentID, userType, userID)
+	return err
+}
@@ -0,0 +1,487 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"educational-platform/models"
+)
+
+// liveVideoCondition matches videos (alias v) that students can see in course
+// outlines: published, or scheduled and due, and not private. It takes the
+// current time as its one parameter.
+const liveVideoCondition = `(v.status = 'published' OR (v.status = 'scheduled' AND v.publish_at <= ?))
+		AND v.visibility != 'private'`
+
+// courseColumns selects a course joined with its teacher (aliases c and t)
+// in the order scanCourse reads them. The count of active enrollments takes
+// the current time as a parameter; the lesson count is added by each query.
+const courseColumns = `c.id, c.teacher_id, c.title, c.description, c.status, c.cover_path,
+		       c.created_at, c.updated_at, t.name, c.sequential,
+		       c.enrollment_mode, c.invite_code, c.enrollment_cap, c.enrollment_ends_at,
+		       (SELECT COUNT(*) FROM enrollments e WHERE e.course_id = c.id AND ` + activeEnrollmentCondition + `)`
+
+func scanCourse(row interface{ Scan(...interface{}) error }, course *models.Course) error {
+	var description, coverPath, inviteCode sql.NullString
+	var endsAt sql.NullTime
+	err := row.Scan(&course.ID, &course.TeacherID, &course.Title, &description, &course.Status, &coverPath,
+		&course.CreatedAt, &course.UpdatedAt, &course.TeacherName, &course.Sequential,
+		&course.EnrollmentMode, &inviteCode, &course.EnrollmentCap, &endsAt, &course.EnrolledCount,
+		&course.LessonCount)
+	if err != nil {
+		return err
+	}
+	course.Description = description.String
+	course.CoverPath = coverPath.String
+	if course.CoverPath != "" {
+		course.CoverURL = fmt.Sprintf("/api/course/%d/cover", course.ID)
+	}
+	course.InviteCode = inviteCode.String
+	if endsAt.Valid {
+		course.EnrollmentEndsAt = &endsAt.Time
+	}
+	return nil
+}
+
+func scanCourses(rows *sql.Rows) ([]models.Course, error) {
+	defer rows.Close()
+
+	courses := []models.Course{}
+	for rows.Next() {
+		var course models.Course
+		err := scanCourse(rows, &course)
+		if err != nil {
+			return nil, err
+		}
+		courses = append(courses, course)
+	}
+	return courses, nil
+}
+
+// Course queries
+func CreateCourse(teacherID int, title, description string) (int, error) {
+	query := `INSERT INTO courses (teacher_id, title, description) VALUES (?, ?, ?)`
+	result, err := DB.Exec(query, teacherID, title, description)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func UpdateCourse(courseID int, title, description, status string, sequential bool) error {
+	query := `UPDATE courses SET title = ?, description = ?, status = ?, sequential = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	_, err := DB.Exec(query, title, description, status, sequential, courseID)
+	return err
+}
+
+// UpdateCourseEnrollment changes how students join a course. An invite code
+// is created the first time the course switches to invite mode.
+func UpdateCourseEnrollment(courseID int, mode string, enrollmentCap int, endsAt *time.Time) error {
+	query := `
+		UPDATE courses
+		SET enrollment_mode = ?, enrollment_cap = ?, enrollment_ends_at = ?,
+		    invite_code = CASE WHEN ? = 'invite' AND invite_code IS NULL THEN ? ELSE invite_code END,
+		    updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+	_, err := DB.Exec(query, mode, enrollmentCap, endsAt, mode, newInviteCode(), courseID)
+	return err
+}
+
+// ResetInviteCode gives a course a new invite code and returns it
+func ResetInviteCode(courseID int) (string, error) {
+	code := newInviteCode()
+	query := `UPDATE courses SET invite_code = ? WHERE id = ?`
+	_, err := DB.Exec(query, code, courseID)
+	return code, err
+}
+
+func UpdateCourseCover(courseID int, coverPath string) error {
+	query := `UPDATE courses SET cover_path = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`
+	_, err := DB.Exec(query, coverPath, courseID)
+	return err
+}
+
+func DeleteCourse(courseID int) error {
+	query := `DELETE FROM courses WHERE id = ?`
+	_, err := DB.Exec(query, courseID)
+	return err
+}
+
+// GetCourseByID returns a course with the number of lessons in it
+func GetCourseByID(courseID int) (*models.Course, error) {
+	query := `
+		SELECT ` + courseColumns + `,
+		       (SELECT COUNT(*) FROM lessons l WHERE l.course_id = c.id)
+		FROM courses c
+		JOIN teachers t ON c.teacher_id = t.id
+		WHERE c.id = ?
+	`
+	course := &models.Course{}
+	err := scanCourse(DB.QueryRow(query, time.Now().UTC(), courseID), course)
+	if err != nil {
+		return nil, err
+	}
+	return course, nil
+}
+
+func GetCoursesByTeacherID(teacherID int) ([]models.Course, error) {
+	query := `
+		SELECT ` + courseColumns + `,
+		       (SELECT COUNT(*) FROM lessons l WHERE l.course_id = c.id)
+		FROM courses c
+		JOIN teachers t ON c.teacher_id = t.id
+		WHERE c.teacher_id = ?
+		ORDER BY c.created_at DESC, c.id DESC
+	`
+	rows, err := DB.Query(query, time.Now().UTC(), teacherID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCourses(rows)
+}
+
+// GetCoursesForStudent lists published courses of the teachers a student is
+// subscribed to and those the student has enrolled in or asked to join,
+// counting only lessons the student can see
+func GetCoursesForStudent(studentID int) ([]models.Course, error) {
+	query := `
+		SELECT ` + courseColumns + `,
+		       (SELECT COUNT(*) FROM lessons l JOIN videos v ON l.video_id = v.id
+		        WHERE l.course_id = c.id AND ` + liveVideoCondition + `)
+		FROM courses c
+		JOIN teachers t ON c.teacher_id = t.id
+		WHERE c.status = 'published'
+		  AND (c.teacher_id IN (SELECT teacher_id FROM subscriptions WHERE student_id = ?)
+		       OR c.id IN (SELECT course_id FROM enrollments WHERE student_id = ?))
+		ORDER BY c.updated_at DESC, c.id DESC
+	`
+	now := time.Now().UTC()
+	rows, err := DB.Query(query, now, now, studentID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	return scanCourses(rows)
+}
+
+// GetCourseSections returns a course's sections with their lessons in order.
+// With liveOnly, lessons whose videos students cannot see are left out.
+func GetCourseSections(courseID int, liveOnly bool) ([]models.CourseSection, error) {
+	rows, err := DB.Query(`SELECT id, course_id, title, position FROM course_sections WHERE course_id = ? ORDER BY position, id`, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sections := []models.CourseSection{}
+	index := make(map[int]int)
+	for rows.Next() {
+		section := models.CourseSection{Lessons: []models.Lesson{}}
+		err := rows.Scan(&section.ID, &section.CourseID, &section.Title, &section.Position)
+		if err != nil {
+			return nil, err
+		}
+		index[section.ID] = len(sections)
+		sections = append(sections, section)
+	}
+	rows.Close()
+
+	query := `
+		SELECT l.id, l.course_id, l.section_id, l.video_id,
+		       CASE WHEN l.title = '' THEN v.title ELSE l.title END,
+		       l.position, COALESCE(v.duration, 0), v.status
+		FROM lessons l
+		JOIN videos v ON l.video_id = v.id
+		WHERE l.course_id = ?
+	`
+	args := []interface{}{courseID}
+	if liveOnly {
+		query += ` AND ` + liveVideoCondition
+		args = append(args, time.Now().UTC())
+	}
+	query += ` ORDER BY l.position, l.id`
+
+	rows, err = DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var lesson models.Lesson
+		err := rows.Scan(&lesson.ID, &lesson.CourseID, &lesson.SectionID, &lesson.VideoID, &lesson.Title,
+			&lesson.Position, &lesson.Duration, &lesson.VideoStatus)
+		if err != nil {
+			return nil, err
+		}
+		if liveOnly {
+			lesson.VideoStatus = ""
+		}
+		if i, ok := index[lesson.SectionID]; ok {
+			sections[i].Lessons = append(sections[i].Lessons, lesson)
+		}
+	}
+	return sections, nil
+}
+
+// Section queries
+func CreateSection(courseID int, title string) (int, error) {
+	query := `
+		INSERT INTO course_sections (course_id, title, position)
+		VALUES (?, ?, (SELECT COALESCE(MAX(position), -1) + 1 FROM course_sections WHERE course_id = ?))
+	`
+	result, err := DB.Exec(query, courseID, title, courseID)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func GetSection(courseID, sectionID int) (*models.CourseSection, error) {
+	query := `SELECT id, course_id, title, position FROM course_sections WHERE id = ? AND course_id = ?`
+	section := &models.CourseSection{}
+	err := DB.QueryRow(query, sectionID, courseID).Scan(&section.ID, &section.CourseID, &section.Title, &section.Position)
+	if err != nil {
+		return nil, err
+	}
+	return section, nil
+}
+
+func UpdateSectionTitle(sectionID int, title string) error {
+	query := `UPDATE course_sections SET title = ? WHERE id = ?`
+	_, err := DB.Exec(query, title, sectionID)
+	return err
+}
+
+// DeleteSection removes a section together with its lessons
+func DeleteSection(sectionID int) error {
+	query := `DELETE FROM course_sections WHERE id = ?`
+	_, err := DB.Exec(query, sectionID)
+	return err
+}
+
+// Lesson queries
+
+// CreateLesson appends a lesson to the end of a section
+func CreateLesson(courseID, sectionID, videoID int, title string) (int, error) {
+	query := `
+		INSERT INTO lessons (course_id, section_id, video_id, title, position)
+		VALUES (?, ?, ?, ?, (SELECT COALESCE(MAX(position), -1) + 1 FROM lessons WHERE section_id = ?))
+	`
+	result, err := DB.Exec(query, courseID, sectionID, videoID, title, sectionID)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// IsVideoInCourse reports whether a course already has a lesson for a video
+func IsVideoInCourse(courseID, videoID int) (bool, error) {
+	query := `SELECT COUNT(*) FROM lessons WHERE course_id = ? AND video_id = ?`
+	var count int
+	err := DB.QueryRow(query, courseID, videoID).Scan(&count)
+	return count > 0, err
+}
+
+func GetLesson(courseID, lessonID int) (*models.Lesson, error) {
+	query := `
+		SELECT l.id, l.course_id, l.section_id, l.video_id,
+		       CASE WHEN l.title = '' THEN v.title ELSE l.title END,
+		       l.position, COALESCE(v.duration, 0), v.status
+		FROM lessons l
+		JOIN videos v ON l.video_id = v.id
+		WHERE l.id = ? AND l.course_id = ?
+	`
+	lesson := &models.Lesson{}
+	err := DB.QueryRow(query, lessonID, courseID).Scan(&lesson.ID, &lesson.CourseID, &lesson.SectionID, &lesson.VideoID,
+		&lesson.Title, &lesson.Position, &lesson.Duration, &lesson.VideoStatus)
+	if err != nil {
+		return nil, err
+	}
+	return lesson, nil
+}
+
+func UpdateLessonTitle(lessonID int, title string) error {
+	query := `UPDATE lessons SET title = ? WHERE id = ?`
+	_, err := DB.Exec(query, title, lessonID)
+	return err
+}
+
+// MoveLesson moves a lesson to the end of another section
+func MoveLesson(lessonID, sectionID int) error {
+	query := `
+		UPDATE lessons
+		SET section_id = ?, position = (SELECT COALESCE(MAX(position), -1) + 1 FROM lessons WHERE section_id = ?)
+		WHERE id = ?
+	`
+	_, err := DB.Exec(query, sectionID, sectionID, lessonID)
+	return err
+}
+
+func DeleteLesson(lessonID int) error {
+	query := `DELETE FROM lessons WHERE id = ?`
+	_, err := DB.Exec(query, lessonID)
+	return err
+}
+
+// ReorderCourse stores a new order of sections and of the lessons within
+// them, moving lessons between sections where needed
+func ReorderCourse(courseID int, sections []models.CourseSection) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i, section := range sections {
+		_, err := tx.Exec(`UPDATE course_sections SET position = ? WHERE id = ? AND course_id = ?`, i, section.ID, courseID)
+		if err != nil {
+			return err
+		}
+		for j, lesson := range section.Lessons {
+			_, err := tx.Exec(`UPDATE lessons SET section_id = ?, position = ? WHERE id = ? AND course_id = ?`,
+				section.ID, j, lesson.ID, courseID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE courses SET updated_at = CURRENT_TIMESTAMP WHERE id = ?`, courseID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Prerequisite queries
+
+// GetCoursePrerequisites returns the prerequisites of every lesson in a
+// course, keyed by lesson ID
+func GetCoursePrerequisites(courseID int) (map[int][]models.LessonPrerequisite, error) {
+	query := `
+		SELECT p.id, p.lesson_id, p.type, COALESCE(p.required_lesson_id, 0),
+		       COALESCE(CASE WHEN r.title = '' THEN v.title ELSE r.title END, ''), p.available_at,
+		       COALESCE(p.required_quiz_id, 0), COALESCE(q.title, ''), COALESCE(q.status, ''),
+		       COALESCE(q.lesson_id, (SELECT ql.id FROM lessons ql WHERE ql.course_id = l.course_id AND ql.video_id = q.video_id), 0)
+		FROM lesson_prerequisites p
+		JOIN lessons l ON p.lesson_id = l.id
+		LEFT JOIN lessons r ON p.required_lesson_id = r.id
+		LEFT JOIN videos v ON r.video_id = v.id
+		LEFT JOIN quizzes q ON p.required_quiz_id = q.id
+		WHERE l.course_id = ?
+		ORDER BY p.id
+	`
+	rows, err := DB.Query(query, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	prerequisites := make(map[int][]models.LessonPrerequisite)
+	for rows.Next() {
+		var p models.LessonPrerequisite
+		var availableAt sql.NullTime
+		err := rows.Scan(&p.ID, &p.LessonID, &p.Type, &p.RequiredLessonID, &p.RequiredLessonTitle, &availableAt,
+			&p.RequiredQuizID, &p.RequiredQuizTitle, &p.QuizStatus, &p.QuizLessonID)
+		if err != nil {
+			return nil, err
+		}
+		if availableAt.Valid {
+			p.AvailableAt = &availableAt.Time
+		}
+		prerequisites[p.LessonID] = append(prerequisites[p.LessonID], p)
+	}
+	return prerequisites, nil
+}
+
+// ReplacePrerequisites replaces all prerequisites of a lesson
+func ReplacePrerequisites(lessonID int, prerequisites []models.LessonPrerequisite) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM lesson_prerequisites WHERE lesson_id = ?`, lessonID)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range prerequisites {
+		var requiredLessonID, requiredQuizID interface{}
+		if p.RequiredLessonID != 0 {
+			requiredLessonID = p.RequiredLessonID
+		}
+		if p.RequiredQuizID != 0 {
+			requiredQuizID = p.RequiredQuizID
+		}
+		_, err := tx.Exec(`INSERT INTO lesson_prerequisites (lesson_id, type, required_lesson_id, required_quiz_id, available_at) VALUES (?, ?, ?, ?, ?)`,
+			lessonID, p.Type, requiredLessonID, requiredQuizID, p.AvailableAt)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetCompletedLessons returns the IDs of a course's lessons whose videos the
+// student has completed
+func GetCompletedLessons(studentID, courseID int) (map[int]bool, error) {
+	query := `
+		SELECT l.id
+		FROM lessons l
+		JOIN video_progress p ON p.video_id = l.video_id AND p.student_id = ?
+		WHERE l.course_id = ? AND p.completed = 1
+	`
+	rows, err := DB.Query(query, studentID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	completed := make(map[int]bool)
+	for rows.Next() {
+		var lessonID int
+		err := rows.Scan(&lessonID)
+		if err != nil {
+			return nil, err
+		}
+		completed[lessonID] = true
+	}
+	return completed, nil
+}
+
+// GetEnrolledCoursesForVideo returns the published courses that contain a
+// video and in which the student holds an active enrollment
+func GetEnrolledCoursesForVideo(studentID, videoID int) ([]int, error) {
+	query := `
+		SELECT c.id
+		FROM lessons l
+		JOIN courses c ON l.course_id = c.id
+		JOIN enrollments e ON e.course_id = c.id
+		WHERE l.video_id = ? AND c.status = 'published'
+		  AND e.student_id = ? AND ` + activeEnrollmentCondition + `
+		ORDER BY c.id
+	`
+	rows, err := DB.Query(query, videoID, studentID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var courseIDs []int
+	for rows.Next() {
+		var courseID int
+		err := rows.Scan(&courseID)
+		if err != nil {
+			return nil, err
+		}
+		courseIDs = append(courseIDs, courseID)
+	}
+	return courseIDs, nil
+}
@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
-	"os"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -13,7 +12,9 @@ var DB *sql.DB
 
 func InitDatabase() error {
 	var err error
-	DB, err = sql.Open("sqlite3", "./educational_platform.db")
+	// Foreign keys are off by default in SQLite; the ON DELETE CASCADE
+	// clauses below depend on them
+	DB, err = sql.Open("sqlite3", "./educational_platform.db?_foreign_keys=on")
 	if err != nil {
 		return err
 	}
@@ -90,7 +91,491 @@ func createTables() error {
 		UNIQUE(student_id, video_id)
 	);`
 
-	tables := []string{teachersTable, studentsTable, videosTable, subscriptionsTable, videoViewsTable}
+	// Resumable (tus) uploads that have not been finalized into videos yet
+	tusUploadsTable := `
+	CREATE TABLE IF NOT EXISTS tus_uploads (
+		id VARCHAR(64) PRIMARY KEY,
+		teacher_id INTEGER NOT NULL,
+		filename VARCHAR(255) NOT NULL,
+		title VARCHAR(200) NOT NULL,
+		description TEXT,
+		upload_length INTEGER NOT NULL,
+		upload_offset INTEGER NOT NULL DEFAULT 0,
+		partial_path VARCHAR(500) NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (teacher_id) REFERENCES teachers(id) ON DELETE CASCADE
+	);`
+
+	// Storyboard sprite sheets used for scrubbing previews
+	storyboardsTable := `
+	CREATE TABLE IF NOT EXISTS storyboards (
+		video_id INTEGER PRIMARY KEY,
+		interval_seconds INTEGER NOT NULL,
+		tile_width INTEGER NOT NULL,
+		tile_height INTEGER NOT NULL,
+		columns INTEGER NOT NULL,
+		rows INTEGER NOT NULL,
+		frame_count INTEGER NOT NULL,
+		sheet_count INTEGER NOT NULL,
+		key_prefix VARCHAR(500) NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+	);`
+
+	// Caption tracks, one per video and language
+	captionsTable := `
+	CREATE TABLE IF NOT EXISTS captions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		video_id INTEGER NOT NULL,
+		language VARCHAR(35) NOT NULL,
+		label VARCHAR(100) NOT NULL,
+		file_path VARCHAR(500) NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+		UNIQUE(video_id, language)
+	);`
+
+	// Chapters of a video, ordered by start time
+	chaptersTable := `
+	CREATE TABLE IF NOT EXISTS chapters (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		video_id INTEGER NOT NULL,
+		title VARCHAR(200) NOT NULL,
+		start_time INTEGER NOT NULL,
+		FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+		UNIQUE(video_id, start_time)
+	);`
+
+	// Media files replaced by a newer upload of the same video
+	videoRevisionsTable := `
+	CREATE TABLE IF NOT EXISTS video_revisions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		video_id INTEGER NOT NULL,
+		filename VARCHAR(255) NOT NULL,
+		file_path VARCHAR(500) NOT NULL,
+		duration INTEGER, -- in seconds
+		file_size INTEGER, -- in bytes
+		replaced_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+	);`
+
+	// Courses arrange a teacher's videos into sections of ordered lessons
+	coursesTable := `
+	CREATE TABLE IF NOT EXISTS courses (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		teacher_id INTEGER NOT NULL,
+		title VARCHAR(200) NOT NULL,
+		description TEXT,
+		status VARCHAR(20) NOT NULL DEFAULT 'draft',
+		cover_path VARCHAR(500),
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (teacher_id) REFERENCES teachers(id) ON DELETE CASCADE
+	);`
+
+	courseSectionsTable := `
+	CREATE TABLE IF NOT EXISTS course_sections (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		course_id INTEGER NOT NULL,
+		title VARCHAR(200) NOT NULL,
+		position INTEGER NOT NULL,
+		FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE CASCADE
+	);`
+
+	// A video appears at most once per course; an empty title uses the video's
+	lessonsTable := `
+	CREATE TABLE IF NOT EXISTS lessons (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		course_id INTEGER NOT NULL,
+		section_id INTEGER NOT NULL,
+		video_id INTEGER NOT NULL,
+		title VARCHAR(200) NOT NULL DEFAULT '',
+		position INTEGER NOT NULL,
+		FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE CASCADE,
+		FOREIGN KEY (section_id) REFERENCES course_sections(id) ON DELETE CASCADE,
+		FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+		UNIQUE(course_id, video_id)
+	);`
+
+	// Pending enrollments wait for the teacher's approval; expires_at ends access
+	enrollmentsTable := `
+	CREATE TABLE IF NOT EXISTS enrollments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		course_id INTEGER NOT NULL,
+		student_id INTEGER NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		requested_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		enrolled_at DATETIME,
+		expires_at DATETIME,
+		FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE CASCADE,
+		FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE,
+		UNIQUE(course_id, student_id)
+	);`
+
+	// Rules that must be met before a lesson unlocks for a student
+	lessonPrerequisitesTable := `
+	CREATE TABLE IF NOT EXISTS lesson_prerequisites (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		lesson_id INTEGER NOT NULL,
+		type VARCHAR(20) NOT NULL,
+		required_lesson_id INTEGER,
+		available_at DATETIME,
+		FOREIGN KEY (lesson_id) REFERENCES lessons(id) ON DELETE CASCADE,
+		FOREIGN KEY (required_lesson_id) REFERENCES lessons(id) ON DELETE CASCADE
+	);`
+
+	// Playback progress per student and video; intervals is a JSON array of
+	// merged [start, end] pairs in seconds
+	videoProgressTable := `
+	CREATE TABLE IF NOT EXISTS video_progress (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		student_id INTEGER NOT NULL,
+		video_id INTEGER NOT NULL,
+		position REAL NOT NULL DEFAULT 0,
+		intervals TEXT NOT NULL DEFAULT '[]',
+		watched_seconds REAL NOT NULL DEFAULT 0,
+		percent REAL NOT NULL DEFAULT 0,
+		completed BOOLEAN NOT NULL DEFAULT 0,
+		completed_at DATETIME,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE,
+		FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+		UNIQUE(student_id, video_id)
+	);`
+
+	// Every viewing of a video; video_views keeps the per-student summary
+	watchSessionsTable := `
+	CREATE TABLE IF NOT EXISTS watch_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		student_id INTEGER NOT NULL,
+		video_id INTEGER NOT NULL,
+		started_at DATETIME NOT NULL,
+		ended_at DATETIME NOT NULL,
+		device VARCHAR(200) NOT NULL DEFAULT '',
+		seconds_watched REAL NOT NULL DEFAULT 0,
+		FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE,
+		FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+	);`
+
+	// Quizzes on a video, or on one lesson of a course when lesson_id is set;
+	// timestamp is where the quiz pops up, 0 for none
+	quizzesTable := `
+	CREATE TABLE IF NOT EXISTS quizzes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		teacher_id INTEGER NOT NULL,
+		video_id INTEGER NOT NULL,
+		lesson_id INTEGER,
+		title VARCHAR(200) NOT NULL,
+		description TEXT,
+		status VARCHAR(20) NOT NULL DEFAULT 'draft',
+		timestamp INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL DEFAULT 0,
+		time_limit INTEGER NOT NULL DEFAULT 0,
+		shuffle_questions BOOLEAN NOT NULL DEFAULT 0,
+		pass_percent INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (teacher_id) REFERENCES teachers(id) ON DELETE CASCADE,
+		FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+		FOREIGN KEY (lesson_id) REFERENCES lessons(id) ON DELETE CASCADE
+	);`
+
+	// options and answer are JSON; answer is in the shape students answer with
+	quizQuestionsTable := `
+	CREATE TABLE IF NOT EXISTS quiz_questions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		quiz_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		type VARCHAR(20) NOT NULL,
+		prompt TEXT NOT NULL,
+		options TEXT NOT NULL DEFAULT '[]',
+		answer TEXT NOT NULL,
+		tolerance REAL NOT NULL DEFAULT 0,
+		points INTEGER NOT NULL DEFAULT 1,
+		FOREIGN KEY (quiz_id) REFERENCES quizzes(id) ON DELETE CASCADE
+	);`
+
+	// question_order and results are JSON; results keep the graded answers
+	// even if the quiz's questions are replaced later
+	quizAttemptsTable := `
+	CREATE TABLE IF NOT EXISTS quiz_attempts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		quiz_id INTEGER NOT NULL,
+		student_id INTEGER NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		question_order TEXT NOT NULL,
+		results TEXT NOT NULL DEFAULT '[]',
+		score INTEGER NOT NULL DEFAULT 0,
+		max_score INTEGER NOT NULL DEFAULT 0,
+		percent REAL NOT NULL DEFAULT 0,
+		passed BOOLEAN NOT NULL DEFAULT 0,
+		started_at DATETIME NOT NULL,
+		expires_at DATETIME,
+		submitted_at DATETIME,
+		FOREIGN KEY (quiz_id) REFERENCES quizzes(id) ON DELETE CASCADE,
+		FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+	);`
+
+	// Homework for a teacher's subscribers, or for a course's students when
+	// course_id is set; rubric is a JSON array of criteria
+	assignmentsTable := `
+	CREATE TABLE IF NOT EXISTS assignments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		teacher_id INTEGER NOT NULL,
+		course_id INTEGER,
+		title VARCHAR(200) NOT NULL,
+		description TEXT,
+		status VARCHAR(20) NOT NULL DEFAULT 'draft',
+		due_at DATETIME,
+		late_policy VARCHAR(20) NOT NULL DEFAULT 'allow',
+		late_penalty REAL NOT NULL DEFAULT 0,
+		late_until DATETIME,
+		submission_type VARCHAR(20) NOT NULL DEFAULT 'any',
+		max_submissions INTEGER NOT NULL DEFAULT 0,
+		resubmit_after_grading BOOLEAN NOT NULL DEFAULT 0,
+		rubric TEXT NOT NULL DEFAULT '[]',
+		max_points REAL NOT NULL DEFAULT 100,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (teacher_id) REFERENCES teachers(id) ON DELETE CASCADE,
+		FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE CASCADE
+	);`
+
+	// Every hand-in is kept; file_path is a storage key and rubric_scores a
+	// JSON array matching the assignment's rubric
+	assignmentSubmissionsTable := `
+	CREATE TABLE IF NOT EXISTS assignment_submissions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		assignment_id INTEGER NOT NULL,
+		student_id INTEGER NOT NULL,
+		number INTEGER NOT NULL,
+		text TEXT NOT NULL DEFAULT '',
+		file_name VARCHAR(255) NOT NULL DEFAULT '',
+		file_size INTEGER NOT NULL DEFAULT 0,
+		file_path VARCHAR(500) NOT NULL DEFAULT '',
+		submitted_at DATETIME NOT NULL,
+		late BOOLEAN NOT NULL DEFAULT 0,
+		rubric_scores TEXT NOT NULL DEFAULT '[]',
+		score REAL,
+		penalty REAL NOT NULL DEFAULT 0,
+		final_score REAL,
+		feedback TEXT NOT NULL DEFAULT '',
+		graded_at DATETIME,
+		FOREIGN KEY (assignment_id) REFERENCES assignments(id) ON DELETE CASCADE,
+		FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE,
+		UNIQUE(assignment_id, student_id, number)
+	);`
+
+	// Gradebook categories of a teacher's own gradebook, or of a course's
+	// when course_id is set; quizzes and assignments point at them
+	gradeCategoriesTable := `
+	CREATE TABLE IF NOT EXISTS grade_categories (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		teacher_id INTEGER NOT NULL,
+		course_id INTEGER,
+		name VARCHAR(100) NOT NULL,
+		weight REAL NOT NULL DEFAULT 0,
+		drop_lowest INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (teacher_id) REFERENCES teachers(id) ON DELETE CASCADE,
+		FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE CASCADE
+	);`
+
+	// Certificates keep their details when the student or course goes, so
+	// issued ones can still be verified
+	certificatesTable := `
+	CREATE TABLE IF NOT EXISTS certificates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		code VARCHAR(20) UNIQUE NOT NULL,
+		student_id INTEGER,
+		course_id INTEGER,
+		student_name VARCHAR(100) NOT NULL,
+		course_title VARCHAR(200) NOT NULL,
+		teacher_name VARCHAR(100) NOT NULL,
+		issued_at DATETIME NOT NULL,
+		signature VARCHAR(200) NOT NULL,
+		revoked_at DATETIME,
+		UNIQUE(course_id, student_id),
+		FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE SET NULL,
+		FOREIGN KEY (course_id) REFERENCES courses(id) ON DELETE SET NULL
+	);`
+
+	// Students' own notes and bookmarks, anchored to a point in a video
+	videoNotesTable := `
+	CREATE TABLE IF NOT EXISTS video_notes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		student_id INTEGER NOT NULL,
+		video_id INTEGER NOT NULL,
+		type VARCHAR(20) NOT NULL,
+		timestamp INTEGER NOT NULL,
+		text TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE,
+		FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE
+	);`
+
+	// Comments and questions on videos. Authors are teachers or students;
+	// replies point at the comment that starts their thread.
+	videoCommentsTable := `
+	CREATE TABLE IF NOT EXISTS video_comments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		video_id INTEGER NOT NULL,
+		parent_id INTEGER,
+		author_type VARCHAR(20) NOT NULL,
+		author_id INTEGER NOT NULL,
+		timestamp INTEGER,
+		body TEXT NOT NULL,
+		accepted BOOLEAN NOT NULL DEFAULT 0,
+		hidden BOOLEAN NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		edited_at DATETIME,
+		FOREIGN KEY (video_id) REFERENCES videos(id) ON DELETE CASCADE,
+		FOREIGN KEY (parent_id) REFERENCES video_comments(id) ON DELETE CASCADE
+	);`
+
+	commentVotesTable := `
+	CREATE TABLE IF NOT EXISTS comment_votes (
+		comment_id INTEGER NOT NULL,
+		user_type VARCHAR(20) NOT NULL,
+		user_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (comment_id, user_type, user_id),
+		FOREIGN KEY (comment_id) REFERENCES video_comments(id) ON DELETE CASCADE
+	);`
+
+	// Announcements go out at send_at, or when created without one; sent_at
+	// is set once they have been delivered
+	announcementsTable := `
+	CREATE TABLE IF NOT EXISTS announcements (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		teacher_id INTEGER NOT NULL,
+		title VARCHAR(200) NOT NULL,
+		body TEXT NOT NULL,
+		pinned BOOLEAN NOT NULL DEFAULT 0,
+		email BOOLEAN NOT NULL DEFAULT 0,
+		send_at DATETIME,
+		sent_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (teacher_id) REFERENCES teachers(id) ON DELETE CASCADE
+	);`
+
+	// The subscribers an announcement was delivered to
+	announcementRecipientsTable := `
+	CREATE TABLE IF NOT EXISTS announcement_recipients (
+		announcement_id INTEGER NOT NULL,
+		student_id INTEGER NOT NULL,
+		read_at DATETIME,
+		PRIMARY KEY (announcement_id, student_id),
+		FOREIGN KEY (announcement_id) REFERENCES announcements(id) ON DELETE CASCADE,
+		FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE
+	);`
+
+	// In-app notifications for teachers and students. source_id is the video,
+	// comment, submission or announcement the notification is about; one
+	// notification is kept per user, type and source.
+	notificationsTable := `
+	CREATE TABLE IF NOT EXISTS notifications (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_type VARCHAR(10) NOT NULL,
+		user_id INTEGER NOT NULL,
+		type VARCHAR(30) NOT NULL,
+		source_id INTEGER NOT NULL,
+		video_id INTEGER,
+		title VARCHAR(200) NOT NULL,
+		body TEXT NOT NULL DEFAULT '',
+		read_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(user_type, user_id, type, source_id)
+	);`
+
+	// Notification types a user has turned off; every type is on by default
+	notificationPreferencesTable := `
+	CREATE TABLE IF NOT EXISTS notification_preferences (
+		user_type VARCHAR(10) NOT NULL,
+		user_id INTEGER NOT NULL,
+		type VARCHAR(30) NOT NULL,
+		enabled BOOLEAN NOT NULL,
+		PRIMARY KEY (user_type, user_id, type)
+	);`
+
+	// Teachers' webhook endpoints; events is a JSON array of event types
+	webhooksTable := `
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		teacher_id INTEGER NOT NULL,
+		url TEXT NOT NULL,
+		description VARCHAR(200) NOT NULL DEFAULT '',
+		events TEXT NOT NULL,
+		secret VARCHAR(100) NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (teacher_id) REFERENCES teachers(id) ON DELETE CASCADE
+	);`
+
+	// Every attempt to deliver an event to a webhook. event_id is shared by
+	// redeliveries of the same event.
+	webhookDeliveriesTable := `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL,
+		event_id VARCHAR(40) NOT NULL,
+		event VARCHAR(50) NOT NULL,
+		payload TEXT NOT NULL,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at DATETIME,
+		last_attempt_at DATETIME,
+		response_status INTEGER,
+		response_body TEXT NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		duration_ms INTEGER,
+		redelivery_of INTEGER,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+	);`
+
+	// Conversations between a student and a teacher; either side can block
+	// the other
+	conversationsTable := `
+	CREATE TABLE IF NOT EXISTS conversations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		teacher_id INTEGER NOT NULL,
+		student_id INTEGER NOT NULL,
+		teacher_blocked_at DATETIME,
+		student_blocked_at DATETIME,
+		last_message_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (teacher_id) REFERENCES teachers(id) ON DELETE CASCADE,
+		FOREIGN KEY (student_id) REFERENCES students(id) ON DELETE CASCADE,
+		UNIQUE(teacher_id, student_id)
+	);`
+
+	// Messages in a conversation with an optional attachment in storage;
+	// read_at is set once the other side reads it
+	messagesTable := `
+	CREATE TABLE IF NOT EXISTS messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		conversation_id INTEGER NOT NULL,
+		sender_type VARCHAR(10) NOT NULL,
+		body TEXT NOT NULL DEFAULT '',
+		attachment_path VARCHAR(500),
+		attachment_name VARCHAR(255),
+		attachment_size INTEGER,
+		read_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (conversation_id) REFERENCES conversations(id) ON DELETE CASCADE
+	);`
+
+	tables := []string{teachersTable, studentsTable, videosTable, subscriptionsTable, videoViewsTable, tusUploadsTable,
+		storyboardsTable, captionsTable, chaptersTable, videoRevisionsTable, coursesTable, courseSectionsTable,
+		lessonsTable, enrollmentsTable, lessonPrerequisitesTable, videoProgressTable,
+		watchSessionsTable, quizzesTable, quizQuestionsTable, quizAttemptsTable, assignmentsTable,
+		assignmentSubmissionsTable, gradeCategoriesTable, certificatesTable, videoNotesTable, videoCommentsTable,
+		commentVotesTable, announcementsTable, announcementRecipientsTable, notificationsTable,
+		notificationPreferencesTable, webhooksTable, webhookDeliveriesTable, conversationsTable, messagesTable}
 
 	for _, table := range tables {
 		_, err := DB.Exec(table)
@@ -99,21 +584,111 @@ func createTables() error {
 		}
 	}
 
-	// Create uploads directory if it doesn't exist
-	err := os.MkdirAll("./uploads/videos", 0755)
+	// Columns added after the initial schema; CREATE TABLE IF NOT EXISTS
+	// does not touch tables that already exist
+	columns := []struct{ table, column, definition string }{
+		{"teachers", "storage_quota", "INTEGER"}, // bytes, NULL uses the server default
+		// Messages each student may send the teacher per hour, NULL uses the
+		// server default
+		{"teachers", "message_rate_limit", "INTEGER"},
+		// Publishing; videos from before these columns were visible to subscribers
+		{"videos", "status", "VARCHAR(20) NOT NULL DEFAULT 'published'"},
+		{"videos", "visibility", "VARCHAR(20) NOT NULL DEFAULT 'subscribers'"},
+		{"videos", "publish_at", "DATETIME"},
+		{"videos", "published_at", "DATETIME"},
+		{"videos", "share_token", "VARCHAR(64)"},
+		{"tus_uploads", "status", "VARCHAR(20) NOT NULL DEFAULT 'published'"},
+		{"tus_uploads", "visibility", "VARCHAR(20) NOT NULL DEFAULT 'subscribers'"},
+		{"tus_uploads", "publish_at", "DATETIME"},
+		{"tus_uploads", "expires_at", "DATETIME"},
+		// Enrollment settings; enrollment_cap 0 means no limit
+		{"courses", "enrollment_mode", "VARCHAR(20) NOT NULL DEFAULT 'open'"},
+		{"courses", "invite_code", "VARCHAR(20)"},
+		{"courses", "enrollment_cap", "INTEGER NOT NULL DEFAULT 0"},
+		{"courses", "enrollment_ends_at", "DATETIME"},
+		{"courses", "sequential", "BOOLEAN NOT NULL DEFAULT 0"},
+		{"lesson_prerequisites", "required_quiz_id", "INTEGER REFERENCES quizzes(id) ON DELETE CASCADE"},
+		// Gradebook categories; items of a deleted category become uncategorized
+		{"quizzes", "category_id", "INTEGER REFERENCES grade_categories(id) ON DELETE SET NULL"},
+		{"assignments", "category_id", "INTEGER REFERENCES grade_categories(id) ON DELETE SET NULL"},
+		// Per-student viewing summary; rows from before count as one viewing
+		{"video_views", "last_watched_at", "DATETIME"},
+		{"video_views", "view_count", "INTEGER NOT NULL DEFAULT 1"},
+		{"video_views", "watch_time", "REAL NOT NULL DEFAULT 0"},
+	}
+
+	for _, col := range columns {
+		err := addColumnIfMissing(col.table, col.column, col.definition)
+		if err != nil {
+			return fmt.Errorf("error migrating table %s: %v", col.table, err)
+		}
+	}
+
+	// Older rows stored local paths such as ./uploads/videos/x.mp4; convert
+	// them to storage keys (videos/x.mp4) relative to the local storage root
+	keyMigrations := []string{
+		`UPDATE videos SET file_path = substr(file_path, 11) WHERE file_path LIKE './uploads/%'`,
+		`UPDATE videos SET file_path = substr(file_path, 9) WHERE file_path LIKE 'uploads/%'`,
+		`UPDATE videos SET thumbnail_path = substr(thumbnail_path, 11) WHERE thumbnail_path LIKE './uploads/%'`,
+		`UPDATE videos SET thumbnail_path = substr(thumbnail_path, 9) WHERE thumbnail_path LIKE 'uploads/%'`,
+	}
+
+	for _, migration := range keyMigrations {
+		_, err := DB.Exec(migration)
+		if err != nil {
+			return fmt.Errorf("error migrating file paths: %v", err)
+		}
+	}
+
+	// Videos from before publishing settings went live when they were uploaded
+	_, err := DB.Exec(`UPDATE videos SET published_at = created_at WHERE status = 'published' AND published_at IS NULL`)
 	if err != nil {
-		return err
+		return fmt.Errorf("error migrating video publish dates: %v", err)
 	}
 
-	err = os.MkdirAll("./uploads/thumbnails", 0755)
+	// Viewing summaries from before last_watched_at were last watched when
+	// they were first watched
+	_, err = DB.Exec(`UPDATE video_views SET last_watched_at = watched_at WHERE last_watched_at IS NULL`)
 	if err != nil {
-		return err
+		return fmt.Errorf("error migrating video view times: %v", err)
+	}
+
+	// Uploads from before expiry expire a day after they were created
+	_, err = DB.Exec(`UPDATE tus_uploads SET expires_at = datetime(created_at, '+1 day') WHERE expires_at IS NULL`)
+	if err != nil {
+		return fmt.Errorf("error migrating upload expiry: %v", err)
 	}
 
 	log.Println("Database tables created successfully")
 	return nil
 }
 
+// addColumnIfMissing adds a column to an existing table unless it is already there
+func addColumnIfMissing(table, column, definition string) error {
+	rows, err := DB.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk)
+		if err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
 func CloseDatabase() {
 	if DB != nil {
 		DB.Close()
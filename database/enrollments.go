@@ -0,0 +1,245 @@
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"time"
+
+	"educational-platform/models"
+)
+
+// ErrCourseFull is returned when an enrollment would exceed the course's cap
+var ErrCourseFull = errors.New("course is full")
+
+// activeEnrollmentCondition matches enrollments (alias e) that grant access:
+// approved and not expired. It takes the current time as its one parameter.
+const activeEnrollmentCondition = `e.status = 'active' AND (e.expires_at IS NULL OR e.expires_at > ?)`
+
+// inviteCodeAlphabet leaves out characters that are easy to confuse when
+// codes are read aloud or typed
+const inviteCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func newInviteCode() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	for i := range b {
+		b[i] = inviteCodeAlphabet[int(b[i])%len(inviteCodeAlphabet)]
+	}
+	return string(b)
+}
+
+const enrollmentColumns = `e.id, e.course_id, e.student_id, e.status, e.requested_at, e.enrolled_at, e.expires_at`
+
+func scanEnrollment(row interface{ Scan(...interface{}) error }, enrollment *models.Enrollment, extra ...interface{}) error {
+	var enrolledAt, expiresAt sql.NullTime
+	dest := []interface{}{&enrollment.ID, &enrollment.CourseID, &enrollment.StudentID, &enrollment.Status,
+		&enrollment.RequestedAt, &enrolledAt, &expiresAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+	if enrolledAt.Valid {
+		enrollment.EnrolledAt = &enrolledAt.Time
+	}
+	if expiresAt.Valid {
+		enrollment.ExpiresAt = &expiresAt.Time
+	}
+	return nil
+}
+
+// Enrollment queries
+
+// CreateEnrollment adds a pending or active enrollment. Active enrollments
+// are refused with ErrCourseFull once the course has enrollmentCap of them.
+func CreateEnrollment(courseID, studentID int, status string, enrollmentCap int) error {
+	now := time.Now().UTC()
+	var enrolledAt interface{}
+	if status == models.EnrollmentStatusActive {
+		enrolledAt = now
+	}
+	query := `
+		INSERT INTO enrollments (course_id, student_id, status, enrolled_at)
+		SELECT ?, ?, ?, ?
+		WHERE ? != 'active' OR ? = 0
+		   OR (SELECT COUNT(*) FROM enrollments e WHERE e.course_id = ? AND ` + activeEnrollmentCondition + `) < ?
+	`
+	result, err := DB.Exec(query, courseID, studentID, status, enrolledAt,
+		status, enrollmentCap, courseID, now, enrollmentCap)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err == nil && n == 0 {
+		err = ErrCourseFull
+	}
+	return err
+}
+
+// ActivateEnrollment approves a pending enrollment, refusing with
+// ErrCourseFull when the course has reached its cap
+func ActivateEnrollment(enrollmentID, courseID, enrollmentCap int) error {
+	now := time.Now().UTC()
+	query := `
+		UPDATE enrollments SET status = 'active', enrolled_at = ?
+		WHERE id = ?
+		  AND (? = 0 OR (SELECT COUNT(*) FROM enrollments e WHERE e.course_id = ? AND ` + activeEnrollmentCondition + `) < ?)
+	`
+	result, err := DB.Exec(query, now, enrollmentID, enrollmentCap, courseID, now, enrollmentCap)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err == nil && n == 0 {
+		err = ErrCourseFull
+	}
+	return err
+}
+
+func GetEnrollment(courseID, studentID int) (*models.Enrollment, error) {
+	query := `SELECT ` + enrollmentColumns + ` FROM enrollments e WHERE e.course_id = ? AND e.student_id = ?`
+	enrollment := &models.Enrollment{}
+	err := scanEnrollment(DB.QueryRow(query, courseID, studentID), enrollment)
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+func GetEnrollmentByID(courseID, enrollmentID int) (*models.Enrollment, error) {
+	query := `
+		SELECT ` + enrollmentColumns + `, s.name
+		FROM enrollments e
+		JOIN students s ON e.student_id = s.id
+		WHERE e.id = ? AND e.course_id = ?
+	`
+	enrollment := &models.Enrollment{}
+	err := scanEnrollment(DB.QueryRow(query, enrollmentID, courseID), enrollment, &enrollment.StudentName)
+	if err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+// GetCourseEnrollments lists a course's enrollments, optionally only those
+// with the given status
+func GetCourseEnrollments(courseID int, status string) ([]models.Enrollment, error) {
+	query := `
+		SELECT ` + enrollmentColumns + `, s.name
+		FROM enrollments e
+		JOIN students s ON e.student_id = s.id
+		WHERE e.course_id = ? AND (? = '' OR e.status = ?)
+		ORDER BY e.requested_at, e.id
+	`
+	rows, err := DB.Query(query, courseID, status, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enrollments := []models.Enrollment{}
+	for rows.Next() {
+		var enrollment models.Enrollment
+		err := scanEnrollment(rows, &enrollment, &enrollment.StudentName)
+		if err != nil {
+			return nil, err
+		}
+		enrollments = append(enrollments, enrollment)
+	}
+	return enrollments, nil
+}
+
+// GetStudentEnrollments lists a student's enrollments in published courses
+func GetStudentEnrollments(studentID int) ([]models.Enrollment, error) {
+	query := `
+		SELECT ` + enrollmentColumns + `, c.title
+		FROM enrollments e
+		JOIN courses c ON e.course_id = c.id
+		WHERE e.student_id = ? AND c.status = 'published'
+		ORDER BY e.requested_at DESC, e.id DESC
+	`
+	rows, err := DB.Query(query, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enrollments := []models.Enrollment{}
+	for rows.Next() {
+		var enrollment models.Enrollment
+		err := scanEnrollment(rows, &enrollment, &enrollment.CourseTitle)
+		if err != nil {
+			return nil, err
+		}
+		enrollments = append(enrollments, enrollment)
+	}
+	return enrollments, nil
+}
+
+func UpdateEnrollmentExpiry(enrollmentID int, expiresAt *time.Time) error {
+	query := `UPDATE enrollments SET expires_at = ? WHERE id = ?`
+	_, err := DB.Exec(query, expiresAt, enrollmentID)
+	return err
+}
+
+func DeleteEnrollment(enrollmentID int) error {
+	query := `DELETE FROM enrollments WHERE id = ?`
+	_, err := DB.Exec(query, enrollmentID)
+	return err
+}
+
+// GetVideoCourseStudents reports, like GetVideoCourseAccess, whether a video
+// is a lesson of a published course, and returns the students with an
+// active enrollment in one of those courses
+func GetVideoCourseStudents(videoID int) (bool, []int, error) {
+	var courses int
+	query := `
+		SELECT COUNT(*) FROM lessons l
+		JOIN courses c ON l.course_id = c.id
+		WHERE l.video_id = ? AND c.status = 'published'
+	`
+	if err := DB.QueryRow(query, videoID).Scan(&courses); err != nil || courses == 0 {
+		return false, nil, err
+	}
+
+	query = `
+		SELECT DISTINCT e.student_id FROM lessons l
+		JOIN courses c ON l.course_id = c.id
+		JOIN enrollments e ON e.course_id = c.id
+		WHERE l.video_id = ? AND c.status = 'published' AND ` + activeEnrollmentCondition + `
+	`
+	rows, err := DB.Query(query, videoID, time.Now().UTC())
+	if err != nil {
+		return true, nil, err
+	}
+	defer rows.Close()
+
+	studentIDs := []int{}
+	for rows.Next() {
+		var studentID int
+		if err := rows.Scan(&studentID); err != nil {
+			return true, nil, err
+		}
+		studentIDs = append(studentIDs, studentID)
+	}
+	return true, studentIDs, rows.Err()
+}
+
+// GetVideoCourseAccess reports whether a video is a lesson of any published
+// course and, if so, whether the student holds an active enrollment in one
+// of them
+func GetVideoCourseAccess(studentID, videoID int) (bool, bool, error) {
+	query := `
+		SELECT COUNT(*),
+		       COUNT(CASE WHEN EXISTS (
+		           SELECT 1 FROM enrollments e
+		           WHERE e.course_id = c.id AND e.student_id = ? AND ` + activeEnrollmentCondition + `
+		       ) THEN 1 END)
+		FROM lessons l
+		JOIN courses c ON l.course_id = c.id
+		WHERE l.video_id = ? AND c.status = 'published'
+	`
+	var courses, enrolledCourses int
+	err := DB.QueryRow(query, studentID, time.Now().UTC(), videoID).Scan(&courses, &enrolledCourses)
+	return courses > 0, enrolledCourses > 0, err
+}
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"database/sql"
+
+	"educational-platform/models"
+)
+
+// Grade category queries
+
+const gradeCategoryColumns = `id, teacher_id, COALESCE(course_id, 0), name, weight, drop_lowest`
+
+func scanGradeCategory(row interface{ Scan(...interface{}) error }, category *models.GradeCategory) error {
+	return row.Scan(&category.ID, &category.TeacherID, &category.CourseID, &category.Name, &category.Weight,
+		&category.DropLowest)
+}
+
+func CreateGradeCategory(category *models.GradeCategory) (int, error) {
+	var courseID interface{}
+	if category.CourseID != 0 {
+		courseID = category.CourseID
+	}
+	query := `INSERT INTO grade_categories (teacher_id, course_id, name, weight, drop_lowest) VALUES (?, ?, ?, ?, ?)`
+	result, err := DB.Exec(query, category.TeacherID, courseID, category.Name, category.Weight, category.DropLowest)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func GetGradeCategory(categoryID int) (*models.GradeCategory, error) {
+	query := `SELECT ` + gradeCategoryColumns + ` FROM grade_categories WHERE id = ?`
+	category := &models.GradeCategory{}
+	err := scanGradeCategory(DB.QueryRow(query, categoryID), category)
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// GetGradeCategories lists the categories of a teacher's own gradebook, or
+// of a course's when courseID is set, in the order they were created
+func GetGradeCategories(teacherID, courseID int) ([]models.GradeCategory, error) {
+	query := `
+		SELECT ` + gradeCategoryColumns + `
+		FROM grade_categories
+		WHERE teacher_id = ? AND COALESCE(course_id, 0) = ?
+		ORDER BY id
+	`
+	rows, err := DB.Query(query, teacherID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.GradeCategory{}
+	for rows.Next() {
+		var category models.GradeCategory
+		err := scanGradeCategory(rows, &category)
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, nil
+}
+
+func UpdateGradeCategory(category *models.GradeCategory) error {
+	query := `UPDATE grade_categories SET name = ?, weight = ?, drop_lowest = ? WHERE id = ?`
+	_, err := DB.Exec(query, category.Name, category.Weight, category.DropLowest, category.ID)
+	return err
+}
+
+// DeleteGradeCategory removes a category; its quizzes and assignments become
+// uncategorized
+func DeleteGradeCategory(categoryID int) error {
+	query := `DELETE FROM grade_categories WHERE id = ?`
+	_, err := DB.Exec(query, categoryID)
+	return err
+}
+
+// Gradebook queries
+
+// GetGradeItems lists the published assignments and quizzes that count in a
+// teacher's own gradebook (those without a course, and quizzes on videos
+// rather than lessons), or in a course's when courseID is set
+func GetGradeItems(teacherID, courseID int) ([]models.GradeItem, error) {
+	items := []models.GradeItem{}
+
+	query := `
+		SELECT id, title, COALESCE(category_id, 0), max_points, due_at
+		FROM assignments
+		WHERE teacher_id = ? AND COALESCE(course_id, 0) = ? AND status = 'published'
+		ORDER BY due_at IS NULL, due_at, id
+	`
+	rows, err := DB.Query(query, teacherID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := models.GradeItem{Type: models.GradeItemAssignment}
+		var dueAt sql.NullTime
+		err := rows.Scan(&item.ID, &item.Title, &item.CategoryID, &item.MaxPoints, &dueAt)
+		if err != nil {
+			return nil, err
+		}
+		if dueAt.Valid {
+			item.DueAt = &dueAt.Time
+		}
+		items = append(items, item)
+	}
+	rows.Close()
+
+	query = `
+		SELECT q.id, q.title, COALESCE(q.category_id, 0),
+		       (SELECT COALESCE(SUM(qq.points), 0) FROM quiz_questions qq WHERE qq.quiz_id = q.id)
+		FROM quizzes q
+		LEFT JOIN lessons l ON q.lesson_id = l.id
+		WHERE q.teacher_id = ? AND COALESCE(l.course_id, 0) = ? AND q.status = 'published'
+		ORDER BY q.id
+	`
+	rows, err = DB.Query(query, teacherID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item := models.GradeItem{Type: models.GradeItemQuiz}
+		err := rows.Scan(&item.ID, &item.Title, &item.CategoryID, &item.MaxPoints)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// GetGradeResults returns the results behind a gradebook: each student's
+// best finished attempt at its quizzes and their newest submission to its
+// assignments. studentID 0 returns every student's.
+func GetGradeResults(teacherID, courseID, studentID int) ([]models.GradeResult, error) {
+	results := []models.GradeResult{}
+
+	// SQLite takes the bare score column from the row with the best percent
+	query := `
+		SELECT a.quiz_id, a.student_id, s.name, a.score, MAX(a.percent)
+		FROM quiz_attempts a
+		JOIN quizzes q ON a.quiz_id = q.id
+		LEFT JOIN lessons l ON q.lesson_id = l.id
+		JOIN students s ON a.student_id = s.id
+		WHERE q.teacher_id = ? AND COALESCE(l.course_id, 0) = ? AND q.status = 'published'
+		  AND a.status IN ('submitted', 'expired') AND (? = 0 OR a.student_id = ?)
+		GROUP BY a.quiz_id, a.student_id
+	`
+	rows, err := DB.Query(query, teacherID, courseID, studentID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		result := models.GradeResult{Type: models.GradeItemQuiz, Graded: true}
+		err := rows.Scan(&result.ItemID, &result.StudentID, &result.StudentName, &result.Points, &result.Percent)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	rows.Close()
+
+	query = `
+		SELECT s.assignment_id, s.student_id, st.name, s.graded_at IS NOT NULL, COALESCE(s.final_score, 0),
+		       a.max_points
+		FROM assignment_submissions s
+		JOIN assignments a ON s.assignment_id = a.id
+		JOIN students st ON s.student_id = st.id
+		WHERE a.teacher_id = ? AND COALESCE(a.course_id, 0) = ? AND a.status = 'published'
+		  AND (? = 0 OR s.student_id = ?) AND ` + latestSubmissionCondition + `
+	`
+	rows, err = DB.Query(query, teacherID, courseID, studentID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		result := models.GradeResult{Type: models.GradeItemAssignment}
+		var maxPoints float64
+		err := rows.Scan(&result.ItemID, &result.StudentID, &result.StudentName, &result.Graded, &result.Points,
+			&maxPoints)
+		if err != nil {
+			return nil, err
+		}
+		if maxPoints > 0 {
+			result.Percent = result.Points / maxPoints * 100
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
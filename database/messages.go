@@ -0,0 +1,328 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"educational-platform/models"
+)
+
+// ErrMessageRateLimited is returned when a sender has already sent as many
+// messages as allowed in the rate limit window
+var ErrMessageRateLimited = errors.New("message rate limit reached")
+
+// conversationColumns selects a conversation (alias c) with its last message
+// (alias m) in the order scanConversation reads them. The one parameter is
+// the type of the user viewing it, whose unread messages are counted.
+const conversationColumns = `
+		SELECT c.id, c.teacher_id, t.name, c.student_id, s.name, c.teacher_blocked_at IS NOT NULL,
+		       c.student_blocked_at IS NOT NULL,
+		       (SELECT COUNT(*) FROM messages WHERE conversation_id = c.id AND sender_type != ? AND read_at IS NULL),
+		       c.last_message_at, c.created_at,
+		       m.id, m.sender_type, m.body, m.attachment_path, m.attachment_name, m.attachment_size, m.read_at,
+		       m.created_at
+		FROM conversations c
+		JOIN teachers t ON c.teacher_id = t.id
+		JOIN students s ON c.student_id = s.id
+		LEFT JOIN messages m ON m.id = (SELECT MAX(id) FROM messages WHERE conversation_id = c.id)`
+
+func scanConversation(row interface{ Scan(...interface{}) error }) (*models.Conversation, error) {
+	conv := &models.Conversation{}
+	var lastMessageAt, readAt, createdAt sql.NullTime
+	var messageID, attachmentSize sql.NullInt64
+	var senderType, body, attachmentPath, attachmentName sql.NullString
+	err := row.Scan(&conv.ID, &conv.TeacherID, &conv.TeacherName, &conv.StudentID, &conv.StudentName,
+		&conv.TeacherBlocked, &conv.StudentBlocked, &conv.Unread, &lastMessageAt, &conv.CreatedAt,
+		&messageID, &senderType, &body, &attachmentPath, &attachmentName, &attachmentSize, &readAt, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastMessageAt.Valid {
+		conv.LastMessageAt = &lastMessageAt.Time
+	}
+	if messageID.Valid {
+		conv.LastMessage = &models.Message{
+			ID:             int(messageID.Int64),
+			ConversationID: conv.ID,
+			SenderType:     senderType.String,
+			Body:           body.String,
+			CreatedAt:      createdAt.Time,
+		}
+		if attachmentPath.Valid {
+			conv.LastMessage.Attachment = &models.MessageAttachment{
+				Name: attachmentName.String,
+				Size: attachmentSize.Int64,
+				Path: attachmentPath.String,
+			}
+		}
+		if readAt.Valid {
+			conv.LastMessage.ReadAt = &readAt.Time
+		}
+	}
+	return conv, nil
+}
+
+const messageColumns = `id, conversation_id, sender_type, body, attachment_path, attachment_name, attachment_size,
+		       read_at, created_at`
+
+func scanMessage(row interface{ Scan(...interface{}) error }, m *models.Message) error {
+	var attachmentPath, attachmentName sql.NullString
+	var attachmentSize sql.NullInt64
+	var readAt sql.NullTime
+	err := row.Scan(&m.ID, &m.ConversationID, &m.SenderType, &m.Body, &attachmentPath, &attachmentName,
+		&attachmentSize, &readAt, &m.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if attachmentPath.Valid {
+		m.Attachment = &models.MessageAttachment{
+			Name: attachmentName.String,
+			Size: attachmentSize.Int64,
+			Path: attachmentPath.String,
+		}
+	}
+	if readAt.Valid {
+		m.ReadAt = &readAt.Time
+	}
+	return nil
+}
+
+// StartConversation returns the conversation between a teacher and a
+// student, creating it if they have none, and whether it was created
+func StartConversation(teacherID, studentID int) (int, bool, error) {
+	query := `
+		INSERT INTO conversations (teacher_id, student_id, created_at) VALUES (?, ?, ?)
+		ON CONFLICT (teacher_id, student_id) DO NOTHING
+	`
+	result, err := DB.Exec(query, teacherID, studentID, time.Now().UTC())
+	if err != nil {
+		return 0, false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, false, err
+	}
+
+	var id int
+	err = DB.QueryRow(`SELECT id FROM conversations WHERE teacher_id = ? AND student_id = ?`, teacherID,
+		studentID).Scan(&id)
+	return id, n > 0, err
+}
+
+// GetConversation returns a conversation as seen by a user of userType
+func GetConversation(conversationID int, userType string) (*models.Conversation, error) {
+	query := conversationColumns + ` WHERE c.id = ?`
+	return scanConversation(DB.QueryRow(query, userType, conversationID))
+}
+
+// GetConversations lists a user's conversations, most recently active first
+func GetConversations(userType string, userID int) ([]models.Conversation, error) {
+	column := "c.student_id"
+	if userType == "teacher" {
+		column = "c.teacher_id"
+	}
+	query := conversationColumns + ` WHERE ` + column + ` = ?
+		ORDER BY COALESCE(c.last_message_at, c.created_at) DESC, c.id DESC`
+	rows, err := DB.Query(query, userType, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []models.Conversation{}
+	for rows.Next() {
+		conv, err := scanConversation(rows)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, *conv)
+	}
+	return conversations, nil
+}
+
+// CreateMessage adds a message to a conversation. With a rateLimit, it
+// returns ErrMessageRateLimited if the sender already sent that many messages
+// in the conversation since the given time.
+func CreateMessage(m *models.Message, rateLimit int, since time.Time) (int, error) {
+	var attachmentPath, attachmentName, attachmentSize interface{}
+	if m.Attachment != nil {
+		attachmentPath, attachmentName, attachmentSize = m.Attachment.Path, m.Attachment.Name, m.Attachment.Size
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO messages (conversation_id, sender_type, body, attachment_path, attachment_name, attachment_size,
+		                      created_at)
+		SELECT ?, ?, ?, ?, ?, ?, ?
+		WHERE ? = 0 OR (SELECT COUNT(*) FROM messages
+		                WHERE conversation_id = ? AND sender_type = ? AND created_at > ?) < ?
+	`
+	result, err := tx.Exec(query, m.ConversationID, m.SenderType, m.Body, attachmentPath, attachmentName,
+		attachmentSize, m.CreatedAt, rateLimit, m.ConversationID, m.SenderType, since, rateLimit)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err == nil && n == 0 {
+		return 0, ErrMessageRateLimited
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(`UPDATE conversations SET last_message_at = ? WHERE id = ?`, m.CreatedAt, m.ConversationID)
+	if err != nil {
+		return 0, err
+	}
+	return int(id), tx.Commit()
+}
+
+// OldestMessageSince returns when the sender's oldest message in a
+// conversation after since was sent, which is when the rate limit window
+// frees up again
+func OldestMessageSince(conversationID int, senderType string, since time.Time) (time.Time, error) {
+	query := `
+		SELECT created_at FROM messages
+		WHERE conversation_id = ? AND sender_type = ? AND created_at > ?
+		ORDER BY created_at
+		LIMIT 1
+	`
+	var createdAt time.Time
+	err := DB.QueryRow(query, conversationID, senderType, since).Scan(&createdAt)
+	return createdAt, err
+}
+
+// GetMessages lists a conversation's messages, newest first. beforeID pages
+// back from an earlier result; 0 starts from the newest.
+func GetMessages(conversationID, beforeID, limit int) ([]models.Message, error) {
+	query := `
+		SELECT ` + messageColumns + `
+		FROM messages
+		WHERE conversation_id = ? AND (? = 0 OR id < ?)
+		ORDER BY id DESC
+		LIMIT ?
+	`
+	rows, err := DB.Query(query, conversationID, beforeID, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		var m models.Message
+		if err := scanMessage(rows, &m); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, nil
+}
+
+// GetMessage returns one message of a conversation
+func GetMessage(conversationID, messageID int) (*models.Message, error) {
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE id = ? AND conversation_id = ?`
+	m := &models.Message{}
+	err := scanMessage(DB.QueryRow(query, messageID, conversationID), m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// MarkConversationRead marks the messages a user of readerType received in a
+// conversation as read. It returns the ID of the newest message that was
+// unread, 0 if there was none, and how many there were.
+func MarkConversationRead(conversationID int, readerType string, readAt time.Time) (int, int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	var lastID sql.NullInt64
+	query := `SELECT MAX(id) FROM messages WHERE conversation_id = ? AND sender_type != ? AND read_at IS NULL`
+	err = tx.QueryRow(query, conversationID, readerType).Scan(&lastID)
+	if err != nil || !lastID.Valid {
+		return 0, 0, err
+	}
+
+	query = `
+		UPDATE messages SET read_at = ?
+		WHERE conversation_id = ? AND sender_type != ? AND read_at IS NULL AND id <= ?
+	`
+	result, err := tx.Exec(query, readAt, conversationID, readerType, lastID.Int64)
+	if err != nil {
+		return 0, 0, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(lastID.Int64), int(n), tx.Commit()
+}
+
+// CountUnreadMessages counts the messages a user has not read across their
+// conversations
+func CountUnreadMessages(userType string, userID int) (int, error) {
+	column := "c.student_id"
+	if userType == "teacher" {
+		column = "c.teacher_id"
+	}
+	query := `
+		SELECT COUNT(*) FROM messages m
+		JOIN conversations c ON m.conversation_id = c.id
+		WHERE ` + column + ` = ? AND m.sender_type != ? AND m.read_at IS NULL
+	`
+	var count int
+	err := DB.QueryRow(query, userID, userType).Scan(&count)
+	return count, err
+}
+
+// SetConversationBlocked blocks or unblocks the other side of a conversation
+// for a user of userType
+func SetConversationBlocked(conversationID int, userType string, blocked bool) error {
+	column := "student_blocked_at"
+	if userType == "teacher" {
+		column = "teacher_blocked_at"
+	}
+	var blockedAt interface{}
+	if blocked {
+		blockedAt = time.Now().UTC()
+	}
+	query := `UPDATE conversations SET ` + column + ` = CASE WHEN ? IS NULL THEN NULL ELSE COALESCE(` + column +
+		`, ?) END WHERE id = ?`
+	_, err := DB.Exec(query, blockedAt, blockedAt, conversationID)
+	return err
+}
+
+// GetTeacherMessageRateLimit returns how many messages each student may send
+// the teacher per hour and whether the teacher set it
+func GetTeacherMessageRateLimit(teacherID int) (int, bool, error) {
+	query := `SELECT message_rate_limit FROM teachers WHERE id = ?`
+	var limit sql.NullInt64
+	err := DB.QueryRow(query, teacherID).Scan(&limit)
+	if err != nil {
+		return 0, false, err
+	}
+	return int(limit.Int64), limit.Valid, nil
+}
+
+// SetTeacherMessageRateLimit sets the teacher's message rate limit, or clears
+// it to use the server default if limit is nil
+func SetTeacherMessageRateLimit(teacherID int, limit *int) error {
+	var value interface{}
+	if limit != nil {
+		value = *limit
+	}
+	query := `UPDATE teachers SET message_rate_limit = ? WHERE id = ?`
+	_, err := DB.Exec(query, value, teacherID)
+	return err
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"educational-platform/models"
+)
+
+// ErrTooManyNotes is returned when a student already has the most notes a
+// video allows
+var ErrTooManyNotes = errors.New("too many notes on this video")
+
+const noteColumns = `n.id, n.video_id, n.type, n.timestamp, n.text, n.created_at, n.updated_at`
+
+func scanNote(row interface{ Scan(...interface{}) error }, note *models.VideoNote, extra ...interface{}) error {
+	dest := []interface{}{&note.ID, &note.VideoID, &note.Type, &note.Timestamp, &note.Text, &note.CreatedAt,
+		&note.UpdatedAt}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// CreateNote stores a student's note, unless they already have maxNotes on
+// the video
+func CreateNote(studentID int, note *models.VideoNote, maxNotes int) (int, error) {
+	query := `
+		INSERT INTO video_notes (student_id, video_id, type, timestamp, text, created_at, updated_at)
+		SELECT ?, ?, ?, ?, ?, ?, ?
+		WHERE (SELECT COUNT(*) FROM video_notes WHERE student_id = ? AND video_id = ?) < ?
+	`
+	result, err := DB.Exec(query, studentID, note.VideoID, note.Type, note.Timestamp, note.Text, note.CreatedAt,
+		note.UpdatedAt, studentID, note.VideoID, maxNotes)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err == nil && n == 0 {
+		return 0, ErrTooManyNotes
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetNote returns one of a student's notes
+func GetNote(noteID, studentID int) (*models.VideoNote, error) {
+	query := `SELECT ` + noteColumns + ` FROM video_notes n WHERE n.id = ? AND n.student_id = ?`
+	note := &models.VideoNote{}
+	err := scanNote(DB.QueryRow(query, noteID, studentID), note)
+	if err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// GetVideoNotes lists a student's notes on a video in timestamp order;
+// noteType "" matches both notes and bookmarks
+func GetVideoNotes(studentID, videoID int, noteType string) ([]models.VideoNote, error) {
+	query := `
+		SELECT ` + noteColumns + `
+		FROM video_notes n
+		WHERE n.student_id = ? AND n.video_id = ? AND (? = '' OR n.type = ?)
+		ORDER BY n.timestamp, n.id
+	`
+	rows, err := DB.Query(query, studentID, videoID, noteType, noteType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []models.VideoNote{}
+	for rows.Next() {
+		var note models.VideoNote
+		err := scanNote(rows, &note)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	return notes, nil
+}
+
+// likePattern matches text containing s in a LIKE ... ESCAPE '\' clause
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+	return "%" + s + "%"
+}
+
+// SearchNotes finds a student's notes containing the search text, most
+// recently edited first. An empty search lists every note; noteType ""
+// matches both notes and bookmarks and videoID 0 any video.
+func SearchNotes(studentID int, search, noteType string, videoID, limit int) ([]models.VideoNote, error) {
+	query := `
+		SELECT ` + noteColumns + `, v.title
+		FROM video_notes n
+		JOIN videos v ON n.video_id = v.id
+		WHERE n.student_id = ? AND n.text LIKE ? ESCAPE '\'
+		  AND (? = '' OR n.type = ?) AND (? = 0 OR n.video_id = ?)
+		ORDER BY n.updated_at DESC, n.id DESC
+		LIMIT ?
+	`
+	rows, err := DB.Query(query, studentID, likePattern(search), noteType, noteType, videoID, videoID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := []models.VideoNote{}
+	for rows.Next() {
+		var note models.VideoNote
+		err := scanNote(rows, &note, &note.VideoTitle)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	return notes, nil
+}
+
+// GetCourseNotes returns a student's notes on the videos of a course's
+// lessons, grouped by video and in timestamp order
+func GetCourseNotes(studentID, courseID int) (map[int][]models.VideoNote, error) {
+	query := `
+		SELECT ` + noteColumns + `
+		FROM video_notes n
+		WHERE n.student_id = ? AND n.video_id IN (SELECT video_id FROM lessons WHERE course_id = ?)
+		ORDER BY n.timestamp, n.id
+	`
+	rows, err := DB.Query(query, studentID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := make(map[int][]models.VideoNote)
+	for rows.Next() {
+		var note models.VideoNote
+		err := scanNote(rows, &note)
+		if err != nil {
+			return nil, err
+		}
+		notes[note.VideoID] = append(notes[note.VideoID], note)
+	}
+	return notes, nil
+}
+
+func UpdateNote(note *models.VideoNote) error {
+	note.UpdatedAt = time.Now().UTC()
+	query := `UPDATE video_notes SET type = ?, timestamp = ?, text = ?, updated_at = ? WHERE id = ?`
+	_, err := DB.Exec(query, note.Type, note.Timestamp, note.Text, note.UpdatedAt, note.ID)
+	return err
+}
+
+func DeleteNote(noteID int) error {
+	query := `DELETE FROM video_notes WHERE id = ?`
+	_, err := DB.Exec(query, noteID)
+	return err
+}
@@ -0,0 +1,230 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"educational-platform/models"
+)
+
+func scanNotification(row interface{ Scan(...interface{}) error }, n *models.Notification) error {
+	var videoID sql.NullInt64
+	var readAt sql.NullTime
+	err := row.Scan(&n.ID, &n.Type, &n.SourceID, &videoID, &n.Title, &n.Body, &readAt, &n.CreatedAt)
+	if err != nil {
+		return err
+	}
+	n.VideoID = int(videoID.Int64)
+	if readAt.Valid {
+		n.ReadAt = &readAt.Time
+	}
+	return nil
+}
+
+// CreateNotifications adds a notification to each user's inbox, skipping
+// users who turned its type off. A user already notified about the same
+// source keeps their notification, unless renotify, which refreshes it and
+// marks it unread again. It returns the notified users' notification IDs.
+func CreateNotifications(userType string, userIDs []int, n *models.Notification, renotify bool) (map[int]int, error) {
+	var videoID interface{}
+	if n.VideoID != 0 {
+		videoID = n.VideoID
+	}
+	conflict := `DO NOTHING`
+	if renotify {
+		conflict = `DO UPDATE SET title = excluded.title, body = excluded.body, read_at = NULL,
+		                            created_at = excluded.created_at`
+	}
+	query := `
+		INSERT INTO notifications (user_type, user_id, type, source_id, video_id, title, body, created_at)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM notification_preferences
+		                  WHERE user_type = ? AND user_id = ? AND type = ? AND enabled = 0)
+		ON CONFLICT (user_type, user_id, type, source_id) ` + conflict + `
+		RETURNING id`
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	notified := make(map[int]int)
+	for _, userID := range userIDs {
+		var id int
+		err := tx.QueryRow(query, userType, userID, n.Type, n.SourceID, videoID, n.Title, n.Body, n.CreatedAt,
+			userType, userID, n.Type).Scan(&id)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		notified[userID] = id
+	}
+	return notified, tx.Commit()
+}
+
+// GetNotifications lists a user's notifications, newest first. beforeID pages
+// back from an earlier result; 0 starts from the newest.
+func GetNotifications(userType string, userID int, unreadOnly bool, notificationType string, beforeID, limit int) ([]models.Notification, error) {
+	query := `
+		SELECT id, type, source_id, video_id, title, body, read_at, created_at
+		FROM notifications
+		WHERE user_type = ? AND user_id = ? AND (? = 0 OR read_at IS NULL) AND (? = '' OR type = ?)
+		  AND (? = 0 OR id < ?)
+		ORDER BY id DESC
+		LIMIT ?
+	`
+	rows, err := DB.Query(query, userType, userID, unreadOnly, notificationType, notificationType, beforeID, beforeID,
+		limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+	for rows.Next() {
+		var n models.Notification
+		if err := scanNotification(rows, &n); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, nil
+}
+
+// CountUnreadNotifications counts a user's unread notifications by type
+func CountUnreadNotifications(userType string, userID int) (map[string]int, error) {
+	query := `
+		SELECT type, COUNT(*) FROM notifications
+		WHERE user_type = ? AND user_id = ? AND read_at IS NULL
+		GROUP BY type
+	`
+	rows, err := DB.Query(query, userType, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var notificationType string
+		var count int
+		if err := rows.Scan(&notificationType, &count); err != nil {
+			return nil, err
+		}
+		counts[notificationType] = count
+	}
+	return counts, nil
+}
+
+// SetNotificationRead marks one of a user's notifications as read or unread.
+// It returns sql.ErrNoRows if the user has no such notification.
+func SetNotificationRead(notificationID int, userType string, userID int, read bool) error {
+	var readAt interface{}
+	if read {
+		readAt = time.Now().UTC()
+	}
+	query := `
+		UPDATE notifications SET read_at = CASE WHEN ? IS NULL THEN NULL ELSE COALESCE(read_at, ?) END
+		WHERE id = ? AND user_type = ? AND user_id = ?
+	`
+	result, err := DB.Exec(query, readAt, readAt, notificationID, userType, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err == nil && n == 0 {
+		err = sql.ErrNoRows
+	}
+	return err
+}
+
+// MarkAllNotificationsRead marks a user's notifications as read, only those
+// of one type if notificationType is set, and returns how many were unread
+func MarkAllNotificationsRead(userType string, userID int, notificationType string) (int, error) {
+	query := `
+		UPDATE notifications SET read_at = ?
+		WHERE user_type = ? AND user_id = ? AND read_at IS NULL AND (? = '' OR type = ?)
+	`
+	result, err := DB.Exec(query, time.Now().UTC(), userType, userID, notificationType, notificationType)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// DeleteNotification removes one of a user's notifications. It returns
+// sql.ErrNoRows if the user has no such notification.
+func DeleteNotification(notificationID int, userType string, userID int) error {
+	query := `DELETE FROM notifications WHERE id = ? AND user_type = ? AND user_id = ?`
+	result, err := DB.Exec(query, notificationID, userType, userID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err == nil && n == 0 {
+		err = sql.ErrNoRows
+	}
+	return err
+}
+
+// DeleteSourceNotifications removes every notification about a source that
+// no longer exists
+func DeleteSourceNotifications(notificationType string, sourceID int) error {
+	query := `DELETE FROM notifications WHERE type = ? AND source_id = ?`
+	_, err := DB.Exec(query, notificationType, sourceID)
+	return err
+}
+
+// DeleteVideoNotifications removes every notification that links to a video,
+// whatever its type, once the video is deleted
+func DeleteVideoNotifications(videoID int) error {
+	_, err := DB.Exec(`DELETE FROM notifications WHERE video_id = ?`, videoID)
+	return err
+}
+
+// GetNotificationPreferences returns the types a user has turned on or off;
+// types that are not listed are on
+func GetNotificationPreferences(userType string, userID int) (map[string]bool, error) {
+	query := `SELECT type, enabled FROM notification_preferences WHERE user_type = ? AND user_id = ?`
+	rows, err := DB.Query(query, userType, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	preferences := make(map[string]bool)
+	for rows.Next() {
+		var notificationType string
+		var enabled bool
+		if err := rows.Scan(&notificationType, &enabled); err != nil {
+			return nil, err
+		}
+		preferences[notificationType] = enabled
+	}
+	return preferences, nil
+}
+
+// SetNotificationPreferences turns notification types on or off for a user
+func SetNotificationPreferences(userType string, userID int, preferences map[string]bool) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO notification_preferences (user_type, user_id, type, enabled) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_type, user_id, type) DO UPDATE SET enabled = excluded.enabled
+	`
+	for notificationType, enabled := range preferences {
+		_, err := tx.Exec(query, userType, userID, notificationType, enabled)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
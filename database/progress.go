@@ -0,0 +1,83 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"educational-platform/models"
+)
+
+const progressColumns = `video_id, position, intervals, watched_seconds, percent, completed, completed_at, updated_at`
+
+func scanProgress(row interface{ Scan(...interface{}) error }, progress *models.VideoProgress) error {
+	var intervals string
+	var completedAt sql.NullTime
+	err := row.Scan(&progress.VideoID, &progress.Position, &intervals, &progress.WatchedSeconds,
+		&progress.Percent, &progress.Completed, &completedAt, &progress.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if completedAt.Valid {
+		progress.CompletedAt = &completedAt.Time
+	}
+	return json.Unmarshal([]byte(intervals), &progress.Intervals)
+}
+
+// Progress queries
+
+// GetVideoProgress returns a student's progress on a video, or
+// sql.ErrNoRows when the student has not played it
+func GetVideoProgress(studentID, videoID int) (*models.VideoProgress, error) {
+	query := `SELECT ` + progressColumns + ` FROM video_progress WHERE student_id = ? AND video_id = ?`
+	progress := &models.VideoProgress{}
+	err := scanProgress(DB.QueryRow(query, studentID, videoID), progress)
+	if err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+// SaveVideoProgress stores a student's progress on a video
+func SaveVideoProgress(studentID int, progress *models.VideoProgress) error {
+	intervals, err := json.Marshal(progress.Intervals)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO video_progress (student_id, video_id, position, intervals, watched_seconds, percent, completed, completed_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(student_id, video_id) DO UPDATE SET
+			position = excluded.position,
+			intervals = excluded.intervals,
+			watched_seconds = excluded.watched_seconds,
+			percent = excluded.percent,
+			completed = excluded.completed,
+			completed_at = excluded.completed_at,
+			updated_at = excluded.updated_at
+	`
+	_, err = DB.Exec(query, studentID, progress.VideoID, progress.Position, string(intervals),
+		progress.WatchedSeconds, progress.Percent, progress.Completed, progress.CompletedAt, progress.UpdatedAt)
+	return err
+}
+
+// GetStudentProgress returns a student's progress on every video played,
+// keyed by video ID
+func GetStudentProgress(studentID int) (map[int]*models.VideoProgress, error) {
+	query := `SELECT ` + progressColumns + ` FROM video_progress WHERE student_id = ?`
+	rows, err := DB.Query(query, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := make(map[int]*models.VideoProgress)
+	for rows.Next() {
+		p := &models.VideoProgress{}
+		err := scanProgress(rows, p)
+		if err != nil {
+			return nil, err
+		}
+		progress[p.VideoID] = p
+	}
+	return progress, nil
+}
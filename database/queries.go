@@ -1,6 +1,13 @@
 package database
 
 import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
 	"educational-platform/models"
 )
 
@@ -35,6 +42,32 @@ func GetTeacherByID(id int) (*models.Teacher, error) {
 	return teacher, nil
 }
 
+// GetTeacherStorageQuota returns the teacher's quota in bytes and whether one is set
+func GetTeacherStorageQuota(teacherID int) (int64, bool, error) {
+	query := `SELECT storage_quota FROM teachers WHERE id = ?`
+	var quota sql.NullInt64
+	err := DB.QueryRow(query, teacherID).Scan(&quota)
+	if err != nil {
+		return 0, false, err
+	}
+	return quota.Int64, quota.Valid, nil
+}
+
+// GetTeacherStorageUsage returns the bytes used by a teacher's videos and
+// their kept revisions, plus the bytes reserved by unfinished resumable uploads
+func GetTeacherStorageUsage(teacherID int) (int64, error) {
+	query := `
+		SELECT
+			(SELECT COALESCE(SUM(file_size), 0) FROM videos WHERE teacher_id = ?) +
+			(SELECT COALESCE(SUM(r.file_size), 0) FROM video_revisions r
+				JOIN videos v ON r.video_id = v.id WHERE v.teacher_id = ?) +
+			(SELECT COALESCE(SUM(upload_length), 0) FROM tus_uploads WHERE teacher_id = ? AND expires_at > ?)
+	`
+	var used int64
+	err := DB.QueryRow(query, teacherID, teacherID, teacherID, time.Now().UTC()).Scan(&used)
+	return used, err
+}
+
 // Student queries
 func CreateStudent(username, email, passwordHash, name string) error {
 	query := `INSERT INTO students (username, email, password_hash, name) VALUES (?, ?, ?, ?)`
@@ -67,16 +100,57 @@ func GetStudentByID(id int) (*models.Student, error) {
 }
 
 // Video queries
-func CreateVideo(teacherID int, title, description, filename, filePath, thumbnailPath string, duration int, fileSize int64) error {
-	query := `INSERT INTO videos (teacher_id, title, description, filename, file_path, thumbnail_path, duration, file_size) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := DB.Exec(query, teacherID, title, description, filename, filePath, thumbnailPath, duration, fileSize)
+
+// videoColumns selects a video joined with its teacher (aliases v and t) in
+// the order scanVideo reads them
+const videoColumns = `v.id, v.teacher_id, v.title, v.description, v.filename, v.file_path,
+		       v.thumbnail_path, v.duration, v.file_size, v.created_at, t.name,
+		       v.status, v.visibility, v.publish_at, v.published_at, v.share_token`
+
+// scanVideo reads a row selected with videoColumns
+func scanVideo(row interface{ Scan(...interface{}) error }, video *models.Video) error {
+	var publishAt, publishedAt sql.NullTime
+	var shareToken sql.NullString
+	err := row.Scan(&video.ID, &video.TeacherID, &video.Title, &video.Description,
+		&video.Filename, &video.FilePath, &video.ThumbnailPath, &video.Duration,
+		&video.FileSize, &video.CreatedAt, &video.TeacherName,
+		&video.Status, &video.Visibility, &publishAt, &publishedAt, &shareToken)
+	if err != nil {
+		return err
+	}
+	if publishAt.Valid {
+		video.PublishAt = &publishAt.Time
+	}
+	if publishedAt.Valid {
+		video.PublishedAt = &publishedAt.Time
+	}
+	video.ShareToken = shareToken.String
+	return nil
+}
+func CreateVideo(teacherID int, title, description, filename, filePath, thumbnailPath string, duration int, fileSize int64, publishing models.VideoPublishing) (int, error) {
+	var publishedAt interface{}
+	if publishing.Status == models.VideoStatusPublished {
+		publishedAt = time.Now().UTC()
+	}
+	query := `INSERT INTO videos (teacher_id, title, description, filename, file_path, thumbnail_path, duration, file_size, status, visibility, publish_at, published_at, share_token) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := DB.Exec(query, teacherID, title, description, filename, filePath, thumbnailPath, duration, fileSize,
+		publishing.Status, publishing.Visibility, publishing.PublishAt, publishedAt, newShareToken())
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func UpdateVideoThumbnail(videoID int, thumbnailPath string) error {
+	query := `UPDATE videos SET thumbnail_path = ? WHERE id = ?`
+	_, err := DB.Exec(query, thumbnailPath, videoID)
 	return err
 }
 
 func GetVideosByTeacherID(teacherID int) ([]models.Video, error) {
 	query := `
-		SELECT v.id, v.teacher_id, v.title, v.description, v.filename, v.file_path, 
-		       v.thumbnail_path, v.duration, v.file_size, v.created_at, t.name
+		SELECT ` + videoColumns + `
 		FROM videos v
 		JOIN teachers t ON v.teacher_id = t.id
 		WHERE v.teacher_id = ?
@@ -91,21 +165,24 @@ func GetVideosByTeacherID(teacherID int) ([]models.Video, error) {
 	var videos []models.Video
 	for rows.Next() {
 		var video models.Video
-		err := rows.Scan(&video.ID, &video.TeacherID, &video.Title, &video.Description, 
-			&video.Filename, &video.FilePath, &video.ThumbnailPath, &video.Duration, 
-			&video.FileSize, &video.CreatedAt, &video.TeacherName)
+		err := scanVideo(rows, &video)
 		if err != nil {
 			return nil, err
 		}
 		videos = append(videos, video)
 	}
+	rows.Close()
+
+	err = attachCaptions(videos)
+	if err != nil {
+		return nil, err
+	}
 	return videos, nil
 }
 
 func GetVideoByID(videoID int) (*models.Video, error) {
 	query := `
-		SELECT v.id, v.teacher_id, v.title, v.description, v.filename, v.file_path, 
-		       v.thumbnail_path, v.duration, v.file_size, v.created_at, t.name
+		SELECT ` + videoColumns + `
 		FROM videos v
 		JOIN teachers t ON v.teacher_id = t.id
 		WHERE v.id = ?
@@ -113,26 +190,46 @@ func GetVideoByID(videoID int) (*models.Video, error) {
 	row := DB.QueryRow(query, videoID)
 	
 	video := &models.Video{}
-	err := row.Scan(&video.ID, &video.TeacherID, &video.Title, &video.Description, 
-		&video.Filename, &video.FilePath, &video.ThumbnailPath, &video.Duration, 
-		&video.FileSize, &video.CreatedAt, &video.TeacherName)
+	err := scanVideo(row, video)
+	if err != nil {
+		return nil, err
+	}
+
+	video.Captions, err = GetCaptionsByVideoID(video.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	video.Chapters, err = GetChaptersByVideoID(video.ID, video.Duration)
 	if err != nil {
 		return nil, err
 	}
 	return video, nil
 }
 
+// GetVideosForStudent lists the published videos a student can watch: those
+// of subscribed teachers, except videos in published courses, which need an
+// enrollment in one of those courses
 func GetVideosForStudent(studentID int) ([]models.Video, error) {
 	query := `
-		SELECT v.id, v.teacher_id, v.title, v.description, v.filename, v.file_path, 
-		       v.thumbnail_path, v.duration, v.file_size, v.created_at, t.name
+		SELECT ` + videoColumns + `
 		FROM videos v
 		JOIN teachers t ON v.teacher_id = t.id
-		JOIN subscriptions s ON s.teacher_id = t.id
-		WHERE s.student_id = ?
-		ORDER BY v.created_at DESC
+		WHERE v.status = 'published'
+		  AND v.visibility IN ('subscribers', 'public')
+		  AND ((v.teacher_id IN (SELECT teacher_id FROM subscriptions WHERE student_id = ?)
+		        AND (v.visibility = 'public' OR NOT EXISTS (
+		            SELECT 1 FROM lessons l JOIN courses c ON l.course_id = c.id
+		            WHERE l.video_id = v.id AND c.status = 'published')))
+		       OR EXISTS (
+		            SELECT 1 FROM lessons l
+		            JOIN courses c ON l.course_id = c.id
+		            JOIN enrollments e ON e.course_id = c.id
+		            WHERE l.video_id = v.id AND c.status = 'published'
+		              AND e.student_id = ? AND ` + activeEnrollmentCondition + `))
+		ORDER BY COALESCE(v.published_at, v.created_at) DESC
 	`
-	rows, err := DB.Query(query, studentID)
+	rows, err := DB.Query(query, studentID, studentID, time.Now().UTC())
 	if err != nil {
 		return nil, err
 	}
@@ -141,17 +238,156 @@ func GetVideosForStudent(studentID int) ([]models.Video, error) {
 	var videos []models.Video
 	for rows.Next() {
 		var video models.Video
-		err := rows.Scan(&video.ID, &video.TeacherID, &video.Title, &video.Description, 
-			&video.Filename, &video.FilePath, &video.ThumbnailPath, &video.Duration, 
-			&video.FileSize, &video.CreatedAt, &video.TeacherName)
+		err := scanVideo(rows, &video)
 		if err != nil {
 			return nil, err
 		}
 		videos = append(videos, video)
 	}
+	rows.Close()
+
+	err = attachCaptions(videos)
+	if err != nil {
+		return nil, err
+	}
 	return videos, nil
 }
 
+// UpdateVideoPublishing changes a video's status and visibility. The first
+// time a video is published its published_at is set.
+func UpdateVideoPublishing(videoID int, publishing models.VideoPublishing) error {
+	query := `
+		UPDATE videos
+		SET status = ?, visibility = ?, publish_at = ?,
+		    published_at = CASE WHEN ? = 'published' THEN COALESCE(published_at, ?) ELSE published_at END
+		WHERE id = ?
+	`
+	_, err := DB.Exec(query, publishing.Status, publishing.Visibility, publishing.PublishAt,
+		publishing.Status, time.Now().UTC(), videoID)
+	return err
+}
+
+// PublishDueVideos publishes scheduled videos whose publish time has passed
+// and returns their IDs
+func PublishDueVideos(now time.Time) ([]int, error) {
+	rows, err := DB.Query(`SELECT id FROM videos WHERE status = 'scheduled' AND publish_at <= ?`, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		query := `UPDATE videos SET status = 'published', published_at = publish_at WHERE id = ? AND status = 'scheduled'`
+		_, err := DB.Exec(query, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// ResetShareToken gives a video a new link token, invalidating old links
+func ResetShareToken(videoID int) (string, error) {
+	token := newShareToken()
+	query := `UPDATE videos SET share_token = ? WHERE id = ?`
+	_, err := DB.Exec(query, token, videoID)
+	return token, err
+}
+
+// newShareToken returns a random URL-safe token for unlisted video links
+func newShareToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// HasWatchedVideo reports whether a student has a recorded view of a video
+func HasWatchedVideo(studentID, videoID int) (bool, error) {
+	query := `SELECT COUNT(*) FROM video_views WHERE student_id = ? AND video_id = ?`
+	var count int
+	err := DB.QueryRow(query, studentID, videoID).Scan(&count)
+	return count > 0, err
+}
+
+// GetPublicVideos lists published public videos from all teachers
+func GetPublicVideos() ([]models.Video, error) {
+	query := `
+		SELECT ` + videoColumns + `
+		FROM videos v
+		JOIN teachers t ON v.teacher_id = t.id
+		WHERE v.status = 'published' AND v.visibility = 'public'
+		ORDER BY COALESCE(v.published_at, v.created_at) DESC
+	`
+	rows, err := DB.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var videos []models.Video
+	for rows.Next() {
+		var video models.Video
+		err := scanVideo(rows, &video)
+		if err != nil {
+			return nil, err
+		}
+		videos = append(videos, video)
+	}
+	rows.Close()
+
+	err = attachCaptions(videos)
+	if err != nil {
+		return nil, err
+	}
+	return videos, nil
+}
+
+func UpdateVideoMetadata(videoID int, title, description string) error {
+	query := `UPDATE videos SET title = ?, description = ? WHERE id = ?`
+	_, err := DB.Exec(query, title, description, videoID)
+	return err
+}
+
+// ReplaceVideoFile points a video at a new media file. The current file is
+// kept as a revision and the storyboard of the old file is dropped.
+func ReplaceVideoFile(videoID int, filename, filePath string, duration int, fileSize int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO video_revisions (video_id, filename, file_path, duration, file_size)
+		SELECT id, filename, file_path, duration, file_size FROM videos WHERE id = ?
+	`, videoID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE videos SET filename = ?, file_path = ?, duration = ?, file_size = ? WHERE id = ?`,
+		filename, filePath, duration, fileSize, videoID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM storyboards WHERE video_id = ?`, videoID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func DeleteVideo(videoID int) error {
 	query := `DELETE FROM videos WHERE id = ?`
 	_, err := DB.Exec(query, videoID)
@@ -231,20 +467,130 @@ func Unsubscribe(studentID, teacherID int) error {
 }
 
 // Video view queries
-func RecordVideoView(studentID, videoID int) error {
-	query := `INSERT OR IGNORE INTO video_views (student_id, video_id) VALUES (?, ?)`
-	_, err := DB.Exec(query, studentID, videoID)
-	return err
+
+// RecordVideoView starts a watch session and counts it in the student's
+// summary for the video. A session of the student on the video active within
+// reuseWindow is returned instead, without counting a new view. It returns
+// the session ID and whether the view was new.
+func RecordVideoView(studentID, videoID int, device string, reuseWindow time.Duration) (int, bool, error) {
+	now := time.Now().UTC()
+
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	if reuseWindow > 0 {
+		var sessionID int
+		err = tx.QueryRow(`SELECT id FROM watch_sessions WHERE student_id = ? AND video_id = ? AND ended_at >= ? ORDER BY ended_at DESC, id DESC LIMIT 1`,
+			studentID, videoID, now.Add(-reuseWindow)).Scan(&sessionID)
+		if err == nil {
+			return sessionID, false, nil
+		}
+		if err != sql.ErrNoRows {
+			return 0, false, err
+		}
+	}
+
+	result, err := tx.Exec(`INSERT INTO watch_sessions (student_id, video_id, started_at, ended_at, device) VALUES (?, ?, ?, ?, ?)`,
+		studentID, videoID, now, now, device)
+	if err != nil {
+		return 0, false, err
+	}
+	sessionID, err := result.LastInsertId()
+	if err != nil {
+		return 0, false, err
+	}
+
+	query := `
+		INSERT INTO video_views (student_id, video_id, watched_at, last_watched_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(student_id, video_id) DO UPDATE SET
+			view_count = view_count + 1,
+			last_watched_at = excluded.last_watched_at
+	`
+	_, err = tx.Exec(query, studentID, videoID, now, now)
+	if err != nil {
+		return 0, false, err
+	}
+	return int(sessionID), true, tx.Commit()
+}
+
+// GetWatchSession returns a session of the given student and video
+func GetWatchSession(sessionID, studentID, videoID int) (*models.WatchSession, error) {
+	query := `
+		SELECT id, student_id, video_id, started_at, ended_at, device, seconds_watched
+		FROM watch_sessions WHERE id = ? AND student_id = ? AND video_id = ?
+	`
+	session := &models.WatchSession{}
+	err := DB.QueryRow(query, sessionID, studentID, videoID).Scan(&session.ID, &session.StudentID, &session.VideoID,
+		&session.StartedAt, &session.EndedAt, &session.Device, &session.SecondsWatched)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// AddWatchTime extends a session to now and adds seconds to its watch time
+// and to the student's summary for the video
+func AddWatchTime(session *models.WatchSession, seconds float64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE watch_sessions SET ended_at = ?, seconds_watched = seconds_watched + ? WHERE id = ?`,
+		time.Now().UTC(), seconds, session.ID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE video_views SET watch_time = watch_time + ? WHERE student_id = ? AND video_id = ?`,
+		seconds, session.StudentID, session.VideoID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetVideoSessions returns the latest watch sessions of a video
+func GetVideoSessions(videoID, limit int) ([]models.WatchSession, error) {
+	query := `
+		SELECT ws.id, ws.student_id, ws.video_id, ws.started_at, ws.ended_at, ws.device, ws.seconds_watched, s.name
+		FROM watch_sessions ws
+		JOIN students s ON ws.student_id = s.id
+		WHERE ws.video_id = ?
+		ORDER BY ws.started_at DESC, ws.id DESC
+		LIMIT ?
+	`
+	rows, err := DB.Query(query, videoID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.WatchSession{}
+	for rows.Next() {
+		var session models.WatchSession
+		err := rows.Scan(&session.ID, &session.StudentID, &session.VideoID, &session.StartedAt, &session.EndedAt,
+			&session.Device, &session.SecondsWatched, &session.StudentName)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
 }
 
 func GetVideoViewsByTeacherID(teacherID int) ([]models.VideoView, error) {
 	query := `
-		SELECT vv.id, vv.student_id, vv.video_id, vv.watched_at, v.title, s.name
+		SELECT vv.id, vv.student_id, vv.video_id, vv.watched_at, v.title, s.name,
+		       vv.last_watched_at, vv.view_count, vv.watch_time
 		FROM video_views vv
 		JOIN videos v ON vv.video_id = v.id
 		JOIN students s ON vv.student_id = s.id
 		WHERE v.teacher_id = ?
-		ORDER BY vv.watched_at DESC
+		ORDER BY COALESCE(vv.last_watched_at, vv.watched_at) DESC
 	`
 	rows, err := DB.Query(query, teacherID)
 	if err != nil {
@@ -255,20 +601,59 @@ func GetVideoViewsByTeacherID(teacherID int) ([]models.VideoView, error) {
 	var views []models.VideoView
 	for rows.Next() {
 		var view models.VideoView
-		err := rows.Scan(&view.ID, &view.StudentID, &view.VideoID, &view.WatchedAt, &view.VideoTitle, &view.StudentName)
+		var lastWatchedAt sql.NullTime
+		err := rows.Scan(&view.ID, &view.StudentID, &view.VideoID, &view.WatchedAt, &view.VideoTitle, &view.StudentName,
+			&lastWatchedAt, &view.ViewCount, &view.WatchTime)
 		if err != nil {
 			return nil, err
 		}
+		view.LastWatchedAt = view.WatchedAt
+		if lastWatchedAt.Valid {
+			view.LastWatchedAt = lastWatchedAt.Time
+		}
 		views = append(views, view)
 	}
 	return views, nil
 }
 
-func GetVideoViewCount(videoID int) (int, error) {
-	query := `SELECT COUNT(*) FROM video_views WHERE video_id = ?`
-	var count int
-	err := DB.QueryRow(query, videoID).Scan(&count)
-	return count, err
+// GetVideoViewCount returns a video's unique viewers, viewings and watch time
+func GetVideoViewCount(videoID int) (*models.ViewStats, error) {
+	query := `SELECT COUNT(*), COALESCE(SUM(view_count), 0), COALESCE(SUM(watch_time), 0) FROM video_views WHERE video_id = ?`
+	stats := &models.ViewStats{}
+	err := DB.QueryRow(query, videoID).Scan(&stats.UniqueViewers, &stats.TotalViews, &stats.WatchTime)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// GetTeacherVideoViewCounts returns the view statistics of each of a
+// teacher's watched videos, keyed by video ID
+func GetTeacherVideoViewCounts(teacherID int) (map[int]*models.ViewStats, error) {
+	query := `
+		SELECT vv.video_id, COUNT(*), SUM(vv.view_count), SUM(vv.watch_time)
+		FROM video_views vv
+		JOIN videos v ON vv.video_id = v.id
+		WHERE v.teacher_id = ?
+		GROUP BY vv.video_id
+	`
+	rows, err := DB.Query(query, teacherID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]*models.ViewStats)
+	for rows.Next() {
+		var videoID int
+		stats := &models.ViewStats{}
+		err := rows.Scan(&videoID, &stats.UniqueViewers, &stats.TotalViews, &stats.WatchTime)
+		if err != nil {
+			return nil, err
+		}
+		counts[videoID] = stats
+	}
+	return counts, nil
 }
 
 // Dashboard statistics queries
@@ -289,21 +674,27 @@ func GetDashboardStats(teacherID int) (*models.DashboardStats, error) {
 		return nil, err
 	}
 
-	// Total views
+	// Views: every viewing, distinct students and time spent watching
 	query = `
-		SELECT COUNT(*) FROM video_views vv
+		SELECT COALESCE(SUM(vv.view_count), 0), COUNT(DISTINCT vv.student_id), COALESCE(SUM(vv.watch_time), 0)
+		FROM video_views vv
 		JOIN videos v ON vv.video_id = v.id
 		WHERE v.teacher_id = ?
 	`
-	err = DB.QueryRow(query, teacherID).Scan(&stats.TotalViews)
+	err = DB.QueryRow(query, teacherID).Scan(&stats.TotalViews, &stats.UniqueViewers, &stats.TotalWatchTime)
+	if err != nil {
+		return nil, err
+	}
+
+	// Storage used by videos and pending uploads
+	stats.StorageUsed, err = GetTeacherStorageUsage(teacherID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Recent videos (last 5)
 	query = `
-		SELECT v.id, v.teacher_id, v.title, v.description, v.filename, v.file_path, 
-		       v.thumbnail_path, v.duration, v.file_size, v.created_at, t.name
+		SELECT ` + videoColumns + `
 		FROM videos v
 		JOIN teachers t ON v.teacher_id = t.id
 		WHERE v.teacher_id = ?
@@ -318,9 +709,7 @@ func GetDashboardStats(teacherID int) (*models.DashboardStats, error) {
 
 	for rows.Next() {
 		var video models.Video
-		err := rows.Scan(&video.ID, &video.TeacherID, &video.Title, &video.Description, 
-			&video.Filename, &video.FilePath, &video.ThumbnailPath, &video.Duration, 
-			&video.FileSize, &video.CreatedAt, &video.TeacherName)
+		err := scanVideo(rows, &video)
 		if err != nil {
 			return nil, err
 		}
@@ -373,4 +762,324 @@ func GetAllTeachers() ([]models.Teacher, error) {
 		teachers = append(teachers, teacher)
 	}
 	return teachers, nil
-}
\ No newline at end of file
+}
+
+// Resumable upload queries
+func CreateTusUpload(upload *models.TusUpload) error {
+	query := `INSERT INTO tus_uploads (id, teacher_id, filename, title, description, upload_length, upload_offset, partial_path, status, visibility, publish_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := DB.Exec(query, upload.ID, upload.TeacherID, upload.Filename, upload.Title, upload.Description, upload.Length, upload.Offset, upload.PartialPath,
+		upload.Publishing.Status, upload.Publishing.Visibility, upload.Publishing.PublishAt, upload.ExpiresAt)
+	return err
+}
+
+const tusUploadColumns = `id, teacher_id, filename, title, description, upload_length, upload_offset, partial_path, created_at,
+		       status, visibility, publish_at, expires_at`
+
+func scanTusUpload(row interface{ Scan(...interface{}) error }) (*models.TusUpload, error) {
+	upload := &models.TusUpload{}
+	var publishAt sql.NullTime
+	err := row.Scan(&upload.ID, &upload.TeacherID, &upload.Filename, &upload.Title, &upload.Description,
+		&upload.Length, &upload.Offset, &upload.PartialPath, &upload.CreatedAt,
+		&upload.Publishing.Status, &upload.Publishing.Visibility, &publishAt, &upload.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if publishAt.Valid {
+		upload.Publishing.PublishAt = &publishAt.Time
+	}
+	return upload, nil
+}
+
+func GetTusUpload(id string) (*models.TusUpload, error) {
+	query := `SELECT ` + tusUploadColumns + ` FROM tus_uploads WHERE id = ?`
+	return scanTusUpload(DB.QueryRow(query, id))
+}
+
+// GetTusUploads lists a teacher's unfinished uploads that have not expired,
+// newest first
+func GetTusUploads(teacherID int) ([]models.TusUpload, error) {
+	query := `SELECT ` + tusUploadColumns + ` FROM tus_uploads WHERE teacher_id = ? AND expires_at > ? ORDER BY created_at DESC`
+	return queryTusUploads(query, teacherID, time.Now().UTC())
+}
+
+// GetExpiredTusUploads lists uploads that expired before the given time
+func GetExpiredTusUploads(before time.Time) ([]models.TusUpload, error) {
+	query := `SELECT ` + tusUploadColumns + ` FROM tus_uploads WHERE expires_at <= ?`
+	return queryTusUploads(query, before)
+}
+
+func queryTusUploads(query string, args ...interface{}) ([]models.TusUpload, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uploads []models.TusUpload
+	for rows.Next() {
+		upload, err := scanTusUpload(rows)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, *upload)
+	}
+	return uploads, rows.Err()
+}
+
+// UpdateTusUploadOffset records the bytes received and pushes back the
+// upload's expiry
+func UpdateTusUploadOffset(id string, offset int64, expiresAt time.Time) error {
+	query := `UPDATE tus_uploads SET upload_offset = ?, expires_at = ? WHERE id = ?`
+	_, err := DB.Exec(query, offset, expiresAt, id)
+	return err
+}
+
+func DeleteTusUpload(id string) error {
+	query := `DELETE FROM tus_uploads WHERE id = ?`
+	_, err := DB.Exec(query, id)
+	return err
+}
+
+// Storyboard queries
+func SaveStoryboard(sb *models.Storyboard) error {
+	query := `
+		INSERT OR REPLACE INTO storyboards
+			(video_id, interval_seconds, tile_width, tile_height, columns, rows, frame_count, sheet_count, key_prefix)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err := DB.Exec(query, sb.VideoID, sb.Interval, sb.TileWidth, sb.TileHeight, sb.Columns, sb.Rows,
+		sb.FrameCount, sb.SheetCount, sb.KeyPrefix)
+	return err
+}
+
+func GetStoryboard(videoID int) (*models.Storyboard, error) {
+	query := `
+		SELECT video_id, interval_seconds, tile_width, tile_height, columns, rows, frame_count, sheet_count, key_prefix
+		FROM storyboards
+		WHERE video_id = ?
+	`
+	row := DB.QueryRow(query, videoID)
+
+	sb := &models.Storyboard{}
+	err := row.Scan(&sb.VideoID, &sb.Interval, &sb.TileWidth, &sb.TileHeight, &sb.Columns, &sb.Rows,
+		&sb.FrameCount, &sb.SheetCount, &sb.KeyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return sb, nil
+}
+
+// Caption queries
+func captionURL(videoID int, language string) string {
+	return fmt.Sprintf("/api/video/%d/captions/%s", videoID, language)
+}
+
+// SaveCaption inserts or replaces the caption track for a video and language.
+// It returns the storage key of the replaced track, if any.
+func SaveCaption(caption *models.Caption) (string, error) {
+	var oldPath string
+	query := `SELECT file_path FROM captions WHERE video_id = ? AND language = ?`
+	err := DB.QueryRow(query, caption.VideoID, caption.Language).Scan(&oldPath)
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	query = `
+		INSERT INTO captions (video_id, language, label, file_path) VALUES (?, ?, ?, ?)
+		ON CONFLICT(video_id, language) DO UPDATE SET label = excluded.label, file_path = excluded.file_path, created_at = CURRENT_TIMESTAMP
+	`
+	_, err = DB.Exec(query, caption.VideoID, caption.Language, caption.Label, caption.FilePath)
+	if err != nil {
+		return "", err
+	}
+	return oldPath, nil
+}
+
+func GetCaption(videoID int, language string) (*models.Caption, error) {
+	query := `SELECT id, video_id, language, label, file_path, created_at FROM captions WHERE video_id = ? AND language = ?`
+	row := DB.QueryRow(query, videoID, language)
+
+	caption := &models.Caption{}
+	err := row.Scan(&caption.ID, &caption.VideoID, &caption.Language, &caption.Label, &caption.FilePath, &caption.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	caption.URL = captionURL(caption.VideoID, caption.Language)
+	return caption, nil
+}
+
+func GetCaptionsByVideoID(videoID int) ([]models.Caption, error) {
+	query := `SELECT id, video_id, language, label, file_path, created_at FROM captions WHERE video_id = ? ORDER BY language`
+	rows, err := DB.Query(query, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var captions []models.Caption
+	for rows.Next() {
+		var caption models.Caption
+		err := rows.Scan(&caption.ID, &caption.VideoID, &caption.Language, &caption.Label, &caption.FilePath, &caption.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		caption.URL = captionURL(caption.VideoID, caption.Language)
+		captions = append(captions, caption)
+	}
+	return captions, nil
+}
+
+func DeleteCaption(videoID int, language string) error {
+	query := `DELETE FROM captions WHERE video_id = ? AND language = ?`
+	_, err := DB.Exec(query, videoID, language)
+	return err
+}
+
+// attachCaptions fills in the caption tracks of a list of videos with one query
+func attachCaptions(videos []models.Video) error {
+	if len(videos) == 0 {
+		return nil
+	}
+
+	index := make(map[int]int, len(videos))
+	placeholders := make([]string, len(videos))
+	args := make([]interface{}, len(videos))
+	for i, video := range videos {
+		index[video.ID] = i
+		placeholders[i] = "?"
+		args[i] = video.ID
+	}
+
+	query := `SELECT id, video_id, language, label, file_path, created_at FROM captions WHERE video_id IN (` +
+		strings.Join(placeholders, ", ") + `) ORDER BY language`
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var caption models.Caption
+		err := rows.Scan(&caption.ID, &caption.VideoID, &caption.Language, &caption.Label, &caption.FilePath, &caption.CreatedAt)
+		if err != nil {
+			return err
+		}
+		caption.URL = captionURL(caption.VideoID, caption.Language)
+		i := index[caption.VideoID]
+		videos[i].Captions = append(videos[i].Captions, caption)
+	}
+	return nil
+}
+
+// Chapter queries
+
+// ReplaceChapters swaps a video's chapters for a new set in one transaction
+func ReplaceChapters(videoID int, chapters []models.Chapter) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM chapters WHERE video_id = ?`, videoID)
+	if err != nil {
+		return err
+	}
+
+	for _, chapter := range chapters {
+		_, err = tx.Exec(`INSERT INTO chapters (video_id, title, start_time) VALUES (?, ?, ?)`,
+			videoID, chapter.Title, chapter.StartTime)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetChaptersByVideoID returns a video's chapters in order, with end times
+// derived from the following chapter or the video's duration
+func GetChaptersByVideoID(videoID, duration int) ([]models.Chapter, error) {
+	query := `SELECT id, video_id, title, start_time FROM chapters WHERE video_id = ? ORDER BY start_time`
+	rows, err := DB.Query(query, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chapters []models.Chapter
+	for rows.Next() {
+		var chapter models.Chapter
+		err := rows.Scan(&chapter.ID, &chapter.VideoID, &chapter.Title, &chapter.StartTime)
+		if err != nil {
+			return nil, err
+		}
+		chapters = append(chapters, chapter)
+	}
+
+	for i := range chapters {
+		if i+1 < len(chapters) {
+			chapters[i].EndTime = chapters[i+1].StartTime
+		} else {
+			chapters[i].EndTime = duration
+		}
+	}
+	return chapters, nil
+}
+
+// DeleteChaptersFrom removes chapters that start at or after a time, after the
+// video was replaced by a shorter file
+func DeleteChaptersFrom(videoID, startTime int) error {
+	query := `DELETE FROM chapters WHERE video_id = ? AND start_time >= ?`
+	_, err := DB.Exec(query, videoID, startTime)
+	return err
+}
+
+// Video revision queries
+func GetVideoRevisions(videoID int) ([]models.VideoRevision, error) {
+	query := `
+		SELECT id, video_id, filename, file_path, duration, file_size, replaced_at
+		FROM video_revisions
+		WHERE video_id = ?
+		ORDER BY replaced_at DESC, id DESC
+	`
+	rows, err := DB.Query(query, videoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []models.VideoRevision
+	for rows.Next() {
+		var revision models.VideoRevision
+		err := rows.Scan(&revision.ID, &revision.VideoID, &revision.Filename, &revision.FilePath,
+			&revision.Duration, &revision.FileSize, &revision.ReplacedAt)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, nil
+}
+
+func GetVideoRevision(videoID, revisionID int) (*models.VideoRevision, error) {
+	query := `
+		SELECT id, video_id, filename, file_path, duration, file_size, replaced_at
+		FROM video_revisions
+		WHERE id = ? AND video_id = ?
+	`
+	row := DB.QueryRow(query, revisionID, videoID)
+
+	revision := &models.VideoRevision{}
+	err := row.Scan(&revision.ID, &revision.VideoID, &revision.Filename, &revision.FilePath,
+		&revision.Duration, &revision.FileSize, &revision.ReplacedAt)
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+func DeleteVideoRevision(revisionID int) error {
+	query := `DELETE FROM video_revisions WHERE id = ?`
+	_, err := DB.Exec(query, revisionID)
+	return err
+}
@@ -0,0 +1,415 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"educational-platform/models"
+)
+
+// ErrNoAttemptsLeft is returned when a student has used every attempt at a quiz
+var ErrNoAttemptsLeft = errors.New("no attempts left")
+
+// ErrAttemptClosed is returned when an attempt is no longer in progress
+var ErrAttemptClosed = errors.New("attempt is not in progress")
+
+const quizColumns = `q.id, q.teacher_id, q.video_id, COALESCE(q.lesson_id, 0), COALESCE(l.course_id, 0),
+	COALESCE(q.category_id, 0), q.title,
+	COALESCE(q.description, ''), q.status, q.timestamp, q.max_attempts, q.time_limit, q.shuffle_questions,
+	q.pass_percent,
+	(SELECT COUNT(*) FROM quiz_questions qq WHERE qq.quiz_id = q.id),
+	(SELECT COALESCE(SUM(qq.points), 0) FROM quiz_questions qq WHERE qq.quiz_id = q.id),
+	q.created_at, q.updated_at`
+
+func scanQuiz(row interface{ Scan(...interface{}) error }, quiz *models.Quiz, extra ...interface{}) error {
+	dest := []interface{}{&quiz.ID, &quiz.TeacherID, &quiz.VideoID, &quiz.LessonID, &quiz.CourseID, &quiz.CategoryID, &quiz.Title,
+		&quiz.Description, &quiz.Status, &quiz.Timestamp, &quiz.MaxAttempts, &quiz.TimeLimit, &quiz.ShuffleQuestions,
+		&quiz.PassPercent, &quiz.QuestionCount, &quiz.MaxScore, &quiz.CreatedAt, &quiz.UpdatedAt}
+	return row.Scan(append(dest, extra...)...)
+}
+
+// Quiz queries
+
+// CreateQuiz adds a quiz to a video, or to a lesson when LessonID is set
+func CreateQuiz(quiz *models.Quiz) (int, error) {
+	var lessonID, categoryID interface{}
+	if quiz.LessonID != 0 {
+		lessonID = quiz.LessonID
+	}
+	if quiz.CategoryID != 0 {
+		categoryID = quiz.CategoryID
+	}
+	query := `
+		INSERT INTO quizzes (teacher_id, video_id, lesson_id, title, description, status, timestamp,
+		                     max_attempts, time_limit, shuffle_questions, pass_percent, category_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := DB.Exec(query, quiz.TeacherID, quiz.VideoID, lessonID, quiz.Title, quiz.Description, quiz.Status,
+		quiz.Timestamp, quiz.MaxAttempts, quiz.TimeLimit, quiz.ShuffleQuestions, quiz.PassPercent, categoryID)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func GetQuizByID(quizID int) (*models.Quiz, error) {
+	query := `SELECT ` + quizColumns + ` FROM quizzes q LEFT JOIN lessons l ON q.lesson_id = l.id WHERE q.id = ?`
+	quiz := &models.Quiz{}
+	err := scanQuiz(DB.QueryRow(query, quizID), quiz)
+	if err != nil {
+		return nil, err
+	}
+	return quiz, nil
+}
+
+// GetQuizzesByTeacherID lists a teacher's quizzes by video and timestamp
+func GetQuizzesByTeacherID(teacherID int) ([]models.Quiz, error) {
+	query := `
+		SELECT ` + quizColumns + `
+		FROM quizzes q
+		LEFT JOIN lessons l ON q.lesson_id = l.id
+		WHERE q.teacher_id = ?
+		ORDER BY q.video_id, q.timestamp, q.id
+	`
+	rows, err := DB.Query(query, teacherID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quizzes := []models.Quiz{}
+	for rows.Next() {
+		var quiz models.Quiz
+		err := scanQuiz(rows, &quiz)
+		if err != nil {
+			return nil, err
+		}
+		quizzes = append(quizzes, quiz)
+	}
+	return quizzes, nil
+}
+
+// GetStudentVideoQuizzes lists the published quizzes a student is offered
+// with a video: those on the video itself and those on its lessons in
+// courses the student is enrolled in. Each comes with a summary of the
+// student's attempts.
+func GetStudentVideoQuizzes(studentID, videoID int) ([]models.Quiz, error) {
+	query := `
+		SELECT ` + quizColumns + `,
+		       (SELECT COUNT(*) FROM quiz_attempts a WHERE a.quiz_id = q.id AND a.student_id = ?),
+		       (SELECT COALESCE(MAX(a.percent), 0) FROM quiz_attempts a WHERE a.quiz_id = q.id AND a.student_id = ?),
+		       EXISTS (SELECT 1 FROM quiz_attempts a WHERE a.quiz_id = q.id AND a.student_id = ? AND a.passed = 1),
+		       (SELECT COALESCE(MAX(a.id), 0) FROM quiz_attempts a
+		        WHERE a.quiz_id = q.id AND a.student_id = ? AND a.status = 'in_progress')
+		FROM quizzes q
+		LEFT JOIN lessons l ON q.lesson_id = l.id
+		WHERE q.video_id = ? AND q.status = 'published'
+		  AND (q.lesson_id IS NULL OR EXISTS (
+		      SELECT 1 FROM enrollments e
+		      JOIN courses c ON e.course_id = c.id
+		      WHERE e.course_id = l.course_id AND e.student_id = ? AND c.status = 'published'
+		        AND ` + activeEnrollmentCondition + `))
+		ORDER BY q.timestamp, q.id
+	`
+	rows, err := DB.Query(query, studentID, studentID, studentID, studentID, videoID, studentID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quizzes []models.Quiz
+	for rows.Next() {
+		var quiz models.Quiz
+		summary := &models.QuizAttemptSummary{}
+		err := scanQuiz(rows, &quiz, &summary.Used, &summary.BestPercent, &summary.Passed, &summary.InProgress)
+		if err != nil {
+			return nil, err
+		}
+		quiz.Attempts = summary
+		quizzes = append(quizzes, quiz)
+	}
+	return quizzes, nil
+}
+
+// GetQuizAttemptSummary sums up a student's attempts at a quiz
+func GetQuizAttemptSummary(quizID, studentID int) (*models.QuizAttemptSummary, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(MAX(percent), 0), COALESCE(MAX(passed), 0),
+		       COALESCE(MAX(CASE WHEN status = 'in_progress' THEN id END), 0)
+		FROM quiz_attempts
+		WHERE quiz_id = ? AND student_id = ?
+	`
+	summary := &models.QuizAttemptSummary{}
+	err := DB.QueryRow(query, quizID, studentID).Scan(&summary.Used, &summary.BestPercent, &summary.Passed, &summary.InProgress)
+	if err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// UpdateQuiz stores a quiz's title, description, settings and category
+func UpdateQuiz(quiz *models.Quiz) error {
+	var categoryID interface{}
+	if quiz.CategoryID != 0 {
+		categoryID = quiz.CategoryID
+	}
+	query := `
+		UPDATE quizzes
+		SET title = ?, description = ?, status = ?, timestamp = ?, max_attempts = ?, time_limit = ?,
+		    shuffle_questions = ?, pass_percent = ?, category_id = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`
+	_, err := DB.Exec(query, quiz.Title, quiz.Description, quiz.Status, quiz.Timestamp, quiz.MaxAttempts,
+		quiz.TimeLimit, quiz.ShuffleQuestions, quiz.PassPercent, categoryID, quiz.ID)
+	return err
+}
+
+// DeleteQuiz removes a quiz with its questions, attempts and the
+// prerequisites that require it
+func DeleteQuiz(quizID int) error {
+	query := `DELETE FROM quizzes WHERE id = ?`
+	_, err := DB.Exec(query, quizID)
+	return err
+}
+
+// Quiz question queries
+
+// GetQuizQuestions returns a quiz's questions, with answers, in order
+func GetQuizQuestions(quizID int) ([]models.QuizQuestion, error) {
+	query := `
+		SELECT id, position, type, prompt, options, answer, tolerance, points
+		FROM quiz_questions
+		WHERE quiz_id = ?
+		ORDER BY position
+	`
+	rows, err := DB.Query(query, quizID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	questions := []models.QuizQuestion{}
+	for rows.Next() {
+		var q models.QuizQuestion
+		var options, answer string
+		err := rows.Scan(&q.ID, &q.Position, &q.Type, &q.Prompt, &options, &answer, &q.Tolerance, &q.Points)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(options), &q.Options)
+		if err != nil {
+			return nil, err
+		}
+		q.Answer = json.RawMessage(answer)
+		questions = append(questions, q)
+	}
+	return questions, nil
+}
+
+// ReplaceQuizQuestions replaces all questions of a quiz. Questions with
+// the ID of one of the quiz's questions update it in place, so attempts in
+// progress keep it; the rest are added and missing ones removed. Finished
+// attempts keep their graded results.
+func ReplaceQuizQuestions(quizID int, questions []models.QuizQuestion) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	keep := []interface{}{quizID}
+	placeholders := ""
+	for _, q := range questions {
+		if q.ID != 0 {
+			keep = append(keep, q.ID)
+			placeholders += ", ?"
+		}
+	}
+	_, err = tx.Exec(`DELETE FROM quiz_questions WHERE quiz_id = ? AND id NOT IN (0`+placeholders+`)`, keep...)
+	if err != nil {
+		return err
+	}
+
+	for i, q := range questions {
+		options, err := json.Marshal(q.Options)
+		if err != nil {
+			return err
+		}
+		if q.Options == nil {
+			options = []byte("[]")
+		}
+		if q.ID != 0 {
+			_, err = tx.Exec(`UPDATE quiz_questions SET position = ?, type = ?, prompt = ?, options = ?, answer = ?, tolerance = ?, points = ? WHERE id = ? AND quiz_id = ?`,
+				i, q.Type, q.Prompt, string(options), string(q.Answer), q.Tolerance, q.Points, q.ID, quizID)
+		} else {
+			_, err = tx.Exec(`INSERT INTO quiz_questions (quiz_id, position, type, prompt, options, answer, tolerance, points) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				quizID, i, q.Type, q.Prompt, string(options), string(q.Answer), q.Tolerance, q.Points)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE quizzes SET updated_at = CURRENT_TIMESTAMP WHERE id = ?`, quizID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Quiz attempt queries
+
+const attemptColumns = `a.id, a.quiz_id, a.student_id, a.status, a.question_order, a.results, a.score, a.max_score,
+	a.percent, a.passed, a.started_at, a.expires_at, a.submitted_at`
+
+func scanAttempt(row interface{ Scan(...interface{}) error }, attempt *models.QuizAttempt, extra ...interface{}) error {
+	var order, results string
+	var expiresAt, submittedAt sql.NullTime
+	dest := []interface{}{&attempt.ID, &attempt.QuizID, &attempt.StudentID, &attempt.Status, &order, &results,
+		&attempt.Score, &attempt.MaxScore, &attempt.Percent, &attempt.Passed, &attempt.StartedAt, &expiresAt, &submittedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+	if expiresAt.Valid {
+		attempt.ExpiresAt = &expiresAt.Time
+	}
+	if submittedAt.Valid {
+		attempt.SubmittedAt = &submittedAt.Time
+	}
+	err = json.Unmarshal([]byte(order), &attempt.QuestionIDs)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(results), &attempt.Results)
+}
+
+// CreateQuizAttempt starts an attempt, refusing with ErrNoAttemptsLeft when
+// the student already has maxAttempts of them (0 for no limit) or has one
+// in progress
+func CreateQuizAttempt(attempt *models.QuizAttempt, maxAttempts int) (int, error) {
+	order, err := json.Marshal(attempt.QuestionIDs)
+	if err != nil {
+		return 0, err
+	}
+	query := `
+		INSERT INTO quiz_attempts (quiz_id, student_id, status, question_order, max_score, started_at, expires_at)
+		SELECT ?, ?, 'in_progress', ?, ?, ?, ?
+		WHERE NOT EXISTS (SELECT 1 FROM quiz_attempts WHERE quiz_id = ? AND student_id = ? AND status = 'in_progress')
+		  AND (? = 0 OR (SELECT COUNT(*) FROM quiz_attempts WHERE quiz_id = ? AND student_id = ?) < ?)
+	`
+	result, err := DB.Exec(query, attempt.QuizID, attempt.StudentID, string(order), attempt.MaxScore,
+		attempt.StartedAt, attempt.ExpiresAt, attempt.QuizID, attempt.StudentID,
+		maxAttempts, attempt.QuizID, attempt.StudentID, maxAttempts)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err == nil && n == 0 {
+		return 0, ErrNoAttemptsLeft
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetQuizAttempt returns an attempt at a quiz; studentID 0 matches any student
+func GetQuizAttempt(quizID, attemptID, studentID int) (*models.QuizAttempt, error) {
+	query := `
+		SELECT ` + attemptColumns + `
+		FROM quiz_attempts a
+		WHERE a.id = ? AND a.quiz_id = ? AND (? = 0 OR a.student_id = ?)
+	`
+	attempt := &models.QuizAttempt{}
+	err := scanAttempt(DB.QueryRow(query, attemptID, quizID, studentID, studentID), attempt)
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+// GetQuizAttempts lists the attempts at a quiz, newest first; studentID 0
+// lists every student's
+func GetQuizAttempts(quizID, studentID int) ([]models.QuizAttempt, error) {
+	query := `
+		SELECT ` + attemptColumns + `, s.name
+		FROM quiz_attempts a
+		JOIN students s ON a.student_id = s.id
+		WHERE a.quiz_id = ? AND (? = 0 OR a.student_id = ?)
+		ORDER BY a.started_at DESC, a.id DESC
+	`
+	rows, err := DB.Query(query, quizID, studentID, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []models.QuizAttempt{}
+	for rows.Next() {
+		var attempt models.QuizAttempt
+		err := scanAttempt(rows, &attempt, &attempt.StudentName)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, nil
+}
+
+// ExpireQuizAttempts closes the attempts at a quiz whose deadline passed
+// before cutoff without a submission
+func ExpireQuizAttempts(quizID int, cutoff time.Time) error {
+	query := `
+		UPDATE quiz_attempts SET status = 'expired'
+		WHERE quiz_id = ? AND status = 'in_progress' AND expires_at IS NOT NULL AND expires_at < ?
+	`
+	_, err := DB.Exec(query, quizID, cutoff)
+	return err
+}
+
+// SubmitQuizAttempt stores the graded results of an attempt, refusing with
+// ErrAttemptClosed when it is no longer in progress
+func SubmitQuizAttempt(attempt *models.QuizAttempt) error {
+	results, err := json.Marshal(attempt.Results)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE quiz_attempts
+		SET status = 'submitted', results = ?, score = ?, max_score = ?, percent = ?, passed = ?, submitted_at = ?
+		WHERE id = ? AND status = 'in_progress'
+	`
+	result, err := DB.Exec(query, string(results), attempt.Score, attempt.MaxScore, attempt.Percent,
+		attempt.Passed, attempt.SubmittedAt, attempt.ID)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err == nil && n == 0 {
+		err = ErrAttemptClosed
+	}
+	return err
+}
+
+// GetPassedQuizzes returns the IDs of the quizzes a student has passed
+func GetPassedQuizzes(studentID int) (map[int]bool, error) {
+	query := `SELECT DISTINCT quiz_id FROM quiz_attempts WHERE student_id = ? AND passed = 1`
+	rows, err := DB.Query(query, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	passed := make(map[int]bool)
+	for rows.Next() {
+		var quizID int
+		err := rows.Scan(&quizID)
+		if err != nil {
+			return nil, err
+		}
+		passed[quizID] = true
+	}
+	return passed, nil
+}
@@ -0,0 +1,268 @@
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"educational-platform/models"
+)
+
+// ErrTooManyWebhooks is returned when a teacher already has the most webhooks
+// allowed
+var ErrTooManyWebhooks = errors.New("too many webhooks")
+
+// NewWebhookSecret returns a random key for signing a webhook's payloads
+func NewWebhookSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+// NewWebhookEventID returns a random ID identifying one event across its
+// deliveries
+func NewWebhookEventID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "evt_" + hex.EncodeToString(b)
+}
+
+const webhookColumns = `id, teacher_id, url, description, events, active, created_at, updated_at`
+
+func scanWebhook(row interface{ Scan(...interface{}) error }) (*models.Webhook, error) {
+	w := &models.Webhook{}
+	var events string
+	err := row.Scan(&w.ID, &w.TeacherID, &w.URL, &w.Description, &events, &w.Active, &w.CreatedAt, &w.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return w, json.Unmarshal([]byte(events), &w.Events)
+}
+
+func CreateWebhook(w *models.Webhook, maxWebhooks int) (int, error) {
+	events, err := json.Marshal(w.Events)
+	if err != nil {
+		return 0, err
+	}
+	query := `
+		INSERT INTO webhooks (teacher_id, url, description, events, secret, active, created_at, updated_at)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?
+		WHERE (SELECT COUNT(*) FROM webhooks WHERE teacher_id = ?) < ?
+	`
+	result, err := DB.Exec(query, w.TeacherID, w.URL, w.Description, string(events), w.Secret, w.Active, w.CreatedAt,
+		w.UpdatedAt, w.TeacherID, maxWebhooks)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	if err == nil && n == 0 {
+		return 0, ErrTooManyWebhooks
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetWebhook returns a webhook without its secret
+func GetWebhook(webhookID int) (*models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = ?`
+	return scanWebhook(DB.QueryRow(query, webhookID))
+}
+
+// GetWebhookSecret returns the key a webhook's payloads are signed with
+func GetWebhookSecret(webhookID int) (string, error) {
+	var secret string
+	err := DB.QueryRow(`SELECT secret FROM webhooks WHERE id = ?`, webhookID).Scan(&secret)
+	return secret, err
+}
+
+// GetTeacherWebhooks lists a teacher's webhooks, oldest first. With
+// activeOnly, inactive ones are left out.
+func GetTeacherWebhooks(teacherID int, activeOnly bool) ([]models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE teacher_id = ? AND (? = 0 OR active = 1) ORDER BY id`
+	rows, err := DB.Query(query, teacherID, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, *w)
+	}
+	return webhooks, nil
+}
+
+func UpdateWebhook(w *models.Webhook) error {
+	events, err := json.Marshal(w.Events)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE webhooks SET url = ?, description = ?, events = ?, active = ?, updated_at = ? WHERE id = ?`
+	_, err = DB.Exec(query, w.URL, w.Description, string(events), w.Active, w.UpdatedAt, w.ID)
+	return err
+}
+
+func SetWebhookSecret(webhookID int, secret string) error {
+	query := `UPDATE webhooks SET secret = ?, updated_at = ? WHERE id = ?`
+	_, err := DB.Exec(query, secret, time.Now().UTC(), webhookID)
+	return err
+}
+
+// DeleteWebhook removes a webhook and its delivery log
+func DeleteWebhook(webhookID int) error {
+	query := `DELETE FROM webhooks WHERE id = ?`
+	_, err := DB.Exec(query, webhookID)
+	return err
+}
+
+// deliveryColumns selects a delivery (alias d) in the order scanDelivery
+// reads them; the payload and response body are added by detailed queries
+const deliveryColumns = `d.id, d.webhook_id, d.event_id, d.event, d.status, d.attempts, d.next_attempt_at,
+		       d.last_attempt_at, COALESCE(d.response_status, 0), d.error, COALESCE(d.duration_ms, 0),
+		       COALESCE(d.redelivery_of, 0), d.created_at`
+
+func scanDelivery(row interface{ Scan(...interface{}) error }, d *models.WebhookDelivery, extra ...interface{}) error {
+	var nextAttemptAt, lastAttemptAt sql.NullTime
+	dest := []interface{}{&d.ID, &d.WebhookID, &d.EventID, &d.Event, &d.Status, &d.Attempts, &nextAttemptAt,
+		&lastAttemptAt, &d.ResponseStatus, &d.Error, &d.DurationMS, &d.RedeliveryOf, &d.CreatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return err
+	}
+	if nextAttemptAt.Valid {
+		d.NextAttemptAt = &nextAttemptAt.Time
+	}
+	if lastAttemptAt.Valid {
+		d.LastAttemptAt = &lastAttemptAt.Time
+	}
+	return nil
+}
+
+// CreateDelivery queues an event for a webhook, due at d.NextAttemptAt
+func CreateDelivery(d *models.WebhookDelivery) (int, error) {
+	var redeliveryOf interface{}
+	if d.RedeliveryOf != 0 {
+		redeliveryOf = d.RedeliveryOf
+	}
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload, status, next_attempt_at, redelivery_of,
+		                                created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	result, err := DB.Exec(query, d.WebhookID, d.EventID, d.Event, string(d.Payload), d.Status, d.NextAttemptAt,
+		redeliveryOf, d.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+// GetDueDeliveries returns pending deliveries to active webhooks whose next
+// attempt is due, oldest first, with their payloads
+func GetDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + deliveryColumns + `, d.payload
+		FROM webhook_deliveries d
+		JOIN webhooks w ON d.webhook_id = w.id
+		WHERE d.status = 'pending' AND d.next_attempt_at <= ? AND w.active = 1
+		ORDER BY d.next_attempt_at, d.id
+		LIMIT ?
+	`
+	rows, err := DB.Query(query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var payload string
+		if err := scanDelivery(rows, &d, &payload); err != nil {
+			return nil, err
+		}
+		d.Payload = json.RawMessage(payload)
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+// RecordDeliveryAttempt saves the outcome of an attempt and when to retry
+func RecordDeliveryAttempt(d *models.WebhookDelivery) error {
+	var responseStatus interface{}
+	if d.ResponseStatus != 0 {
+		responseStatus = d.ResponseStatus
+	}
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, next_attempt_at = ?, last_attempt_at = ?, response_status = ?,
+		    response_body = ?, error = ?, duration_ms = ?
+		WHERE id = ?
+	`
+	_, err := DB.Exec(query, d.Status, d.Attempts, d.NextAttemptAt, d.LastAttemptAt, responseStatus, d.ResponseBody,
+		d.Error, d.DurationMS, d.ID)
+	return err
+}
+
+// GetWebhookDeliveries lists a webhook's deliveries newest first, optionally
+// only those with a status. beforeID pages back from an earlier result.
+func GetWebhookDeliveries(webhookID int, status string, beforeID, limit int) ([]models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries d
+		WHERE d.webhook_id = ? AND (? = '' OR d.status = ?) AND (? = 0 OR d.id < ?)
+		ORDER BY d.id DESC
+		LIMIT ?
+	`
+	rows, err := DB.Query(query, webhookID, status, status, beforeID, beforeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := scanDelivery(rows, &d); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+// GetWebhookDelivery returns one of a webhook's deliveries with its payload
+// and the endpoint's last response
+func GetWebhookDelivery(webhookID, deliveryID int) (*models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + deliveryColumns + `, d.payload, d.response_body
+		FROM webhook_deliveries d
+		WHERE d.id = ? AND d.webhook_id = ?
+	`
+	d := &models.WebhookDelivery{}
+	var payload string
+	err := scanDelivery(DB.QueryRow(query, deliveryID, webhookID), d, &payload, &d.ResponseBody)
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = json.RawMessage(payload)
+	return d, nil
+}
+
+// DeleteOldDeliveries removes finished deliveries created before a time
+func DeleteOldDeliveries(before time.Time) (int64, error) {
+	query := `DELETE FROM webhook_deliveries WHERE status != 'pending' AND created_at < ?`
+	result, err := DB.Exec(query, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,197 @@
+// Package events is an in-process bus that fans out real-time events to the
+// connections of the user they concern.
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	SubscriptionCreated = "subscription.created" // a student subscribed to the teacher
+	SubscriptionDeleted = "subscription.deleted" // a student unsubscribed from the teacher
+	VideoViewed         = "video.viewed"         // a student started watching one of the teacher's videos
+	VideoProcessed      = "video.processed"      // an upload was stored and its video created
+	StoryboardReady     = "video.storyboard_ready"
+	NotificationCreated = "notification"
+	MessageCreated      = "message.created" // a new message in one of the user's conversations
+	MessageRead         = "message.read"    // the other side read the user's messages
+
+	// Sent by the stream handlers rather than published
+	Ready = "ready" // first event of a new connection
+	Reset = "reset" // events were missed while disconnected; refetch state
+)
+
+// Bus limits
+const (
+	historySize = 10000 // events kept for replay, across all users
+	bufferSize  = 64    // events queued per connection before it is dropped
+	maxStreams  = 10    // open connections per user
+)
+
+// ErrTooManyStreams is returned when a user already has maxStreams open
+var ErrTooManyStreams = errors.New("too many open event streams")
+
+// User identifies who an event is for
+type User struct {
+	Type string // teacher or student
+	ID   int
+}
+
+// Event is one real-time update. IDs increase across the whole bus, so a
+// client can resume after the last ID it saw.
+type Event struct {
+	ID   int64           `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+	Time time.Time       `json:"time"`
+
+	user User
+}
+
+// Subscription receives a user's events for one connection
+type Subscription struct {
+	// Replay holds the events published since the client's last event ID
+	Replay []Event
+	// Reset is set when events the client asked for are no longer kept,
+	// or were published before the server restarted
+	Reset bool
+	// Cursor is the last event ID published before the subscription started
+	Cursor int64
+
+	ch   chan Event
+	user User
+	bus  *Bus
+}
+
+// Events delivers new events. It is closed if the connection falls
+// bufferSize events behind; the client can reconnect to catch up from the
+// replay history.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Close stops delivery to the subscription
+func (s *Subscription) Close() {
+	b := s.bus
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if subs := b.subs[s.user]; subs != nil {
+		delete(subs, s)
+		if len(subs) == 0 {
+			delete(b.subs, s.user)
+		}
+	}
+}
+
+// Bus keeps recent events and the open subscriptions
+type Bus struct {
+	mu      sync.Mutex
+	startID int64 // IDs below this were issued before the server started
+	lastID  int64
+	history []Event // ring buffer; event ID n is at (n - startID) % historySize
+	subs    map[User]map[*Subscription]struct{}
+}
+
+// NewBus returns an empty bus. Event IDs start from the current time in
+// milliseconds, so IDs handed out before a restart are recognized as stale.
+func NewBus() *Bus {
+	start := time.Now().UnixMilli()
+	return &Bus{
+		startID: start,
+		lastID:  start - 1,
+		history: make([]Event, 0, historySize),
+		subs:    make(map[User]map[*Subscription]struct{}),
+	}
+}
+
+// Default is the bus used by the application
+var Default = NewBus()
+
+// Publish sends an event to the user's open connections and keeps it for
+// replay. data is encoded as JSON straight away.
+func (b *Bus) Publish(user User, eventType string, data interface{}) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	ev := Event{ID: b.lastID, Type: eventType, Data: raw, Time: time.Now().UTC(), user: user}
+	if len(b.history) < historySize {
+		b.history = append(b.history, ev)
+	} else {
+		b.history[(ev.ID-b.startID)%historySize] = ev
+	}
+
+	for sub := range b.subs[user] {
+		select {
+		case sub.ch <- ev:
+		default:
+			// Too slow to keep up; drop the connection rather than
+			// block publishers or buffer without limit
+			close(sub.ch)
+			delete(b.subs[user], sub)
+		}
+	}
+	if len(b.subs[user]) == 0 {
+		delete(b.subs, user)
+	}
+}
+
+// Subscribe opens a subscription to a user's events. With a lastEventID it
+// also returns the user's events published after it, or Reset if some of them
+// are no longer kept.
+func (b *Bus) Subscribe(user User, lastEventID int64) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.subs[user]) >= maxStreams {
+		return nil, ErrTooManyStreams
+	}
+
+	sub := &Subscription{
+		Cursor: b.lastID,
+		ch:     make(chan Event, bufferSize),
+		user:   user,
+		bus:    b,
+	}
+
+	if lastEventID != 0 {
+		oldest := b.lastID - int64(len(b.history)) + 1
+		if lastEventID < b.startID-1 || lastEventID > b.lastID || lastEventID < oldest-1 {
+			sub.Reset = true
+		} else {
+			for id := lastEventID + 1; id <= b.lastID; id++ {
+				ev := b.history[(id-b.startID)%historySize]
+				if ev.user == user {
+					sub.Replay = append(sub.Replay, ev)
+				}
+			}
+		}
+	}
+
+	if b.subs[user] == nil {
+		b.subs[user] = make(map[*Subscription]struct{})
+	}
+	b.subs[user][sub] = struct{}{}
+	return sub, nil
+}
+
+// Publish sends an event on the default bus
+func Publish(user User, eventType string, data interface{}) {
+	Default.Publish(user, eventType, data)
+}
+
+// Subscribe opens a subscription on the default bus
+func Subscribe(user User, lastEventID int64) (*Subscription, error) {
+	return Default.Subscribe(user, lastEventID)
+}
@@ -5,6 +5,9 @@ go 1.25.0
 require (
 	github.com/gofiber/fiber/v3 v3.0.0-rc.2
 	github.com/mattn/go-sqlite3 v1.14.22
+	github.com/valyala/fasthttp v1.65.0
+	golang.org/x/image v0.31.0
+	golang.org/x/text v0.29.0
 )
 
 require (
@@ -18,9 +21,7 @@ require (
 	github.com/philhofer/fwd v1.2.0 // indirect
 	github.com/tinylib/msgp v1.4.0 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
-	github.com/valyala/fasthttp v1.65.0 // indirect
 	golang.org/x/crypto v0.42.0 // indirect
 	golang.org/x/net v0.44.0 // indirect
 	golang.org/x/sys v0.36.0 // indirect
-	golang.org/x/text v0.29.0 // indirect
 )
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"time"
+
+	"educational-platform/database"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// checkVideoAccess reports whether the current request may watch a video's
+// media. Teachers always see their own videos. Everyone else needs a live
+// video (published, or scheduled and due) and then, by visibility: nothing
+// for public videos, the ?token= link token for unlisted ones, or a
+// subscription to the teacher. Videos that are lessons of a published course
+// need an active enrollment in such a course instead of a subscription.
+// Private videos are for the teacher alone.
+// Archived videos stay available to students who already watched them.
+//
+// It works on public routes by loading the session itself. When access is
+// denied the error response has already been written.
+func checkVideoAccess(c fiber.Ctx, video *models.Video) (bool, error) {
+	loggedIn := loadSession(c)
+	var userID int
+	var userType string
+	if loggedIn {
+		userID = c.Locals("user_id").(int)
+		userType = c.Locals("user_type").(string)
+	}
+
+	if userType == "teacher" && video.TeacherID == userID {
+		return true, nil
+	}
+
+	// Hidden videos look the same as missing ones
+	notFound := func() (bool, error) {
+		return false, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Video not found",
+		})
+	}
+
+	switch video.Status {
+	case models.VideoStatusPublished:
+	case models.VideoStatusScheduled:
+		// The scheduler may not have run yet
+		if video.PublishAt == nil || video.PublishAt.After(time.Now()) {
+			return notFound()
+		}
+	case models.VideoStatusArchived:
+		if userType != "student" {
+			return notFound()
+		}
+		watched, err := database.HasWatchedVideo(userID, video.ID)
+		if err != nil {
+			return false, c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Message: "Failed to check access",
+			})
+		}
+		if !watched {
+			return notFound()
+		}
+	default:
+		return notFound()
+	}
+
+	switch video.Visibility {
+	case models.VisibilityPublic:
+		return true, nil
+
+	case models.VisibilityUnlisted:
+		token := c.Query("token")
+		if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(video.ShareToken)) == 1 {
+			return true, nil
+		}
+		return notFound()
+
+	case models.VisibilitySubscribers:
+		if !loggedIn {
+			return false, c.Status(401).JSON(models.APIResponse{
+				Success: false,
+				Message: "Not authenticated",
+			})
+		}
+		if userType != "student" {
+			return false, c.Status(403).JSON(models.APIResponse{
+				Success: false,
+				Message: "Not authorized to access this video",
+			})
+		}
+
+		// Videos in published courses need an enrollment in one of them;
+		// a subscription to the teacher is not enough
+		inCourse, enrolled, err := database.GetVideoCourseAccess(userID, video.ID)
+		if err != nil {
+			return false, c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Message: "Failed to check enrollment",
+			})
+		}
+		if inCourse {
+			if !enrolled {
+				return false, c.Status(403).JSON(models.APIResponse{
+					Success: false,
+					Message: "You must enroll in a course containing this video to watch it",
+				})
+			}
+			return true, nil
+		}
+
+		subscribed, err := database.IsSubscribed(userID, video.TeacherID)
+		if err != nil {
+			return false, c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Message: "Failed to check subscription",
+			})
+		}
+		if !subscribed {
+			return false, c.Status(403).JSON(models.APIResponse{
+				Success: false,
+				Message: "You must subscribe to this teacher to watch their videos",
+			})
+		}
+		return true, nil
+	}
+
+	return notFound()
+}
+
+// hideShareTokens clears link tokens from videos before they are shown to
+// anyone but their teacher
+func hideShareTokens(videos []models.Video) {
+	for i := range videos {
+		videos[i].ShareToken = ""
+	}
+}
+
+// mediaCacheControl lets shared caches keep media of public videos only
+func mediaCacheControl(video *models.Video, maxAge int) string {
+	if video.Status == models.VideoStatusPublished && video.Visibility == models.VisibilityPublic {
+		return fmt.Sprintf("public, max-age=%d", maxAge)
+	}
+	return fmt.Sprintf("private, max-age=%d", maxAge)
+}
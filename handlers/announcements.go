@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"educational-platform/database"
+	"educational-platform/mail"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// maxAnnouncementBody is the longest announcement body, in characters
+const maxAnnouncementBody = 10000
+
+// announcementCheckInterval is how often the scheduler looks for due
+// announcements
+const announcementCheckInterval = 30 * time.Second
+
+// applyAnnouncementSettings validates an announcement request and applies it.
+// The send time and email delivery can only change before it is sent.
+func applyAnnouncementSettings(a *models.Announcement, req *models.AnnouncementRequest) error {
+	if req.Title != nil {
+		title, err := checkTitle(*req.Title)
+		if err != nil {
+			return err
+		}
+		a.Title = title
+	}
+	if req.Body != nil {
+		a.Body = strings.TrimSpace(*req.Body)
+	}
+	if req.Pinned != nil {
+		a.Pinned = *req.Pinned
+	}
+	if a.SentAt != nil && (req.Email != nil || req.SendAt != nil) {
+		return errors.New("The send time and email delivery cannot change after an announcement is sent")
+	}
+	if req.Email != nil {
+		a.Email = *req.Email
+	}
+	if req.SendAt != nil {
+		if !req.SendAt.After(time.Now()) {
+			return errors.New("Send time must be in the future")
+		}
+		sendAt := req.SendAt.UTC()
+		a.SendAt = &sendAt
+	}
+
+	if a.Title == "" {
+		return errors.New("Title is required")
+	}
+	if a.Body == "" {
+		return errors.New("Body is required")
+	}
+	if utf8.RuneCountInString(a.Body) > maxAnnouncementBody {
+		return fmt.Errorf("Body can be at most %d characters", maxAnnouncementBody)
+	}
+	return nil
+}
+
+// deliverAnnouncement sends an announcement to the teacher's current
+// subscribers and notifies them, emailing them in the background if it asks
+// for email.
+// Announcements that were already sent are left alone.
+func deliverAnnouncement(announcementID int) error {
+	a, err := database.GetAnnouncement(announcementID)
+	if err != nil {
+		return err
+	}
+	studentIDs, err := subscriberIDs(a.TeacherID)
+	if err != nil {
+		return err
+	}
+
+	delivered, err := database.DeliverAnnouncement(a.ID, studentIDs, time.Now().UTC())
+	if err != nil || !delivered {
+		return err
+	}
+	notifyAnnouncement(a, studentIDs)
+	if a.Email {
+		go emailAnnouncement(a, studentIDs)
+	}
+	return nil
+}
+
+func emailAnnouncement(a *models.Announcement, studentIDs []int) {
+	subject := fmt.Sprintf("[%s] %s", a.TeacherName, a.Title)
+	text := a.Body + "\n\n--\nYou received this announcement because you are subscribed to " + a.TeacherName + "."
+	for _, studentID := range studentIDs {
+		student, err := database.GetStudentByID(studentID)
+		if err != nil {
+			continue
+		}
+		err = mail.Sender.Send(&mail.Message{To: student.Email, Subject: subject, Text: text})
+		if err != nil {
+			log.Printf("Failed to email announcement %d to student %d: %v", a.ID, studentID, err)
+		}
+	}
+}
+
+// StartAnnouncementScheduler sends scheduled announcements in the background
+// once their send time has passed
+func StartAnnouncementScheduler() {
+	go func() {
+		sendDueAnnouncements()
+		ticker := time.NewTicker(announcementCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			sendDueAnnouncements()
+		}
+	}()
+}
+
+func sendDueAnnouncements() {
+	ids, err := database.GetDueAnnouncements(time.Now().UTC())
+	if err != nil {
+		log.Printf("Failed to check scheduled announcements: %v", err)
+		return
+	}
+	for _, id := range ids {
+		if err := deliverAnnouncement(id); err != nil {
+			log.Printf("Failed to send announcement %d: %v", id, err)
+			continue
+		}
+		log.Printf("Sent scheduled announcement %d", id)
+	}
+}
+
+// getOwnedAnnouncement loads the :id announcement if the teacher wrote it
+func getOwnedAnnouncement(c fiber.Ctx) (*models.Announcement, error) {
+	userID := c.Locals("user_id").(int)
+	announcementID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid announcement ID",
+		})
+	}
+
+	a, err := database.GetAnnouncement(announcementID)
+	if err != nil || a.TeacherID != userID {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Announcement not found",
+		})
+	}
+	return a, nil
+}
+
+// Write an announcement to subscribers, sent now or at send_at
+func CreateAnnouncementHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	var req models.AnnouncementRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	now := time.Now().UTC()
+	a := &models.Announcement{
+		TeacherID: userID,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := applyAnnouncementSettings(a, &req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	id, err := database.CreateAnnouncement(a)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to create announcement",
+		})
+	}
+	message := "Announcement scheduled successfully"
+	if a.SendAt == nil {
+		message = "Announcement sent successfully"
+		if err := deliverAnnouncement(id); err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Message: "Failed to send announcement",
+			})
+		}
+	}
+
+	a, err = database.GetAnnouncement(id)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get announcement",
+		})
+	}
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    a,
+	})
+}
+
+// List the teacher's announcements with how many subscribers read them
+func GetAnnouncementsHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	announcements, err := database.GetTeacherAnnouncements(userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get announcements",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    announcements,
+	})
+}
+
+// Get one of the teacher's announcements
+func GetAnnouncementHandler(c fiber.Ctx) error {
+	a, err := getOwnedAnnouncement(c)
+	if a == nil {
+		return err
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    a,
+	})
+}
+
+// Edit an announcement. Its send time and email delivery can change until it
+// is sent.
+func UpdateAnnouncementHandler(c fiber.Ctx) error {
+	a, err := getOwnedAnnouncement(c)
+	if a == nil {
+		return err
+	}
+
+	var req models.AnnouncementRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+	if err := applyAnnouncementSettings(a, &req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+	a.UpdatedAt = time.Now().UTC()
+
+	err = database.UpdateAnnouncement(a)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update announcement",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Announcement updated successfully",
+		Data:    a,
+	})
+}
+
+// Send a scheduled announcement now instead of waiting for its send time
+func SendAnnouncementHandler(c fiber.Ctx) error {
+	a, err := getOwnedAnnouncement(c)
+	if a == nil {
+		return err
+	}
+	if a.SentAt != nil {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Message: "Announcement has already been sent",
+		})
+	}
+
+	if err := deliverAnnouncement(a.ID); err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to send announcement",
+		})
+	}
+
+	a, err = database.GetAnnouncement(a.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get announcement",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Announcement sent successfully",
+		Data:    a,
+	})
+}
+
+// Delete an announcement, removing it from every student's list
+func DeleteAnnouncementHandler(c fiber.Ctx) error {
+	a, err := getOwnedAnnouncement(c)
+	if a == nil {
+		return err
+	}
+
+	err = database.DeleteAnnouncement(a.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to delete announcement",
+		})
+	}
+	database.DeleteSourceNotifications(models.NotificationAnnouncement, a.ID)
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Announcement deleted successfully",
+	})
+}
+
+// List the announcements sent to the student, ?unread=true for unread only
+func GetStudentAnnouncementsHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	announcements, err := database.GetStudentAnnouncements(userID, c.Query("unread") == "true")
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get announcements",
+		})
+	}
+
+	unread := 0
+	for _, a := range announcements {
+		if a.ReadAt == nil {
+			unread++
+		}
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"announcements": announcements,
+			"unread":        unread,
+		},
+	})
+}
+
+// setAnnouncementRead marks the student's :id announcement read or unread
+func setAnnouncementRead(c fiber.Ctx, read bool) error {
+	userID := c.Locals("user_id").(int)
+	announcementID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid announcement ID",
+		})
+	}
+
+	err = database.SetAnnouncementRead(announcementID, userID, read)
+	if err != nil {
+		status, message := 500, "Failed to update announcement"
+		if err == sql.ErrNoRows {
+			status, message = 404, "Announcement not found"
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	message := "Announcement marked as read"
+	if !read {
+		message = "Announcement marked as unread"
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: message,
+	})
+}
+
+// Mark an announcement as read
+func MarkAnnouncementReadHandler(c fiber.Ctx) error {
+	return setAnnouncementRead(c, true)
+}
+
+// Mark an announcement as unread
+func MarkAnnouncementUnreadHandler(c fiber.Ctx) error {
+	return setAnnouncementRead(c, false)
+}
+
+// Mark every announcement sent to the student as read
+func MarkAllAnnouncementsReadHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	count, err := database.MarkAllAnnouncementsRead(userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update announcements",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "All announcements marked as read",
+		Data: map[string]interface{}{
+			"marked": count,
+		},
+	})
+}
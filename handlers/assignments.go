@@ -0,0 +1,896 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"educational-platform/database"
+	"educational-platform/models"
+	"educational-platform/storage"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Assignment limits
+const (
+	maxRubricCriteria = 20
+	maxSubmissionText = 50000 // characters
+	maxFeedback       = 5000  // characters
+	maxSubmissionName = 255   // characters in an uploaded file's name, also for message attachments
+	defaultMaxPoints  = 100
+)
+
+// roundScore rounds a score to one decimal place
+func roundScore(score float64) float64 {
+	return math.Round(score*10) / 10
+}
+
+// latePenalty returns the percentage taken off a submission made at the
+// given time: late_penalty for every started day past the due date, up to 100
+func latePenalty(assignment *models.Assignment, submittedAt time.Time) float64 {
+	if assignment.LatePolicy != models.LatePolicyPenalty || assignment.DueAt == nil ||
+		!submittedAt.After(*assignment.DueAt) {
+		return 0
+	}
+	days := math.Ceil(submittedAt.Sub(*assignment.DueAt).Hours() / 24)
+	return math.Min(days*assignment.LatePenalty, 100)
+}
+
+// studentStatus sums up where a student stands from their submissions,
+// newest first
+func studentStatus(assignment *models.Assignment, status *models.AssignmentStudentStatus, submissions []models.AssignmentSubmission) {
+	status.SubmissionCount = len(submissions)
+	switch {
+	case len(submissions) > 0:
+		latest := submissions[0]
+		status.Submission = &latest
+		status.Late = latest.Late
+		status.Status = models.SubmissionStatusSubmitted
+		if latest.Graded {
+			status.Status = models.SubmissionStatusGraded
+		}
+	case assignment.DueAt != nil && time.Now().After(*assignment.DueAt):
+		status.Status = models.SubmissionStatusMissing
+		status.Late = true
+	default:
+		status.Status = models.SubmissionStatusNotSubmitted
+	}
+}
+
+// uploadedFileName cleans the name of an uploaded file for display and
+// returns it with an extension safe to use in a storage key
+func uploadedFileName(filename, fallback string) (string, string) {
+	name := strings.TrimSpace(path.Base(strings.ReplaceAll(filename, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		name = fallback
+	}
+	if utf8.RuneCountInString(name) > maxSubmissionName {
+		name = string([]rune(name)[:maxSubmissionName])
+	}
+	ext := strings.ToLower(path.Ext(name))
+	if len(ext) > 10 || strings.ContainsAny(ext, " /?#%") {
+		ext = ""
+	}
+	return name, ext
+}
+
+// setSubmissionURL points a submitted file at the download route under base
+func setSubmissionURL(sub *models.AssignmentSubmission, base string) {
+	if sub.FilePath != "" {
+		sub.FileURL = fmt.Sprintf("%s/assignments/%d/submissions/%d/file", base, sub.AssignmentID, sub.ID)
+	}
+}
+
+func setSubmissionURLs(submissions []models.AssignmentSubmission, base string) {
+	for i := range submissions {
+		setSubmissionURL(&submissions[i], base)
+	}
+}
+
+// deleteSubmissionFiles removes submitted files from storage
+func deleteSubmissionFiles(keys []string) {
+	for _, key := range keys {
+		storage.Store.Delete(key)
+	}
+}
+
+// getOwnedAssignment loads the assignment named by the :id parameter and
+// checks that it belongs to the current teacher. On failure the error
+// response has already been written and the returned assignment is nil.
+func getOwnedAssignment(c fiber.Ctx) (*models.Assignment, error) {
+	userID := c.Locals("user_id").(int)
+	assignmentID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid assignment ID",
+		})
+	}
+
+	assignment, err := database.GetAssignmentByID(assignmentID)
+	if err != nil {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Assignment not found",
+		})
+	}
+
+	if assignment.TeacherID != userID {
+		return nil, c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: "Not authorized to modify this assignment",
+		})
+	}
+
+	return assignment, nil
+}
+
+// getStudentAssignment loads the assignment named by the :id parameter if
+// the current student is set it. On failure the error response has already
+// been written and the returned assignment is nil.
+func getStudentAssignment(c fiber.Ctx) (*models.Assignment, error) {
+	userID := c.Locals("user_id").(int)
+	assignmentID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid assignment ID",
+		})
+	}
+
+	assignment, err := database.GetStudentAssignment(assignmentID, userID)
+	if err == sql.ErrNoRows {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Assignment not found",
+		})
+	}
+	if err != nil {
+		return nil, c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get assignment",
+		})
+	}
+
+	return assignment, nil
+}
+
+// getAssignmentSubmission loads the :submission_id submission to an
+// assignment; studentID 0 allows any student's
+func getAssignmentSubmission(c fiber.Ctx, assignment *models.Assignment, studentID int) (*models.AssignmentSubmission, error) {
+	submissionID, err := strconv.Atoi(c.Params("submission_id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid submission ID",
+		})
+	}
+
+	sub, err := database.GetSubmission(assignment.ID, submissionID, studentID)
+	if err != nil {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Submission not found",
+		})
+	}
+	return sub, nil
+}
+
+// validateRubric checks rubric criteria and returns them trimmed. Errors
+// number criteria from 1.
+func validateRubric(criteria []models.RubricCriterion) ([]models.RubricCriterion, error) {
+	if len(criteria) > maxRubricCriteria {
+		return nil, fmt.Errorf("A rubric can have at most %d criteria", maxRubricCriteria)
+	}
+
+	rubric := make([]models.RubricCriterion, 0, len(criteria))
+	for i, criterion := range criteria {
+		title := strings.TrimSpace(criterion.Title)
+		switch {
+		case title == "":
+			return nil, fmt.Errorf("Criterion %d: title is required", i+1)
+		case utf8.RuneCountInString(title) > maxCourseTitle:
+			return nil, fmt.Errorf("Criterion %d: title is too long", i+1)
+		case criterion.Points <= 0:
+			return nil, fmt.Errorf("Criterion %d: points must be positive", i+1)
+		}
+		rubric = append(rubric, models.RubricCriterion{
+			Title:       title,
+			Description: strings.TrimSpace(criterion.Description),
+			Points:      criterion.Points,
+		})
+	}
+	return rubric, nil
+}
+
+// applyAssignmentSettings copies the settings of a request onto an
+// assignment and checks them. A course must belong to the assignment's
+// teacher.
+func applyAssignmentSettings(assignment *models.Assignment, req *models.AssignmentRequest) error {
+	var err error
+	if req.Title != nil {
+		assignment.Title, err = checkTitle(*req.Title)
+		if err != nil {
+			return err
+		}
+	}
+	if req.Description != nil {
+		assignment.Description = *req.Description
+	}
+	if req.CourseID != nil {
+		if *req.CourseID != 0 {
+			course, err := database.GetCourseByID(*req.CourseID)
+			if err != nil || course.TeacherID != assignment.TeacherID {
+				return errors.New("Course not found")
+			}
+		}
+		assignment.CourseID = *req.CourseID
+	}
+	if req.Status != nil {
+		if *req.Status != models.AssignmentStatusDraft && *req.Status != models.AssignmentStatusPublished {
+			return errors.New("Status must be draft or published")
+		}
+		assignment.Status = *req.Status
+	}
+	if req.DueAt != nil {
+		assignment.DueAt, err = parseOptionalTime(*req.DueAt)
+		if err != nil {
+			return errors.New("Invalid due date, use RFC 3339 format")
+		}
+	}
+	if req.LateUntil != nil {
+		assignment.LateUntil, err = parseOptionalTime(*req.LateUntil)
+		if err != nil {
+			return errors.New("Invalid late submission cutoff, use RFC 3339 format")
+		}
+	}
+	if req.LatePolicy != nil {
+		switch *req.LatePolicy {
+		case models.LatePolicyAllow, models.LatePolicyPenalty, models.LatePolicyReject:
+			assignment.LatePolicy = *req.LatePolicy
+		default:
+			return errors.New("Late policy must be allow, penalty or reject")
+		}
+	}
+	if req.LatePenalty != nil {
+		if *req.LatePenalty < 0 || *req.LatePenalty > 100 {
+			return errors.New("Late penalty must be between 0 and 100")
+		}
+		assignment.LatePenalty = *req.LatePenalty
+	}
+	if req.SubmissionType != nil {
+		switch *req.SubmissionType {
+		case models.SubmissionText, models.SubmissionFile, models.SubmissionAny:
+			assignment.SubmissionType = *req.SubmissionType
+		default:
+			return errors.New("Submission type must be text, file or any")
+		}
+	}
+	if req.MaxSubmissions != nil {
+		if *req.MaxSubmissions < 0 {
+			return errors.New("Maximum submissions cannot be negative")
+		}
+		assignment.MaxSubmissions = *req.MaxSubmissions
+	}
+	if req.ResubmitAfterGrading != nil {
+		assignment.ResubmitAfterGrading = *req.ResubmitAfterGrading
+	}
+	if req.Rubric != nil {
+		assignment.Rubric, err = validateRubric(*req.Rubric)
+		if err != nil {
+			return err
+		}
+	}
+	if req.MaxPoints != nil {
+		if *req.MaxPoints <= 0 {
+			return errors.New("Maximum points must be positive")
+		}
+		assignment.MaxPoints = *req.MaxPoints
+	}
+
+	// A rubric sets the points; without one max_points does
+	if len(assignment.Rubric) > 0 {
+		total := 0.0
+		for _, criterion := range assignment.Rubric {
+			total += criterion.Points
+		}
+		assignment.MaxPoints = total
+	}
+
+	if req.CategoryID != nil {
+		assignment.CategoryID = *req.CategoryID
+	}
+	if assignment.CategoryID != 0 {
+		err = checkGradeCategory(assignment.TeacherID, assignment.CourseID, assignment.CategoryID)
+		if err != nil {
+			if req.CategoryID != nil {
+				return err
+			}
+			// Moving to another course's gradebook leaves the old category behind
+			assignment.CategoryID = 0
+		}
+	}
+
+	if assignment.LateUntil != nil {
+		if assignment.DueAt == nil {
+			return errors.New("A late submission cutoff needs a due date")
+		}
+		if !assignment.LateUntil.After(*assignment.DueAt) {
+			return errors.New("The late submission cutoff must be after the due date")
+		}
+	}
+	return nil
+}
+
+// Create an assignment for the teacher's subscribers or one of their courses
+func CreateAssignmentHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	var req models.AssignmentRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	if req.Title == nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Title is required",
+		})
+	}
+	assignment := &models.Assignment{
+		TeacherID:      userID,
+		Status:         models.AssignmentStatusDraft,
+		LatePolicy:     models.LatePolicyAllow,
+		SubmissionType: models.SubmissionAny,
+		MaxPoints:      defaultMaxPoints,
+	}
+	err := applyAssignmentSettings(assignment, &req)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	assignmentID, err := database.CreateAssignment(assignment)
+	if err == nil {
+		assignment, err = database.GetAssignmentByID(assignmentID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to create assignment",
+		})
+	}
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: "Assignment created successfully",
+		Data:    assignment,
+	})
+}
+
+// List the teacher's assignments; ?course_id= narrows it to one course
+func GetTeacherAssignmentsHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	courseID := 0
+	if s := c.Query("course_id"); s != "" {
+		var err error
+		courseID, err = strconv.Atoi(s)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: "Invalid course ID",
+			})
+		}
+	}
+
+	assignments, err := database.GetAssignmentsByTeacherID(userID, courseID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get assignments",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    assignments,
+	})
+}
+
+// Get an assignment
+func GetTeacherAssignmentHandler(c fiber.Ctx) error {
+	assignment, err := getOwnedAssignment(c)
+	if assignment == nil {
+		return err
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    assignment,
+	})
+}
+
+// Update an assignment's title, description or settings. The rubric and
+// points cannot change once submissions have been graded against them.
+func UpdateAssignmentHandler(c fiber.Ctx) error {
+	assignment, err := getOwnedAssignment(c)
+	if assignment == nil {
+		return err
+	}
+
+	var req models.AssignmentRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	oldRubric, oldPoints := assignment.Rubric, assignment.MaxPoints
+	err = applyAssignmentSettings(assignment, &req)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	if req.Rubric != nil || req.MaxPoints != nil {
+		changed := assignment.MaxPoints != oldPoints || len(assignment.Rubric) != len(oldRubric)
+		for i := 0; !changed && i < len(oldRubric); i++ {
+			changed = assignment.Rubric[i].Points != oldRubric[i].Points
+		}
+		if changed {
+			graded, err := database.HasGradedSubmissions(assignment.ID)
+			if err != nil {
+				return c.Status(500).JSON(models.APIResponse{
+					Success: false,
+					Message: "Failed to update assignment",
+				})
+			}
+			if graded {
+				return c.Status(409).JSON(models.APIResponse{
+					Success: false,
+					Message: "The rubric and points cannot change once submissions have been graded",
+				})
+			}
+		}
+	}
+
+	err = database.UpdateAssignment(assignment)
+	if err == nil {
+		assignment, err = database.GetAssignmentByID(assignment.ID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update assignment",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Assignment updated successfully",
+		Data:    assignment,
+	})
+}
+
+// Delete an assignment with its submissions and their files
+func DeleteAssignmentHandler(c fiber.Ctx) error {
+	assignment, err := getOwnedAssignment(c)
+	if assignment == nil {
+		return err
+	}
+
+	keys, err := database.GetSubmissionFiles(assignment.ID, 0)
+	if err == nil {
+		err = database.DeleteAssignment(assignment.ID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to delete assignment",
+		})
+	}
+	deleteSubmissionFiles(keys)
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Assignment deleted successfully",
+	})
+}
+
+// List where each student stands on an assignment with their submissions;
+// ?student_id= narrows it to one student
+func GetAssignmentSubmissionsHandler(c fiber.Ctx) error {
+	assignment, err := getOwnedAssignment(c)
+	if assignment == nil {
+		return err
+	}
+
+	studentID := 0
+	if s := c.Query("student_id"); s != "" {
+		studentID, err = strconv.Atoi(s)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: "Invalid student ID",
+			})
+		}
+	}
+
+	students, err := database.GetAssignmentStudents(assignment)
+	var submissions []models.AssignmentSubmission
+	if err == nil {
+		submissions, err = database.GetAssignmentSubmissions(assignment.ID, studentID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get submissions",
+		})
+	}
+	setSubmissionURLs(submissions, "/api/teacher")
+
+	byStudent := make(map[int][]models.AssignmentSubmission)
+	for _, sub := range submissions {
+		byStudent[sub.StudentID] = append(byStudent[sub.StudentID], sub)
+	}
+
+	statuses := []models.AssignmentStudentStatus{}
+	for _, student := range students {
+		if studentID != 0 && student.StudentID != studentID {
+			continue
+		}
+		studentStatus(assignment, &student, byStudent[student.StudentID])
+		statuses = append(statuses, student)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: fiber.Map{
+			"assignment":  assignment,
+			"students":    statuses,
+			"submissions": submissions,
+		},
+	})
+}
+
+// sendSubmissionFile downloads a submitted file under its original name
+func sendSubmissionFile(c fiber.Ctx, sub *models.AssignmentSubmission) error {
+	if sub.FilePath == "" {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Submission has no file",
+		})
+	}
+
+	return sendStoredDownload(c, sub.FilePath, sub.FileName, "Submission file not found")
+}
+
+// Download a submitted file
+func GetSubmissionFileHandler(c fiber.Ctx) error {
+	assignment, err := getOwnedAssignment(c)
+	if assignment == nil {
+		return err
+	}
+	sub, err := getAssignmentSubmission(c, assignment, 0)
+	if sub == nil {
+		return err
+	}
+	return sendSubmissionFile(c, sub)
+}
+
+// Grade a submission, by rubric criterion when the assignment has a rubric.
+// Late penalties are taken off the score; grading again replaces the grade.
+func GradeSubmissionHandler(c fiber.Ctx) error {
+	assignment, err := getOwnedAssignment(c)
+	if assignment == nil {
+		return err
+	}
+	sub, err := getAssignmentSubmission(c, assignment, 0)
+	if sub == nil {
+		return err
+	}
+
+	var req models.GradeRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	invalid := func(message string) error {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	var score float64
+	if len(assignment.Rubric) > 0 {
+		if len(req.RubricScores) != len(assignment.Rubric) {
+			return invalid(fmt.Sprintf("Give a score for each of the %d rubric criteria", len(assignment.Rubric)))
+		}
+		for i, points := range req.RubricScores {
+			if points < 0 || points > assignment.Rubric[i].Points {
+				return invalid(fmt.Sprintf("Criterion %d: score must be between 0 and %g", i+1, assignment.Rubric[i].Points))
+			}
+			score += points
+		}
+		sub.RubricScores = req.RubricScores
+	} else {
+		if req.Score == nil {
+			return invalid("Score is required")
+		}
+		if *req.Score < 0 || *req.Score > assignment.MaxPoints {
+			return invalid(fmt.Sprintf("Score must be between 0 and %g", assignment.MaxPoints))
+		}
+		score = *req.Score
+		sub.RubricScores = nil
+	}
+
+	feedback := strings.TrimSpace(req.Feedback)
+	if utf8.RuneCountInString(feedback) > maxFeedback {
+		return invalid("Feedback is too long")
+	}
+
+	finalScore := roundScore(score * (1 - sub.Penalty/100))
+	now := time.Now().UTC()
+	sub.Score = &score
+	sub.FinalScore = &finalScore
+	sub.Feedback = feedback
+	sub.Graded = true
+	sub.GradedAt = &now
+
+	err = database.GradeSubmission(sub)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to grade submission",
+		})
+	}
+	setSubmissionURL(sub, "/api/teacher")
+	notifyGraded(assignment, sub)
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Submission graded successfully",
+		Data:    sub,
+	})
+}
+
+// List the assignments set for the student with where they stand on each
+func GetStudentAssignmentsHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	assignments, err := database.GetAssignmentsForStudent(userID)
+	var submissions []models.AssignmentSubmission
+	if err == nil {
+		submissions, err = database.GetStudentSubmissions(userID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get assignments",
+		})
+	}
+	setSubmissionURLs(submissions, "/api/student")
+
+	byAssignment := make(map[int][]models.AssignmentSubmission)
+	for _, sub := range submissions {
+		byAssignment[sub.AssignmentID] = append(byAssignment[sub.AssignmentID], sub)
+	}
+
+	for i := range assignments {
+		assignment := &assignments[i]
+		status := &models.AssignmentStudentStatus{StudentID: userID}
+		studentStatus(assignment, status, byAssignment[assignment.ID])
+		assignment.StudentStatus = status
+		// Counts across the class are for teachers
+		assignment.SubmittedCount, assignment.GradedCount = 0, 0
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    assignments,
+	})
+}
+
+// Get an assignment with the student's submissions and grades
+func GetStudentAssignmentHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	assignment, err := getStudentAssignment(c)
+	if assignment == nil {
+		return err
+	}
+
+	submissions, err := database.GetAssignmentSubmissions(assignment.ID, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get assignment",
+		})
+	}
+	setSubmissionURLs(submissions, "/api/student")
+
+	status := &models.AssignmentStudentStatus{StudentID: userID}
+	studentStatus(assignment, status, submissions)
+	assignment.StudentStatus = status
+	assignment.Submissions = submissions
+	assignment.SubmittedCount, assignment.GradedCount = 0, 0
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    assignment,
+	})
+}
+
+// Submit an assignment as multipart form data with a "text" field, a "file"
+// or both, as the assignment's submission type allows. Each submission is
+// kept; the newest is the one graded.
+func SubmitAssignmentHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	assignment, err := getStudentAssignment(c)
+	if assignment == nil {
+		return err
+	}
+
+	forbidden := func(message string) error {
+		return c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+	invalid := func(message string) error {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+	failed := func() error {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to save submission",
+		})
+	}
+
+	now := time.Now().UTC()
+	late := assignment.DueAt != nil && now.After(*assignment.DueAt)
+	if late && assignment.LatePolicy == models.LatePolicyReject {
+		return forbidden("The due date has passed")
+	}
+	if assignment.LateUntil != nil && now.After(*assignment.LateUntil) {
+		return forbidden("Late submissions are no longer accepted")
+	}
+
+	previous, err := database.GetAssignmentSubmissions(assignment.ID, userID)
+	if err != nil {
+		return failed()
+	}
+	if assignment.MaxSubmissions > 0 && len(previous) >= assignment.MaxSubmissions {
+		return forbidden("No submissions left")
+	}
+	if len(previous) > 0 && previous[0].Graded && !assignment.ResubmitAfterGrading {
+		return forbidden("This assignment has been graded and cannot be resubmitted")
+	}
+
+	form, err := readFormUpload(c, "file", MaxSubmissionSize, fmt.Sprintf("File exceeds the maximum size of %d MB", MaxSubmissionSize>>20))
+	if err != nil {
+		e := err.(*fiber.Error)
+		return c.Status(e.Code).JSON(models.APIResponse{
+			Success: false,
+			Message: e.Message,
+		})
+	}
+	defer form.Remove()
+
+	text := strings.TrimSpace(form.Value("text"))
+	if utf8.RuneCountInString(text) > maxSubmissionText {
+		return invalid("Text is too long")
+	}
+	hasFile := form.HasFile()
+
+	switch assignment.SubmissionType {
+	case models.SubmissionText:
+		if hasFile {
+			return invalid("This assignment only accepts text")
+		}
+		if text == "" {
+			return invalid("Text is required")
+		}
+	case models.SubmissionFile:
+		if text != "" {
+			return invalid("This assignment only accepts a file")
+		}
+		if !hasFile {
+			return invalid("No file provided")
+		}
+	default:
+		if text == "" && !hasFile {
+			return invalid("Submit text, a file or both")
+		}
+	}
+
+	sub := &models.AssignmentSubmission{
+		AssignmentID: assignment.ID,
+		StudentID:    userID,
+		Text:         text,
+		SubmittedAt:  now,
+		Late:         late,
+		Penalty:      latePenalty(assignment, now),
+	}
+
+	if hasFile {
+		name, ext := uploadedFileName(form.Filename, "submission")
+
+		file, err := form.Open()
+		if err != nil {
+			return failed()
+		}
+		defer file.Close()
+
+		contentType := mime.TypeByExtension(ext)
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		key := fmt.Sprintf("submissions/assignment_%d_student_%d_%s%s", assignment.ID, userID, GenerateSessionID()[:8], ext)
+		err = storage.Store.Put(key, file, form.Size, contentType)
+		if err != nil {
+			return failed()
+		}
+		sub.FilePath, sub.FileName, sub.FileSize = key, name, form.Size
+	}
+
+	submissionID, err := database.CreateSubmission(sub, assignment.MaxSubmissions)
+	if err != nil {
+		if sub.FilePath != "" {
+			storage.Store.Delete(sub.FilePath)
+		}
+		if err == database.ErrNoSubmissionsLeft {
+			return forbidden("No submissions left")
+		}
+		return failed()
+	}
+
+	saved, err := database.GetSubmission(assignment.ID, submissionID, userID)
+	if err != nil {
+		return failed()
+	}
+	setSubmissionURL(saved, "/api/student")
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: "Assignment submitted successfully",
+		Data:    saved,
+	})
+}
+
+// Download one of the student's own submitted files
+func GetStudentSubmissionFileHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	assignment, err := getStudentAssignment(c)
+	if assignment == nil {
+		return err
+	}
+	sub, err := getAssignmentSubmission(c, assignment, userID)
+	if sub == nil {
+		return err
+	}
+	return sendSubmissionFile(c, sub)
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"educational-platform/models"
+)
+
+func TestLatePenalty(t *testing.T) {
+	due := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		policy    string
+		penalty   float64
+		dueAt     *time.Time
+		submitted time.Time
+		want      float64
+	}{
+		{"on time", models.LatePolicyPenalty, 10, &due, due.Add(-time.Hour), 0},
+		{"at due time", models.LatePolicyPenalty, 10, &due, due, 0},
+		{"a minute late", models.LatePolicyPenalty, 10, &due, due.Add(time.Minute), 10},
+		{"exactly one day", models.LatePolicyPenalty, 10, &due, due.Add(24 * time.Hour), 10},
+		{"second day started", models.LatePolicyPenalty, 10, &due, due.Add(25 * time.Hour), 20},
+		{"fractional penalty", models.LatePolicyPenalty, 2.5, &due, due.Add(50 * time.Hour), 7.5},
+		{"capped at 100", models.LatePolicyPenalty, 30, &due, due.Add(4 * 24 * time.Hour), 100},
+		{"allow policy", models.LatePolicyAllow, 10, &due, due.Add(48 * time.Hour), 0},
+		{"reject policy", models.LatePolicyReject, 10, &due, due.Add(48 * time.Hour), 0},
+		{"no due date", models.LatePolicyPenalty, 10, nil, due, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignment := &models.Assignment{LatePolicy: tt.policy, LatePenalty: tt.penalty, DueAt: tt.dueAt}
+			if got := latePenalty(assignment, tt.submitted); got != tt.want {
+				t.Errorf("latePenalty = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
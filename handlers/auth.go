@@ -36,45 +36,52 @@ func GenerateSessionID() string {
 	return hex.EncodeToString(bytes)
 }
 
-// Middleware to check if user is authenticated
-func AuthMiddleware(c fiber.Ctx) error {
+// loadSession copies the session's user info into the request locals and
+// reports whether the request carries a valid session
+func loadSession(c fiber.Ctx) bool {
 	sessionID := c.Cookies("session_id")
 	if sessionID == "" {
-		return c.Status(401).JSON(models.APIResponse{
-			Success: false,
-			Message: "Not authenticated",
-		})
+		return false
 	}
 
 	session, exists := sessions[sessionID]
 	if !exists {
-		return c.Status(401).JSON(models.APIResponse{
-			Success: false,
-			Message: "Not authenticated",
-		})
+		return false
 	}
 
 	userID := session["user_id"]
 	userType := session["user_type"]
 
 	if userID == nil || userType == nil {
-		return c.Status(401).JSON(models.APIResponse{
-			Success: false,
-			Message: "Not authenticated",
-		})
+		return false
 	}
 
 	// Add user info to context
 	c.Locals("user_id", userID)
 	c.Locals("user_type", userType)
 
+	return true
+}
+
+// Middleware to check if user is authenticated
+func AuthMiddleware(c fiber.Ctx) error {
+	if !loadSession(c) {
+		return c.Status(401).JSON(models.APIResponse{
+			Success: false,
+			Message: "Not authenticated",
+		})
+	}
+
 	return c.Next()
 }
 
 // Middleware to check if user is a teacher
 func TeacherAuthMiddleware(c fiber.Ctx) error {
-	if err := AuthMiddleware(c); err != nil {
-		return err
+	if !loadSession(c) {
+		return c.Status(401).JSON(models.APIResponse{
+			Success: false,
+			Message: "Not authenticated",
+		})
 	}
 
 	userType := c.Locals("user_type").(string)
@@ -90,8 +97,11 @@ func TeacherAuthMiddleware(c fiber.Ctx) error {
 
 // Middleware to check if user is a student
 func StudentAuthMiddleware(c fiber.Ctx) error {
-	if err := AuthMiddleware(c); err != nil {
-		return err
+	if !loadSession(c) {
+		return c.Status(401).JSON(models.APIResponse{
+			Success: false,
+			Message: "Not authenticated",
+		})
 	}
 
 	userType := c.Locals("user_type").(string)
@@ -0,0 +1,512 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"educational-platform/database"
+	"educational-platform/models"
+	"educational-platform/storage"
+
+	"github.com/gofiber/fiber/v3"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// maxCaptionSize limits caption uploads (2 MB, far more than a lecture needs)
+const maxCaptionSize = 2 << 20
+
+// captionCue is one timed block of subtitle text
+type captionCue struct {
+	start    int // milliseconds
+	end      int
+	settings string
+	text     []string
+}
+
+// SRT styling that WebVTT does not understand: <font> tags and ASS overrides like {\an8}
+var srtUnsupportedMarkup = regexp.MustCompile(`(?i)</?font[^>]*>|\{\\[^}]*\}`)
+
+// parseCaptionLanguage validates a BCP 47 language tag and returns its
+// canonical form, e.g. "en-us" becomes "en-US"
+func parseCaptionLanguage(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" || len(value) > 35 {
+		return "", false
+	}
+	tag, err := language.Parse(value)
+	if err != nil {
+		return "", false
+	}
+	return tag.String(), true
+}
+
+// captionLabel names a language in itself ("العربية", "English") for track labels
+func captionLabel(lang string) string {
+	if name := display.Self.Name(language.Make(lang)); name != "" {
+		return name
+	}
+	return lang
+}
+
+// decodeCaptionText converts an uploaded caption file to UTF-8 with \n line
+// endings. Files are expected in UTF-8 unless a charset is named, such as
+// windows-1256 for older Arabic subtitles. UTF-16 files with a BOM are
+// detected automatically.
+func decodeCaptionText(data []byte, charset string) (string, error) {
+	var err error
+	if charset != "" {
+		encoding, lookupErr := htmlindex.Get(charset)
+		if lookupErr != nil {
+			return "", fmt.Errorf("Unknown caption encoding %q", charset)
+		}
+		data, err = encoding.NewDecoder().Bytes(data)
+		if err != nil {
+			return "", fmt.Errorf("Caption file is not valid %s", charset)
+		}
+	} else if bytes.HasPrefix(data, []byte{0xFF, 0xFE}) || bytes.HasPrefix(data, []byte{0xFE, 0xFF}) {
+		data, err = unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder().Bytes(data)
+		if err != nil {
+			return "", errors.New("Caption file is not valid UTF-16")
+		}
+	}
+
+	if !utf8.Valid(data) {
+		return "", errors.New("Caption file must be UTF-8 encoded, or name its encoding in the encoding field")
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", errors.New("Caption file contains binary data")
+	}
+
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return text, nil
+}
+
+// parseCueTimestamp parses a WebVTT ([hh:]mm:ss.ttt) or SRT (hh:mm:ss,ttt)
+// timestamp into milliseconds
+func parseCueTimestamp(s string, srt bool) (int, bool) {
+	if srt {
+		s = strings.Replace(s, ",", ".", 1)
+	}
+	clock, frac, found := strings.Cut(s, ".")
+	if !found || len(frac) != 3 || !isDigits(frac) {
+		return 0, false
+	}
+
+	parts := strings.Split(clock, ":")
+	if len(parts) == 2 && !srt {
+		parts = append([]string{"0"}, parts...)
+	}
+	if len(parts) != 3 || !isDigits(parts[0]) || len(parts[1]) != 2 || len(parts[2]) != 2 {
+		return 0, false
+	}
+
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	seconds, err3 := strconv.Atoi(parts[2])
+	millis, _ := strconv.Atoi(frac)
+	if err1 != nil || err2 != nil || err3 != nil || minutes > 59 || seconds > 59 {
+		return 0, false
+	}
+	return ((hours*60+minutes)*60+seconds)*1000 + millis, true
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseCueTiming parses a "start --> end [settings]" line
+func parseCueTiming(line string, srt bool, lineNo int) (*captionCue, error) {
+	left, right, _ := strings.Cut(line, "-->")
+	fields := strings.Fields(right)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("Line %d: invalid cue timing", lineNo)
+	}
+
+	start, ok1 := parseCueTimestamp(strings.TrimSpace(left), srt)
+	end, ok2 := parseCueTimestamp(fields[0], srt)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("Line %d: invalid cue timing", lineNo)
+	}
+	if end <= start {
+		return nil, fmt.Errorf("Line %d: cue ends before it starts", lineNo)
+	}
+
+	cue := &captionCue{start: start, end: end}
+	// SRT may carry X1:... Y2:... positioning, which has no WebVTT equivalent
+	if !srt {
+		cue.settings = strings.Join(fields[1:], " ")
+	}
+	return cue, nil
+}
+
+// parseSRT parses SubRip subtitles. Errors name the offending line.
+func parseSRT(text string) ([]captionCue, error) {
+	lines := strings.Split(text, "\n")
+	var cues []captionCue
+
+	for i := 0; i < len(lines); {
+		if strings.TrimSpace(lines[i]) == "" {
+			i++
+			continue
+		}
+
+		// Cue counter, then the timing line
+		if !strings.Contains(lines[i], "-->") {
+			if !isDigits(strings.TrimSpace(lines[i])) {
+				return nil, fmt.Errorf("Line %d: expected a cue number or timing", i+1)
+			}
+			i++
+			if i >= len(lines) || !strings.Contains(lines[i], "-->") {
+				return nil, fmt.Errorf("Line %d: expected cue timing", i+1)
+			}
+		}
+
+		cue, err := parseCueTiming(lines[i], true, i+1)
+		if err != nil {
+			return nil, err
+		}
+		i++
+
+		for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+			line := srtUnsupportedMarkup.ReplaceAllString(lines[i], "")
+			cue.text = append(cue.text, strings.ReplaceAll(line, "-->", "--&gt;"))
+		}
+		// Blank cues are common in SRT exports; WebVTT has no use for them
+		if len(cue.text) > 0 {
+			cues = append(cues, *cue)
+		}
+	}
+
+	if len(cues) == 0 {
+		return nil, errors.New("Caption file contains no cues")
+	}
+	return cues, nil
+}
+
+// parseVTT validates a WebVTT file and returns its cues. Errors name the
+// offending line.
+func parseVTT(text string) ([]captionCue, error) {
+	lines := strings.Split(text, "\n")
+	if lines[0] != "WEBVTT" && !strings.HasPrefix(lines[0], "WEBVTT ") && !strings.HasPrefix(lines[0], "WEBVTT\t") {
+		return nil, errors.New("Line 1: missing WEBVTT header")
+	}
+
+	// Skip the rest of the header block
+	i := 1
+	for i < len(lines) && strings.TrimSpace(lines[i]) != "" {
+		i++
+	}
+
+	var cues []captionCue
+	for i < len(lines) {
+		if strings.TrimSpace(lines[i]) == "" {
+			i++
+			continue
+		}
+
+		// Comments, style sheets and region definitions are kept as they are
+		first := strings.Fields(lines[i])[0]
+		if first == "NOTE" || first == "STYLE" || first == "REGION" {
+			for i < len(lines) && strings.TrimSpace(lines[i]) != "" {
+				i++
+			}
+			continue
+		}
+
+		// Optional cue identifier, then the timing line
+		if !strings.Contains(lines[i], "-->") {
+			i++
+			if i >= len(lines) || !strings.Contains(lines[i], "-->") {
+				return nil, fmt.Errorf("Line %d: expected cue timing", i+1)
+			}
+		}
+
+		cue, err := parseCueTiming(lines[i], false, i+1)
+		if err != nil {
+			return nil, err
+		}
+		i++
+
+		for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+			if strings.Contains(lines[i], "-->") {
+				return nil, fmt.Errorf("Line %d: cue text cannot contain \"-->\"", i+1)
+			}
+			cue.text = append(cue.text, lines[i])
+		}
+		cues = append(cues, *cue)
+	}
+
+	if len(cues) == 0 {
+		return nil, errors.New("Caption file contains no cues")
+	}
+	return cues, nil
+}
+
+// buildCaptionVTT writes cues as a WebVTT file
+func buildCaptionVTT(cues []captionCue) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for _, cue := range cues {
+		b.WriteString(formatVTTTimestamp(cue.start) + " --> " + formatVTTTimestamp(cue.end))
+		if cue.settings != "" {
+			b.WriteString(" " + cue.settings)
+		}
+		b.WriteString("\n" + strings.Join(cue.text, "\n") + "\n\n")
+	}
+	return b.String()
+}
+
+// convertCaptions validates an uploaded caption file and returns it as
+// WebVTT. WebVTT files are stored as uploaded so styling blocks survive;
+// anything else is parsed as SRT.
+func convertCaptions(text string) (string, error) {
+	if strings.HasPrefix(text, "WEBVTT") {
+		if _, err := parseVTT(text); err != nil {
+			return "", err
+		}
+		return text, nil
+	}
+
+	cues, err := parseSRT(text)
+	if err != nil {
+		return "", err
+	}
+	return buildCaptionVTT(cues), nil
+}
+
+// Upload a caption track for a video, replacing any track in the same language
+func UploadCaptionHandler(c fiber.Ctx) error {
+	video, err := getOwnedVideo(c)
+	if video == nil {
+		return err
+	}
+
+	form, err := readFormUpload(c, "caption", maxCaptionSize, "Caption file exceeds the maximum size of 2 MB")
+	if err != nil {
+		e := err.(*fiber.Error)
+		return c.Status(e.Code).JSON(models.APIResponse{
+			Success: false,
+			Message: e.Message,
+		})
+	}
+	defer form.Remove()
+
+	lang, ok := parseCaptionLanguage(form.Value("language"))
+	if !ok {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "A valid language code such as \"en\" or \"ar\" is required",
+		})
+	}
+
+	label := strings.TrimSpace(form.Value("label"))
+	if label == "" {
+		label = captionLabel(lang)
+	}
+	if utf8.RuneCountInString(label) > 100 {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Caption label is too long",
+		})
+	}
+
+	if !form.HasFile() {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "No caption file provided",
+		})
+	}
+
+	file, err := form.Open()
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to read caption file",
+		})
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxCaptionSize))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to read caption file",
+		})
+	}
+
+	text, err := decodeCaptionText(data, strings.TrimSpace(form.Value("encoding")))
+	if err == nil {
+		text, err = convertCaptions(text)
+	}
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	// A new key per upload keeps caches from serving the old track
+	base := strings.TrimSuffix(path.Base(video.FilePath), path.Ext(video.FilePath))
+	key := "captions/" + base + "_" + lang + "_" + GenerateSessionID()[:8] + ".vtt"
+
+	err = storage.Store.Put(key, strings.NewReader(text), int64(len(text)), "text/vtt; charset=utf-8")
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to save caption file",
+		})
+	}
+
+	oldKey, err := database.SaveCaption(&models.Caption{
+		VideoID:  video.ID,
+		Language: lang,
+		Label:    label,
+		FilePath: key,
+	})
+	if err != nil {
+		storage.Store.Delete(key)
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to save caption file",
+		})
+	}
+	if oldKey != "" && oldKey != key {
+		storage.Store.Delete(oldKey)
+	}
+
+	caption, err := database.GetCaption(video.ID, lang)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to save caption file",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Caption uploaded successfully",
+		Data:    caption,
+	})
+}
+
+// Delete a video's caption track
+func DeleteCaptionHandler(c fiber.Ctx) error {
+	video, err := getOwnedVideo(c)
+	if video == nil {
+		return err
+	}
+
+	lang, ok := parseCaptionLanguage(c.Params("lang"))
+	if !ok {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Caption not found",
+		})
+	}
+
+	caption, err := database.GetCaption(video.ID, lang)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Caption not found",
+		})
+	}
+
+	err = database.DeleteCaption(video.ID, lang)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to delete caption",
+		})
+	}
+	storage.Store.Delete(caption.FilePath)
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Caption deleted successfully",
+	})
+}
+
+// Serve a caption track to the video's teacher and subscribed students
+func ServeCaptionHandler(c fiber.Ctx) error {
+	videoID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid video ID",
+		})
+	}
+
+	video, err := database.GetVideoByID(videoID)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Video not found",
+		})
+	}
+
+	if ok, err := checkVideoAccess(c, video); !ok {
+		return err
+	}
+
+	lang, ok := parseCaptionLanguage(c.Params("lang"))
+	var caption *models.Caption
+	if ok {
+		caption, err = database.GetCaption(video.ID, lang)
+	}
+	if !ok || err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Caption not found",
+		})
+	}
+
+	// Tracks are served directly rather than redirected to object storage:
+	// <track> elements only load same-origin or CORS-enabled URLs
+	reader, err := storage.Store.Get(caption.FilePath, 0, -1)
+	if err == storage.ErrNotFound {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Caption not found",
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to read file",
+		})
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to read file",
+		})
+	}
+
+	c.Set("Content-Type", "text/vtt; charset=utf-8")
+	c.Set("Cache-Control", "private, max-age=3600")
+	return c.Send(data)
+}
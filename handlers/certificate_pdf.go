@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+
+	"educational-platform/models"
+)
+
+// Certificate page layout, in points on a landscape A4 page
+const (
+	certificatePageWidth  = 842
+	certificatePageHeight = 595
+	certificateTextWidth  = 700
+)
+
+// centeredText draws a line centred on the page, shrinking the font down to
+// minSize to fit and cutting the text short if it still does not fit
+func centeredText(content *bytes.Buffer, text string, bold bool, size, minSize, y float64) {
+	encoded := winAnsi(text)
+	for size > minSize && pdfTextWidth(encoded, bold, size) > certificateTextWidth {
+		size--
+	}
+	if pdfTextWidth(encoded, bold, size) > certificateTextWidth {
+		for len(encoded) > 0 && pdfTextWidth(append(encoded, "..."...), bold, size) > certificateTextWidth {
+			encoded = encoded[:len(encoded)-1]
+		}
+		encoded = append(encoded, "..."...)
+	}
+
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	x := (certificatePageWidth - pdfTextWidth(encoded, bold, size)) / 2
+	fmt.Fprintf(content, "BT /%s %.1f Tf %.2f %.2f Td %s Tj ET\n", font, size, x, y, pdfString(encoded))
+}
+
+// renderCertificatePDF lays a certificate out as a one-page PDF. The details
+// are printed with the verification code, where to check it and the
+// signature, and are repeated in the document information.
+func renderCertificatePDF(certificate *models.Certificate, verifyURL string) []byte {
+	var content bytes.Buffer
+	// Double border
+	content.WriteString("0.15 0.25 0.45 RG 4 w 20 20 802 555 re S\n")
+	content.WriteString("0.6 0.5 0.25 RG 1 w 32 32 778 531 re S\n")
+
+	content.WriteString("0.15 0.25 0.45 rg\n")
+	centeredText(&content, "CERTIFICATE OF COMPLETION", true, 30, 30, 470)
+	content.WriteString("0.2 0.2 0.2 rg\n")
+	centeredText(&content, "This certifies that", false, 14, 14, 420)
+	centeredText(&content, certificate.StudentName, true, 32, 16, 372)
+	centeredText(&content, "has successfully completed the course", false, 14, 14, 333)
+	centeredText(&content, certificate.CourseTitle, true, 24, 12, 293)
+	centeredText(&content, "taught by "+certificate.TeacherName, false, 14, 10, 258)
+	centeredText(&content, "Issued on "+certificate.IssuedAt.Format("January 2, 2006"), false, 12, 12, 225)
+
+	centeredText(&content, "Verification code: "+certificate.Code, true, 12, 12, 122)
+	centeredText(&content, "Verify at "+verifyURL, false, 10, 6, 104)
+	content.WriteString("0.45 0.45 0.45 rg\n")
+	centeredText(&content, "Ed25519 signature", false, 8, 8, 80)
+	centeredText(&content, certificate.Signature, false, 8, 6, 68)
+
+	info := fmt.Sprintf("<< /Title %s /Author %s /Subject %s /Creator %s /CreationDate (D:%s) "+
+		"/CertificateCode %s /CertificateSignature %s >>",
+		pdfTextString("Certificate of Completion: "+certificate.CourseTitle),
+		pdfTextString(certificate.TeacherName),
+		pdfTextString(certificate.StudentName+" completed "+certificate.CourseTitle),
+		pdfTextString("Educational Platform"),
+		certificate.IssuedAt.UTC().Format("20060102150405Z"),
+		pdfString([]byte(certificate.Code)),
+		pdfString([]byte(certificate.Signature)))
+
+	return writePDF(certificatePageWidth, certificatePageHeight, [][]byte{content.Bytes()}, info)
+}
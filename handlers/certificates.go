@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"educational-platform/database"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// CertificateKeyFile keeps the generated signing key when
+// CERTIFICATE_SIGNING_KEY is not set
+var CertificateKeyFile = "./certificate_signing.key"
+
+// certificateKey signs certificates; its public half is published with every
+// verification so anyone can check a certificate independently
+var certificateKey ed25519.PrivateKey
+
+// InitCertificates loads the certificate signing key, generating and saving
+// one on first start
+func InitCertificates() error {
+	if file := os.Getenv("CERTIFICATE_KEY_FILE"); file != "" {
+		CertificateKeyFile = file
+	}
+
+	encoded := os.Getenv("CERTIFICATE_SIGNING_KEY")
+	if encoded == "" {
+		data, err := os.ReadFile(CertificateKeyFile)
+		if errors.Is(err, os.ErrNotExist) {
+			seed := make([]byte, ed25519.SeedSize)
+			if _, err := rand.Read(seed); err != nil {
+				return err
+			}
+			encoded = base64.StdEncoding.EncodeToString(seed)
+			if err := os.WriteFile(CertificateKeyFile, []byte(encoded+"\n"), 0600); err != nil {
+				return err
+			}
+			log.Printf("Generated a certificate signing key in %s", CertificateKeyFile)
+		} else if err != nil {
+			return err
+		} else {
+			encoded = string(data)
+		}
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return fmt.Errorf("certificate signing key must be %d bytes of base64", ed25519.SeedSize)
+	}
+	certificateKey = ed25519.NewKeyFromSeed(seed)
+	return nil
+}
+
+// certificateSignedData is what a certificate's signature covers, one field
+// per line
+func certificateSignedData(certificate *models.Certificate) string {
+	return strings.Join([]string{
+		"certificate-v1",
+		certificate.Code,
+		certificate.StudentName,
+		certificate.CourseTitle,
+		certificate.TeacherName,
+		certificate.IssuedAt.UTC().Format(time.RFC3339),
+	}, "\n")
+}
+
+func signCertificate(certificate *models.Certificate) {
+	signature := ed25519.Sign(certificateKey, []byte(certificateSignedData(certificate)))
+	certificate.Signature = base64.StdEncoding.EncodeToString(signature)
+}
+
+// certificateSignatureValid reports whether a certificate's details are the
+// ones that were signed
+func certificateSignatureValid(certificate *models.Certificate) bool {
+	signature, err := base64.StdEncoding.DecodeString(certificate.Signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(certificateKey.Public().(ed25519.PublicKey), []byte(certificateSignedData(certificate)),
+		signature)
+}
+
+// setCertificateURLs fills in where a certificate is verified and downloaded
+func setCertificateURLs(c fiber.Ctx, certificate *models.Certificate) {
+	certificate.VerifyURL = c.BaseURL() + "/api/certificates/" + certificate.Code
+	certificate.PDFURL = certificate.VerifyURL + "/pdf"
+}
+
+// courseCompletion measures a student's progress towards a course's
+// certificate: every lesson students can see must be completed and every
+// published quiz on them passed
+func courseCompletion(studentID, courseID int) (*models.CourseCompletion, error) {
+	sections, err := database.GetCourseSections(courseID, true)
+	if err != nil {
+		return nil, err
+	}
+	completedLessons, err := database.GetCompletedLessons(studentID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	quizIDs, err := database.GetCourseQuizIDs(courseID)
+	if err != nil {
+		return nil, err
+	}
+	passedQuizzes, err := database.GetPassedQuizzes(studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	completion := &models.CourseCompletion{QuizzesTotal: len(quizIDs)}
+	for _, section := range sections {
+		for _, lesson := range section.Lessons {
+			completion.LessonsTotal++
+			if completedLessons[lesson.ID] {
+				completion.LessonsCompleted++
+			}
+		}
+	}
+	for _, quizID := range quizIDs {
+		if passedQuizzes[quizID] {
+			completion.QuizzesPassed++
+		}
+	}
+
+	completion.Complete = completion.LessonsTotal > 0 &&
+		completion.LessonsCompleted == completion.LessonsTotal &&
+		completion.QuizzesPassed == completion.QuizzesTotal
+	return completion, nil
+}
+
+// getEnrolledCourse loads the :id course for a student enrolled in it.
+// Certificates stay available after access expires.
+func getEnrolledCourse(c fiber.Ctx) (*models.Course, error) {
+	userID := c.Locals("user_id").(int)
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid course ID",
+		})
+	}
+
+	course, err := database.GetCourseByID(courseID)
+	if err != nil || course.Status != models.CourseStatusPublished {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Course not found",
+		})
+	}
+
+	enrollment, err := database.GetEnrollment(course.ID, userID)
+	if err != nil || enrollment.Status != models.EnrollmentStatusActive {
+		return nil, c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: "Not enrolled in this course",
+		})
+	}
+
+	return course, nil
+}
+
+// Get a student's progress towards a course certificate, and the certificate
+// once issued
+func GetCourseCertificateHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	course, err := getEnrolledCourse(c)
+	if course == nil {
+		return err
+	}
+
+	failed := func() error {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get certificate",
+		})
+	}
+
+	completion, err := courseCompletion(userID, course.ID)
+	if err != nil {
+		return failed()
+	}
+	completion.Certificate, err = database.GetCourseCertificate(course.ID, userID)
+	if err == sql.ErrNoRows {
+		completion.Certificate = nil
+	} else if err != nil {
+		return failed()
+	} else {
+		setCertificateURLs(c, completion.Certificate)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    completion,
+	})
+}
+
+// Issue the certificate for a completed course. Asking again returns the
+// certificate already issued.
+func IssueCertificateHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	course, err := getEnrolledCourse(c)
+	if course == nil {
+		return err
+	}
+
+	failed := func() error {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to issue certificate",
+		})
+	}
+	existing := func(certificate *models.Certificate) error {
+		if certificate.RevokedAt != nil {
+			return c.Status(403).JSON(models.APIResponse{
+				Success: false,
+				Message: "Your certificate for this course was revoked",
+			})
+		}
+		setCertificateURLs(c, certificate)
+		return c.JSON(models.APIResponse{
+			Success: true,
+			Message: "Certificate already issued",
+			Data:    certificate,
+		})
+	}
+
+	certificate, err := database.GetCourseCertificate(course.ID, userID)
+	if err == nil {
+		return existing(certificate)
+	} else if err != sql.ErrNoRows {
+		return failed()
+	}
+
+	completion, err := courseCompletion(userID, course.ID)
+	if err != nil {
+		return failed()
+	}
+	if !completion.Complete {
+		return c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: "Complete every lesson and pass every quiz of the course first",
+			Data:    completion,
+		})
+	}
+
+	student, err := database.GetStudentByID(userID)
+	if err != nil {
+		return failed()
+	}
+	code, err := database.NewCertificateCode()
+	if err != nil {
+		return failed()
+	}
+
+	certificate = &models.Certificate{
+		Code:        code,
+		StudentID:   userID,
+		CourseID:    course.ID,
+		StudentName: student.Name,
+		CourseTitle: course.Title,
+		TeacherName: course.TeacherName,
+		IssuedAt:    time.Now().UTC().Truncate(time.Second),
+	}
+	signCertificate(certificate)
+
+	certificate.ID, err = database.CreateCertificate(certificate)
+	if err != nil {
+		// Another request may have issued it first
+		if issued, getErr := database.GetCourseCertificate(course.ID, userID); getErr == nil {
+			return existing(issued)
+		}
+		return failed()
+	}
+
+	setCertificateURLs(c, certificate)
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: "Certificate issued",
+		Data:    certificate,
+	})
+}
+
+// List the student's certificates
+func GetStudentCertificatesHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	certificates, err := database.GetStudentCertificates(userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get certificates",
+		})
+	}
+	for i := range certificates {
+		setCertificateURLs(c, &certificates[i])
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    certificates,
+	})
+}
+
+// List the certificates issued for a course
+func GetCourseCertificatesHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+
+	certificates, err := database.GetCourseCertificates(course.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get certificates",
+		})
+	}
+	for i := range certificates {
+		setCertificateURLs(c, &certificates[i])
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    certificates,
+	})
+}
+
+// Revoke a certificate issued for a course. Verifying it afterwards reports
+// the revocation.
+func RevokeCertificateHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+
+	certificateID, err := strconv.Atoi(c.Params("certificate_id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid certificate ID",
+		})
+	}
+
+	certificate, err := database.GetCertificateByID(certificateID)
+	if err != nil || certificate.CourseID != course.ID {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Certificate not found",
+		})
+	}
+
+	err = database.RevokeCertificate(certificate.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to revoke certificate",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Certificate revoked",
+	})
+}
+
+// getCertificateByCode loads the :code certificate; codes are not case
+// sensitive
+func getCertificateByCode(c fiber.Ctx) (*models.Certificate, error) {
+	code := strings.ToUpper(strings.TrimSpace(c.Params("code")))
+	certificate, err := database.GetCertificateByCode(code)
+	if err != nil {
+		status, message := 500, "Failed to verify certificate"
+		if err == sql.ErrNoRows {
+			status, message = 404, "Certificate not found"
+		}
+		return nil, c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+	return certificate, nil
+}
+
+// Verify a certificate by its code. Public: anyone holding a certificate can
+// check it here, and can check the signature themselves with the public key.
+func VerifyCertificateHandler(c fiber.Ctx) error {
+	certificate, err := getCertificateByCode(c)
+	if certificate == nil {
+		return err
+	}
+
+	// Accounts and courses are not exposed publicly
+	certificate.StudentID, certificate.CourseID = 0, 0
+	setCertificateURLs(c, certificate)
+
+	verification := models.CertificateVerification{
+		Revoked:     certificate.RevokedAt != nil,
+		Certificate: certificate,
+		Algorithm:   "Ed25519",
+		PublicKey:   base64.StdEncoding.EncodeToString(certificateKey.Public().(ed25519.PublicKey)),
+		SignedData:  certificateSignedData(certificate),
+	}
+	verification.Valid = !verification.Revoked && certificateSignatureValid(certificate)
+
+	message := "Certificate is valid"
+	if verification.Revoked {
+		message = "Certificate was revoked"
+	} else if !verification.Valid {
+		message = "Certificate signature does not match"
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    verification,
+	})
+}
+
+// Download a certificate as a PDF. Public, like verification.
+func GetCertificatePDFHandler(c fiber.Ctx) error {
+	certificate, err := getCertificateByCode(c)
+	if certificate == nil {
+		return err
+	}
+	if certificate.RevokedAt != nil {
+		return c.Status(410).JSON(models.APIResponse{
+			Success: false,
+			Message: "Certificate was revoked",
+		})
+	}
+
+	setCertificateURLs(c, certificate)
+	c.Set("Content-Type", "application/pdf")
+	c.Set("Content-Disposition", `inline; filename="certificate_`+certificate.Code+`.pdf"`)
+	c.Set("Cache-Control", "private, no-cache")
+	return c.Send(renderCertificatePDF(certificate, certificate.VerifyURL))
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"educational-platform/database"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Chapter limits
+const (
+	maxChapters     = 100
+	maxChapterTitle = 200 // characters
+)
+
+// openChapterLength ends the last chapter of a video whose duration is
+// unknown, so players keep showing it until the end
+const openChapterLength = 24 * 3600
+
+// validateChapters checks chapters against the video's duration (0 when
+// unknown) and returns them sorted by start time. Errors number chapters in
+// the order they were given.
+func validateChapters(inputs []models.ChapterInput, duration int) ([]models.Chapter, error) {
+	if len(inputs) > maxChapters {
+		return nil, fmt.Errorf("A video can have at most %d chapters", maxChapters)
+	}
+
+	chapters := make([]models.Chapter, 0, len(inputs))
+	for i, input := range inputs {
+		title := strings.TrimSpace(input.Title)
+		switch {
+		case title == "":
+			return nil, fmt.Errorf("Chapter %d: title is required", i+1)
+		case utf8.RuneCountInString(title) > maxChapterTitle:
+			return nil, fmt.Errorf("Chapter %d: title is too long", i+1)
+		case input.StartTime < 0:
+			return nil, fmt.Errorf("Chapter %d: start time cannot be negative", i+1)
+		case duration > 0 && input.StartTime >= duration:
+			return nil, fmt.Errorf("Chapter %d: starts after the end of the video", i+1)
+		}
+		chapters = append(chapters, models.Chapter{Title: title, StartTime: input.StartTime})
+	}
+
+	sort.SliceStable(chapters, func(i, j int) bool {
+		return chapters[i].StartTime < chapters[j].StartTime
+	})
+	for i := 1; i < len(chapters); i++ {
+		if chapters[i].StartTime == chapters[i-1].StartTime {
+			return nil, fmt.Errorf("Two chapters start at %s", formatVTTTimestamp(chapters[i].StartTime*1000))
+		}
+	}
+	return chapters, nil
+}
+
+// ffprobeChapters is the part of ffprobe's JSON output that lists chapters
+type ffprobeChapters struct {
+	Chapters []struct {
+		StartTime string            `json:"start_time"`
+		Tags      map[string]string `json:"tags"`
+	} `json:"chapters"`
+}
+
+// probeVideoChapters reads chapter markers embedded in a video file, such as
+// MP4 chapter tracks or Matroska editions. It returns nil without ffprobe.
+func probeVideoChapters(videoPath string) []models.ChapterInput {
+	if !isFFprobeAvailable() {
+		return nil
+	}
+
+	cmd := exec.Command("ffprobe",
+		"-v", "error",
+		"-show_chapters",
+		"-of", "json",
+		videoPath)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil
+	}
+
+	var probed ffprobeChapters
+	if err := json.Unmarshal(output, &probed); err != nil {
+		return nil
+	}
+
+	var chapters []models.ChapterInput
+	last := -1
+	for i, chapter := range probed.Chapters {
+		seconds, err := strconv.ParseFloat(chapter.StartTime, 64)
+		if err != nil || seconds < 0 {
+			continue
+		}
+		// Chapters are stored in whole seconds; keep the first of any that collide
+		start := int(math.Floor(seconds))
+		if start == last {
+			continue
+		}
+		last = start
+
+		title := strings.TrimSpace(chapter.Tags["title"])
+		if title == "" {
+			title = fmt.Sprintf("Chapter %d", i+1)
+		}
+		if utf8.RuneCountInString(title) > maxChapterTitle {
+			title = string([]rune(title)[:maxChapterTitle])
+		}
+		chapters = append(chapters, models.ChapterInput{Title: title, StartTime: start})
+	}
+	return chapters
+}
+
+// importChapters saves chapters found in an uploaded file. Uploads never fail
+// because of bad chapter metadata; it is logged and skipped.
+func importChapters(videoID int, inputs []models.ChapterInput, duration int) {
+	if len(inputs) > maxChapters {
+		inputs = inputs[:maxChapters]
+	}
+
+	chapters, err := validateChapters(inputs, duration)
+	if err == nil {
+		err = database.ReplaceChapters(videoID, chapters)
+	}
+	if err != nil {
+		log.Printf("chapters for video %d: %v", videoID, err)
+	}
+}
+
+// buildChaptersVTT writes chapters as a WebVTT chapters track
+func buildChaptersVTT(chapters []models.Chapter) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for i, chapter := range chapters {
+		end := chapter.EndTime
+		if end <= chapter.StartTime {
+			end = chapter.StartTime + openChapterLength
+		}
+		// Newlines would end the cue early; titles are single-line anyway
+		title := strings.Join(strings.Fields(chapter.Title), " ")
+		title = strings.ReplaceAll(title, "-->", "--&gt;")
+
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s\n\n",
+			i+1, formatVTTTimestamp(chapter.StartTime*1000), formatVTTTimestamp(end*1000), title)
+	}
+	return b.String()
+}
+
+// Replace a video's chapters; an empty list removes them
+func UpdateChaptersHandler(c fiber.Ctx) error {
+	video, err := getOwnedVideo(c)
+	if video == nil {
+		return err
+	}
+
+	var req models.ChaptersRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	chapters, err := validateChapters(req.Chapters, video.Duration)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	err = database.ReplaceChapters(video.ID, chapters)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to save chapters",
+		})
+	}
+
+	saved, err := database.GetChaptersByVideoID(video.ID, video.Duration)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get chapters",
+		})
+	}
+	if saved == nil {
+		saved = []models.Chapter{}
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Chapters updated successfully",
+		Data:    saved,
+	})
+}
+
+// Serve a video's chapters as a WebVTT chapters track
+func ServeChaptersVTTHandler(c fiber.Ctx) error {
+	videoID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid video ID",
+		})
+	}
+
+	video, err := database.GetVideoByID(videoID)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Video not found",
+		})
+	}
+
+	if ok, err := checkVideoAccess(c, video); !ok {
+		return err
+	}
+
+	if len(video.Chapters) == 0 {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Video has no chapters",
+		})
+	}
+
+	c.Set("Content-Type", "text/vtt; charset=utf-8")
+	c.Set("Cache-Control", "private, no-cache")
+	return c.SendString(buildChaptersVTT(video.Chapters))
+}
@@ -0,0 +1,547 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"educational-platform/database"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Comment limits. Authors can change their mind for a while after posting;
+// the video's teacher can delete comments at any time.
+const (
+	maxCommentLength    = 5000 // characters
+	commentEditWindow   = 15 * time.Minute
+	commentDeleteWindow = time.Hour
+	maxListedQuestions  = 200
+)
+
+// commentViewer returns who is making the request
+func commentViewer(c fiber.Ctx) (string, int) {
+	return c.Locals("user_type").(string), c.Locals("user_id").(int)
+}
+
+// isVideoTeacher reports whether the request comes from the video's teacher
+func isVideoTeacher(c fiber.Ctx, video *models.Video) bool {
+	userType, userID := commentViewer(c)
+	return userType == "teacher" && userID == video.TeacherID
+}
+
+// getCommentVideo loads a video whose comments the user may see: the same
+// users who may watch it
+func getCommentVideo(c fiber.Ctx, videoID int) (*models.Video, error) {
+	video, err := database.GetVideoByID(videoID)
+	if err != nil {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Video not found",
+		})
+	}
+	if ok, err := checkVideoAccess(c, video); !ok {
+		return nil, err
+	}
+	if ok, err := checkLessonUnlocked(c, video); !ok {
+		return nil, err
+	}
+	return video, nil
+}
+
+// getVisibleComment loads the :id comment and its video for a user who may
+// see it. Hidden comments, and replies in hidden threads, are only visible
+// to the teacher.
+func getVisibleComment(c fiber.Ctx) (*models.Comment, *models.Video, error) {
+	userType, userID := commentViewer(c)
+	commentID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid comment ID",
+		})
+	}
+
+	notFound := func() (*models.Comment, *models.Video, error) {
+		return nil, nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Comment not found",
+		})
+	}
+
+	comment, err := database.GetComment(commentID, userType, userID)
+	if err != nil {
+		return notFound()
+	}
+	video, err := getCommentVideo(c, comment.VideoID)
+	if video == nil {
+		return nil, nil, err
+	}
+
+	if !isVideoTeacher(c, video) {
+		hidden := comment.Hidden
+		if !hidden && comment.ParentID != 0 {
+			parent, err := database.GetComment(comment.ParentID, userType, userID)
+			hidden = err != nil || parent.Hidden
+		}
+		if hidden {
+			return notFound()
+		}
+	}
+	return comment, video, nil
+}
+
+// checkCommentTimestamp validates a point in a video, in seconds
+func checkCommentTimestamp(timestamp int, video *models.Video) error {
+	if timestamp < 0 {
+		return fmt.Errorf("Timestamp cannot be negative")
+	}
+	if video.Duration > 0 && timestamp > video.Duration {
+		return fmt.Errorf("Timestamp is past the end of the video")
+	}
+	return nil
+}
+
+// checkCommentBody trims a comment body and checks its length
+func checkCommentBody(body *string) (string, error) {
+	if body == nil || strings.TrimSpace(*body) == "" {
+		return "", fmt.Errorf("Comment body is required")
+	}
+	trimmed := strings.TrimSpace(*body)
+	if utf8.RuneCountInString(trimmed) > maxCommentLength {
+		return "", fmt.Errorf("Comments can be at most %d characters", maxCommentLength)
+	}
+	return trimmed, nil
+}
+
+// List a video's comments as threads: ?sort=newest (the default), top or
+// timestamp. Replies follow the accepted answer, oldest first.
+func GetVideoCommentsHandler(c fiber.Ctx) error {
+	userType, userID := commentViewer(c)
+	videoID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid video ID",
+		})
+	}
+
+	sortBy := c.Query("sort", "newest")
+	if sortBy != "newest" && sortBy != "top" && sortBy != "timestamp" {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Sort must be newest, top or timestamp",
+		})
+	}
+
+	video, err := getCommentVideo(c, videoID)
+	if video == nil {
+		return err
+	}
+
+	comments, err := database.GetVideoComments(video.ID, userType, userID, isVideoTeacher(c, video))
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get comments",
+		})
+	}
+
+	threads := []models.Comment{}
+	replies := make(map[int][]models.Comment)
+	for _, comment := range comments {
+		if comment.ParentID == 0 {
+			threads = append(threads, comment)
+		} else {
+			replies[comment.ParentID] = append(replies[comment.ParentID], comment)
+		}
+	}
+
+	for i := range threads {
+		thread := &threads[i]
+		thread.Replies = replies[thread.ID]
+		sort.SliceStable(thread.Replies, func(a, b int) bool {
+			return thread.Replies[a].Accepted && !thread.Replies[b].Accepted
+		})
+		for _, reply := range thread.Replies {
+			if !reply.Hidden && (reply.Accepted || (reply.AuthorType == "teacher" && reply.AuthorID == video.TeacherID)) {
+				thread.Answered = true
+			}
+		}
+	}
+
+	sort.SliceStable(threads, func(a, b int) bool {
+		switch sortBy {
+		case "top":
+			if threads[a].Upvotes != threads[b].Upvotes {
+				return threads[a].Upvotes > threads[b].Upvotes
+			}
+		case "timestamp":
+			ta, tb := threads[a].Timestamp, threads[b].Timestamp
+			if ta == nil || tb == nil {
+				if ta != nil || tb != nil {
+					return ta != nil
+				}
+			} else if *ta != *tb {
+				return *ta < *tb
+			}
+		}
+		return threads[a].ID > threads[b].ID
+	})
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    threads,
+	})
+}
+
+// Comment on a video, or reply to a comment with parent_id
+func CreateCommentHandler(c fiber.Ctx) error {
+	userType, userID := commentViewer(c)
+	videoID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid video ID",
+		})
+	}
+
+	video, err := getCommentVideo(c, videoID)
+	if video == nil {
+		return err
+	}
+
+	var req models.CommentRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	invalid := func(message string) error {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	body, err := checkCommentBody(req.Body)
+	if err != nil {
+		return invalid(err.Error())
+	}
+
+	comment := &models.Comment{
+		VideoID:    video.ID,
+		AuthorType: userType,
+		AuthorID:   userID,
+		Body:       body,
+		CreatedAt:  time.Now().UTC(),
+	}
+
+	var parent *models.Comment
+	if req.ParentID != 0 {
+		if req.Timestamp != nil {
+			return invalid("Replies cannot have a timestamp")
+		}
+		parent, err = database.GetComment(req.ParentID, userType, userID)
+		if err != nil || parent.VideoID != video.ID || (parent.Hidden && !isVideoTeacher(c, video)) {
+			return invalid("Comment to reply to not found")
+		}
+		// Replies to replies join the same thread
+		comment.ParentID = parent.ID
+		if parent.ParentID != 0 {
+			comment.ParentID = parent.ParentID
+		}
+	} else if req.Timestamp != nil {
+		if err := checkCommentTimestamp(*req.Timestamp, video); err != nil {
+			return invalid(err.Error())
+		}
+		comment.Timestamp = req.Timestamp
+	}
+
+	commentID, err := database.CreateComment(comment)
+	if err == nil {
+		comment, err = database.GetComment(commentID, userType, userID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to post comment",
+		})
+	}
+	if parent != nil {
+		notifyCommentReply(parent, comment, video)
+	}
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: "Comment posted successfully",
+		Data:    comment,
+	})
+}
+
+// Edit one of the user's own comments, shortly after posting it
+func UpdateCommentHandler(c fiber.Ctx) error {
+	userType, userID := commentViewer(c)
+	comment, _, err := getVisibleComment(c)
+	if comment == nil {
+		return err
+	}
+
+	if comment.AuthorType != userType || comment.AuthorID != userID {
+		return c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: "Not authorized to edit this comment",
+		})
+	}
+	if time.Since(comment.CreatedAt) > commentEditWindow {
+		return c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("Comments can only be edited within %d minutes of posting", int(commentEditWindow.Minutes())),
+		})
+	}
+
+	var req models.CommentRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+	body, err := checkCommentBody(req.Body)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	err = database.UpdateCommentBody(comment.ID, body)
+	if err == nil {
+		comment, err = database.GetComment(comment.ID, userType, userID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update comment",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Comment updated successfully",
+		Data:    comment,
+	})
+}
+
+// Delete a comment and its replies. Authors can delete their own for a while
+// after posting; the video's teacher can delete any.
+func DeleteCommentHandler(c fiber.Ctx) error {
+	userType, userID := commentViewer(c)
+	comment, video, err := getVisibleComment(c)
+	if comment == nil {
+		return err
+	}
+
+	if !isVideoTeacher(c, video) {
+		if comment.AuthorType != userType || comment.AuthorID != userID {
+			return c.Status(403).JSON(models.APIResponse{
+				Success: false,
+				Message: "Not authorized to delete this comment",
+			})
+		}
+		if time.Since(comment.CreatedAt) > commentDeleteWindow {
+			return c.Status(403).JSON(models.APIResponse{
+				Success: false,
+				Message: fmt.Sprintf("Comments can only be deleted within %d minutes of posting", int(commentDeleteWindow.Minutes())),
+			})
+		}
+	}
+
+	err = database.DeleteComment(comment.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to delete comment",
+		})
+	}
+	database.DeleteSourceNotifications(models.NotificationCommentReply, comment.ID)
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Comment deleted successfully",
+	})
+}
+
+// voteComment adds or removes the user's upvote on a comment that is not
+// their own
+func voteComment(c fiber.Ctx, upvote bool) error {
+	userType, userID := commentViewer(c)
+	comment, _, err := getVisibleComment(c)
+	if comment == nil {
+		return err
+	}
+
+	if comment.AuthorType == userType && comment.AuthorID == userID {
+		return c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: "You cannot vote on your own comment",
+		})
+	}
+
+	if upvote {
+		err = database.AddCommentVote(comment.ID, userType, userID)
+	} else {
+		err = database.RemoveCommentVote(comment.ID, userType, userID)
+	}
+	if err == nil {
+		comment, err = database.GetComment(comment.ID, userType, userID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update vote",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    comment,
+	})
+}
+
+// Upvote a comment
+func UpvoteCommentHandler(c fiber.Ctx) error {
+	return voteComment(c, true)
+}
+
+// Remove an upvote
+func RemoveCommentVoteHandler(c fiber.Ctx) error {
+	return voteComment(c, false)
+}
+
+// acceptReply marks or unmarks a reply as the answer to its thread. The
+// video's teacher and the student who started the thread may do this.
+func acceptReply(c fiber.Ctx, accepted bool) error {
+	userType, userID := commentViewer(c)
+	reply, video, err := getVisibleComment(c)
+	if reply == nil {
+		return err
+	}
+
+	if reply.ParentID == 0 {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Only replies can be accepted as answers",
+		})
+	}
+
+	if !isVideoTeacher(c, video) {
+		thread, err := database.GetComment(reply.ParentID, userType, userID)
+		if err != nil && err != sql.ErrNoRows {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Message: "Failed to accept answer",
+			})
+		}
+		if err != nil || thread.AuthorType != userType || thread.AuthorID != userID {
+			return c.Status(403).JSON(models.APIResponse{
+				Success: false,
+				Message: "Only the teacher or the author of the question can accept answers",
+			})
+		}
+	}
+
+	err = database.AcceptReply(reply, accepted)
+	if err == nil {
+		reply, err = database.GetComment(reply.ID, userType, userID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to accept answer",
+		})
+	}
+
+	message := "Answer accepted"
+	if !accepted {
+		message = "Answer no longer accepted"
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    reply,
+	})
+}
+
+// Accept a reply as the answer to its thread
+func AcceptCommentHandler(c fiber.Ctx) error {
+	return acceptReply(c, true)
+}
+
+// Withdraw acceptance of a reply
+func UnacceptCommentHandler(c fiber.Ctx) error {
+	return acceptReply(c, false)
+}
+
+// Hide or show a comment on one of the teacher's videos
+func ModerateCommentHandler(c fiber.Ctx) error {
+	comment, video, err := getVisibleComment(c)
+	if comment == nil {
+		return err
+	}
+	if !isVideoTeacher(c, video) {
+		return c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: "Not authorized to moderate this comment",
+		})
+	}
+
+	var req models.ModerateCommentRequest
+	if err := c.Bind().Body(&req); err != nil || req.Hidden == nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Give hidden as true or false",
+		})
+	}
+
+	err = database.SetCommentHidden(comment.ID, *req.Hidden)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to moderate comment",
+		})
+	}
+	comment.Hidden = *req.Hidden
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Comment updated successfully",
+		Data:    comment,
+	})
+}
+
+// List the questions on the teacher's videos, newest first; ?unanswered=true
+// leaves out those with an accepted answer or a reply from the teacher
+func GetTeacherQuestionsHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	questions, err := database.GetTeacherQuestions(userID, c.Query("unanswered") == "true", maxListedQuestions)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get questions",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    questions,
+	})
+}
@@ -0,0 +1,853 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"educational-platform/database"
+	"educational-platform/models"
+	"educational-platform/storage"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// maxCourseTitle matches the title columns of courses, sections and lessons
+const maxCourseTitle = 200
+
+// checkTitle trims a required title and checks its length
+func checkTitle(title string) (string, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return "", errors.New("Title is required")
+	}
+	if utf8.RuneCountInString(title) > maxCourseTitle {
+		return "", errors.New("Title is too long")
+	}
+	return title, nil
+}
+
+// getOwnedCourse loads the course named by the :id parameter and checks that
+// it belongs to the current teacher. On failure the error response has
+// already been written and the returned course is nil.
+func getOwnedCourse(c fiber.Ctx) (*models.Course, error) {
+	userID := c.Locals("user_id").(int)
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid course ID",
+		})
+	}
+
+	course, err := database.GetCourseByID(courseID)
+	if err != nil {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Course not found",
+		})
+	}
+
+	if course.TeacherID != userID {
+		return nil, c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: "Not authorized to modify this course",
+		})
+	}
+
+	return course, nil
+}
+
+// getCourseSection loads the :section_id section of a course
+func getCourseSection(c fiber.Ctx, course *models.Course) (*models.CourseSection, error) {
+	sectionID, err := strconv.Atoi(c.Params("section_id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid section ID",
+		})
+	}
+
+	section, err := database.GetSection(course.ID, sectionID)
+	if err != nil {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Section not found",
+		})
+	}
+	return section, nil
+}
+
+// getCourseLesson loads the :lesson_id lesson of a course
+func getCourseLesson(c fiber.Ctx, course *models.Course) (*models.Lesson, error) {
+	lessonID, err := strconv.Atoi(c.Params("lesson_id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid lesson ID",
+		})
+	}
+
+	lesson, err := database.GetLesson(course.ID, lessonID)
+	if err != nil {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Lesson not found",
+		})
+	}
+	return lesson, nil
+}
+
+// sendCourseOutline responds with a course and its sections. Students only
+// see lessons whose videos are live, marked with whether they are completed
+// or locked; teachers see every lesson with its prerequisites.
+func sendCourseOutline(c fiber.Ctx, course *models.Course, liveOnly bool) error {
+	sections, err := database.GetCourseSections(course.ID, liveOnly)
+	if err == nil {
+		if liveOnly {
+			err = applyLessonLocks(c.Locals("user_id").(int), course, sections)
+		} else {
+			err = attachPrerequisites(course.ID, sections)
+		}
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get course",
+		})
+	}
+	course.Sections = sections
+
+	if liveOnly {
+		course.LessonCount = 0
+		for _, section := range sections {
+			course.LessonCount += len(section.Lessons)
+		}
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    course,
+	})
+}
+
+// Create a course
+func CreateCourseHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	var req models.CourseRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	var title, description string
+	if req.Title != nil {
+		title = *req.Title
+	}
+	if req.Description != nil {
+		description = *req.Description
+	}
+
+	title, err := checkTitle(title)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	courseID, err := database.CreateCourse(userID, title, description)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to create course",
+		})
+	}
+
+	course, err := database.GetCourseByID(courseID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to create course",
+		})
+	}
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: "Course created successfully",
+		Data:    course,
+	})
+}
+
+// List the teacher's courses
+func GetTeacherCoursesHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	courses, err := database.GetCoursesByTeacherID(userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get courses",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    courses,
+	})
+}
+
+// Get a course outline with every lesson, including unpublished videos
+func GetTeacherCourseHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+	return sendCourseOutline(c, course, false)
+}
+
+// Update a course's details, status or enrollment settings
+func UpdateCourseHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+
+	var req models.CourseRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	title, description, status, sequential := course.Title, course.Description, course.Status, course.Sequential
+	if req.Title != nil {
+		title, err = checkTitle(*req.Title)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+		}
+	}
+	if req.Description != nil {
+		description = *req.Description
+	}
+	if req.Status != nil {
+		status = *req.Status
+		if status != models.CourseStatusDraft && status != models.CourseStatusPublished {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: "Status must be draft or published",
+			})
+		}
+	}
+
+	if req.Sequential != nil {
+		sequential = *req.Sequential
+	}
+
+	changeEnrollment := req.EnrollmentMode != nil || req.EnrollmentCap != nil || req.EnrollmentEndsAt != nil
+	mode, enrollmentCap, endsAt := course.EnrollmentMode, course.EnrollmentCap, course.EnrollmentEndsAt
+	if req.EnrollmentMode != nil {
+		mode = *req.EnrollmentMode
+		if !enrollmentModes[mode] {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: "Enrollment mode must be open, approval or invite",
+			})
+		}
+	}
+	if req.EnrollmentCap != nil {
+		enrollmentCap = *req.EnrollmentCap
+		if enrollmentCap < 0 {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: "Enrollment cap cannot be negative",
+			})
+		}
+	}
+	if req.EnrollmentEndsAt != nil {
+		endsAt, err = parseOptionalTime(*req.EnrollmentEndsAt)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: "Enrollment end date must be an RFC 3339 timestamp",
+			})
+		}
+	}
+
+	err = database.UpdateCourse(course.ID, title, description, status, sequential)
+	if err == nil && changeEnrollment {
+		err = database.UpdateCourseEnrollment(course.ID, mode, enrollmentCap, endsAt)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update course",
+		})
+	}
+
+	updated, err := database.GetCourseByID(course.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update course",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Course updated successfully",
+		Data:    updated,
+	})
+}
+
+// Delete a course; its videos are kept
+func DeleteCourseHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+
+	// Collect the course's submitted files before the rows go
+	submissionFiles, err := database.GetSubmissionFiles(0, course.ID)
+	if err == nil {
+		err = database.DeleteCourse(course.ID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to delete course",
+		})
+	}
+	deleteThumbnail(course.CoverPath)
+	deleteSubmissionFiles(submissionFiles)
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Course deleted successfully",
+	})
+}
+
+// Upload a course cover image
+func UploadCourseCoverHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+
+	img, err := readUploadedImage(c, "cover", "Cover image")
+	if err != nil {
+		e := err.(*fiber.Error)
+		return c.Status(e.Code).JSON(models.APIResponse{
+			Success: false,
+			Message: e.Message,
+		})
+	}
+
+	// A new key per upload keeps caches from serving the old image
+	key := "courses/course_" + strconv.Itoa(course.ID) + "_cover_" + GenerateSessionID()[:8] + ".jpg"
+
+	err = storeThumbnail(key, img)
+	if err == nil {
+		err = database.UpdateCourseCover(course.ID, key)
+	}
+	if err != nil {
+		deleteThumbnail(key)
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to save cover image",
+		})
+	}
+	deleteThumbnail(course.CoverPath)
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Cover image updated successfully",
+	})
+}
+
+// Add a section to the end of a course
+func CreateSectionHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+
+	var req models.SectionRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	title, err := checkTitle(req.Title)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	sectionID, err := database.CreateSection(course.ID, title)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to create section",
+		})
+	}
+
+	section, err := database.GetSection(course.ID, sectionID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to create section",
+		})
+	}
+	section.Lessons = []models.Lesson{}
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: "Section created successfully",
+		Data:    section,
+	})
+}
+
+// Rename a section
+func UpdateSectionHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+	section, err := getCourseSection(c, course)
+	if section == nil {
+		return err
+	}
+
+	var req models.SectionRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	title, err := checkTitle(req.Title)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	err = database.UpdateSectionTitle(section.ID, title)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update section",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Section updated successfully",
+	})
+}
+
+// Delete a section and the lessons in it
+func DeleteSectionHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+	section, err := getCourseSection(c, course)
+	if section == nil {
+		return err
+	}
+
+	err = database.DeleteSection(section.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to delete section",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Section deleted successfully",
+	})
+}
+
+// Add one of the teacher's videos as a lesson at the end of a section
+func CreateLessonHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+	section, err := getCourseSection(c, course)
+	if section == nil {
+		return err
+	}
+
+	var req models.LessonRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	video, err := database.GetVideoByID(req.VideoID)
+	if err != nil || video.TeacherID != course.TeacherID {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Video not found",
+		})
+	}
+
+	exists, err := database.IsVideoInCourse(course.ID, video.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to create lesson",
+		})
+	}
+	if exists {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Message: "Video is already a lesson in this course",
+		})
+	}
+
+	title := ""
+	if req.Title != nil && strings.TrimSpace(*req.Title) != "" {
+		title, err = checkTitle(*req.Title)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	lessonID, err := database.CreateLesson(course.ID, section.ID, video.ID, title)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to create lesson",
+		})
+	}
+
+	lesson, err := database.GetLesson(course.ID, lessonID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to create lesson",
+		})
+	}
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: "Lesson created successfully",
+		Data:    lesson,
+	})
+}
+
+// Change a lesson's title or move it to the end of another section
+func UpdateLessonHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+	lesson, err := getCourseLesson(c, course)
+	if lesson == nil {
+		return err
+	}
+
+	var req models.LessonRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	if req.SectionID != 0 && req.SectionID != lesson.SectionID {
+		if _, err := database.GetSection(course.ID, req.SectionID); err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: "Section not found",
+			})
+		}
+
+		sections, err := database.GetCourseSections(course.ID, false)
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Message: "Failed to update lesson",
+			})
+		}
+		err = checkPrerequisiteOrder(course.ID, moveLessonInOutline(sections, lesson.ID, req.SectionID))
+		if err != nil {
+			e := err.(*fiber.Error)
+			return c.Status(e.Code).JSON(models.APIResponse{
+				Success: false,
+				Message: e.Message,
+			})
+		}
+	}
+
+	if req.Title != nil {
+		title := ""
+		if strings.TrimSpace(*req.Title) != "" {
+			title, err = checkTitle(*req.Title)
+			if err != nil {
+				return c.Status(400).JSON(models.APIResponse{
+					Success: false,
+					Message: err.Error(),
+				})
+			}
+		}
+		err = database.UpdateLessonTitle(lesson.ID, title)
+	}
+	if err == nil && req.SectionID != 0 && req.SectionID != lesson.SectionID {
+		err = database.MoveLesson(lesson.ID, req.SectionID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update lesson",
+		})
+	}
+
+	updated, err := database.GetLesson(course.ID, lesson.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update lesson",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Lesson updated successfully",
+		Data:    updated,
+	})
+}
+
+// Remove a lesson from a course; the video is kept
+func DeleteLessonHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+	lesson, err := getCourseLesson(c, course)
+	if lesson == nil {
+		return err
+	}
+
+	err = database.DeleteLesson(lesson.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to delete lesson",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Lesson deleted successfully",
+	})
+}
+
+// Reorder a course's sections and lessons. The request must list every
+// section and every lesson exactly once; lessons may change sections.
+func ReorderCourseHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+
+	var req models.CourseOrderRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	current, err := database.GetCourseSections(course.ID, false)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get course",
+		})
+	}
+
+	sectionIDs := make(map[int]bool)
+	lessonIDs := make(map[int]bool)
+	for _, section := range current {
+		sectionIDs[section.ID] = true
+		for _, lesson := range section.Lessons {
+			lessonIDs[lesson.ID] = true
+		}
+	}
+
+	order := make([]models.CourseSection, 0, len(req.Sections))
+	for _, section := range req.Sections {
+		if !sectionIDs[section.ID] {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: "The order must list every section of the course exactly once",
+			})
+		}
+		delete(sectionIDs, section.ID)
+
+		ordered := models.CourseSection{ID: section.ID}
+		for _, lessonID := range section.Lessons {
+			if !lessonIDs[lessonID] {
+				return c.Status(400).JSON(models.APIResponse{
+					Success: false,
+					Message: "The order must list every lesson of the course exactly once",
+				})
+			}
+			delete(lessonIDs, lessonID)
+			ordered.Lessons = append(ordered.Lessons, models.Lesson{ID: lessonID})
+		}
+		order = append(order, ordered)
+	}
+	if len(sectionIDs) > 0 || len(lessonIDs) > 0 {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "The order must list every section and lesson of the course exactly once",
+		})
+	}
+
+	err = checkPrerequisiteOrder(course.ID, order)
+	if err != nil {
+		e := err.(*fiber.Error)
+		return c.Status(e.Code).JSON(models.APIResponse{
+			Success: false,
+			Message: e.Message,
+		})
+	}
+
+	err = database.ReorderCourse(course.ID, order)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to reorder course",
+		})
+	}
+
+	return sendCourseOutline(c, course, false)
+}
+
+// List published courses from subscribed teachers and the student's own
+// enrollments
+func GetStudentCoursesHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	courses, err := database.GetCoursesForStudent(userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get courses",
+		})
+	}
+
+	enrollments, err := database.GetStudentEnrollments(userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get courses",
+		})
+	}
+	byCourse := make(map[int]*models.Enrollment)
+	for i := range enrollments {
+		byCourse[enrollments[i].CourseID] = &enrollments[i]
+	}
+	for i := range courses {
+		courses[i].InviteCode = ""
+		courses[i].Enrollment = byCourse[courses[i].ID]
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    courses,
+	})
+}
+
+// Get the outline of a published course along with the student's enrollment
+func GetStudentCourseHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid course ID",
+		})
+	}
+
+	course, err := database.GetCourseByID(courseID)
+	if err != nil || course.Status != models.CourseStatusPublished {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Course not found",
+		})
+	}
+	course.InviteCode = ""
+
+	course.Enrollment, err = database.GetEnrollment(course.ID, userID)
+	if err != nil && err != sql.ErrNoRows {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get course",
+		})
+	}
+
+	return sendCourseOutline(c, course, true)
+}
+
+// Serve a course cover image; draft courses only to their teacher
+func ServeCourseCoverHandler(c fiber.Ctx) error {
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid course ID",
+		})
+	}
+
+	course, err := database.GetCourseByID(courseID)
+	if err == nil && course.Status != models.CourseStatusPublished {
+		if !loadSession(c) || c.Locals("user_type") != "teacher" || c.Locals("user_id") != course.TeacherID {
+			err = storage.ErrNotFound
+		}
+	}
+	if err != nil || course.CoverPath == "" {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Cover image not found",
+		})
+	}
+
+	width, _ := strconv.Atoi(c.Query("w"))
+	key := thumbnailSizeKey(course.CoverPath, pickThumbnailSize(c.Query("size"), width))
+
+	if course.Status == models.CourseStatusPublished {
+		c.Set("Cache-Control", "public, max-age=86400")
+	} else {
+		c.Set("Cache-Control", "private, max-age=86400")
+	}
+	return sendStoredObject(c, key, "image/jpeg", "Cover image not found")
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"educational-platform/database"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+var enrollmentModes = map[string]bool{
+	models.EnrollmentOpen:     true,
+	models.EnrollmentApproval: true,
+	models.EnrollmentInvite:   true,
+}
+
+// parseOptionalTime reads an RFC 3339 timestamp as UTC; "" means no time
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	t = t.UTC()
+	return &t, nil
+}
+
+// enrollmentExpired reports whether an enrollment's access has ended
+func enrollmentExpired(enrollment *models.Enrollment) bool {
+	return enrollment.ExpiresAt != nil && !enrollment.ExpiresAt.After(time.Now())
+}
+
+// getCourseEnrollment loads the :enrollment_id enrollment of a course
+func getCourseEnrollment(c fiber.Ctx, course *models.Course) (*models.Enrollment, error) {
+	enrollmentID, err := strconv.Atoi(c.Params("enrollment_id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid enrollment ID",
+		})
+	}
+
+	enrollment, err := database.GetEnrollmentByID(course.ID, enrollmentID)
+	if err != nil {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Enrollment not found",
+		})
+	}
+	return enrollment, nil
+}
+
+// Enroll in a published course. Open courses enroll right away, approval
+// courses create a pending request and invite courses need the invite code.
+func EnrollCourseHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid course ID",
+		})
+	}
+
+	var req models.EnrollRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().Body(&req); err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: "Invalid request body",
+			})
+		}
+	}
+
+	course, err := database.GetCourseByID(courseID)
+	if err != nil || course.Status != models.CourseStatusPublished {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Course not found",
+		})
+	}
+
+	existing, err := database.GetEnrollment(course.ID, userID)
+	if err != nil && err != sql.ErrNoRows {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to enroll",
+		})
+	}
+	if existing != nil {
+		message := "You are already enrolled in this course"
+		code := 409
+		if existing.Status == models.EnrollmentStatusPending {
+			message = "Your enrollment request is awaiting approval"
+		} else if enrollmentExpired(existing) {
+			message = "Your enrollment in this course has ended"
+			code = 403
+		}
+		return c.Status(code).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	if course.EnrollmentEndsAt != nil && !course.EnrollmentEndsAt.After(time.Now()) {
+		return c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: "Enrollment for this course has closed",
+		})
+	}
+
+	status := models.EnrollmentStatusActive
+	message := "Enrolled successfully"
+	switch course.EnrollmentMode {
+	case models.EnrollmentApproval:
+		status = models.EnrollmentStatusPending
+		message = "Enrollment request sent"
+	case models.EnrollmentInvite:
+		code := strings.ToUpper(strings.TrimSpace(req.InviteCode))
+		if code == "" || subtle.ConstantTimeCompare([]byte(code), []byte(course.InviteCode)) != 1 {
+			return c.Status(403).JSON(models.APIResponse{
+				Success: false,
+				Message: "Invalid invite code",
+			})
+		}
+	}
+
+	err = database.CreateEnrollment(course.ID, userID, status, course.EnrollmentCap)
+	if err == database.ErrCourseFull {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Message: "This course is full",
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to enroll",
+		})
+	}
+
+	enrollment, err := database.GetEnrollment(course.ID, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to enroll",
+		})
+	}
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    enrollment,
+	})
+}
+
+// Leave a course or withdraw a pending request
+func LeaveCourseHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	courseID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid course ID",
+		})
+	}
+
+	enrollment, err := database.GetEnrollment(courseID, userID)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Enrollment not found",
+		})
+	}
+
+	err = database.DeleteEnrollment(enrollment.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to leave course",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Left course successfully",
+	})
+}
+
+// List the student's enrollments and pending requests
+func GetStudentEnrollmentsHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	enrollments, err := database.GetStudentEnrollments(userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get enrollments",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    enrollments,
+	})
+}
+
+// List a course's enrollments; ?status=pending lists requests to approve
+func GetCourseEnrollmentsHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+
+	status := c.Query("status")
+	if status != "" && status != models.EnrollmentStatusPending && status != models.EnrollmentStatusActive {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Status must be pending or active",
+		})
+	}
+
+	enrollments, err := database.GetCourseEnrollments(course.ID, status)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get enrollments",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    enrollments,
+	})
+}
+
+// Approve a pending enrollment or change when an enrollment expires
+func UpdateEnrollmentHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+	enrollment, err := getCourseEnrollment(c, course)
+	if enrollment == nil {
+		return err
+	}
+
+	var req models.EnrollmentUpdateRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	if req.Status != nil && *req.Status != models.EnrollmentStatusActive {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Status can only be set to active",
+		})
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != nil {
+		expiresAt, err = parseOptionalTime(*req.ExpiresAt)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: "Expiry must be an RFC 3339 timestamp",
+			})
+		}
+	}
+
+	if req.Status != nil && enrollment.Status == models.EnrollmentStatusPending {
+		err = database.ActivateEnrollment(enrollment.ID, course.ID, course.EnrollmentCap)
+		if err == database.ErrCourseFull {
+			return c.Status(409).JSON(models.APIResponse{
+				Success: false,
+				Message: "This course is full",
+			})
+		}
+	}
+	if err == nil && req.ExpiresAt != nil {
+		err = database.UpdateEnrollmentExpiry(enrollment.ID, expiresAt)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update enrollment",
+		})
+	}
+
+	updated, err := database.GetEnrollmentByID(course.ID, enrollment.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update enrollment",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Enrollment updated successfully",
+		Data:    updated,
+	})
+}
+
+// Reject a pending request or remove a student from a course
+func DeleteEnrollmentHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+	enrollment, err := getCourseEnrollment(c, course)
+	if enrollment == nil {
+		return err
+	}
+
+	err = database.DeleteEnrollment(enrollment.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to remove enrollment",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Enrollment removed successfully",
+	})
+}
+
+// Replace a course's invite code, invalidating the old one
+func ResetInviteCodeHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+
+	code, err := database.ResetInviteCode(course.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to reset invite code",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Invite code reset successfully",
+		Data: map[string]interface{}{
+			"invite_code": code,
+		},
+	})
+}
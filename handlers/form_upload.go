@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// maxFormFields limits the text fields read alongside an uploaded file, in
+// bytes for all of them together
+const maxFormFields = 1 << 20
+
+// formUpload is a multipart form read from the request stream: its text
+// fields and at most one file, staged on disk
+type formUpload struct {
+	c        fiber.Ctx
+	fields   map[string]string // nil when the body was not multipart
+	Filename string            // of the file part, "" without one
+	Path     string            // the staged file
+	Size     int64
+}
+
+// readFormUpload streams a multipart/form-data body, staging the first part
+// named fileField on disk. Bodies and files larger than maxFile are rejected
+// with a 413 carrying tooLarge before more of them is read. Other bodies are
+// left to the usual form parsing, without a file. Errors are *fiber.Error
+// values; otherwise the caller must call Remove.
+func readFormUpload(c fiber.Ctx, fileField string, maxFile int64, tooLarge string) (*formUpload, error) {
+	form := &formUpload{c: c}
+	mediaType, params, err := mime.ParseMediaType(c.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		return form, nil
+	}
+	if params["boundary"] == "" {
+		return nil, fiber.NewError(400, "Failed to parse form")
+	}
+
+	if length := int64(c.Request().Header.ContentLength()); length > maxFile+maxFormFields {
+		return nil, fiber.NewError(413, tooLarge)
+	}
+
+	form.fields = make(map[string]string)
+	fieldBytes := int64(0)
+	reader := multipart.NewReader(requestBodyReader(c), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return form, nil
+		}
+		if err != nil {
+			form.Remove()
+			return nil, fiber.NewError(400, "Failed to parse form")
+		}
+
+		switch {
+		case part.FormName() == fileField && part.FileName() != "":
+			if form.Path != "" {
+				continue
+			}
+			form.Filename = part.FileName()
+			err = form.stage(part, maxFile, tooLarge)
+		case part.FileName() == "":
+			var value []byte
+			value, err = io.ReadAll(io.LimitReader(part, maxFormFields-fieldBytes+1))
+			fieldBytes += int64(len(value))
+			if err == nil && fieldBytes > maxFormFields {
+				err = fiber.NewError(413, "Form fields are too large")
+			}
+			if _, ok := form.fields[part.FormName()]; !ok {
+				form.fields[part.FormName()] = string(value)
+			}
+		}
+
+		if err != nil {
+			form.Remove()
+			if _, ok := err.(*fiber.Error); !ok {
+				err = fiber.NewError(400, "Failed to parse form")
+			}
+			return nil, err
+		}
+	}
+}
+
+// stage copies a file part into the staging directory, stopping as soon as
+// it exceeds max bytes
+func (f *formUpload) stage(part *multipart.Part, max int64, tooLarge string) error {
+	file, err := os.CreateTemp(StagingDir, "form_*")
+	if err != nil {
+		return fiber.NewError(500, "Failed to save file")
+	}
+	f.Path = file.Name()
+
+	f.Size, err = io.Copy(file, io.LimitReader(part, max+1))
+	file.Close()
+	if err != nil {
+		return err
+	}
+	if f.Size > max {
+		return fiber.NewError(413, tooLarge)
+	}
+	return nil
+}
+
+// Value returns a text field of the form
+func (f *formUpload) Value(name string) string {
+	if f.fields == nil {
+		return f.c.FormValue(name)
+	}
+	return f.fields[name]
+}
+
+// HasFile reports whether the form carried the file
+func (f *formUpload) HasFile() bool {
+	return f.Path != ""
+}
+
+// Open opens the staged file for reading
+func (f *formUpload) Open() (*os.File, error) {
+	return os.Open(f.Path)
+}
+
+// Remove deletes the staged file
+func (f *formUpload) Remove() {
+	if f.Path != "" {
+		os.Remove(f.Path)
+		f.Path = ""
+	}
+}
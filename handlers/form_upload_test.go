@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/valyala/fasthttp"
+)
+
+// uploadConfig matches how main.go serves uploads
+var uploadConfig = fiber.Config{StreamRequestBody: true, DisablePreParseMultipartForm: true}
+
+func TestReadFormUpload(t *testing.T) {
+	stagingDir := StagingDir
+	StagingDir = t.TempDir()
+	t.Cleanup(func() { StagingDir = stagingDir })
+
+	var staged string
+	app := fiber.New(uploadConfig)
+	app.Post("/", func(c fiber.Ctx) error {
+		form, err := readFormUpload(c, "file", 10, "File too large")
+		if err != nil {
+			e := err.(*fiber.Error)
+			return c.Status(e.Code).SendString(e.Message)
+		}
+		defer form.Remove()
+		staged = form.Path
+		content := ""
+		if form.HasFile() {
+			file, err := form.Open()
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			data, _ := io.ReadAll(file)
+			content = string(data)
+		}
+		return c.SendString(form.Value("text") + "|" + form.Filename + "|" + content)
+	})
+
+	multipartBody := func(text, file string) (string, io.Reader) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		writer.WriteField("text", text)
+		if file != "" {
+			part, _ := writer.CreateFormFile("file", "notes.txt")
+			part.Write([]byte(file))
+		}
+		writer.Close()
+		return writer.FormDataContentType(), body
+	}
+
+	tests := []struct {
+		name   string
+		file   string // multipart file content, "" for none
+		form   string // url encoded body instead of multipart
+		status int
+		want   string
+	}{
+		{"text only", "", "", 200, "hello||"},
+		{"with file", "0123456789", "", 200, "hello|notes.txt|0123456789"},
+		{"file too large", "0123456789a", "", 413, "File too large"},
+		{"url encoded", "", "text=hello", 200, "hello||"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tt.form)
+			contentType := "application/x-www-form-urlencoded"
+			if tt.form == "" {
+				contentType, body = multipartBody("hello", tt.file)
+			}
+			staged = ""
+			req := httptest.NewRequest("POST", "/", body)
+			req.Header.Set("Content-Type", contentType)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.status || string(got) != tt.want {
+				t.Errorf("got %d %q, want %d %q", resp.StatusCode, got, tt.status, tt.want)
+			}
+			if staged != "" {
+				if _, err := os.Stat(staged); !os.IsNotExist(err) {
+					t.Errorf("staged file kept: %v", err)
+				}
+			}
+			if entries, _ := os.ReadDir(StagingDir); len(entries) != 0 {
+				t.Errorf("%d files left in the staging directory", len(entries))
+			}
+		})
+	}
+}
+
+func TestReadFormUploadContentLength(t *testing.T) {
+	// Only the header is sent: the body must not be read to reject it
+	app := fiber.New(uploadConfig)
+	fctx := &fasthttp.RequestCtx{}
+	fctx.Request.Header.SetContentType("multipart/form-data; boundary=x")
+	fctx.Request.Header.SetContentLength(maxFormFields + 11)
+	c := app.AcquireCtx(fctx)
+	defer app.ReleaseCtx(c)
+
+	_, err := readFormUpload(c, "file", 10, "File too large")
+	if e, ok := err.(*fiber.Error); !ok || e.Code != 413 {
+		t.Errorf("err = %v, want 413 before parsing", err)
+	}
+}
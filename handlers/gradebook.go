@@ -0,0 +1,524 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"educational-platform/database"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Gradebook limits
+const (
+	maxGradeCategories    = 20
+	maxCategoryName       = 100 // characters
+	maxCategoryWeight     = 1000
+	maxCategoryDropLowest = 50
+)
+
+// letterGrades maps final percentages to letters, highest first
+var letterGrades = []struct {
+	min    float64
+	letter string
+}{
+	{90, "A"},
+	{80, "B"},
+	{70, "C"},
+	{60, "D"},
+	{0, "F"},
+}
+
+func letterGrade(percent float64) string {
+	for _, grade := range letterGrades {
+		if percent >= grade.min {
+			return grade.letter
+		}
+	}
+	return "F"
+}
+
+// checkGradeCategory checks that a category belongs to the gradebook of the
+// given teacher and course (0 for the teacher's own)
+func checkGradeCategory(teacherID, courseID, categoryID int) error {
+	category, err := database.GetGradeCategory(categoryID)
+	if err != nil || category.TeacherID != teacherID {
+		return errors.New("Gradebook category not found")
+	}
+	if category.CourseID != courseID {
+		return errors.New("Gradebook category belongs to another gradebook")
+	}
+	return nil
+}
+
+// gradeKey identifies a student's result on a gradebook item
+type gradeKey struct {
+	itemType  string
+	itemID    int
+	studentID int
+}
+
+// gradeStudent works out a student's scores, category averages and final
+// grade. Graded work counts, and so does missing work, as 0; work that is
+// not due yet or waiting to be graded does not. Without categories every
+// item counts equally; with them, uncategorized items do not count.
+func gradeStudent(book *models.Gradebook, row *models.StudentGrades, results map[gradeKey]models.GradeResult, now time.Time) {
+	row.Scores = make([]models.GradeScore, len(book.Items))
+	for i, item := range book.Items {
+		score := models.GradeScore{Type: item.Type, ID: item.ID, Status: models.SubmissionStatusNotSubmitted}
+		result, ok := results[gradeKey{item.Type, item.ID, row.StudentID}]
+		switch {
+		case ok && result.Graded:
+			points, percent := roundScore(result.Points), roundScore(result.Percent)
+			score.Status, score.Points, score.Percent = models.SubmissionStatusGraded, &points, &percent
+		case ok:
+			score.Status = models.SubmissionStatusSubmitted
+		case item.DueAt != nil && now.After(*item.DueAt):
+			zero, zeroPercent := 0.0, 0.0
+			score.Status, score.Points, score.Percent = models.SubmissionStatusMissing, &zero, &zeroPercent
+		}
+		row.Scores[i] = score
+	}
+
+	// average returns the mean percent of the counted scores in the given
+	// category, after dropping the lowest; all is the implicit category
+	average := func(categoryID, dropLowest int, all bool) *float64 {
+		var counted []int
+		for i, item := range book.Items {
+			if (all || item.CategoryID == categoryID) && row.Scores[i].Percent != nil {
+				counted = append(counted, i)
+			}
+		}
+		if len(counted) == 0 {
+			return nil
+		}
+
+		sort.SliceStable(counted, func(a, b int) bool {
+			return *row.Scores[counted[a]].Percent < *row.Scores[counted[b]].Percent
+		})
+		// Always keep at least one score
+		drop := min(dropLowest, len(counted)-1)
+		for _, i := range counted[:drop] {
+			row.Scores[i].Dropped = true
+		}
+
+		total := 0.0
+		for _, i := range counted[drop:] {
+			total += *row.Scores[i].Percent
+		}
+		percent := roundScore(total / float64(len(counted)-drop))
+		return &percent
+	}
+
+	row.Categories = []models.CategoryGrade{}
+	if len(book.Categories) == 0 {
+		row.FinalPercent = average(0, 0, true)
+	} else {
+		var weighted, weights float64
+		for _, category := range book.Categories {
+			percent := average(category.ID, category.DropLowest, false)
+			row.Categories = append(row.Categories, models.CategoryGrade{CategoryID: category.ID, Percent: percent})
+			if percent != nil && category.Weight > 0 {
+				weighted += *percent * category.Weight
+				weights += category.Weight
+			}
+		}
+		// Weights are relative; categories with nothing counted yet are left out
+		if weights > 0 {
+			final := roundScore(weighted / weights)
+			row.FinalPercent = &final
+		}
+	}
+	if row.FinalPercent != nil {
+		row.Letter = letterGrade(*row.FinalPercent)
+	}
+}
+
+// loadGradebook builds the gradebook of a teacher's own quizzes and
+// assignments, or of a course's. Rows cover the subscribers or the course's
+// active students, and anyone else with results; with studentID set, only
+// that student's row is built.
+func loadGradebook(teacherID, courseID, studentID int) (*models.Gradebook, error) {
+	book := &models.Gradebook{TeacherID: teacherID, CourseID: courseID}
+
+	var err error
+	book.Categories, err = database.GetGradeCategories(teacherID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	book.Items, err = database.GetGradeItems(teacherID, courseID)
+	if err != nil {
+		return nil, err
+	}
+	results, err := database.GetGradeResults(teacherID, courseID, studentID)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []models.StudentGrades
+	seen := make(map[int]bool)
+	addRow := func(id int, name string) {
+		if !seen[id] {
+			seen[id] = true
+			rows = append(rows, models.StudentGrades{StudentID: id, StudentName: name})
+		}
+	}
+
+	switch {
+	case studentID != 0:
+		student, err := database.GetStudentByID(studentID)
+		if err != nil {
+			return nil, err
+		}
+		addRow(student.ID, student.Name)
+	case courseID != 0:
+		enrollments, err := database.GetCourseEnrollments(courseID, models.EnrollmentStatusActive)
+		if err != nil {
+			return nil, err
+		}
+		for _, enrollment := range enrollments {
+			addRow(enrollment.StudentID, enrollment.StudentName)
+		}
+	default:
+		subscriptions, err := database.GetSubscriptionsByTeacherID(teacherID)
+		if err != nil {
+			return nil, err
+		}
+		for _, sub := range subscriptions {
+			addRow(sub.StudentID, sub.StudentName)
+		}
+	}
+
+	byKey := make(map[gradeKey]models.GradeResult, len(results))
+	for _, result := range results {
+		byKey[gradeKey{result.Type, result.ItemID, result.StudentID}] = result
+		addRow(result.StudentID, result.StudentName)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := strings.ToLower(rows[i].StudentName), strings.ToLower(rows[j].StudentName)
+		if a != b {
+			return a < b
+		}
+		return rows[i].StudentID < rows[j].StudentID
+	})
+
+	now := time.Now()
+	for i := range rows {
+		gradeStudent(book, &rows[i], byKey, now)
+	}
+	book.Students = rows
+	if book.Students == nil {
+		book.Students = []models.StudentGrades{}
+	}
+	return book, nil
+}
+
+// getGradebookScope reads the ?course_id= of a teacher's gradebook request,
+// 0 for their own gradebook, and checks that they own the course. On failure
+// the error response has already been written and ok is false.
+func getGradebookScope(c fiber.Ctx) (*models.Course, bool, error) {
+	userID := c.Locals("user_id").(int)
+	s := c.Query("course_id")
+	if s == "" || s == "0" {
+		return nil, true, nil
+	}
+
+	courseID, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, false, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid course ID",
+		})
+	}
+
+	course, err := database.GetCourseByID(courseID)
+	if err != nil || course.TeacherID != userID {
+		return nil, false, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Course not found",
+		})
+	}
+	return course, true, nil
+}
+
+// applyGradeCategorySettings copies the settings of a request onto a category
+func applyGradeCategorySettings(category *models.GradeCategory, req *models.GradeCategoryRequest) error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return errors.New("Name is required")
+		}
+		if utf8.RuneCountInString(name) > maxCategoryName {
+			return errors.New("Name is too long")
+		}
+		category.Name = name
+	}
+	if req.Weight != nil {
+		if *req.Weight < 0 || *req.Weight > maxCategoryWeight {
+			return fmt.Errorf("Weight must be between 0 and %d", maxCategoryWeight)
+		}
+		category.Weight = *req.Weight
+	}
+	if req.DropLowest != nil {
+		if *req.DropLowest < 0 || *req.DropLowest > maxCategoryDropLowest {
+			return fmt.Errorf("Drop lowest must be between 0 and %d", maxCategoryDropLowest)
+		}
+		category.DropLowest = *req.DropLowest
+	}
+	return nil
+}
+
+// getOwnedGradeCategory loads the category named by the :id parameter and
+// checks that it belongs to the current teacher. On failure the error
+// response has already been written and the returned category is nil.
+func getOwnedGradeCategory(c fiber.Ctx) (*models.GradeCategory, error) {
+	userID := c.Locals("user_id").(int)
+	categoryID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid category ID",
+		})
+	}
+
+	category, err := database.GetGradeCategory(categoryID)
+	if err != nil {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Category not found",
+		})
+	}
+
+	if category.TeacherID != userID {
+		return nil, c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: "Not authorized to modify this category",
+		})
+	}
+
+	return category, nil
+}
+
+// Get the teacher's own gradebook, or a course's with ?course_id=
+func GetTeacherGradebookHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	course, ok, err := getGradebookScope(c)
+	if !ok {
+		return err
+	}
+
+	courseID := 0
+	if course != nil {
+		courseID = course.ID
+	}
+	book, err := loadGradebook(userID, courseID, 0)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get gradebook",
+		})
+	}
+	if course != nil {
+		book.CourseTitle = course.Title
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    book,
+	})
+}
+
+// Add a category to the teacher's own gradebook or a course's
+func CreateGradeCategoryHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	var req models.GradeCategoryRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	invalid := func(message string) error {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	if req.Name == nil {
+		return invalid("Name is required")
+	}
+	category := &models.GradeCategory{TeacherID: userID}
+	if req.CourseID != nil && *req.CourseID != 0 {
+		course, err := database.GetCourseByID(*req.CourseID)
+		if err != nil || course.TeacherID != userID {
+			return invalid("Course not found")
+		}
+		category.CourseID = course.ID
+	}
+	err := applyGradeCategorySettings(category, &req)
+	if err != nil {
+		return invalid(err.Error())
+	}
+
+	existing, err := database.GetGradeCategories(userID, category.CourseID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to create category",
+		})
+	}
+	if len(existing) >= maxGradeCategories {
+		return invalid(fmt.Sprintf("A gradebook can have at most %d categories", maxGradeCategories))
+	}
+
+	categoryID, err := database.CreateGradeCategory(category)
+	if err == nil {
+		category, err = database.GetGradeCategory(categoryID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to create category",
+		})
+	}
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: "Category created successfully",
+		Data:    category,
+	})
+}
+
+// Rename a gradebook category or change its weight or drop rule
+func UpdateGradeCategoryHandler(c fiber.Ctx) error {
+	category, err := getOwnedGradeCategory(c)
+	if category == nil {
+		return err
+	}
+
+	var req models.GradeCategoryRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	err = applyGradeCategorySettings(category, &req)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	err = database.UpdateGradeCategory(category)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update category",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Category updated successfully",
+		Data:    category,
+	})
+}
+
+// Delete a gradebook category; its quizzes and assignments become uncategorized
+func DeleteGradeCategoryHandler(c fiber.Ctx) error {
+	category, err := getOwnedGradeCategory(c)
+	if category == nil {
+		return err
+	}
+
+	err = database.DeleteGradeCategory(category.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to delete category",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Category deleted successfully",
+	})
+}
+
+// Get the student's own grades in a course's gradebook (?course_id=) or a
+// teacher's (?teacher_id=)
+func GetStudentGradebookHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	invalid := func(message string) error {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+	notFound := func() error {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Gradebook not found",
+		})
+	}
+
+	var teacherID, courseID int
+	var courseTitle string
+	switch {
+	case c.Query("course_id") != "":
+		id, err := strconv.Atoi(c.Query("course_id"))
+		if err != nil {
+			return invalid("Invalid course ID")
+		}
+		course, err := database.GetCourseByID(id)
+		if err != nil || course.Status != models.CourseStatusPublished {
+			return notFound()
+		}
+		// Grades stay visible after access expires
+		enrollment, err := database.GetEnrollment(course.ID, userID)
+		if err != nil || enrollment.Status != models.EnrollmentStatusActive {
+			return notFound()
+		}
+		teacherID, courseID, courseTitle = course.TeacherID, course.ID, course.Title
+	case c.Query("teacher_id") != "":
+		id, err := strconv.Atoi(c.Query("teacher_id"))
+		if err != nil {
+			return invalid("Invalid teacher ID")
+		}
+		subscribed, err := database.IsSubscribed(userID, id)
+		if err != nil || !subscribed {
+			return notFound()
+		}
+		teacherID = id
+	default:
+		return invalid("Give a course_id or teacher_id")
+	}
+
+	book, err := loadGradebook(teacherID, courseID, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get gradebook",
+		})
+	}
+	book.CourseTitle = courseTitle
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    book,
+	})
+}
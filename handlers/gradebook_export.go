@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// gradebookTable lays a gradebook out as rows of cells, a header first.
+// Cells are strings, float64 numbers or nil for blanks. Scores are
+// percentages; work that does not count yet is left blank.
+func gradebookTable(book *models.Gradebook) [][]interface{} {
+	header := []interface{}{"Student ID", "Student"}
+	for _, item := range book.Items {
+		header = append(header, fmt.Sprintf("%s (%s, %%)", item.Title, item.Type))
+	}
+	for _, category := range book.Categories {
+		header = append(header, category.Name+" (%)")
+	}
+	header = append(header, "Final (%)", "Letter")
+
+	table := [][]interface{}{header}
+	for _, student := range book.Students {
+		row := []interface{}{float64(student.StudentID), student.StudentName}
+		for _, score := range student.Scores {
+			if score.Percent != nil {
+				row = append(row, *score.Percent)
+			} else {
+				row = append(row, nil)
+			}
+		}
+		for _, category := range student.Categories {
+			if category.Percent != nil {
+				row = append(row, *category.Percent)
+			} else {
+				row = append(row, nil)
+			}
+		}
+		if student.FinalPercent != nil {
+			row = append(row, *student.FinalPercent, student.Letter)
+		} else {
+			row = append(row, nil, nil)
+		}
+		table = append(table, row)
+	}
+	return table
+}
+
+// csvCell formats a cell for CSV. Text that spreadsheets would read as a
+// formula is prefixed with an apostrophe.
+func csvCell(cell interface{}) string {
+	switch v := cell.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	}
+	return ""
+}
+
+func writeGradebookCSV(w io.Writer, table [][]interface{}) error {
+	writer := csv.NewWriter(w)
+	for _, row := range table {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			record[i] = csvCell(cell)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// xlsxColumn names a zero-based column the way spreadsheets do: A, B, ... Z, AA
+func xlsxColumn(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+// The fixed parts of a single-sheet workbook
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`
+
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Gradebook" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`
+)
+
+// xlsxSheet writes a worksheet with the header row frozen. Text is stored
+// inline rather than in a shared strings table to keep the package small.
+func xlsxSheet(table [][]interface{}) []byte {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	b.WriteString(`<sheetViews><sheetView workbookViewId="0">` +
+		`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	b.WriteString(`<sheetData>`)
+	for r, row := range table {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for col, cell := range row {
+			ref := xlsxColumn(col) + strconv.Itoa(r+1)
+			switch v := cell.(type) {
+			case float64:
+				fmt.Fprintf(&b, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'f', -1, 64))
+			case string:
+				fmt.Fprintf(&b, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+				xml.EscapeText(&b, []byte(v))
+				b.WriteString(`</t></is></c>`)
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.Bytes()
+}
+
+// writeGradebookXLSX writes the table as an Office Open XML workbook
+func writeGradebookXLSX(w io.Writer, table [][]interface{}) error {
+	parts := []struct {
+		name string
+		data []byte
+	}{
+		{"[Content_Types].xml", []byte(xlsxContentTypes)},
+		{"_rels/.rels", []byte(xlsxRootRels)},
+		{"xl/workbook.xml", []byte(xlsxWorkbook)},
+		{"xl/_rels/workbook.xml.rels", []byte(xlsxWorkbookRels)},
+		{"xl/worksheets/sheet1.xml", xlsxSheet(table)},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, part := range parts {
+		f, err := archive.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(part.data); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+// Export the teacher's own gradebook, or a course's with ?course_id=, as
+// ?format=csv (the default) or xlsx
+func ExportGradebookHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	course, ok, err := getGradebookScope(c)
+	if !ok {
+		return err
+	}
+
+	format := c.Query("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Format must be csv or xlsx",
+		})
+	}
+
+	courseID := 0
+	filename := "gradebook"
+	if course != nil {
+		courseID = course.ID
+		filename = fmt.Sprintf("gradebook_course_%d", course.ID)
+	}
+
+	book, err := loadGradebook(userID, courseID, 0)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to export gradebook",
+		})
+	}
+
+	var out bytes.Buffer
+	contentType := "text/csv; charset=utf-8"
+	if format == "xlsx" {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		err = writeGradebookXLSX(&out, gradebookTable(book))
+	} else {
+		// A byte order mark makes Excel read the file as UTF-8
+		out.WriteString("\ufeff")
+		err = writeGradebookCSV(&out, gradebookTable(book))
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to export gradebook",
+		})
+	}
+
+	c.Set("Content-Type", contentType)
+	c.Set("Content-Disposition", `attachment; filename="`+filename+"."+format+`"`)
+	c.Set("Cache-Control", "private, no-cache")
+	return c.Send(out.Bytes())
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"educational-platform/models"
+)
+
+func TestGradeStudent(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-24*time.Hour), now.Add(24*time.Hour)
+
+	graded := func(itemType string, id int, percent float64) models.GradeResult {
+		return models.GradeResult{Type: itemType, ItemID: id, StudentID: 1, Graded: true, Points: percent / 10, Percent: percent}
+	}
+	quiz := func(id, categoryID int, dueAt *time.Time) models.GradeItem {
+		return models.GradeItem{Type: models.GradeItemQuiz, ID: id, CategoryID: categoryID, MaxPoints: 10, DueAt: dueAt}
+	}
+	assignment := func(id, categoryID int, dueAt *time.Time) models.GradeItem {
+		return models.GradeItem{Type: models.GradeItemAssignment, ID: id, CategoryID: categoryID, MaxPoints: 10, DueAt: dueAt}
+	}
+
+	tests := []struct {
+		name       string
+		categories []models.GradeCategory
+		items      []models.GradeItem
+		results    []models.GradeResult
+		status     []string
+		dropped    []bool
+		categoryPc []*float64 // per category, in order
+		final      *float64
+		letter     string
+	}{
+		{
+			name:    "nothing graded",
+			items:   []models.GradeItem{quiz(1, 0, nil), assignment(1, 0, &future)},
+			status:  []string{models.SubmissionStatusNotSubmitted, models.SubmissionStatusNotSubmitted},
+			dropped: []bool{false, false},
+		},
+		{
+			name:  "plain average",
+			items: []models.GradeItem{quiz(1, 0, nil), quiz(2, 0, nil), assignment(1, 0, nil)},
+			results: []models.GradeResult{
+				graded(models.GradeItemQuiz, 1, 100),
+				graded(models.GradeItemQuiz, 2, 70),
+				graded(models.GradeItemAssignment, 1, 85.04),
+			},
+			status:  []string{models.SubmissionStatusGraded, models.SubmissionStatusGraded, models.SubmissionStatusGraded},
+			dropped: []bool{false, false, false},
+			final:   ptr(85.0),
+			letter:  "B",
+		},
+		{
+			name:  "submitted work does not count",
+			items: []models.GradeItem{quiz(1, 0, nil), assignment(1, 0, &past)},
+			results: []models.GradeResult{
+				graded(models.GradeItemQuiz, 1, 92),
+				{Type: models.GradeItemAssignment, ItemID: 1, StudentID: 1},
+			},
+			status:  []string{models.SubmissionStatusGraded, models.SubmissionStatusSubmitted},
+			dropped: []bool{false, false},
+			final:   ptr(92.0),
+			letter:  "A",
+		},
+		{
+			name:    "missing counts as zero",
+			items:   []models.GradeItem{quiz(1, 0, nil), assignment(1, 0, &past), assignment(2, 0, &future)},
+			results: []models.GradeResult{graded(models.GradeItemQuiz, 1, 90)},
+			status:  []string{models.SubmissionStatusGraded, models.SubmissionStatusMissing, models.SubmissionStatusNotSubmitted},
+			dropped: []bool{false, false, false},
+			final:   ptr(45.0),
+			letter:  "F",
+		},
+		{
+			name: "weighted categories",
+			categories: []models.GradeCategory{
+				{ID: 1, Name: "Quizzes", Weight: 25},
+				{ID: 2, Name: "Assignments", Weight: 75},
+			},
+			items: []models.GradeItem{quiz(1, 1, nil), quiz(2, 1, nil), assignment(1, 2, nil)},
+			results: []models.GradeResult{
+				graded(models.GradeItemQuiz, 1, 60),
+				graded(models.GradeItemQuiz, 2, 80),
+				graded(models.GradeItemAssignment, 1, 90),
+			},
+			status:     []string{models.SubmissionStatusGraded, models.SubmissionStatusGraded, models.SubmissionStatusGraded},
+			dropped:    []bool{false, false, false},
+			categoryPc: []*float64{ptr(70.0), ptr(90.0)},
+			final:      ptr(85.0),
+			letter:     "B",
+		},
+		{
+			name: "weights are relative to counted categories",
+			categories: []models.GradeCategory{
+				{ID: 1, Name: "Quizzes", Weight: 1},
+				{ID: 2, Name: "Assignments", Weight: 3},
+				{ID: 3, Name: "Unweighted", Weight: 0},
+			},
+			items: []models.GradeItem{quiz(1, 1, nil), assignment(1, 2, &future), quiz(2, 3, nil)},
+			results: []models.GradeResult{
+				graded(models.GradeItemQuiz, 1, 64),
+				graded(models.GradeItemQuiz, 2, 10),
+			},
+			status:     []string{models.SubmissionStatusGraded, models.SubmissionStatusNotSubmitted, models.SubmissionStatusGraded},
+			dropped:    []bool{false, false, false},
+			categoryPc: []*float64{ptr(64.0), nil, ptr(10.0)},
+			final:      ptr(64.0),
+			letter:     "D",
+		},
+		{
+			name: "uncategorized items are ignored with categories",
+			categories: []models.GradeCategory{
+				{ID: 1, Name: "Quizzes", Weight: 1},
+			},
+			items: []models.GradeItem{quiz(1, 1, nil), assignment(1, 0, nil)},
+			results: []models.GradeResult{
+				graded(models.GradeItemQuiz, 1, 75),
+				graded(models.GradeItemAssignment, 1, 0),
+			},
+			status:     []string{models.SubmissionStatusGraded, models.SubmissionStatusGraded},
+			dropped:    []bool{false, false},
+			categoryPc: []*float64{ptr(75.0)},
+			final:      ptr(75.0),
+			letter:     "C",
+		},
+		{
+			name: "drop lowest",
+			categories: []models.GradeCategory{
+				{ID: 1, Name: "Quizzes", Weight: 1, DropLowest: 1},
+			},
+			items: []models.GradeItem{quiz(1, 1, nil), quiz(2, 1, &past), quiz(3, 1, nil)},
+			results: []models.GradeResult{
+				graded(models.GradeItemQuiz, 1, 80),
+				graded(models.GradeItemQuiz, 3, 100),
+			},
+			status:     []string{models.SubmissionStatusGraded, models.SubmissionStatusMissing, models.SubmissionStatusGraded},
+			dropped:    []bool{false, true, false},
+			categoryPc: []*float64{ptr(90.0)},
+			final:      ptr(90.0),
+			letter:     "A",
+		},
+		{
+			name: "drop lowest keeps one score",
+			categories: []models.GradeCategory{
+				{ID: 1, Name: "Quizzes", Weight: 1, DropLowest: 5},
+			},
+			items: []models.GradeItem{quiz(1, 1, nil), quiz(2, 1, nil)},
+			results: []models.GradeResult{
+				graded(models.GradeItemQuiz, 1, 40),
+				graded(models.GradeItemQuiz, 2, 79.96),
+			},
+			status:     []string{models.SubmissionStatusGraded, models.SubmissionStatusGraded},
+			dropped:    []bool{true, false},
+			categoryPc: []*float64{ptr(80.0)},
+			final:      ptr(80.0),
+			letter:     "B",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			book := &models.Gradebook{Categories: tt.categories, Items: tt.items}
+			results := make(map[gradeKey]models.GradeResult)
+			for _, result := range tt.results {
+				results[gradeKey{result.Type, result.ItemID, result.StudentID}] = result
+			}
+			row := &models.StudentGrades{StudentID: 1}
+
+			gradeStudent(book, row, results, now)
+
+			if len(row.Scores) != len(tt.items) {
+				t.Fatalf("got %d scores, want %d", len(row.Scores), len(tt.items))
+			}
+			for i, score := range row.Scores {
+				if score.Status != tt.status[i] {
+					t.Errorf("score %d: status %s, want %s", i, score.Status, tt.status[i])
+				}
+				if score.Dropped != tt.dropped[i] {
+					t.Errorf("score %d: dropped %v, want %v", i, score.Dropped, tt.dropped[i])
+				}
+			}
+			var categoryPc []*float64
+			for _, category := range row.Categories {
+				categoryPc = append(categoryPc, category.Percent)
+			}
+			if !reflect.DeepEqual(categoryPc, tt.categoryPc) {
+				t.Errorf("category percents %s, want %s", formatPercents(categoryPc), formatPercents(tt.categoryPc))
+			}
+			if !reflect.DeepEqual(row.FinalPercent, tt.final) {
+				t.Errorf("final percent %s, want %s", formatPercents([]*float64{row.FinalPercent}), formatPercents([]*float64{tt.final}))
+			}
+			if row.Letter != tt.letter {
+				t.Errorf("letter %q, want %q", row.Letter, tt.letter)
+			}
+		})
+	}
+}
+
+func TestLetterGrade(t *testing.T) {
+	tests := []struct {
+		percent float64
+		want    string
+	}{
+		{100, "A"},
+		{90, "A"},
+		{89.9, "B"},
+		{80, "B"},
+		{70, "C"},
+		{60, "D"},
+		{59.9, "F"},
+		{0, "F"},
+	}
+	for _, tt := range tests {
+		if got := letterGrade(tt.percent); got != tt.want {
+			t.Errorf("letterGrade(%v) = %q, want %q", tt.percent, got, tt.want)
+		}
+	}
+}
+
+func ptr(x float64) *float64 {
+	return &x
+}
+
+func formatPercents(percents []*float64) string {
+	s := "["
+	for i, p := range percents {
+		if i > 0 {
+			s += " "
+		}
+		if p == nil {
+			s += "nil"
+		} else {
+			s += strconv.FormatFloat(*p, 'f', -1, 64)
+		}
+	}
+	return s + "]"
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"educational-platform/models"
+	"educational-platform/storage"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// presignExpiry is how long redirect URLs to object storage stay valid
+const presignExpiry = time.Hour
+
+// videoContentTypes maps accepted video extensions to their MIME types
+var videoContentTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".mov":  "video/quicktime",
+	".avi":  "video/x-msvideo",
+	".mkv":  "video/x-matroska",
+	".webm": "video/webm",
+}
+
+func videoContentType(name string) string {
+	if contentType, ok := videoContentTypes[strings.ToLower(filepath.Ext(name))]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// sendStoredObject serves an object from storage. Backends that support
+// presigned URLs get a redirect; otherwise the object is streamed with
+// support for single byte ranges so players can seek.
+func sendStoredObject(c fiber.Ctx, key, contentType, notFoundMessage string) error {
+	return sendObject(c, key, contentType, storage.ResponseHeaders{}, notFoundMessage)
+}
+
+// sendStoredDownload serves an uploaded file as a download under its original
+// name. A presigned redirect asks the bucket for the same headers, so the
+// browser saves the file rather than rendering it inline.
+func sendStoredDownload(c fiber.Ctx, key, filename, notFoundMessage string) error {
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": filename})
+	c.Set("Content-Disposition", disposition)
+	c.Set("Cache-Control", "private, no-cache")
+	c.Set("X-Content-Type-Options", "nosniff")
+	return sendObject(c, key, contentType, storage.ResponseHeaders{
+		ContentType:        contentType,
+		ContentDisposition: disposition,
+	}, notFoundMessage)
+}
+
+// sendObject serves an object as sendStoredObject does, with headers
+// overridden in the response to a presigned URL
+func sendObject(c fiber.Ctx, key, contentType string, headers storage.ResponseHeaders, notFoundMessage string) error {
+	if url, err := storage.Store.PresignedURL(key, presignExpiry, headers); err == nil {
+		return c.Redirect().Status(302).To(url)
+	}
+
+	info, err := storage.Store.Stat(key)
+	if err == storage.ErrNotFound {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: notFoundMessage,
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to read file",
+		})
+	}
+
+	offset, length, partial, ok := parseByteRange(c.Get("Range"), info.Size)
+	if !ok {
+		c.Set("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+		return c.SendStatus(416)
+	}
+
+	reader, err := storage.Store.Get(key, offset, length)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to read file",
+		})
+	}
+
+	c.Set("Accept-Ranges", "bytes")
+	c.Set("Content-Type", contentType)
+	if partial {
+		c.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, info.Size))
+		c.Status(206)
+	}
+	return c.SendStream(reader, int(length))
+}
+
+// parseByteRange parses a single "bytes=start-end" Range header. Without a
+// header the whole object is returned. ok is false for unsatisfiable ranges.
+func parseByteRange(header string, size int64) (offset, length int64, partial, ok bool) {
+	if header == "" || !strings.HasPrefix(header, "bytes=") || strings.Contains(header, ",") {
+		return 0, size, false, true
+	}
+
+	spec := strings.TrimPrefix(header, "bytes=")
+	startStr, endStr, found := strings.Cut(spec, "-")
+	if !found {
+		return 0, size, false, true
+	}
+
+	var start, end int64
+	var err error
+	if startStr == "" {
+		// Suffix range: the last N bytes
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false, false
+		}
+		if n > size {
+			n = size
+		}
+		start, end = size-n, size-1
+	} else {
+		start, err = strconv.ParseInt(startStr, 10, 64)
+		if err != nil || start >= size {
+			return 0, 0, false, false
+		}
+		end = size - 1
+		if endStr != "" {
+			end, err = strconv.ParseInt(endStr, 10, 64)
+			if err != nil || end < start {
+				return 0, 0, false, false
+			}
+			if end >= size {
+				end = size - 1
+			}
+		}
+	}
+
+	return start, end - start + 1, true, true
+}
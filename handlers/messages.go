@@ -0,0 +1,565 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"educational-platform/database"
+	"educational-platform/events"
+	"educational-platform/models"
+	"educational-platform/storage"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Messaging limits
+const (
+	maxMessageBody          = 5000 // characters
+	defaultMessageLimit     = 50
+	maxMessageLimit         = 100
+	defaultMessageRateLimit = 30 // messages each student may send a teacher per hour
+	maxMessageRateLimit     = 1000
+	messageRateWindow       = time.Hour
+)
+
+// setAttachmentURL points a message's attachment at its download route
+func setAttachmentURL(m *models.Message) {
+	if m.Attachment != nil {
+		m.Attachment.URL = fmt.Sprintf("/api/conversations/%d/messages/%d/attachment", m.ConversationID, m.ID)
+	}
+}
+
+// viewConversation fills in the parts of a conversation that depend on who
+// is looking at it
+func viewConversation(conv *models.Conversation, userType string) {
+	if userType == "teacher" {
+		conv.BlockedByMe, conv.BlockedByOther = conv.TeacherBlocked, conv.StudentBlocked
+	} else {
+		conv.BlockedByMe, conv.BlockedByOther = conv.StudentBlocked, conv.TeacherBlocked
+	}
+	if conv.LastMessage != nil {
+		setAttachmentURL(conv.LastMessage)
+	}
+}
+
+// otherSide returns the type and ID of the user on the other side of a
+// conversation from a user of userType
+func otherSide(conv *models.Conversation, userType string) (string, int) {
+	if userType == "teacher" {
+		return "student", conv.StudentID
+	}
+	return "teacher", conv.TeacherID
+}
+
+// teacherMessageRateLimit returns how many messages each student may send a
+// teacher per hour, 0 for unlimited
+func teacherMessageRateLimit(teacherID int) (int, bool, error) {
+	limit, ok, err := database.GetTeacherMessageRateLimit(teacherID)
+	if err != nil {
+		return 0, false, err
+	}
+	if !ok {
+		return defaultMessageRateLimit, true, nil
+	}
+	return limit, false, nil
+}
+
+// getConversation loads the :id conversation if the current user is part of
+// it. On failure the error response has already been written and the
+// returned conversation is nil.
+func getConversation(c fiber.Ctx) (*models.Conversation, error) {
+	userType := c.Locals("user_type").(string)
+	userID := c.Locals("user_id").(int)
+	conversationID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid conversation ID",
+		})
+	}
+
+	conv, err := database.GetConversation(conversationID, userType)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get conversation",
+		})
+	}
+	if err == sql.ErrNoRows || (userType == "teacher" && conv.TeacherID != userID) ||
+		(userType == "student" && conv.StudentID != userID) {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Conversation not found",
+		})
+	}
+	viewConversation(conv, userType)
+	return conv, nil
+}
+
+// List the user's conversations, most recently active first, with the
+// number of unread messages across them
+func GetConversationsHandler(c fiber.Ctx) error {
+	userType := c.Locals("user_type").(string)
+	userID := c.Locals("user_id").(int)
+
+	conversations, err := database.GetConversations(userType, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get conversations",
+		})
+	}
+
+	unread := 0
+	for i := range conversations {
+		viewConversation(&conversations[i], userType)
+		unread += conversations[i].Unread
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"conversations": conversations,
+			"unread":        unread,
+		},
+	})
+}
+
+// Start a conversation with a teacher the student is subscribed to, or a
+// student subscribed to the teacher. An existing conversation between them
+// is returned instead of starting another.
+func StartConversationHandler(c fiber.Ctx) error {
+	userType := c.Locals("user_type").(string)
+	userID := c.Locals("user_id").(int)
+
+	var req models.ConversationRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	teacherID, studentID := req.TeacherID, userID
+	if userType == "teacher" {
+		teacherID, studentID = userID, req.StudentID
+	}
+	if teacherID <= 0 || studentID <= 0 {
+		message := "teacher_id is required"
+		if userType == "teacher" {
+			message = "student_id is required"
+		}
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	subscribed, err := database.IsSubscribed(studentID, teacherID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to start conversation",
+		})
+	}
+	if !subscribed {
+		return c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: "Messages can only be sent between a student and a teacher they are subscribed to",
+		})
+	}
+
+	id, created, err := database.StartConversation(teacherID, studentID)
+	var conv *models.Conversation
+	if err == nil {
+		conv, err = database.GetConversation(id, userType)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to start conversation",
+		})
+	}
+	viewConversation(conv, userType)
+
+	status, message := 200, "Conversation already exists"
+	if created {
+		status, message = 201, "Conversation started successfully"
+	}
+	return c.Status(status).JSON(models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    conv,
+	})
+}
+
+// Get one of the user's conversations
+func GetConversationHandler(c fiber.Ctx) error {
+	conv, err := getConversation(c)
+	if conv == nil {
+		return err
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    conv,
+	})
+}
+
+// List a conversation's messages, newest first: ?limit= and ?before= the ID
+// of the oldest message of the previous page. Messages the user sent carry
+// read_at once the other side has read them.
+func GetMessagesHandler(c fiber.Ctx) error {
+	conv, err := getConversation(c)
+	if conv == nil {
+		return err
+	}
+
+	invalid := func(message string) error {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	limit := defaultMessageLimit
+	if c.Query("limit") != "" {
+		n, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || n < 1 || n > maxMessageLimit {
+			return invalid(fmt.Sprintf("Limit must be between 1 and %d", maxMessageLimit))
+		}
+		limit = n
+	}
+	beforeID := 0
+	if c.Query("before") != "" {
+		n, err := strconv.Atoi(c.Query("before"))
+		if err != nil || n < 1 {
+			return invalid("Invalid before ID")
+		}
+		beforeID = n
+	}
+
+	messages, err := database.GetMessages(conv.ID, beforeID, limit)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get messages",
+		})
+	}
+	for i := range messages {
+		setAttachmentURL(&messages[i])
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    messages,
+	})
+}
+
+// Send a message with a body, an attachment or both. Both sides must still
+// be subscribed and neither may have blocked the other; students are held to
+// the teacher's rate limit.
+func SendMessageHandler(c fiber.Ctx) error {
+	userType := c.Locals("user_type").(string)
+	conv, err := getConversation(c)
+	if conv == nil {
+		return err
+	}
+
+	forbidden := func(message string) error {
+		return c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+	invalid := func(message string) error {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+	failed := func() error {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to send message",
+		})
+	}
+
+	if conv.BlockedByMe {
+		return forbidden("You blocked this conversation; unblock it to send messages")
+	}
+	if conv.BlockedByOther {
+		return forbidden("You cannot send messages in this conversation")
+	}
+	subscribed, err := database.IsSubscribed(conv.StudentID, conv.TeacherID)
+	if err != nil {
+		return failed()
+	}
+	if !subscribed {
+		return forbidden("Messages can only be sent between a student and a teacher they are subscribed to")
+	}
+
+	form, err := readFormUpload(c, "file", MaxAttachmentSize, fmt.Sprintf("File exceeds the maximum size of %d MB", MaxAttachmentSize>>20))
+	if err != nil {
+		e := err.(*fiber.Error)
+		return c.Status(e.Code).JSON(models.APIResponse{
+			Success: false,
+			Message: e.Message,
+		})
+	}
+	defer form.Remove()
+
+	var body string
+	if strings.HasPrefix(c.Get("Content-Type"), fiber.MIMEApplicationJSON) {
+		var req models.MessageRequest
+		if err := c.Bind().Body(&req); err != nil {
+			return invalid("Invalid request body")
+		}
+		body = req.Body
+	} else {
+		body = form.Value("body")
+	}
+	body = strings.TrimSpace(body)
+	if utf8.RuneCountInString(body) > maxMessageBody {
+		return invalid(fmt.Sprintf("Message can be at most %d characters", maxMessageBody))
+	}
+	hasFile := form.HasFile()
+	if body == "" && !hasFile {
+		return invalid("Send a message body, a file or both")
+	}
+
+	now := time.Now().UTC()
+	m := &models.Message{
+		ConversationID: conv.ID,
+		SenderType:     userType,
+		Body:           body,
+		CreatedAt:      now,
+	}
+
+	rateLimit := 0
+	if userType == "student" {
+		rateLimit, _, err = teacherMessageRateLimit(conv.TeacherID)
+		if err != nil {
+			return failed()
+		}
+	}
+	if hasFile {
+		name, ext := uploadedFileName(form.Filename, "attachment")
+		file, err := form.Open()
+		if err != nil {
+			return failed()
+		}
+		defer file.Close()
+
+		contentType := mime.TypeByExtension(ext)
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		key := fmt.Sprintf("messages/conversation_%d_%s_%s%s", conv.ID, userType, GenerateSessionID()[:8], ext)
+		err = storage.Store.Put(key, file, form.Size, contentType)
+		if err != nil {
+			return failed()
+		}
+		m.Attachment = &models.MessageAttachment{Name: name, Size: form.Size, Path: key}
+	}
+
+	id, err := database.CreateMessage(m, rateLimit, now.Add(-messageRateWindow))
+	if err != nil {
+		if m.Attachment != nil {
+			storage.Store.Delete(m.Attachment.Path)
+		}
+		if err == database.ErrMessageRateLimited {
+			// Students can send again once their oldest message in the
+			// window is an hour old
+			oldest, err := database.OldestMessageSince(conv.ID, userType, now.Add(-messageRateWindow))
+			if err == nil {
+				wait := oldest.Add(messageRateWindow).Sub(now)
+				c.Set("Retry-After", strconv.Itoa(int(math.Ceil(math.Max(wait.Seconds(), 1)))))
+			}
+			return c.Status(429).JSON(models.APIResponse{
+				Success: false,
+				Message: fmt.Sprintf("You can send %s at most %d messages per hour", conv.TeacherName, rateLimit),
+			})
+		}
+		return failed()
+	}
+	m.ID = id
+	setAttachmentURL(m)
+
+	recipientType, recipientID := otherSide(conv, userType)
+	publishEvent(recipientType, recipientID, events.MessageCreated, m)
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: "Message sent successfully",
+		Data:    m,
+	})
+}
+
+// Mark the messages the user received in a conversation as read, sending the
+// other side a read receipt
+func MarkConversationReadHandler(c fiber.Ctx) error {
+	userType := c.Locals("user_type").(string)
+	conv, err := getConversation(c)
+	if conv == nil {
+		return err
+	}
+
+	readAt := time.Now().UTC()
+	lastID, n, err := database.MarkConversationRead(conv.ID, userType, readAt)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to mark messages read",
+		})
+	}
+	if n > 0 {
+		senderType, senderID := otherSide(conv, userType)
+		publishEvent(senderType, senderID, events.MessageRead, map[string]interface{}{
+			"conversation_id": conv.ID,
+			"last_read_id":    lastID,
+			"read_at":         readAt,
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"read": n,
+		},
+	})
+}
+
+// setConversationBlocked blocks or unblocks the other side of the :id
+// conversation for the current user
+func setConversationBlocked(c fiber.Ctx, blocked bool) error {
+	userType := c.Locals("user_type").(string)
+	conv, err := getConversation(c)
+	if conv == nil {
+		return err
+	}
+
+	err = database.SetConversationBlocked(conv.ID, userType, blocked)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update conversation",
+		})
+	}
+	conv.BlockedByMe = blocked
+
+	message := "Conversation unblocked"
+	if blocked {
+		message = "Conversation blocked"
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    conv,
+	})
+}
+
+// Block the other side of a conversation from sending messages. Neither
+// side can send until it is unblocked; the history stays readable.
+func BlockConversationHandler(c fiber.Ctx) error {
+	return setConversationBlocked(c, true)
+}
+
+// Unblock the other side of a conversation
+func UnblockConversationHandler(c fiber.Ctx) error {
+	return setConversationBlocked(c, false)
+}
+
+// Download a message's attachment
+func GetMessageAttachmentHandler(c fiber.Ctx) error {
+	conv, err := getConversation(c)
+	if conv == nil {
+		return err
+	}
+	messageID, err := strconv.Atoi(c.Params("message_id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid message ID",
+		})
+	}
+
+	m, err := database.GetMessage(conv.ID, messageID)
+	if err != nil || m.Attachment == nil {
+		status, message := 500, "Failed to get message"
+		if err == sql.ErrNoRows {
+			status, message = 404, "Message not found"
+		} else if err == nil {
+			status, message = 404, "Message has no attachment"
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	return sendStoredDownload(c, m.Attachment.Path, m.Attachment.Name, "Attachment not found")
+}
+
+// Get the teacher's messaging settings
+func GetMessageSettingsHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	limit, isDefault, err := teacherMessageRateLimit(userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get message settings",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    models.MessageSettings{RateLimit: limit, Default: isDefault},
+	})
+}
+
+// Set how many messages each student may send the teacher per hour; 0 for
+// no limit, null for the server default
+func UpdateMessageSettingsHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	var req models.MessageSettingsRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+	if req.RateLimit != nil && (*req.RateLimit < 0 || *req.RateLimit > maxMessageRateLimit) {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("Rate limit must be between 0 and %d", maxMessageRateLimit),
+		})
+	}
+
+	err := database.SetTeacherMessageRateLimit(userID, req.RateLimit)
+	var limit int
+	var isDefault bool
+	if err == nil {
+		limit, isDefault, err = teacherMessageRateLimit(userID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update message settings",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Message settings updated successfully",
+		Data:    models.MessageSettings{RateLimit: limit, Default: isDefault},
+	})
+}
@@ -0,0 +1,298 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"educational-platform/database"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Note limits
+const (
+	maxNoteText      = 5000 // characters
+	maxBookmarkLabel = 200  // characters
+	maxNotesPerVideo = 500
+	maxNoteResults   = 200
+)
+
+// applyNoteSettings validates a note request and applies it to a note.
+// Timestamps must fall within the video when its duration is known.
+func applyNoteSettings(note *models.VideoNote, req *models.NoteRequest, video *models.Video) error {
+	if req.Type != nil {
+		note.Type = *req.Type
+	}
+	if req.Timestamp != nil {
+		note.Timestamp = *req.Timestamp
+	}
+	if req.Text != nil {
+		note.Text = strings.TrimSpace(*req.Text)
+	}
+
+	switch note.Type {
+	case models.NoteTypeNote:
+		if note.Text == "" {
+			return errors.New("Note text is required")
+		}
+		if utf8.RuneCountInString(note.Text) > maxNoteText {
+			return fmt.Errorf("Notes can be at most %d characters", maxNoteText)
+		}
+	case models.NoteTypeBookmark:
+		if utf8.RuneCountInString(note.Text) > maxBookmarkLabel {
+			return fmt.Errorf("Bookmark labels can be at most %d characters", maxBookmarkLabel)
+		}
+	default:
+		return errors.New("Type must be note or bookmark")
+	}
+
+	if note.Timestamp < 0 {
+		return errors.New("Timestamp cannot be negative")
+	}
+	if video.Duration > 0 && note.Timestamp > video.Duration {
+		return errors.New("Timestamp is past the end of the video")
+	}
+	return nil
+}
+
+// getStudentNote loads the student's :id note
+func getStudentNote(c fiber.Ctx) (*models.VideoNote, error) {
+	userID := c.Locals("user_id").(int)
+	noteID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid note ID",
+		})
+	}
+
+	note, err := database.GetNote(noteID, userID)
+	if err != nil {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Note not found",
+		})
+	}
+	return note, nil
+}
+
+// Add a note or bookmark to a video the student can watch
+func CreateNoteHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	videoID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid video ID",
+		})
+	}
+
+	video, err := database.GetVideoByID(videoID)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Video not found",
+		})
+	}
+	if ok, err := checkVideoAccess(c, video); !ok {
+		return err
+	}
+	if ok, err := checkLessonUnlocked(c, video); !ok {
+		return err
+	}
+
+	var req models.NoteRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+	if req.Timestamp == nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Timestamp is required",
+		})
+	}
+
+	now := time.Now().UTC()
+	note := &models.VideoNote{
+		VideoID:   video.ID,
+		Type:      models.NoteTypeNote,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := applyNoteSettings(note, &req, video); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	note.ID, err = database.CreateNote(userID, note, maxNotesPerVideo)
+	if err == database.ErrTooManyNotes {
+		return c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("A video can have at most %d notes", maxNotesPerVideo),
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to create note",
+		})
+	}
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: "Note created successfully",
+		Data:    note,
+	})
+}
+
+// List the student's notes on a video, ?type=note or bookmark
+func GetVideoNotesHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	videoID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid video ID",
+		})
+	}
+
+	noteType := c.Query("type")
+	if noteType != "" && noteType != models.NoteTypeNote && noteType != models.NoteTypeBookmark {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Type must be note or bookmark",
+		})
+	}
+
+	// Notes are the student's own, so they stay readable after access ends
+	notes, err := database.GetVideoNotes(userID, videoID, noteType)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get notes",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    notes,
+	})
+}
+
+// Search the student's notes: ?q= text, ?type= and ?video_id=
+func SearchNotesHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	noteType := c.Query("type")
+	if noteType != "" && noteType != models.NoteTypeNote && noteType != models.NoteTypeBookmark {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Type must be note or bookmark",
+		})
+	}
+	videoID := 0
+	if c.Query("video_id") != "" {
+		id, err := strconv.Atoi(c.Query("video_id"))
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: "Invalid video ID",
+			})
+		}
+		videoID = id
+	}
+
+	notes, err := database.SearchNotes(userID, strings.TrimSpace(c.Query("q")), noteType, videoID, maxNoteResults)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to search notes",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    notes,
+	})
+}
+
+// Update a note's type, timestamp or text
+func UpdateNoteHandler(c fiber.Ctx) error {
+	note, err := getStudentNote(c)
+	if note == nil {
+		return err
+	}
+
+	var req models.NoteRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	video, err := database.GetVideoByID(note.VideoID)
+	if err != nil {
+		status, message := 500, "Failed to update note"
+		if err == sql.ErrNoRows {
+			status, message = 404, "Note not found"
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	if err := applyNoteSettings(note, &req, video); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	err = database.UpdateNote(note)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update note",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Note updated successfully",
+		Data:    note,
+	})
+}
+
+// Delete a note
+func DeleteNoteHandler(c fiber.Ctx) error {
+	note, err := getStudentNote(c)
+	if note == nil {
+		return err
+	}
+
+	err = database.DeleteNote(note.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to delete note",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Note deleted successfully",
+	})
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"educational-platform/database"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// formatNoteTime shows a timestamp as m:ss, or h:mm:ss from an hour on
+func formatNoteTime(seconds int) string {
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
+
+// noteLabel is how a note reads in an export; bookmarks are marked as such
+func noteLabel(note models.VideoNote) string {
+	if note.Type == models.NoteTypeBookmark {
+		if note.Text == "" {
+			return "Bookmark"
+		}
+		return "Bookmark: " + note.Text
+	}
+	return note.Text
+}
+
+// courseNotesLesson is a lesson with the student's notes on it, for exports
+type courseNotesLesson struct {
+	Section string
+	Title   string
+	Notes   []models.VideoNote
+}
+
+// loadCourseNotes lists the lessons of a course the student has notes on,
+// in course order
+func loadCourseNotes(studentID, courseID int) ([]courseNotesLesson, error) {
+	sections, err := database.GetCourseSections(courseID, false)
+	if err != nil {
+		return nil, err
+	}
+	notes, err := database.GetCourseNotes(studentID, courseID)
+	if err != nil {
+		return nil, err
+	}
+
+	lessons := []courseNotesLesson{}
+	for _, section := range sections {
+		for _, lesson := range section.Lessons {
+			if len(notes[lesson.VideoID]) > 0 {
+				lessons = append(lessons, courseNotesLesson{section.Title, lesson.Title, notes[lesson.VideoID]})
+			}
+		}
+	}
+	return lessons, nil
+}
+
+func writeNotesMarkdown(out *bytes.Buffer, course *models.Course, lessons []courseNotesLesson, exportedAt time.Time) {
+	fmt.Fprintf(out, "# Notes: %s\n\n", course.Title)
+	fmt.Fprintf(out, "_%s, exported %s_\n", course.TeacherName, exportedAt.Format("January 2, 2006"))
+	if len(lessons) == 0 {
+		out.WriteString("\nNo notes yet.\n")
+	}
+
+	section := ""
+	for i, lesson := range lessons {
+		if i == 0 || lesson.Section != section {
+			section = lesson.Section
+			fmt.Fprintf(out, "\n## %s\n", section)
+		}
+		fmt.Fprintf(out, "\n### %s\n\n", lesson.Title)
+		for _, note := range lesson.Notes {
+			// Continuation lines are indented to stay in the list item
+			text := strings.ReplaceAll(noteLabel(note), "\n", "\n  ")
+			fmt.Fprintf(out, "- **[%s]** %s\n", formatNoteTime(note.Timestamp), text)
+		}
+	}
+}
+
+// Notes PDF layout, in points on a portrait A4 page
+const (
+	notesPageWidth  = 595
+	notesPageHeight = 842
+	notesMargin     = 56
+	notesTimeWidth  = 48 // column for timestamps
+)
+
+// notesPDF lays lines of text out down pages, starting a new page when one
+// fills up
+type notesPDF struct {
+	pages   [][]byte
+	content bytes.Buffer
+	y       float64
+}
+
+func (p *notesPDF) newPage() {
+	if p.content.Len() > 0 {
+		p.finishPage()
+	}
+	p.y = notesPageHeight - notesMargin
+}
+
+func (p *notesPDF) finishPage() {
+	number := winAnsi(fmt.Sprintf("Page %d", len(p.pages)+1))
+	x := (notesPageWidth - pdfTextWidth(number, false, 8)) / 2
+	fmt.Fprintf(&p.content, "0.5 0.5 0.5 rg BT /F1 8.0 Tf %.2f %d Td %s Tj ET\n", x, notesMargin/2, pdfString(number))
+	p.pages = append(p.pages, append([]byte(nil), p.content.Bytes()...))
+	p.content.Reset()
+}
+
+// text writes wrapped text at an indent from the margin, leaving space
+// above it first. The first line may carry a label in the margin column.
+func (p *notesPDF) text(text string, bold bool, size, indent, spaceBefore float64, label string) {
+	lines := wrapPDFText(text, bold, size, notesPageWidth-2*notesMargin-indent)
+	leading := size * 1.3
+	p.y -= spaceBefore
+	for i, line := range lines {
+		if p.y-leading < notesMargin {
+			p.newPage()
+		}
+		p.y -= leading
+		font := "F1"
+		if bold {
+			font = "F2"
+		}
+		if i == 0 && label != "" {
+			fmt.Fprintf(&p.content, "0.15 0.25 0.45 rg BT /F2 %.1f Tf %d %.2f Td %s Tj ET\n", size, notesMargin, p.y,
+				pdfString(winAnsi(label)))
+		}
+		fmt.Fprintf(&p.content, "0.1 0.1 0.1 rg BT /%s %.1f Tf %.2f %.2f Td %s Tj ET\n", font, size,
+			notesMargin+indent, p.y, pdfString(line))
+	}
+}
+
+// wrapPDFText breaks text into WinAnsi lines no wider than width, keeping
+// its own line breaks and splitting words that are too long by themselves
+func wrapPDFText(text string, bold bool, size, width float64) [][]byte {
+	lines := [][]byte{}
+	for _, paragraph := range strings.Split(text, "\n") {
+		var line []byte
+		for _, word := range strings.Fields(paragraph) {
+			encoded := winAnsi(word)
+			candidate := append(append(append([]byte(nil), line...), ' '), encoded...)
+			if len(line) == 0 {
+				candidate = encoded
+			}
+			if pdfTextWidth(candidate, bold, size) <= width {
+				line = candidate
+				continue
+			}
+			if len(line) > 0 {
+				lines = append(lines, line)
+			}
+			for pdfTextWidth(encoded, bold, size) > width {
+				n := len(encoded) - 1
+				for n > 1 && pdfTextWidth(encoded[:n], bold, size) > width {
+					n--
+				}
+				lines = append(lines, encoded[:n])
+				encoded = encoded[n:]
+			}
+			line = encoded
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func renderNotesPDF(course *models.Course, lessons []courseNotesLesson, exportedAt time.Time) []byte {
+	p := &notesPDF{}
+	p.newPage()
+	p.text("Notes: "+course.Title, true, 18, 0, 0, "")
+	p.text(course.TeacherName+", exported "+exportedAt.Format("January 2, 2006"), false, 10, 0, 4, "")
+	if len(lessons) == 0 {
+		p.text("No notes yet.", false, 11, 0, 18, "")
+	}
+
+	section := ""
+	for i, lesson := range lessons {
+		if i == 0 || lesson.Section != section {
+			section = lesson.Section
+			p.text(section, true, 14, 0, 20, "")
+		}
+		p.text(lesson.Title, true, 12, 0, 10, "")
+		for _, note := range lesson.Notes {
+			p.text(noteLabel(note), false, 10, notesTimeWidth, 4, formatNoteTime(note.Timestamp))
+		}
+	}
+	p.finishPage()
+
+	info := fmt.Sprintf("<< /Title %s /Creator %s /CreationDate (D:%s) >>",
+		pdfTextString("Notes: "+course.Title),
+		pdfTextString("Educational Platform"),
+		exportedAt.Format("20060102150405Z"))
+	return writePDF(notesPageWidth, notesPageHeight, p.pages, info)
+}
+
+// Export the student's notes on a course, in course order, as
+// ?format=markdown (the default) or pdf
+func ExportCourseNotesHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	course, err := getEnrolledCourse(c)
+	if course == nil {
+		return err
+	}
+
+	format := c.Query("format", "markdown")
+	if format != "markdown" && format != "pdf" {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Format must be markdown or pdf",
+		})
+	}
+
+	lessons, err := loadCourseNotes(userID, course.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to export notes",
+		})
+	}
+
+	now := time.Now().UTC()
+	filename := fmt.Sprintf("notes_course_%d", course.ID)
+	c.Set("Cache-Control", "private, no-cache")
+	if format == "pdf" {
+		c.Set("Content-Type", "application/pdf")
+		c.Set("Content-Disposition", `attachment; filename="`+filename+`.pdf"`)
+		return c.Send(renderNotesPDF(course, lessons, now))
+	}
+
+	var out bytes.Buffer
+	writeNotesMarkdown(&out, course, lessons, now)
+	c.Set("Content-Type", "text/markdown; charset=utf-8")
+	c.Set("Content-Disposition", `attachment; filename="`+filename+`.md"`)
+	return c.Send(out.Bytes())
+}
@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"educational-platform/database"
+	"educational-platform/events"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Notification list limits
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 100
+	notificationExcerpt      = 200 // characters of a comment quoted in a notification
+)
+
+// notificationTypes are the notifications each kind of user can receive
+var notificationTypes = map[string][]string{
+	"student": {
+		models.NotificationNewVideo,
+		models.NotificationCommentReply,
+		models.NotificationGraded,
+		models.NotificationAnnouncement,
+	},
+	"teacher": {
+		models.NotificationCommentReply,
+	},
+}
+
+// isNotificationType reports whether users of userType receive notifications
+// of type notificationType
+func isNotificationType(userType, notificationType string) bool {
+	for _, t := range notificationTypes[userType] {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+// excerpt shortens text to at most max characters for quoting
+func excerpt(text string, max int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:max-1])) + "…"
+}
+
+// notify puts a notification in the users' inboxes and pushes it to their
+// open event streams. Failing to notify never fails the action that caused
+// it, so errors are only logged.
+func notify(userType string, userIDs []int, n models.Notification, renotify bool) {
+	if len(userIDs) == 0 {
+		return
+	}
+	n.CreatedAt = time.Now().UTC()
+	notified, err := database.CreateNotifications(userType, userIDs, &n, renotify)
+	if err != nil {
+		log.Printf("Failed to create %s notifications for source %d: %v", n.Type, n.SourceID, err)
+		return
+	}
+	for userID, id := range notified {
+		n.ID = id
+		publishEvent(userType, userID, events.NotificationCreated, n)
+	}
+}
+
+// subscriberIDs returns the IDs of the students subscribed to a teacher
+func subscriberIDs(teacherID int) ([]int, error) {
+	subscriptions, err := database.GetSubscriptionsByTeacherID(teacherID)
+	if err != nil {
+		return nil, err
+	}
+	studentIDs := make([]int, len(subscriptions))
+	for i, subscription := range subscriptions {
+		studentIDs[i] = subscription.StudentID
+	}
+	return studentIDs, nil
+}
+
+// videoAudienceIDs returns the IDs of the students who can watch a live
+// video, following checkVideoAccess: lessons of published courses are for
+// their enrolled students, other videos for the teacher's subscribers. Public
+// lessons reach both.
+func videoAudienceIDs(video *models.Video) ([]int, error) {
+	inCourse, enrolledIDs, err := database.GetVideoCourseStudents(video.ID)
+	if err != nil {
+		return nil, err
+	}
+	if inCourse && video.Visibility == models.VisibilitySubscribers {
+		return enrolledIDs, nil
+	}
+
+	studentIDs, err := subscriberIDs(video.TeacherID)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[int]bool, len(studentIDs))
+	for _, id := range studentIDs {
+		seen[id] = true
+	}
+	for _, id := range enrolledIDs {
+		if !seen[id] {
+			studentIDs = append(studentIDs, id)
+		}
+	}
+	return studentIDs, nil
+}
+
+// notifyNewVideo tells the students who can watch a video about it once it
+// is live. Each student hears about a video only once, even if it is
+// unpublished and published again.
+func notifyNewVideo(videoID int) {
+	video, err := database.GetVideoByID(videoID)
+	if err != nil {
+		return
+	}
+	live := video.Status == models.VideoStatusPublished ||
+		(video.Status == models.VideoStatusScheduled && video.PublishAt != nil && !video.PublishAt.After(time.Now()))
+	if !live || (video.Visibility != models.VisibilitySubscribers && video.Visibility != models.VisibilityPublic) {
+		return
+	}
+
+	studentIDs, err := videoAudienceIDs(video)
+	if err != nil {
+		log.Printf("Failed to notify students of video %d: %v", video.ID, err)
+		return
+	}
+	notify("student", studentIDs, models.Notification{
+		Type:     models.NotificationNewVideo,
+		SourceID: video.ID,
+		VideoID:  video.ID,
+		Title:    fmt.Sprintf("New video from %s", video.TeacherName),
+		Body:     video.Title,
+	}, false)
+}
+
+// notifyCommentReply tells the author of a comment that someone replied
+func notifyCommentReply(parent, reply *models.Comment, video *models.Video) {
+	if parent.AuthorType == reply.AuthorType && parent.AuthorID == reply.AuthorID {
+		return
+	}
+	notify(parent.AuthorType, []int{parent.AuthorID}, models.Notification{
+		Type:     models.NotificationCommentReply,
+		SourceID: reply.ID,
+		VideoID:  video.ID,
+		Title:    fmt.Sprintf("%s replied to your comment on %s", reply.AuthorName, video.Title),
+		Body:     excerpt(reply.Body, notificationExcerpt),
+	}, false)
+}
+
+// notifyGraded tells a student their submission was graded; grading it again
+// brings the notification back as unread
+func notifyGraded(assignment *models.Assignment, sub *models.AssignmentSubmission) {
+	body := fmt.Sprintf("Score: %g / %g", *sub.FinalScore, assignment.MaxPoints)
+	notify("student", []int{sub.StudentID}, models.Notification{
+		Type:     models.NotificationGraded,
+		SourceID: sub.ID,
+		Title:    fmt.Sprintf("Your submission to %s was graded", assignment.Title),
+		Body:     body,
+	}, true)
+}
+
+// notifyAnnouncement tells the recipients of an announcement it was sent
+func notifyAnnouncement(a *models.Announcement, studentIDs []int) {
+	notify("student", studentIDs, models.Notification{
+		Type:     models.NotificationAnnouncement,
+		SourceID: a.ID,
+		Title:    fmt.Sprintf("Announcement from %s", a.TeacherName),
+		Body:     a.Title,
+	}, false)
+}
+
+// List the user's notifications, newest first: ?unread=true, ?type=,
+// ?limit= and ?before= the ID of the last notification of the previous page
+func GetNotificationsHandler(c fiber.Ctx) error {
+	userType := c.Locals("user_type").(string)
+	userID := c.Locals("user_id").(int)
+
+	invalid := func(message string) error {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	notificationType := c.Query("type")
+	if notificationType != "" && !isNotificationType(userType, notificationType) {
+		return invalid("Unknown notification type")
+	}
+	limit := defaultNotificationLimit
+	if c.Query("limit") != "" {
+		n, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || n < 1 || n > maxNotificationLimit {
+			return invalid(fmt.Sprintf("Limit must be between 1 and %d", maxNotificationLimit))
+		}
+		limit = n
+	}
+	beforeID := 0
+	if c.Query("before") != "" {
+		n, err := strconv.Atoi(c.Query("before"))
+		if err != nil || n < 1 {
+			return invalid("Invalid before ID")
+		}
+		beforeID = n
+	}
+
+	notifications, err := database.GetNotifications(userType, userID, c.Query("unread") == "true", notificationType,
+		beforeID, limit)
+	var unread map[string]int
+	if err == nil {
+		unread, err = database.CountUnreadNotifications(userType, userID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get notifications",
+		})
+	}
+
+	total := 0
+	for _, count := range unread {
+		total += count
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"notifications":  notifications,
+			"unread":         total,
+			"unread_by_type": unread,
+		},
+	})
+}
+
+// Count the user's unread notifications, in total and by type
+func GetUnreadNotificationCountHandler(c fiber.Ctx) error {
+	userType := c.Locals("user_type").(string)
+	userID := c.Locals("user_id").(int)
+
+	unread, err := database.CountUnreadNotifications(userType, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to count notifications",
+		})
+	}
+
+	total := 0
+	for _, count := range unread {
+		total += count
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"unread":         total,
+			"unread_by_type": unread,
+		},
+	})
+}
+
+// setNotificationRead marks the user's :id notification read or unread
+func setNotificationRead(c fiber.Ctx, read bool) error {
+	userType := c.Locals("user_type").(string)
+	userID := c.Locals("user_id").(int)
+	notificationID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid notification ID",
+		})
+	}
+
+	err = database.SetNotificationRead(notificationID, userType, userID, read)
+	if err != nil {
+		status, message := 500, "Failed to update notification"
+		if err == sql.ErrNoRows {
+			status, message = 404, "Notification not found"
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	message := "Notification marked as read"
+	if !read {
+		message = "Notification marked as unread"
+	}
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: message,
+	})
+}
+
+// Mark a notification as read
+func MarkNotificationReadHandler(c fiber.Ctx) error {
+	return setNotificationRead(c, true)
+}
+
+// Mark a notification as unread
+func MarkNotificationUnreadHandler(c fiber.Ctx) error {
+	return setNotificationRead(c, false)
+}
+
+// Mark all the user's notifications as read, or only those of ?type=
+func MarkAllNotificationsReadHandler(c fiber.Ctx) error {
+	userType := c.Locals("user_type").(string)
+	userID := c.Locals("user_id").(int)
+
+	notificationType := c.Query("type")
+	if notificationType != "" && !isNotificationType(userType, notificationType) {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Unknown notification type",
+		})
+	}
+
+	count, err := database.MarkAllNotificationsRead(userType, userID, notificationType)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update notifications",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Notifications marked as read",
+		Data: map[string]interface{}{
+			"marked": count,
+		},
+	})
+}
+
+// Remove a notification from the user's inbox
+func DeleteNotificationHandler(c fiber.Ctx) error {
+	userType := c.Locals("user_type").(string)
+	userID := c.Locals("user_id").(int)
+	notificationID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid notification ID",
+		})
+	}
+
+	err = database.DeleteNotification(notificationID, userType, userID)
+	if err != nil {
+		status, message := 500, "Failed to delete notification"
+		if err == sql.ErrNoRows {
+			status, message = 404, "Notification not found"
+		}
+		return c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Notification deleted successfully",
+	})
+}
+
+// notificationPreferences returns whether each type the user can receive is on
+func notificationPreferences(userType string, userID int) (map[string]bool, error) {
+	stored, err := database.GetNotificationPreferences(userType, userID)
+	if err != nil {
+		return nil, err
+	}
+	preferences := make(map[string]bool)
+	for _, notificationType := range notificationTypes[userType] {
+		enabled, ok := stored[notificationType]
+		preferences[notificationType] = enabled || !ok
+	}
+	return preferences, nil
+}
+
+// Get which notification types the user receives
+func GetNotificationPreferencesHandler(c fiber.Ctx) error {
+	userType := c.Locals("user_type").(string)
+	userID := c.Locals("user_id").(int)
+
+	preferences, err := notificationPreferences(userType, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get notification preferences",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    preferences,
+	})
+}
+
+// Turn notification types on or off; types left out keep their setting
+func UpdateNotificationPreferencesHandler(c fiber.Ctx) error {
+	userType := c.Locals("user_type").(string)
+	userID := c.Locals("user_id").(int)
+
+	var req map[string]bool
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+	for notificationType := range req {
+		if !isNotificationType(userType, notificationType) {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: fmt.Sprintf("Unknown notification type %q", notificationType),
+			})
+		}
+	}
+
+	err := database.SetNotificationPreferences(userType, userID, req)
+	var preferences map[string]bool
+	if err == nil {
+		preferences, err = notificationPreferences(userType, userID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update notification preferences",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Notification preferences updated successfully",
+		Data:    preferences,
+	})
+}
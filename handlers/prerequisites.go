@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"educational-platform/database"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// maxPrerequisites limits the rules on a single lesson
+const maxPrerequisites = 20
+
+// lessonPositions numbers the lessons of an outline in course order
+func lessonPositions(sections []models.CourseSection) map[int]int {
+	positions := make(map[int]int)
+	for _, section := range sections {
+		for _, lesson := range section.Lessons {
+			positions[lesson.ID] = len(positions)
+		}
+	}
+	return positions
+}
+
+// moveLessonInOutline returns a copy of an outline with a lesson moved to the
+// end of another section, as database.MoveLesson does
+func moveLessonInOutline(sections []models.CourseSection, lessonID, sectionID int) []models.CourseSection {
+	var moved models.Lesson
+	result := make([]models.CourseSection, len(sections))
+	for i, section := range sections {
+		result[i] = section
+		result[i].Lessons = nil
+		for _, lesson := range section.Lessons {
+			if lesson.ID == lessonID {
+				moved = lesson
+				continue
+			}
+			result[i].Lessons = append(result[i].Lessons, lesson)
+		}
+	}
+	for i := range result {
+		if result[i].ID == sectionID {
+			result[i].Lessons = append(result[i].Lessons, moved)
+		}
+	}
+	return result
+}
+
+// checkPrerequisiteOrder makes sure every lesson still comes after the
+// lessons it requires when the course is arranged as in sections. Keeping
+// prerequisites earlier in the course rules out cycles, and with them
+// lessons that can never unlock.
+func checkPrerequisiteOrder(courseID int, sections []models.CourseSection) error {
+	prerequisites, err := database.GetCoursePrerequisites(courseID)
+	if err != nil {
+		return fiber.NewError(500, "Failed to check prerequisites")
+	}
+
+	positions := lessonPositions(sections)
+	for lessonID, rules := range prerequisites {
+		for _, p := range rules {
+			if p.Type == models.PrerequisiteLesson && positions[p.RequiredLessonID] >= positions[lessonID] {
+				return fiber.NewError(400, fmt.Sprintf("A lesson cannot come before its prerequisite %q", p.RequiredLessonTitle))
+			}
+			// A quiz whose video has left the course no longer has a place in it
+			position, ok := positions[p.QuizLessonID]
+			if p.Type == models.PrerequisiteQuiz && ok && position >= positions[lessonID] {
+				return fiber.NewError(400, fmt.Sprintf("A lesson cannot come before the lesson of its required quiz %q", p.RequiredQuizTitle))
+			}
+		}
+	}
+	return nil
+}
+
+// attachPrerequisites adds each lesson's rules to a teacher outline
+func attachPrerequisites(courseID int, sections []models.CourseSection) error {
+	prerequisites, err := database.GetCoursePrerequisites(courseID)
+	if err != nil {
+		return err
+	}
+	for i := range sections {
+		for j := range sections[i].Lessons {
+			lesson := &sections[i].Lessons[j]
+			lesson.Prerequisites = prerequisites[lesson.ID]
+		}
+	}
+	return nil
+}
+
+// applyLessonLocks marks the lessons of a student outline as completed and
+// locks those whose prerequisites are not met, giving the reason. In
+// sequential courses each lesson also requires the one before it. Rules that
+// point at lessons the student cannot see, or at unpublished quizzes, are
+// ignored, so an unpublished video never blocks the rest of a course.
+func applyLessonLocks(studentID int, course *models.Course, sections []models.CourseSection) error {
+	prerequisites, err := database.GetCoursePrerequisites(course.ID)
+	if err != nil {
+		return err
+	}
+	completed, err := database.GetCompletedLessons(studentID, course.ID)
+	if err != nil {
+		return err
+	}
+	passed, err := database.GetPassedQuizzes(studentID)
+	if err != nil {
+		return err
+	}
+
+	visible := lessonPositions(sections)
+	now := time.Now()
+	var previous *models.Lesson
+
+	for i := range sections {
+		for j := range sections[i].Lessons {
+			lesson := &sections[i].Lessons[j]
+			lesson.Completed = completed[lesson.ID]
+
+			var reasons []string
+			if course.Sequential && previous != nil && !completed[previous.ID] {
+				reasons = append(reasons, fmt.Sprintf("Complete %q first", previous.Title))
+			}
+
+			for _, p := range prerequisites[lesson.ID] {
+				met := true
+				reason := ""
+				switch p.Type {
+				case models.PrerequisiteLesson:
+					if _, ok := visible[p.RequiredLessonID]; !ok {
+						continue
+					}
+					met = completed[p.RequiredLessonID]
+					reason = fmt.Sprintf("Complete %q first", p.RequiredLessonTitle)
+				case models.PrerequisiteQuiz:
+					if _, ok := visible[p.QuizLessonID]; !ok || p.QuizStatus != models.QuizStatusPublished {
+						continue
+					}
+					met = passed[p.RequiredQuizID]
+					reason = fmt.Sprintf("Pass the quiz %q first", p.RequiredQuizTitle)
+				case models.PrerequisiteDate:
+					met = p.AvailableAt == nil || !p.AvailableAt.After(now)
+					if p.AvailableAt != nil {
+						reason = "Available from " + p.AvailableAt.UTC().Format(time.RFC3339)
+					}
+				}
+
+				if !met && !containsString(reasons, reason) {
+					reasons = append(reasons, reason)
+				}
+				p.Met = &met
+				lesson.Prerequisites = append(lesson.Prerequisites, p)
+			}
+
+			if len(reasons) > 0 {
+				lesson.Locked = true
+				lesson.LockedReason = strings.Join(reasons, "; ")
+			}
+			previous = lesson
+		}
+	}
+	return nil
+}
+
+// quizLessonInCourse returns the lesson of a course outline a quiz belongs
+// to: its own lesson, or the lesson that plays its video. It is 0 when the
+// quiz is not part of the course.
+func quizLessonInCourse(quiz *models.Quiz, courseID int, sections []models.CourseSection) int {
+	if quiz.LessonID != 0 {
+		if quiz.CourseID == courseID {
+			return quiz.LessonID
+		}
+		return 0
+	}
+	for _, section := range sections {
+		for _, lesson := range section.Lessons {
+			if lesson.VideoID == quiz.VideoID {
+				return lesson.ID
+			}
+		}
+	}
+	return 0
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkLessonUnlocked refuses students a video that is a lesson of their
+// enrolled courses while it is locked in all of them. Call it after
+// checkVideoAccess. When access is denied the error response has already
+// been written.
+func checkLessonUnlocked(c fiber.Ctx, video *models.Video) (bool, error) {
+	if c.Locals("user_type") != "student" {
+		return true, nil
+	}
+	userID := c.Locals("user_id").(int)
+
+	failed := func() (bool, error) {
+		return false, c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to check prerequisites",
+		})
+	}
+
+	courseIDs, err := database.GetEnrolledCoursesForVideo(userID, video.ID)
+	if err != nil {
+		return failed()
+	}
+
+	reason := ""
+	for _, courseID := range courseIDs {
+		course, err := database.GetCourseByID(courseID)
+		if err != nil {
+			return failed()
+		}
+		sections, err := database.GetCourseSections(courseID, true)
+		if err == nil {
+			err = applyLessonLocks(userID, course, sections)
+		}
+		if err != nil {
+			return failed()
+		}
+
+		for _, section := range sections {
+			for _, lesson := range section.Lessons {
+				if lesson.VideoID != video.ID {
+					continue
+				}
+				if !lesson.Locked {
+					return true, nil
+				}
+				reason = lesson.LockedReason
+			}
+		}
+	}
+
+	// Archived videos are not part of student outlines and stay available
+	// to students who already watched them
+	if reason == "" {
+		return true, nil
+	}
+	return false, c.Status(403).JSON(models.APIResponse{
+		Success: false,
+		Message: "This lesson is locked: " + reason,
+	})
+}
+
+// Replace the prerequisites of a lesson
+func UpdatePrerequisitesHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+	lesson, err := getCourseLesson(c, course)
+	if lesson == nil {
+		return err
+	}
+
+	var req models.PrerequisitesRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	if len(req.Prerequisites) > maxPrerequisites {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("A lesson can have at most %d prerequisites", maxPrerequisites),
+		})
+	}
+
+	sections, err := database.GetCourseSections(course.ID, false)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update prerequisites",
+		})
+	}
+	positions := lessonPositions(sections)
+
+	invalid := func(message string) error {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	rules := make([]models.LessonPrerequisite, 0, len(req.Prerequisites))
+	required := make(map[int]bool)
+	requiredQuizzes := make(map[int]bool)
+	for _, p := range req.Prerequisites {
+		rule := models.LessonPrerequisite{LessonID: lesson.ID, Type: p.Type}
+		switch p.Type {
+		case models.PrerequisiteLesson:
+			position, ok := positions[p.RequiredLessonID]
+			if !ok {
+				return invalid("Required lesson not found in this course")
+			}
+			if position >= positions[lesson.ID] {
+				return invalid("A required lesson must come before this lesson in the course")
+			}
+			if required[p.RequiredLessonID] {
+				return invalid("Each lesson can only be required once")
+			}
+			required[p.RequiredLessonID] = true
+			rule.RequiredLessonID = p.RequiredLessonID
+		case models.PrerequisiteQuiz:
+			quiz, err := database.GetQuizByID(p.RequiredQuizID)
+			if err != nil || quiz.TeacherID != course.TeacherID {
+				return invalid("Required quiz not found")
+			}
+			quizLessonID := quizLessonInCourse(quiz, course.ID, sections)
+			if quizLessonID == 0 {
+				return invalid("A required quiz must belong to a lesson or video of this course")
+			}
+			if positions[quizLessonID] >= positions[lesson.ID] {
+				return invalid("A required quiz must belong to a lesson before this one in the course")
+			}
+			if requiredQuizzes[quiz.ID] {
+				return invalid("Each quiz can only be required once")
+			}
+			requiredQuizzes[quiz.ID] = true
+			rule.RequiredQuizID = quiz.ID
+		case models.PrerequisiteDate:
+			if p.AvailableAt == nil {
+				return invalid("A date prerequisite needs available_at")
+			}
+			availableAt := p.AvailableAt.UTC()
+			rule.AvailableAt = &availableAt
+		default:
+			return invalid("Prerequisite type must be lesson, quiz or date")
+		}
+		rules = append(rules, rule)
+	}
+
+	err = database.ReplacePrerequisites(lesson.ID, rules)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update prerequisites",
+		})
+	}
+
+	prerequisites, err := database.GetCoursePrerequisites(course.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update prerequisites",
+		})
+	}
+	saved := prerequisites[lesson.ID]
+	if saved == nil {
+		saved = []models.LessonPrerequisite{}
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Prerequisites updated successfully",
+		Data:    saved,
+	})
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"educational-platform/database"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// completionPercent is how much of a video must be watched to complete it
+const completionPercent = 90
+
+// maxHeartbeatIntervals limits the intervals sent in one heartbeat
+const maxHeartbeatIntervals = 50
+
+// heartbeatSlack is the most extra playback, in seconds, accepted beyond the
+// wall time since the student's last heartbeat for a video
+const heartbeatSlack = 5
+
+// intervalJoinGap joins intervals separated by less than this many seconds,
+// which absorbs rounding between consecutive heartbeats
+const intervalJoinGap = 1.0
+
+// progressLocks serialize heartbeats for the same student and video, so
+// heartbeats sent at once from several sessions share one budget
+var progressLocks [64]sync.Mutex
+
+// mergeIntervals sorts intervals and joins those that overlap or touch
+func mergeIntervals(intervals [][2]float64) [][2]float64 {
+	sorted := make([][2]float64, len(intervals))
+	copy(sorted, intervals)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i][0] < sorted[j][0] })
+
+	merged := [][2]float64{}
+	for _, interval := range sorted {
+		last := len(merged) - 1
+		if last >= 0 && interval[0] <= merged[last][1]+intervalJoinGap {
+			merged[last][1] = math.Max(merged[last][1], interval[1])
+			continue
+		}
+		merged = append(merged, interval)
+	}
+	return merged
+}
+
+// intervalsLength is the total length of merged intervals
+func intervalsLength(intervals [][2]float64) float64 {
+	total := 0.0
+	for _, interval := range intervals {
+		total += interval[1] - interval[0]
+	}
+	return total
+}
+
+// addIntervals merges newly played intervals into the watched ones, taking
+// them from the earliest start until they would add more than budget seconds
+// of new coverage; the interval that crosses the budget is cut short
+func addIntervals(watched, played [][2]float64, budget float64) [][2]float64 {
+	merged := mergeIntervals(watched)
+	total := intervalsLength(merged)
+	for _, interval := range mergeIntervals(played) {
+		if budget <= 0 {
+			break
+		}
+		next := mergeIntervals(append(merged, interval))
+		added := intervalsLength(next) - total
+		for added > budget && interval[1] > interval[0] {
+			interval[1] = math.Floor((interval[1]-(added-budget))*10) / 10
+			next = mergeIntervals(append(merged, interval))
+			added = intervalsLength(next) - total
+		}
+		if interval[1] <= interval[0] {
+			break
+		}
+		merged, total, budget = next, total+added, budget-added
+	}
+	return merged
+}
+
+// roundTenth rounds seconds and percentages for storage and display
+func roundTenth(x float64) float64 {
+	return math.Round(x*10) / 10
+}
+
+// attachProgress adds the student's progress to each video
+func attachProgress(studentID int, videos []models.Video) error {
+	progress, err := database.GetStudentProgress(studentID)
+	if err != nil {
+		return err
+	}
+	for i := range videos {
+		videos[i].Progress = progress[videos[i].ID]
+	}
+	return nil
+}
+
+// Record a playback heartbeat
+func UpdateProgressHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	videoID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid video ID",
+		})
+	}
+
+	video, err := database.GetVideoByID(videoID)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Video not found",
+		})
+	}
+
+	if ok, err := checkVideoAccess(c, video); !ok {
+		return err
+	}
+	if ok, err := checkLessonUnlocked(c, video); !ok {
+		return err
+	}
+
+	var req models.ProgressRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	invalid := func(message string) error {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	if req.Position < 0 {
+		return invalid("Position cannot be negative")
+	}
+	if len(req.Intervals) > maxHeartbeatIntervals {
+		return invalid(fmt.Sprintf("A heartbeat can report at most %d intervals", maxHeartbeatIntervals))
+	}
+
+	var session *models.WatchSession
+	if req.SessionID != 0 {
+		session, err = database.GetWatchSession(req.SessionID, userID, video.ID)
+		if err != nil {
+			return invalid("Watch session not found")
+		}
+	}
+
+	// Only the probed duration counts: without it progress is recorded but
+	// has no percent, so the video cannot be completed
+	duration := float64(video.Duration)
+
+	var intervals [][2]float64
+	for _, interval := range req.Intervals {
+		start, end := interval[0], interval[1]
+		if start < 0 || end < start {
+			return invalid("Each interval must be a [start, end] pair of non-negative times with end not before start")
+		}
+		if duration > 0 {
+			end = math.Min(end, duration)
+		}
+		if end > start {
+			intervals = append(intervals, [2]float64{roundTenth(start), roundTenth(end)})
+		}
+	}
+
+	lock := &progressLocks[(userID*31+video.ID)%len(progressLocks)]
+	lock.Lock()
+	defer lock.Unlock()
+
+	progress, err := database.GetVideoProgress(userID, video.ID)
+	if err == sql.ErrNoRows {
+		progress, err = &models.VideoProgress{VideoID: video.ID}, nil
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to record progress",
+		})
+	}
+
+	progress.Position = roundTenth(req.Position)
+	if duration > 0 {
+		progress.Position = math.Min(progress.Position, duration)
+	}
+
+	// New coverage and watch time are never more than the wall time since
+	// the student's previous heartbeat for the video, from any session, or
+	// since the session started for the first one. The slack for rounding
+	// is capped by that time too, so frequent heartbeats do not add it up.
+	var since time.Time
+	if !progress.UpdatedAt.IsZero() {
+		since = progress.UpdatedAt
+	} else if session != nil {
+		since = session.StartedAt
+	}
+	budget := 0.0
+	if !since.IsZero() {
+		elapsed := math.Max(time.Since(since).Seconds(), 0)
+		budget = elapsed + math.Min(elapsed, heartbeatSlack)
+	}
+	progress.Intervals = addIntervals(progress.Intervals, intervals, budget)
+
+	watched := intervalsLength(progress.Intervals)
+	progress.WatchedSeconds = roundTenth(watched)
+	if duration > 0 {
+		progress.Percent = roundTenth(math.Min(watched/duration*100, 100))
+	}
+
+	now := time.Now().UTC()
+	// Only heartbeats of a watch session can complete a video
+	if !progress.Completed && session != nil && progress.Percent >= completionPercent {
+		progress.Completed = true
+		progress.CompletedAt = &now
+	}
+	progress.UpdatedAt = now
+
+	err = database.SaveVideoProgress(userID, progress)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to record progress",
+		})
+	}
+
+	// Played time counts towards the watch session within the same budget
+	if session != nil {
+		played := math.Min(intervalsLength(intervals), budget)
+		err = database.AddWatchTime(session, roundTenth(played))
+		if err != nil {
+			return c.Status(500).JSON(models.APIResponse{
+				Success: false,
+				Message: "Failed to record progress",
+			})
+		}
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    progress,
+	})
+}
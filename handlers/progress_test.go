@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"educational-platform/database"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestMergeIntervals(t *testing.T) {
+	tests := []struct {
+		name      string
+		intervals [][2]float64
+		want      [][2]float64
+	}{
+		{"empty", nil, [][2]float64{}},
+		{"single", [][2]float64{{5, 10}}, [][2]float64{{5, 10}}},
+		{"unsorted", [][2]float64{{20, 30}, {0, 10}}, [][2]float64{{0, 10}, {20, 30}}},
+		{"overlapping", [][2]float64{{0, 10}, {5, 15}}, [][2]float64{{0, 15}}},
+		{"contained", [][2]float64{{0, 30}, {10, 20}}, [][2]float64{{0, 30}}},
+		{"touching", [][2]float64{{0, 10}, {10, 20}}, [][2]float64{{0, 20}}},
+		{"within join gap", [][2]float64{{0, 10}, {10.6, 20}}, [][2]float64{{0, 20}}},
+		{"at join gap", [][2]float64{{0, 10}, {10 + intervalJoinGap, 20}}, [][2]float64{{0, 20}}},
+		{"past join gap", [][2]float64{{0, 10}, {11.5, 20}}, [][2]float64{{0, 10}, {11.5, 20}}},
+		{"chain", [][2]float64{{20, 25}, {0, 10}, {9, 21}}, [][2]float64{{0, 25}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeIntervals(tt.intervals)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeIntervals(%v) = %v, want %v", tt.intervals, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeIntervalsKeepsInput(t *testing.T) {
+	intervals := [][2]float64{{20, 30}, {0, 10}}
+	mergeIntervals(intervals)
+	if want := [][2]float64{{20, 30}, {0, 10}}; !reflect.DeepEqual(intervals, want) {
+		t.Errorf("input changed to %v", intervals)
+	}
+}
+
+func TestAddIntervals(t *testing.T) {
+	tests := []struct {
+		name    string
+		watched [][2]float64
+		played  [][2]float64
+		budget  float64
+		want    [][2]float64
+	}{
+		{"within budget", nil, [][2]float64{{0, 10}}, 15, [][2]float64{{0, 10}}},
+		{"cut at budget", nil, [][2]float64{{0, 60}}, 15, [][2]float64{{0, 15}}},
+		{"no budget", [][2]float64{{0, 10}}, [][2]float64{{20, 30}}, 0, [][2]float64{{0, 10}}},
+		{"rewatch is free", [][2]float64{{0, 30}}, [][2]float64{{5, 25}}, 0, [][2]float64{{0, 30}}},
+		{"only new coverage counts", [][2]float64{{0, 30}}, [][2]float64{{20, 60}}, 10, [][2]float64{{0, 40}}},
+		{"earliest first", nil, [][2]float64{{100, 110}, {0, 10}}, 15, [][2]float64{{0, 10}, {100, 105}}},
+		{"later intervals dropped", nil, [][2]float64{{0, 10}, {50, 60}}, 10, [][2]float64{{0, 10}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := addIntervals(tt.watched, tt.played, tt.budget)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("addIntervals(%v, %v, %v) = %v, want %v", tt.watched, tt.played, tt.budget, got, tt.want)
+			}
+		})
+	}
+}
+
+// setupTestDB opens a fresh database in a temporary working directory
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := database.InitDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.DB.Close() })
+}
+
+// loginStudent creates a student and returns its ID and a session cookie
+func loginStudent(t *testing.T, username string) (int, string) {
+	t.Helper()
+	if err := database.CreateStudent(username, username+"@example.com", "x", username); err != nil {
+		t.Fatal(err)
+	}
+	student, err := database.GetStudentByUsername(username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionID := "test-" + username
+	sessions[sessionID] = map[string]interface{}{"user_id": student.ID, "user_type": "student"}
+	t.Cleanup(func() { delete(sessions, sessionID) })
+	return student.ID, "session_id=" + sessionID
+}
+
+// createPublicVideo creates a published public video of the given
+// duration, 0 when unknown
+func createPublicVideo(t *testing.T, duration int) int {
+	t.Helper()
+	teacher, err := database.GetTeacherByUsername("teacher")
+	if err != nil {
+		if err := database.CreateTeacher("teacher", "teacher@example.com", "x", "Teacher"); err != nil {
+			t.Fatal(err)
+		}
+		if teacher, err = database.GetTeacherByUsername("teacher"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	publishing := models.VideoPublishing{Status: models.VideoStatusPublished, Visibility: models.VisibilityPublic}
+	videoID, err := database.CreateVideo(teacher.ID, "Lesson", "", "lesson.mp4", "videos/lesson.mp4", "", duration, 1000, publishing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return videoID
+}
+
+// progressApp serves the heartbeat route for tests
+func progressApp() *fiber.App {
+	app := fiber.New()
+	app.Post("/videos/:id/progress", func(c fiber.Ctx) error {
+		loadSession(c)
+		return c.Next()
+	}, UpdateProgressHandler)
+	return app
+}
+
+// startWatchSession records a view whose session started the given time ago
+func startWatchSession(t *testing.T, studentID, videoID int, ago time.Duration) int {
+	t.Helper()
+	sessionID, _, err := database.RecordVideoView(studentID, videoID, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := time.Now().UTC().Add(-ago)
+	_, err = database.DB.Exec(`UPDATE watch_sessions SET started_at = ?, ended_at = ? WHERE id = ?`, started, started, sessionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sessionID
+}
+
+// sendHeartbeat posts a heartbeat and fails the test unless it is accepted
+func sendHeartbeat(t *testing.T, app *fiber.App, cookie string, videoID int, body string) {
+	t.Helper()
+	req := httptest.NewRequest("POST", fmt.Sprintf("/videos/%d/progress", videoID), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Cookie", cookie)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("heartbeat %s: status %d", body, resp.StatusCode)
+	}
+}
+
+func TestUpdateProgressCompletion(t *testing.T) {
+	setupTestDB(t)
+	studentID, cookie := loginStudent(t, "student")
+	app := progressApp()
+
+	tests := []struct {
+		name      string
+		duration  int // probed at upload, 0 when unknown
+		body      string
+		percent   float64
+		completed bool
+	}{
+		{"probed duration", 10, `{"position": 9.5, "intervals": [[0, 9.5]]}`, 95, true},
+		{"forged duration", 0, `{"position": 9.5, "intervals": [[0, 9.5]], "duration": 10}`, 0, false},
+		{"forged shorter duration", 600, `{"position": 9.5, "intervals": [[0, 9.5]], "duration": 10}`, 1.6, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			videoID := createPublicVideo(t, tt.duration)
+			sessionID := startWatchSession(t, studentID, videoID, time.Minute)
+			body := strings.Replace(tt.body, "}", fmt.Sprintf(`, "session_id": %d}`, sessionID), 1)
+			sendHeartbeat(t, app, cookie, videoID, body)
+
+			progress, err := database.GetVideoProgress(studentID, videoID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if progress.WatchedSeconds != 9.5 {
+				t.Errorf("watched %v seconds, want 9.5", progress.WatchedSeconds)
+			}
+			if progress.Percent != tt.percent {
+				t.Errorf("percent %v, want %v", progress.Percent, tt.percent)
+			}
+			if progress.Completed != tt.completed {
+				t.Errorf("completed %v, want %v", progress.Completed, tt.completed)
+			}
+		})
+	}
+}
+
+func TestUpdateProgressBudgetAcrossSessions(t *testing.T) {
+	setupTestDB(t)
+	studentID, cookie := loginStudent(t, "student")
+	app := progressApp()
+	videoID := createPublicVideo(t, 600)
+
+	// Two sessions started a minute ago take turns, each claiming a minute
+	first := startWatchSession(t, studentID, videoID, time.Minute)
+	second := startWatchSession(t, studentID, videoID, time.Minute)
+	sendHeartbeat(t, app, cookie, videoID, fmt.Sprintf(`{"position": 60, "intervals": [[0, 60]], "session_id": %d}`, first))
+	sendHeartbeat(t, app, cookie, videoID, fmt.Sprintf(`{"position": 120, "intervals": [[60, 120]], "session_id": %d}`, second))
+
+	progress, err := database.GetVideoProgress(studentID, videoID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.WatchedSeconds < 60 || progress.WatchedSeconds > 61 {
+		t.Errorf("watched %v seconds, want about 60", progress.WatchedSeconds)
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"educational-platform/database"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// publishCheckInterval is how often the scheduler looks for due videos
+const publishCheckInterval = 30 * time.Second
+
+var videoStatuses = map[string]bool{
+	models.VideoStatusDraft:     true,
+	models.VideoStatusScheduled: true,
+	models.VideoStatusPublished: true,
+	models.VideoStatusArchived:  true,
+}
+
+var videoVisibilities = map[string]bool{
+	models.VisibilitySubscribers: true,
+	models.VisibilityPublic:      true,
+	models.VisibilityUnlisted:    true,
+	models.VisibilityPrivate:     true,
+}
+
+// validatePublishing fills in defaults (published, visible to subscribers)
+// and checks the settings. Only scheduled videos keep a publish time.
+func validatePublishing(publishing *models.VideoPublishing) error {
+	if publishing.Status == "" {
+		publishing.Status = models.VideoStatusPublished
+	}
+	if publishing.Visibility == "" {
+		publishing.Visibility = models.VisibilitySubscribers
+	}
+
+	if !videoStatuses[publishing.Status] {
+		return errors.New("Status must be draft, scheduled, published or archived")
+	}
+	if !videoVisibilities[publishing.Visibility] {
+		return errors.New("Visibility must be subscribers, public, unlisted or private")
+	}
+
+	if publishing.Status != models.VideoStatusScheduled {
+		publishing.PublishAt = nil
+		return nil
+	}
+	if publishing.PublishAt == nil {
+		return errors.New("A publish time is required to schedule a video")
+	}
+	if !publishing.PublishAt.After(time.Now()) {
+		return errors.New("Publish time must be in the future")
+	}
+	publishAt := publishing.PublishAt.UTC()
+	publishing.PublishAt = &publishAt
+	return nil
+}
+
+// parsePublishing reads publishing settings from upload form fields or tus
+// metadata, where the publish time is an RFC 3339 timestamp
+func parsePublishing(status, visibility, publishAt string) (models.VideoPublishing, error) {
+	publishing := models.VideoPublishing{Status: status, Visibility: visibility}
+	if publishAt != "" {
+		t, err := time.Parse(time.RFC3339, publishAt)
+		if err != nil {
+			return publishing, errors.New("Publish time must be an RFC 3339 timestamp")
+		}
+		publishing.PublishAt = &t
+	}
+	return publishing, validatePublishing(&publishing)
+}
+
+// StartPublishScheduler publishes scheduled videos in the background once
+// their publish time has passed
+func StartPublishScheduler() {
+	go func() {
+		publishDueVideos()
+		ticker := time.NewTicker(publishCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			publishDueVideos()
+		}
+	}()
+}
+
+func publishDueVideos() {
+	ids, err := database.PublishDueVideos(time.Now().UTC())
+	if err != nil {
+		log.Printf("Failed to publish scheduled videos: %v", err)
+		return
+	}
+	for _, id := range ids {
+		log.Printf("Published scheduled video %d", id)
+		notifyNewVideo(id)
+	}
+}
+
+// Replace the link token of a video, breaking previously shared links
+func ResetShareTokenHandler(c fiber.Ctx) error {
+	video, err := getOwnedVideo(c)
+	if video == nil {
+		return err
+	}
+
+	token, err := database.ResetShareToken(video.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to reset share link",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Share link reset successfully",
+		Data: map[string]interface{}{
+			"share_token": token,
+		},
+	})
+}
+
+// List published public videos from all teachers
+func GetPublicVideosHandler(c fiber.Ctx) error {
+	videos, err := database.GetPublicVideos()
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get videos",
+		})
+	}
+	hideShareTokens(videos)
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    videos,
+	})
+}
@@ -0,0 +1,1003 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"educational-platform/database"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Quiz limits
+const (
+	maxQuizQuestions   = 100
+	maxQuestionOptions = 10
+	maxQuestionPrompt  = 1000 // characters
+	maxOptionLength    = 200  // characters, also for accepted short-text answers
+	maxQuestionPoints  = 100
+	maxShortAnswer     = 1000 // characters in a student's short-text answer
+)
+
+// quizSubmitGrace is how long after a time limit, in seconds, a submission
+// is still accepted, which covers the time it takes to arrive
+const quizSubmitGrace = 30
+
+// normalizeAnswerText compares short-text answers ignoring case and spacing
+func normalizeAnswerText(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
+
+// parseAnswer reads an answer in the shape a question's type expects and
+// returns it in its stored form
+func parseAnswer(question *models.QuizQuestion, raw json.RawMessage) (json.RawMessage, error) {
+	var value interface{}
+	switch question.Type {
+	case models.QuestionMultipleChoice:
+		var index int
+		if json.Unmarshal(raw, &index) != nil || index < 0 || index >= len(question.Options) {
+			return nil, errors.New("answer must be the index of an option")
+		}
+		value = index
+	case models.QuestionMultiSelect:
+		var indexes []int
+		if json.Unmarshal(raw, &indexes) != nil {
+			return nil, errors.New("answer must be a list of option indexes")
+		}
+		seen := make(map[int]bool)
+		for _, index := range indexes {
+			if index < 0 || index >= len(question.Options) || seen[index] {
+				return nil, errors.New("answer must list distinct option indexes")
+			}
+			seen[index] = true
+		}
+		sort.Ints(indexes)
+		value = indexes
+	case models.QuestionTrueFalse:
+		var answer bool
+		if json.Unmarshal(raw, &answer) != nil {
+			return nil, errors.New("answer must be true or false")
+		}
+		value = answer
+	case models.QuestionNumeric:
+		var answer float64
+		if json.Unmarshal(raw, &answer) != nil {
+			return nil, errors.New("answer must be a number")
+		}
+		value = answer
+	case models.QuestionShortText:
+		var answer string
+		if json.Unmarshal(raw, &answer) != nil {
+			return nil, errors.New("answer must be text")
+		}
+		if utf8.RuneCountInString(answer) > maxShortAnswer {
+			return nil, errors.New("answer is too long")
+		}
+		value = strings.TrimSpace(answer)
+	}
+	return json.Marshal(value)
+}
+
+// answerCorrect grades an answer returned by parseAnswer
+func answerCorrect(question *models.QuizQuestion, answer json.RawMessage) bool {
+	switch question.Type {
+	case models.QuestionNumeric:
+		var given, correct float64
+		json.Unmarshal(answer, &given)
+		json.Unmarshal(question.Answer, &correct)
+		// Allow for floating point error at the edge of the tolerance
+		return given >= correct-question.Tolerance-1e-9 && given <= correct+question.Tolerance+1e-9
+	case models.QuestionShortText:
+		var given string
+		var accepted []string
+		json.Unmarshal(answer, &given)
+		json.Unmarshal(question.Answer, &accepted)
+		for _, a := range accepted {
+			if normalizeAnswerText(a) == normalizeAnswerText(given) {
+				return true
+			}
+		}
+		return false
+	default:
+		// Both sides are in stored form, so equal answers encode equally
+		return string(answer) == string(question.Answer)
+	}
+}
+
+// validateQuestions checks the questions of a quiz against its current
+// ones and returns them with their answers in stored form. Errors number
+// questions from 1.
+func validateQuestions(inputs []models.QuizQuestionInput, existing []models.QuizQuestion) ([]models.QuizQuestion, error) {
+	if len(inputs) > maxQuizQuestions {
+		return nil, fmt.Errorf("A quiz can have at most %d questions", maxQuizQuestions)
+	}
+
+	existingIDs := make(map[int]bool)
+	for _, q := range existing {
+		existingIDs[q.ID] = true
+	}
+	seen := make(map[int]bool)
+
+	questions := make([]models.QuizQuestion, 0, len(inputs))
+	for i, input := range inputs {
+		fail := func(message string) ([]models.QuizQuestion, error) {
+			return nil, fmt.Errorf("Question %d: %s", i+1, message)
+		}
+
+		if input.ID != 0 {
+			if !existingIDs[input.ID] {
+				return fail("no question with this ID in the quiz")
+			}
+			if seen[input.ID] {
+				return fail("the same question is listed twice")
+			}
+			seen[input.ID] = true
+		}
+
+		question := models.QuizQuestion{
+			ID:     input.ID,
+			Type:   input.Type,
+			Prompt: strings.TrimSpace(input.Prompt),
+			Points: input.Points,
+		}
+		if question.Prompt == "" {
+			return fail("prompt is required")
+		}
+		if utf8.RuneCountInString(question.Prompt) > maxQuestionPrompt {
+			return fail("prompt is too long")
+		}
+		if question.Points == 0 {
+			question.Points = 1
+		}
+		if question.Points < 0 || question.Points > maxQuestionPoints {
+			return fail(fmt.Sprintf("points must be between 1 and %d", maxQuestionPoints))
+		}
+
+		switch input.Type {
+		case models.QuestionMultipleChoice, models.QuestionMultiSelect:
+			if len(input.Options) < 2 || len(input.Options) > maxQuestionOptions {
+				return fail(fmt.Sprintf("needs between 2 and %d options", maxQuestionOptions))
+			}
+			for _, option := range input.Options {
+				option = strings.TrimSpace(option)
+				if option == "" {
+					return fail("options cannot be empty")
+				}
+				if utf8.RuneCountInString(option) > maxOptionLength {
+					return fail("an option is too long")
+				}
+				question.Options = append(question.Options, option)
+			}
+		case models.QuestionTrueFalse, models.QuestionNumeric, models.QuestionShortText:
+			if len(input.Options) > 0 {
+				return fail("only choice questions have options")
+			}
+		default:
+			return fail("type must be multiple_choice, multi_select, true_false, numeric or short_text")
+		}
+
+		if len(input.Answer) == 0 || string(input.Answer) == "null" {
+			return fail("answer is required")
+		}
+
+		var err error
+		switch input.Type {
+		case models.QuestionShortText:
+			// One accepted answer or a list of them
+			var accepted []string
+			var single string
+			if json.Unmarshal(input.Answer, &single) == nil {
+				accepted = []string{single}
+			} else if json.Unmarshal(input.Answer, &accepted) != nil {
+				return fail("answer must be text or a list of accepted answers")
+			}
+			if len(accepted) == 0 || len(accepted) > maxQuestionOptions {
+				return fail(fmt.Sprintf("needs between 1 and %d accepted answers", maxQuestionOptions))
+			}
+			for j, a := range accepted {
+				accepted[j] = strings.TrimSpace(a)
+				if accepted[j] == "" {
+					return fail("accepted answers cannot be empty")
+				}
+				if utf8.RuneCountInString(accepted[j]) > maxOptionLength {
+					return fail("an accepted answer is too long")
+				}
+			}
+			question.Answer, err = json.Marshal(accepted)
+		default:
+			question.Answer, err = parseAnswer(&question, input.Answer)
+		}
+		if err != nil {
+			return fail(err.Error())
+		}
+		if input.Type == models.QuestionMultiSelect && string(question.Answer) == "[]" {
+			return fail("at least one option must be correct")
+		}
+
+		if input.Type == models.QuestionNumeric {
+			if input.Tolerance < 0 {
+				return fail("tolerance cannot be negative")
+			}
+			question.Tolerance = input.Tolerance
+		}
+
+		question.Position = i
+		questions = append(questions, question)
+	}
+	return questions, nil
+}
+
+// hideAnswers clears the correct answers of questions shown to students
+func hideAnswers(questions []models.QuizQuestion) {
+	for i := range questions {
+		questions[i].Answer = nil
+		questions[i].Tolerance = 0
+	}
+}
+
+// attemptQuestions returns an attempt's questions in the order they are
+// asked, leaving out any that have since been removed from the quiz
+func attemptQuestions(attempt *models.QuizAttempt, questions []models.QuizQuestion) []models.QuizQuestion {
+	byID := make(map[int]models.QuizQuestion)
+	for _, q := range questions {
+		byID[q.ID] = q
+	}
+	ordered := []models.QuizQuestion{}
+	for _, id := range attempt.QuestionIDs {
+		if q, ok := byID[id]; ok {
+			ordered = append(ordered, q)
+		}
+	}
+	return ordered
+}
+
+// attemptDeadlinePassed reports whether an attempt ran out of time, allowing
+// quizSubmitGrace for the submission to arrive
+func attemptDeadlinePassed(attempt *models.QuizAttempt, now time.Time) bool {
+	return attempt.ExpiresAt != nil && now.After(attempt.ExpiresAt.Add(quizSubmitGrace*time.Second))
+}
+
+// getOwnedQuiz loads the quiz named by the :id parameter and checks that it
+// belongs to the current teacher. On failure the error response has already
+// been written and the returned quiz is nil.
+func getOwnedQuiz(c fiber.Ctx) (*models.Quiz, error) {
+	userID := c.Locals("user_id").(int)
+	quizID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid quiz ID",
+		})
+	}
+
+	quiz, err := database.GetQuizByID(quizID)
+	if err != nil {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Quiz not found",
+		})
+	}
+
+	if quiz.TeacherID != userID {
+		return nil, c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: "Not authorized to modify this quiz",
+		})
+	}
+
+	return quiz, nil
+}
+
+// getStudentQuiz loads the published quiz named by the :id parameter and
+// checks that the student may take it: they need access to its video and,
+// for lesson quizzes, an active enrollment in the course with the lesson
+// unlocked. On failure the error response has already been written and the
+// returned quiz is nil.
+func getStudentQuiz(c fiber.Ctx) (*models.Quiz, error) {
+	userID := c.Locals("user_id").(int)
+	quizID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid quiz ID",
+		})
+	}
+
+	notFound := func() (*models.Quiz, error) {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Quiz not found",
+		})
+	}
+	failed := func() (*models.Quiz, error) {
+		return nil, c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get quiz",
+		})
+	}
+
+	quiz, err := database.GetQuizByID(quizID)
+	if err != nil || quiz.Status != models.QuizStatusPublished {
+		return notFound()
+	}
+	video, err := database.GetVideoByID(quiz.VideoID)
+	if err != nil {
+		return notFound()
+	}
+	if ok, err := checkVideoAccess(c, video); !ok {
+		return nil, err
+	}
+
+	if quiz.LessonID == 0 {
+		if ok, err := checkLessonUnlocked(c, video); !ok {
+			return nil, err
+		}
+		return quiz, nil
+	}
+
+	course, err := database.GetCourseByID(quiz.CourseID)
+	if err != nil || course.Status != models.CourseStatusPublished {
+		return notFound()
+	}
+	enrollment, err := database.GetEnrollment(course.ID, userID)
+	if err != nil && err != sql.ErrNoRows {
+		return failed()
+	}
+	if enrollment == nil || enrollment.Status != models.EnrollmentStatusActive || enrollmentExpired(enrollment) {
+		return nil, c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: "You must be enrolled in this quiz's course to take it",
+		})
+	}
+
+	sections, err := database.GetCourseSections(course.ID, true)
+	if err == nil {
+		err = applyLessonLocks(userID, course, sections)
+	}
+	if err != nil {
+		return failed()
+	}
+	for _, section := range sections {
+		for _, lesson := range section.Lessons {
+			if lesson.ID != quiz.LessonID {
+				continue
+			}
+			if lesson.Locked {
+				return nil, c.Status(403).JSON(models.APIResponse{
+					Success: false,
+					Message: "This lesson is locked: " + lesson.LockedReason,
+				})
+			}
+			return quiz, nil
+		}
+	}
+	return notFound()
+}
+
+// createQuiz adds a draft quiz to a video, or to one of its lessons
+func createQuiz(c fiber.Ctx, video *models.Video, lesson *models.Lesson) error {
+	var req models.QuizRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	if req.Title == nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Title is required",
+		})
+	}
+	quiz := &models.Quiz{TeacherID: video.TeacherID, VideoID: video.ID, Status: models.QuizStatusDraft}
+	if lesson != nil {
+		quiz.LessonID, quiz.CourseID = lesson.ID, lesson.CourseID
+	}
+	err := applyQuizSettings(quiz, &req, video.Duration)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	quizID, err := database.CreateQuiz(quiz)
+	if err == nil {
+		quiz, err = database.GetQuizByID(quizID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to create quiz",
+		})
+	}
+	quiz.Questions = []models.QuizQuestion{}
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: "Quiz created successfully",
+		Data:    quiz,
+	})
+}
+
+// applyQuizSettings copies the settings of a request onto a quiz, checking
+// them against the video's duration (0 when unknown)
+func applyQuizSettings(quiz *models.Quiz, req *models.QuizRequest, duration int) error {
+	var err error
+	if req.Title != nil {
+		quiz.Title, err = checkTitle(*req.Title)
+		if err != nil {
+			return err
+		}
+	}
+	if req.Description != nil {
+		quiz.Description = *req.Description
+	}
+	if req.Status != nil {
+		if *req.Status != models.QuizStatusDraft && *req.Status != models.QuizStatusPublished {
+			return errors.New("Status must be draft or published")
+		}
+		if *req.Status == models.QuizStatusPublished && quiz.QuestionCount == 0 {
+			return errors.New("Add questions before publishing the quiz")
+		}
+		quiz.Status = *req.Status
+	}
+	if req.Timestamp != nil {
+		if *req.Timestamp < 0 {
+			return errors.New("Timestamp cannot be negative")
+		}
+		if duration > 0 && *req.Timestamp >= duration {
+			return errors.New("Timestamp is after the end of the video")
+		}
+		quiz.Timestamp = *req.Timestamp
+	}
+	if req.MaxAttempts != nil {
+		if *req.MaxAttempts < 0 {
+			return errors.New("Maximum attempts cannot be negative")
+		}
+		quiz.MaxAttempts = *req.MaxAttempts
+	}
+	if req.TimeLimit != nil {
+		if *req.TimeLimit < 0 {
+			return errors.New("Time limit cannot be negative")
+		}
+		quiz.TimeLimit = *req.TimeLimit
+	}
+	if req.ShuffleQuestions != nil {
+		quiz.ShuffleQuestions = *req.ShuffleQuestions
+	}
+	if req.PassPercent != nil {
+		if *req.PassPercent < 0 || *req.PassPercent > 100 {
+			return errors.New("Pass percentage must be between 0 and 100")
+		}
+		quiz.PassPercent = *req.PassPercent
+	}
+	if req.CategoryID != nil {
+		// Lesson quizzes count in their course's gradebook, others in the teacher's
+		if *req.CategoryID != 0 {
+			if err := checkGradeCategory(quiz.TeacherID, quiz.CourseID, *req.CategoryID); err != nil {
+				return err
+			}
+		}
+		quiz.CategoryID = *req.CategoryID
+	}
+	return nil
+}
+
+// Create a quiz on a video
+func CreateVideoQuizHandler(c fiber.Ctx) error {
+	video, err := getOwnedVideo(c)
+	if video == nil {
+		return err
+	}
+	return createQuiz(c, video, nil)
+}
+
+// Create a quiz on a lesson; only students enrolled in the course see it
+func CreateLessonQuizHandler(c fiber.Ctx) error {
+	course, err := getOwnedCourse(c)
+	if course == nil {
+		return err
+	}
+	lesson, err := getCourseLesson(c, course)
+	if lesson == nil {
+		return err
+	}
+
+	video, err := database.GetVideoByID(lesson.VideoID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to create quiz",
+		})
+	}
+	return createQuiz(c, video, lesson)
+}
+
+// List the teacher's quizzes
+func GetTeacherQuizzesHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	quizzes, err := database.GetQuizzesByTeacherID(userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get quizzes",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    quizzes,
+	})
+}
+
+// Get a quiz with its questions and answers
+func GetTeacherQuizHandler(c fiber.Ctx) error {
+	quiz, err := getOwnedQuiz(c)
+	if quiz == nil {
+		return err
+	}
+
+	quiz.Questions, err = database.GetQuizQuestions(quiz.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get quiz",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    quiz,
+	})
+}
+
+// Update a quiz's title, description or settings
+func UpdateQuizHandler(c fiber.Ctx) error {
+	quiz, err := getOwnedQuiz(c)
+	if quiz == nil {
+		return err
+	}
+
+	var req models.QuizRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	video, err := database.GetVideoByID(quiz.VideoID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update quiz",
+		})
+	}
+
+	err = applyQuizSettings(quiz, &req, video.Duration)
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	err = database.UpdateQuiz(quiz)
+	if err == nil {
+		quiz, err = database.GetQuizByID(quiz.ID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update quiz",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Quiz updated successfully",
+		Data:    quiz,
+	})
+}
+
+// Delete a quiz with its attempts
+func DeleteQuizHandler(c fiber.Ctx) error {
+	quiz, err := getOwnedQuiz(c)
+	if quiz == nil {
+		return err
+	}
+
+	err = database.DeleteQuiz(quiz.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to delete quiz",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Quiz deleted successfully",
+	})
+}
+
+// Replace a quiz's questions. Attempts in progress are graded against the
+// questions they were given that are still there, as edited.
+func UpdateQuizQuestionsHandler(c fiber.Ctx) error {
+	quiz, err := getOwnedQuiz(c)
+	if quiz == nil {
+		return err
+	}
+
+	var req models.QuizQuestionsRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	existing, err := database.GetQuizQuestions(quiz.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to save questions",
+		})
+	}
+
+	questions, err := validateQuestions(req.Questions, existing)
+	if err == nil && len(questions) == 0 && quiz.Status == models.QuizStatusPublished {
+		err = errors.New("A published quiz needs at least one question")
+	}
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	err = database.ReplaceQuizQuestions(quiz.ID, questions)
+	if err == nil {
+		quiz, err = database.GetQuizByID(quiz.ID)
+	}
+	if err == nil {
+		quiz.Questions, err = database.GetQuizQuestions(quiz.ID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to save questions",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Questions updated successfully",
+		Data:    quiz,
+	})
+}
+
+// List the attempts at a quiz; ?student_id= narrows it to one student
+func GetQuizAttemptsHandler(c fiber.Ctx) error {
+	quiz, err := getOwnedQuiz(c)
+	if quiz == nil {
+		return err
+	}
+
+	studentID := 0
+	if s := c.Query("student_id"); s != "" {
+		studentID, err = strconv.Atoi(s)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: "Invalid student ID",
+			})
+		}
+	}
+
+	err = database.ExpireQuizAttempts(quiz.ID, time.Now().UTC().Add(-quizSubmitGrace*time.Second))
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get attempts",
+		})
+	}
+
+	attempts, err := database.GetQuizAttempts(quiz.ID, studentID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get attempts",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    attempts,
+	})
+}
+
+// Get a quiz with a summary of the student's attempts
+func GetStudentQuizHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	quiz, err := getStudentQuiz(c)
+	if quiz == nil {
+		return err
+	}
+
+	err = database.ExpireQuizAttempts(quiz.ID, time.Now().UTC().Add(-quizSubmitGrace*time.Second))
+	if err == nil {
+		quiz.Attempts, err = database.GetQuizAttemptSummary(quiz.ID, userID)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get quiz",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    quiz,
+	})
+}
+
+// List the student's attempts at a quiz with their results
+func GetStudentQuizAttemptsHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	quiz, err := getStudentQuiz(c)
+	if quiz == nil {
+		return err
+	}
+
+	err = database.ExpireQuizAttempts(quiz.ID, time.Now().UTC().Add(-quizSubmitGrace*time.Second))
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get attempts",
+		})
+	}
+
+	attempts, err := database.GetQuizAttempts(quiz.ID, userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get attempts",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    attempts,
+	})
+}
+
+// Start an attempt at a quiz, or resume the one in progress
+func StartQuizAttemptHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	quiz, err := getStudentQuiz(c)
+	if quiz == nil {
+		return err
+	}
+
+	failed := func() error {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to start attempt",
+		})
+	}
+
+	now := time.Now().UTC()
+	err = database.ExpireQuizAttempts(quiz.ID, now.Add(-quizSubmitGrace*time.Second))
+	if err != nil {
+		return failed()
+	}
+	questions, err := database.GetQuizQuestions(quiz.ID)
+	if err != nil {
+		return failed()
+	}
+
+	attempt := &models.QuizAttempt{
+		QuizID:    quiz.ID,
+		StudentID: userID,
+		Status:    models.AttemptInProgress,
+		StartedAt: now,
+	}
+	for _, q := range questions {
+		attempt.QuestionIDs = append(attempt.QuestionIDs, q.ID)
+		attempt.MaxScore += q.Points
+	}
+	if quiz.ShuffleQuestions {
+		rand.Shuffle(len(attempt.QuestionIDs), func(i, j int) {
+			attempt.QuestionIDs[i], attempt.QuestionIDs[j] = attempt.QuestionIDs[j], attempt.QuestionIDs[i]
+		})
+	}
+	if quiz.TimeLimit > 0 {
+		expiresAt := now.Add(time.Duration(quiz.TimeLimit) * time.Second)
+		attempt.ExpiresAt = &expiresAt
+	}
+
+	status := 201
+	message := "Attempt started"
+	attempt.ID, err = database.CreateQuizAttempt(attempt, quiz.MaxAttempts)
+	if err == database.ErrNoAttemptsLeft {
+		// Either an attempt is still open, which is resumed, or all are used
+		var summary *models.QuizAttemptSummary
+		summary, err = database.GetQuizAttemptSummary(quiz.ID, userID)
+		if err != nil {
+			return failed()
+		}
+		if summary.InProgress == 0 {
+			return c.Status(403).JSON(models.APIResponse{
+				Success: false,
+				Message: "You have used all your attempts at this quiz",
+			})
+		}
+		attempt, err = database.GetQuizAttempt(quiz.ID, summary.InProgress, userID)
+		status = 200
+		message = "Attempt resumed"
+	}
+	if err != nil {
+		return failed()
+	}
+
+	attempt.Questions = attemptQuestions(attempt, questions)
+	hideAnswers(attempt.Questions)
+
+	return c.Status(status).JSON(models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    attempt,
+	})
+}
+
+// Submit the answers of an attempt and grade it
+func SubmitQuizAttemptHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+	quiz, err := getStudentQuiz(c)
+	if quiz == nil {
+		return err
+	}
+
+	attemptID, err := strconv.Atoi(c.Params("attempt_id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid attempt ID",
+		})
+	}
+	attempt, err := database.GetQuizAttempt(quiz.ID, attemptID, userID)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Attempt not found",
+		})
+	}
+
+	failed := func() error {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to submit attempt",
+		})
+	}
+
+	now := time.Now().UTC()
+	if attempt.Status == models.AttemptInProgress && attemptDeadlinePassed(attempt, now) {
+		err = database.ExpireQuizAttempts(quiz.ID, now.Add(-quizSubmitGrace*time.Second))
+		if err != nil {
+			return failed()
+		}
+		attempt.Status = models.AttemptExpired
+	}
+	switch attempt.Status {
+	case models.AttemptExpired:
+		return c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: "The time limit for this attempt has passed",
+		})
+	case models.AttemptSubmitted:
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Message: "This attempt has already been submitted",
+		})
+	}
+
+	var req models.QuizSubmitRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	questions, err := database.GetQuizQuestions(quiz.ID)
+	if err != nil {
+		return failed()
+	}
+	asked := attemptQuestions(attempt, questions)
+
+	byID := make(map[int]*models.QuizQuestion)
+	for i := range asked {
+		byID[asked[i].ID] = &asked[i]
+	}
+	answers := make(map[int]json.RawMessage)
+	for _, a := range req.Answers {
+		question, ok := byID[a.QuestionID]
+		if !ok {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: fmt.Sprintf("Question %d is not part of this attempt", a.QuestionID),
+			})
+		}
+		if _, ok := answers[a.QuestionID]; ok {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: fmt.Sprintf("Question %d is answered more than once", a.QuestionID),
+			})
+		}
+		if len(a.Answer) == 0 || string(a.Answer) == "null" {
+			continue
+		}
+		answer, err := parseAnswer(question, a.Answer)
+		if err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: fmt.Sprintf("Question %d: %s", a.QuestionID, err.Error()),
+			})
+		}
+		answers[a.QuestionID] = answer
+	}
+
+	// Unanswered questions score nothing
+	attempt.Score, attempt.MaxScore = 0, 0
+	attempt.Results = make([]models.QuizAnswerResult, 0, len(asked))
+	for i := range asked {
+		question := &asked[i]
+		result := models.QuizAnswerResult{QuestionID: question.ID, MaxPoints: question.Points}
+		if answer, ok := answers[question.ID]; ok {
+			result.Answer = answer
+			result.Correct = answerCorrect(question, answer)
+		}
+		if result.Correct {
+			result.Points = question.Points
+		}
+		attempt.Score += result.Points
+		attempt.MaxScore += question.Points
+		attempt.Results = append(attempt.Results, result)
+	}
+	if attempt.MaxScore > 0 {
+		attempt.Percent = roundTenth(float64(attempt.Score) / float64(attempt.MaxScore) * 100)
+	}
+	attempt.Passed = attempt.Percent >= float64(quiz.PassPercent)
+	attempt.Status = models.AttemptSubmitted
+	attempt.SubmittedAt = &now
+
+	err = database.SubmitQuizAttempt(attempt)
+	if err == database.ErrAttemptClosed {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Message: "This attempt has already been submitted",
+		})
+	}
+	if err != nil {
+		return failed()
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Attempt submitted",
+		Data:    attempt,
+	})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"educational-platform/models"
+)
+
+func TestParseAnswer(t *testing.T) {
+	options := []string{"a", "b", "c", "d"}
+
+	tests := []struct {
+		name         string
+		questionType string
+		raw          string
+		want         string // stored form; empty when the answer is rejected
+	}{
+		{"choice", models.QuestionMultipleChoice, `2`, `2`},
+		{"choice out of range", models.QuestionMultipleChoice, `4`, ""},
+		{"choice negative", models.QuestionMultipleChoice, `-1`, ""},
+		{"choice not a number", models.QuestionMultipleChoice, `"b"`, ""},
+		{"multi select sorted", models.QuestionMultiSelect, `[3,0,1]`, `[0,1,3]`},
+		{"multi select empty", models.QuestionMultiSelect, `[]`, `[]`},
+		{"multi select duplicate", models.QuestionMultiSelect, `[1,1]`, ""},
+		{"multi select out of range", models.QuestionMultiSelect, `[0,4]`, ""},
+		{"multi select not a list", models.QuestionMultiSelect, `1`, ""},
+		{"true false", models.QuestionTrueFalse, `false`, `false`},
+		{"true false as text", models.QuestionTrueFalse, `"true"`, ""},
+		{"numeric", models.QuestionNumeric, `3.50`, `3.5`},
+		{"numeric as text", models.QuestionNumeric, `"3.5"`, ""},
+		{"short text trimmed", models.QuestionShortText, `"  Paris "`, `"Paris"`},
+		{"short text not text", models.QuestionShortText, `5`, ""},
+		{"short text too long", models.QuestionShortText, `"` + strings.Repeat("é", maxShortAnswer+1) + `"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			question := &models.QuizQuestion{Type: tt.questionType, Options: options}
+			got, err := parseAnswer(question, json.RawMessage(tt.raw))
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("parseAnswer(%s) = %s, want an error", tt.raw, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseAnswer(%s): %v", tt.raw, err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("parseAnswer(%s) = %s, want %s", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnswerCorrect(t *testing.T) {
+	options := []string{"a", "b", "c", "d"}
+
+	tests := []struct {
+		name         string
+		questionType string
+		answer       string // the question's stored answer
+		tolerance    float64
+		given        string // as submitted, before parseAnswer
+		want         bool
+	}{
+		{"choice", models.QuestionMultipleChoice, `1`, 0, `1`, true},
+		{"wrong choice", models.QuestionMultipleChoice, `1`, 0, `2`, false},
+		{"multi select any order", models.QuestionMultiSelect, `[0,2]`, 0, `[2,0]`, true},
+		{"multi select missing option", models.QuestionMultiSelect, `[0,2]`, 0, `[0]`, false},
+		{"multi select extra option", models.QuestionMultiSelect, `[0,2]`, 0, `[0,1,2]`, false},
+		{"multi select none correct", models.QuestionMultiSelect, `[]`, 0, `[]`, true},
+		{"true false", models.QuestionTrueFalse, `true`, 0, `true`, true},
+		{"wrong true false", models.QuestionTrueFalse, `true`, 0, `false`, false},
+		{"numeric exact", models.QuestionNumeric, `9.81`, 0, `9.81`, true},
+		{"numeric within tolerance", models.QuestionNumeric, `9.81`, 0.05, `9.78`, true},
+		{"numeric at tolerance", models.QuestionNumeric, `0.3`, 0.1, `0.2`, true},
+		{"numeric past tolerance", models.QuestionNumeric, `9.81`, 0.05, `9.7`, false},
+		{"numeric no tolerance", models.QuestionNumeric, `3`, 0, `3.01`, false},
+		{"short text", models.QuestionShortText, `["Paris"]`, 0, `"Paris"`, true},
+		{"short text case and spacing", models.QuestionShortText, `["New  York"]`, 0, `"  new york "`, true},
+		{"short text second accepted", models.QuestionShortText, `["NYC","New York"]`, 0, `"new york"`, true},
+		{"short text inner spacing", models.QuestionShortText, `["New York"]`, 0, `"NewYork"`, false},
+		{"short text wrong", models.QuestionShortText, `["Paris"]`, 0, `"Lyon"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			question := &models.QuizQuestion{
+				Type:      tt.questionType,
+				Options:   options,
+				Answer:    json.RawMessage(tt.answer),
+				Tolerance: tt.tolerance,
+			}
+			given, err := parseAnswer(question, json.RawMessage(tt.given))
+			if err != nil {
+				t.Fatalf("parseAnswer(%s): %v", tt.given, err)
+			}
+			if got := answerCorrect(question, given); got != tt.want {
+				t.Errorf("answerCorrect(%s) = %v, want %v", given, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"educational-platform/database"
+	"educational-platform/events"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Real-time stream timing
+const (
+	eventHeartbeatInterval = 25 * time.Second
+	eventRetryInterval     = 3 * time.Second // reconnect delay suggested to SSE clients
+	// A WebSocket client that sends nothing, not even a pong, for this long
+	// is gone
+	eventSocketIdleTimeout = 2*eventHeartbeatInterval + 10*time.Second
+)
+
+// publishEvent sends a real-time event to a user's open streams, and queues
+// it for a teacher's webhooks that subscribe to it
+func publishEvent(userType string, userID int, eventType string, data interface{}) {
+	events.Publish(events.User{Type: userType, ID: userID}, eventType, data)
+	if userType == "teacher" && isWebhookEvent(eventType) {
+		queueWebhooks(userID, eventType, data)
+	}
+}
+
+// publishStudentEvent sends a teacher an event about something one of their
+// students did, adding the student's ID and name to data
+func publishStudentEvent(teacherID int, eventType string, studentID int, data map[string]interface{}) {
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	data["student_id"] = studentID
+	if student, err := database.GetStudentByID(studentID); err == nil {
+		data["student_name"] = student.Name
+	}
+	publishEvent("teacher", teacherID, eventType, data)
+}
+
+// subscribeEvents opens an event subscription for the current user, resuming
+// after the Last-Event-ID header or ?last_event_id= if given
+func subscribeEvents(c fiber.Ctx) (*events.Subscription, int64, error) {
+	userType := c.Locals("user_type").(string)
+	userID := c.Locals("user_id").(int)
+
+	lastEventID := c.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var lastID int64
+	if lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || id < 0 {
+			return nil, 0, c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: "Invalid last event ID",
+			})
+		}
+		lastID = id
+	}
+
+	sub, err := events.Subscribe(events.User{Type: userType, ID: userID}, lastID)
+	if err == events.ErrTooManyStreams {
+		return nil, 0, c.Status(429).JSON(models.APIResponse{
+			Success: false,
+			Message: "Too many open event streams",
+		})
+	}
+	if err != nil {
+		return nil, 0, c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to open event stream",
+		})
+	}
+	return sub, lastID, nil
+}
+
+// openingEvents are the first events of a stream: ready for a new client,
+// reset if the client missed events that are no longer kept, or else the
+// events published since its last event ID
+func openingEvents(sub *events.Subscription, lastID int64) []events.Event {
+	now := time.Now().UTC()
+	if sub.Reset {
+		return []events.Event{{ID: sub.Cursor, Type: events.Reset, Data: json.RawMessage("{}"), Time: now}}
+	}
+	if lastID == 0 {
+		return []events.Event{{ID: sub.Cursor, Type: events.Ready, Data: json.RawMessage("{}"), Time: now}}
+	}
+	return sub.Replay
+}
+
+// writeServerSentEvent writes an event in text/event-stream format
+func writeServerSentEvent(w *bufio.Writer, ev events.Event) {
+	fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, ev.Data)
+}
+
+// Stream the user's real-time events as Server-Sent Events
+func EventStreamHandler(c fiber.Ctx) error {
+	sub, lastID, err := subscribeEvents(c)
+	if sub == nil {
+		return err
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("X-Accel-Buffering", "no") // keep proxies from buffering the stream
+
+	return c.SendStreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+
+		fmt.Fprintf(w, "retry: %d\n\n", eventRetryInterval.Milliseconds())
+		for _, ev := range openingEvents(sub, lastID) {
+			writeServerSentEvent(w, ev)
+		}
+		if w.Flush() != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(eventHeartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case ev, ok := <-sub.Events():
+				if !ok {
+					// Dropped for falling behind; the client reconnects
+					// with its last event ID and catches up
+					return
+				}
+				writeServerSentEvent(w, ev)
+			case <-heartbeat.C:
+				w.WriteString(": heartbeat\n\n")
+			}
+			// A failed flush means the client has gone
+			if w.Flush() != nil {
+				return
+			}
+		}
+	})
+}
+
+// Stream the user's real-time events over a WebSocket, one JSON text message
+// per event
+func EventSocketHandler(c fiber.Ctx) error {
+	accept, err := checkWebSocketUpgrade(c)
+	if err != nil {
+		return c.Status(err.(*fiber.Error).Code).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	sub, lastID, err := subscribeEvents(c)
+	if sub == nil {
+		return err
+	}
+
+	c.Status(fiber.StatusSwitchingProtocols)
+	c.Set("Upgrade", "websocket")
+	c.Set("Connection", "Upgrade")
+	c.Set("Sec-WebSocket-Accept", accept)
+	c.RequestCtx().Hijack(func(conn net.Conn) {
+		defer sub.Close()
+		serveEventSocket(newWSConn(conn), sub, lastID)
+	})
+	return nil
+}
+
+// serveEventSocket pushes events to a WebSocket until either side closes it
+func serveEventSocket(ws *wsConn, sub *events.Subscription, lastID int64) {
+	send := func(ev events.Event) error {
+		message, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		return ws.writeFrame(wsText, message)
+	}
+
+	for _, ev := range openingEvents(sub, lastID) {
+		if send(ev) != nil {
+			return
+		}
+	}
+
+	done := make(chan error, 1)
+	go ws.readLoop(eventSocketIdleTimeout, done)
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case ev, ok := <-sub.Events():
+			if !ok {
+				ws.writeClose(wsCloseTryAgain, "too slow, reconnect with last_event_id")
+				return
+			}
+			if send(ev) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if ws.writeFrame(wsPing, nil) != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
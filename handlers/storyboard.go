@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"educational-platform/database"
+	"educational-platform/events"
+	"educational-platform/models"
+	"educational-platform/storage"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Storyboard layout: small frames tiled into sheets of columns x rows
+const (
+	storyboardTileWidth   = 160
+	storyboardTileHeight  = 90
+	storyboardColumns     = 10
+	storyboardRows        = 10
+	storyboardMinInterval = 5   // seconds
+	storyboardMaxFrames   = 400 // per video; long lectures get a coarser interval
+)
+
+// storyboardInterval picks the seconds between preview frames for a duration
+func storyboardInterval(duration int) int {
+	interval := (duration + storyboardMaxFrames - 1) / storyboardMaxFrames
+	if interval < storyboardMinInterval {
+		interval = storyboardMinInterval
+	}
+	return interval
+}
+
+// storyboardSheetKey returns the storage key of sheet n
+func storyboardSheetKey(sb *models.Storyboard, n int) string {
+	return fmt.Sprintf("%s/sheet_%d.jpg", sb.KeyPrefix, n)
+}
+
+// generateStoryboard extracts frames from a stored video at regular intervals,
+// tiles them into sprite sheets and records the layout. It runs in the
+// background after upload, so failures are only logged.
+func generateStoryboard(videoID int, videoKey string, duration int) {
+	videoPath, cleanup, err := storage.LocalFile(storage.Store, videoKey, StagingDir)
+	if err != nil {
+		log.Printf("storyboard for video %d: %v", videoID, err)
+		return
+	}
+	defer cleanup()
+
+	frameDir, err := os.MkdirTemp(StagingDir, "storyboard_*")
+	if err != nil {
+		log.Printf("storyboard for video %d: %v", videoID, err)
+		return
+	}
+	defer os.RemoveAll(frameDir)
+
+	interval := storyboardInterval(duration)
+
+	// One frame every interval seconds, letterboxed into the tile size
+	filter := fmt.Sprintf("fps=1/%d,scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2",
+		interval, storyboardTileWidth, storyboardTileHeight, storyboardTileWidth, storyboardTileHeight)
+	cmd := exec.Command("ffmpeg",
+		"-i", videoPath,
+		"-vf", filter,
+		"-q:v", "5",
+		filepath.Join(frameDir, "frame_%05d.jpg"),
+		"-y")
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("storyboard for video %d: ffmpeg failed: %v: %s", videoID, err, lastLine(output))
+		return
+	}
+
+	frames, _ := filepath.Glob(filepath.Join(frameDir, "frame_*.jpg"))
+	if len(frames) == 0 {
+		log.Printf("storyboard for video %d: no frames extracted", videoID)
+		return
+	}
+
+	base := strings.TrimSuffix(filepath.Base(videoKey), filepath.Ext(videoKey))
+	sb := &models.Storyboard{
+		VideoID:    videoID,
+		Interval:   interval,
+		TileWidth:  storyboardTileWidth,
+		TileHeight: storyboardTileHeight,
+		Columns:    storyboardColumns,
+		Rows:       storyboardRows,
+		FrameCount: len(frames),
+		KeyPrefix:  "storyboards/" + base,
+	}
+
+	perSheet := sb.Columns * sb.Rows
+	for start := 0; start < len(frames); start += perSheet {
+		end := start + perSheet
+		if end > len(frames) {
+			end = len(frames)
+		}
+
+		sheet, err := composeSpriteSheet(sb, frames[start:end])
+		if err != nil {
+			log.Printf("storyboard for video %d: %v", videoID, err)
+			deleteStoryboardSheets(sb)
+			return
+		}
+
+		var buf bytes.Buffer
+		err = jpeg.Encode(&buf, sheet, &jpeg.Options{Quality: 75})
+		if err == nil {
+			err = storage.Store.Put(storyboardSheetKey(sb, sb.SheetCount), &buf, int64(buf.Len()), "image/jpeg")
+		}
+		if err != nil {
+			log.Printf("storyboard for video %d: %v", videoID, err)
+			deleteStoryboardSheets(sb)
+			return
+		}
+		sb.SheetCount++
+	}
+
+	// The video may have been deleted while frames were extracted
+	video, err := database.GetVideoByID(videoID)
+	if err != nil {
+		deleteStoryboardSheets(sb)
+		return
+	}
+
+	if err := database.SaveStoryboard(sb); err != nil {
+		log.Printf("storyboard for video %d: %v", videoID, err)
+		deleteStoryboardSheets(sb)
+		return
+	}
+	publishEvent("teacher", video.TeacherID, events.StoryboardReady, map[string]interface{}{
+		"video_id": videoID,
+	})
+}
+
+// composeSpriteSheet tiles frame images left to right, top to bottom. The
+// last sheet is only as tall as the rows it uses.
+func composeSpriteSheet(sb *models.Storyboard, framePaths []string) (*image.RGBA, error) {
+	rows := (len(framePaths) + sb.Columns - 1) / sb.Columns
+	sheet := image.NewRGBA(image.Rect(0, 0, sb.Columns*sb.TileWidth, rows*sb.TileHeight))
+
+	for i, framePath := range framePaths {
+		file, err := os.Open(framePath)
+		if err != nil {
+			return nil, err
+		}
+		frame, err := jpeg.Decode(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		x := (i % sb.Columns) * sb.TileWidth
+		y := (i / sb.Columns) * sb.TileHeight
+		tile := image.Rect(x, y, x+sb.TileWidth, y+sb.TileHeight)
+		draw.Draw(sheet, tile, frame, frame.Bounds().Min, draw.Src)
+	}
+	return sheet, nil
+}
+
+// deleteStoryboardSheets removes every stored sheet of a storyboard
+func deleteStoryboardSheets(sb *models.Storyboard) {
+	sheets := sb.SheetCount
+	if sheets == 0 {
+		// Partially written storyboard; remove as many sheets as the frames need
+		perSheet := sb.Columns * sb.Rows
+		sheets = (sb.FrameCount + perSheet - 1) / perSheet
+	}
+	for n := 0; n < sheets; n++ {
+		storage.Store.Delete(storyboardSheetKey(sb, n))
+	}
+}
+
+// buildStoryboardVTT writes a WebVTT thumbnails track whose cues point at
+// sprite sheet regions with #xywh media fragments
+func buildStoryboardVTT(sb *models.Storyboard, duration int) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+
+	perSheet := sb.Columns * sb.Rows
+	for i := 0; i < sb.FrameCount; i++ {
+		start := i * sb.Interval
+		end := start + sb.Interval
+		if duration > 0 && end > duration {
+			end = duration
+		}
+		if end <= start {
+			break
+		}
+
+		sheet := i / perSheet
+		pos := i % perSheet
+		x := (pos % sb.Columns) * sb.TileWidth
+		y := (pos / sb.Columns) * sb.TileHeight
+
+		fmt.Fprintf(&b, "%s --> %s\nstoryboard/%d.jpg#xywh=%d,%d,%d,%d\n\n",
+			formatVTTTimestamp(start*1000), formatVTTTimestamp(end*1000), sheet, x, y, sb.TileWidth, sb.TileHeight)
+	}
+	return b.String()
+}
+
+// formatVTTTimestamp formats milliseconds as HH:MM:SS.mmm
+func formatVTTTimestamp(ms int) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// lastLine returns the last non-empty line of command output for logging
+func lastLine(output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return lines[len(lines)-1]
+}
+
+// getVideoStoryboard loads the video named by :id and its storyboard. On
+// failure the error response has already been written and sb is nil.
+func getVideoStoryboard(c fiber.Ctx) (*models.Video, *models.Storyboard, error) {
+	videoID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid video ID",
+		})
+	}
+
+	video, err := database.GetVideoByID(videoID)
+	if err != nil {
+		return nil, nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Video not found",
+		})
+	}
+
+	if ok, err := checkVideoAccess(c, video); !ok {
+		return nil, nil, err
+	}
+
+	sb, err := database.GetStoryboard(videoID)
+	if err != nil {
+		return nil, nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Storyboard not available",
+		})
+	}
+
+	return video, sb, nil
+}
+
+// Serve the WebVTT thumbnails track for scrubbing previews
+func ServeStoryboardVTTHandler(c fiber.Ctx) error {
+	video, sb, err := getVideoStoryboard(c)
+	if sb == nil {
+		return err
+	}
+
+	c.Set("Content-Type", "text/vtt; charset=utf-8")
+	c.Set("Cache-Control", mediaCacheControl(video, 3600))
+	return c.SendString(buildStoryboardVTT(sb, video.Duration))
+}
+
+// Serve one storyboard sprite sheet
+func ServeStoryboardSheetHandler(c fiber.Ctx) error {
+	video, sb, err := getVideoStoryboard(c)
+	if sb == nil {
+		return err
+	}
+
+	sheet, err := strconv.Atoi(strings.TrimSuffix(c.Params("sheet"), ".jpg"))
+	if err != nil || sheet < 0 || sheet >= sb.SheetCount {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Storyboard sheet not found",
+		})
+	}
+
+	c.Set("Cache-Control", mediaCacheControl(video, 86400))
+	return sendStoredObject(c, storyboardSheetKey(sb, sheet), "image/jpeg", "Storyboard sheet not found")
+}
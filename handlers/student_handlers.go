@@ -1,14 +1,26 @@
 package handlers
 
 import (
+	"database/sql"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"educational-platform/database"
+	"educational-platform/events"
 	"educational-platform/models"
 
 	"github.com/gofiber/fiber/v3"
 )
 
+// maxDeviceLength matches the watch_sessions.device column
+const maxDeviceLength = 200
+
+// watchSessionReuseWindow is how long after its last activity a watch
+// session is continued instead of counting a new view
+const watchSessionReuseWindow = 30 * time.Minute
+
 // Student dashboard endpoint
 func StudentDashboardHandler(c fiber.Ctx) error {
 	userID := c.Locals("user_id").(int)
@@ -24,12 +36,16 @@ func StudentDashboardHandler(c fiber.Ctx) error {
 
 	// Get available videos from subscribed teachers
 	videos, err := database.GetVideosForStudent(userID)
+	if err == nil {
+		err = attachProgress(userID, videos)
+	}
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
 			Message: "Failed to get videos",
 		})
 	}
+	hideShareTokens(videos)
 
 	return c.JSON(models.APIResponse{
 		Success: true,
@@ -92,6 +108,7 @@ func SubscribeToTeacherHandler(c fiber.Ctx) error {
 			Message: "Failed to subscribe",
 		})
 	}
+	publishStudentEvent(teacherID, events.SubscriptionCreated, studentID, nil)
 
 	return c.JSON(models.APIResponse{
 		Success: true,
@@ -135,6 +152,7 @@ func UnsubscribeFromTeacherHandler(c fiber.Ctx) error {
 			Message: "Failed to unsubscribe",
 		})
 	}
+	publishStudentEvent(teacherID, events.SubscriptionDeleted, studentID, nil)
 
 	return c.JSON(models.APIResponse{
 		Success: true,
@@ -147,12 +165,16 @@ func GetStudentVideosHandler(c fiber.Ctx) error {
 	userID := c.Locals("user_id").(int)
 
 	videos, err := database.GetVideosForStudent(userID)
+	if err == nil {
+		err = attachProgress(userID, videos)
+	}
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
 			Message: "Failed to get videos",
 		})
 	}
+	hideShareTokens(videos)
 
 	return c.JSON(models.APIResponse{
 		Success: true,
@@ -181,28 +203,70 @@ func WatchVideoHandler(c fiber.Ctx) error {
 		})
 	}
 
-	// Check if student is subscribed to the teacher
-	subscribed, err := database.IsSubscribed(studentID, video.TeacherID)
+	// Check status, visibility and the student's subscription
+	if ok, err := checkVideoAccess(c, video); !ok {
+		return err
+	}
+	if ok, err := checkLessonUnlocked(c, video); !ok {
+		return err
+	}
+	video.ShareToken = ""
+
+	// The device defaults to the browser's user agent
+	var req models.WatchRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().Body(&req); err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: "Invalid request body",
+			})
+		}
+	}
+	device := strings.TrimSpace(req.Device)
+	if device == "" {
+		device = c.Get("User-Agent")
+	}
+	if utf8.RuneCountInString(device) > maxDeviceLength {
+		device = string([]rune(device)[:maxDeviceLength])
+	}
+
+	// Record the view as a new watch session, unless the student is still
+	// watching: reloads and repeated calls continue the recent session. The
+	// progress lock keeps concurrent calls from both starting one.
+	lock := &progressLocks[(studentID*31+video.ID)%len(progressLocks)]
+	lock.Lock()
+	var newView bool
+	video.WatchSessionID, newView, err = database.RecordVideoView(studentID, videoID, device, watchSessionReuseWindow)
+	lock.Unlock()
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
-			Message: "Failed to check subscription",
+			Message: "Failed to record view",
+		})
+	}
+	if newView {
+		publishStudentEvent(video.TeacherID, events.VideoViewed, studentID, map[string]interface{}{
+			"video_id":    video.ID,
+			"video_title": video.Title,
+			"device":      device,
 		})
 	}
 
-	if !subscribed {
-		return c.Status(403).JSON(models.APIResponse{
+	// Include where to resume playback
+	video.Progress, err = database.GetVideoProgress(studentID, videoID)
+	if err != nil && err != sql.ErrNoRows {
+		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
-			Message: "You must subscribe to this teacher to watch their videos",
+			Message: "Failed to get progress",
 		})
 	}
 
-	// Record the view
-	err = database.RecordVideoView(studentID, videoID)
+	// Include the quizzes to offer, with their pop-up times
+	video.Quizzes, err = database.GetStudentVideoQuizzes(studentID, videoID)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
-			Message: "Failed to record view",
+			Message: "Failed to get quizzes",
 		})
 	}
 
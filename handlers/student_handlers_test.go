@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"educational-platform/database"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+func TestWatchVideoReusesRecentSession(t *testing.T) {
+	setupTestDB(t)
+	_, cookie := loginStudent(t, "student")
+	videoID := createPublicVideo(t, 600)
+
+	app := fiber.New()
+	app.Post("/watch/:id", func(c fiber.Ctx) error {
+		loadSession(c)
+		return c.Next()
+	}, WatchVideoHandler)
+
+	watch := func() int {
+		t.Helper()
+		req := httptest.NewRequest("POST", fmt.Sprintf("/watch/%d", videoID), nil)
+		req.Header.Set("Cookie", cookie)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 200 {
+			t.Fatalf("watch: status %d", resp.StatusCode)
+		}
+		var body struct {
+			Data struct {
+				WatchSessionID int `json:"watch_session_id"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Data.WatchSessionID
+	}
+	views := func() int {
+		t.Helper()
+		var count int
+		if err := database.DB.QueryRow(`SELECT view_count FROM video_views WHERE video_id = ?`, videoID).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	first := watch()
+	if again := watch(); again != first {
+		t.Errorf("repeated call started session %d, want %d continued", again, first)
+	}
+	if n := views(); n != 1 {
+		t.Errorf("%d views counted for one session, want 1", n)
+	}
+
+	idle := time.Now().UTC().Add(-watchSessionReuseWindow - time.Minute)
+	if _, err := database.DB.Exec(`UPDATE watch_sessions SET ended_at = ? WHERE id = ?`, idle, first); err != nil {
+		t.Fatal(err)
+	}
+	if later := watch(); later == first {
+		t.Error("idle session continued")
+	}
+	if n := views(); n != 2 {
+		t.Errorf("%d views counted, want 2 after the session went idle", n)
+	}
+}
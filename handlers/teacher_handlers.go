@@ -1,15 +1,22 @@
 package handlers
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
 	"educational-platform/database"
+	"educational-platform/events"
 	"educational-platform/models"
+	"educational-platform/storage"
 
 	"github.com/gofiber/fiber/v3"
 )
@@ -26,112 +33,270 @@ func TeacherDashboardHandler(c fiber.Ctx) error {
 		})
 	}
 
+	stats.StorageQuota, err = teacherStorageQuota(userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get dashboard stats",
+		})
+	}
+
 	return c.JSON(models.APIResponse{
 		Success: true,
 		Data:    stats,
 	})
 }
 
-// Upload video endpoint
+// Upload video endpoint. The multipart body is streamed straight to disk so the
+// size limit and storage quota are enforced while the file is still arriving.
 func UploadVideoHandler(c fiber.Ctx) error {
 	userID := c.Locals("user_id").(int)
 
-	// Parse multipart form
-	form, err := c.MultipartForm()
-	if err != nil {
+	mediaType, params, err := mime.ParseMediaType(c.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
 		return c.Status(400).JSON(models.APIResponse{
 			Success: false,
 			Message: "Failed to parse form",
 		})
 	}
 
+	limit, quotaBound, err := uploadLimit(userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to check storage quota",
+		})
+	}
+
+	var title, description, status, visibility, publishAt, originalFilename, filePath string
+
+	// Parse multipart form
+	reader := multipart.NewReader(requestBodyReader(c), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if filePath != "" {
+				os.Remove(filePath)
+			}
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: "Failed to parse form",
+			})
+		}
+
+		switch part.FormName() {
+		case "title":
+			title, err = readFormField(part)
+		case "description":
+			description, err = readFormField(part)
+		case "status":
+			status, err = readFormField(part)
+		case "visibility":
+			visibility, err = readFormField(part)
+		case "publish_at":
+			publishAt, err = readFormField(part)
+		case "video":
+			if filePath != "" {
+				continue
+			}
+			originalFilename = part.FileName()
+			filePath, err = saveVideoPart(part, limit, quotaBound)
+		}
+
+		if err != nil {
+			if filePath != "" {
+				os.Remove(filePath)
+			}
+			code := 400
+			if e, ok := err.(*fiber.Error); ok {
+				code = e.Code
+			}
+			return c.Status(code).JSON(models.APIResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+		}
+	}
+
 	// Get video file
-	files := form.File["video"]
-	if len(files) == 0 {
+	if filePath == "" {
 		return c.Status(400).JSON(models.APIResponse{
 			Success: false,
 			Message: "No video file provided",
 		})
 	}
 
-	videoFile := files[0]
-
-	// Get title and description
-	title := c.FormValue("title")
-	description := c.FormValue("description")
-
 	if title == "" {
+		os.Remove(filePath)
 		return c.Status(400).JSON(models.APIResponse{
 			Success: false,
 			Message: "Title is required",
 		})
 	}
 
-	// Validate file type
-	ext := strings.ToLower(filepath.Ext(videoFile.Filename))
-	allowedExts := []string{".mp4", ".avi", ".mov", ".mkv", ".webm"}
-	isValidExt := false
-	for _, allowedExt := range allowedExts {
-		if ext == allowedExt {
-			isValidExt = true
-			break
-		}
-	}
-
-	if !isValidExt {
+	publishing, err := parsePublishing(status, visibility, publishAt)
+	if err != nil {
+		os.Remove(filePath)
 		return c.Status(400).JSON(models.APIResponse{
 			Success: false,
-			Message: "Invalid video file type",
+			Message: err.Error(),
 		})
 	}
 
-	// Generate unique filename
-	timestamp := time.Now().Unix()
-	filename := fmt.Sprintf("video_%d_%d%s", userID, timestamp, ext)
-	filePath := filepath.Join("./uploads/videos", filename)
-
-	// Save video file
-	err = c.SaveFile(videoFile, filePath)
+	videoID, err := createVideoFromFile(userID, title, description, publishing, originalFilename, filePath)
 	if err != nil {
 		return c.Status(500).JSON(models.APIResponse{
 			Success: false,
-			Message: "Failed to save video file",
+			Message: err.Error(),
 		})
 	}
 
-	// Generate thumbnail
-	thumbnailPath := GenerateThumbnail(filePath, filename)
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Video uploaded successfully",
+		Data: map[string]interface{}{
+			"video_id": videoID,
+		},
+	})
+}
+
+// readFormField reads a small text field from a multipart stream
+func readFormField(part *multipart.Part) (string, error) {
+	value, err := io.ReadAll(io.LimitReader(part, 64<<10))
+	if err != nil {
+		return "", fiber.NewError(400, "Failed to parse form")
+	}
+	return string(value), nil
+}
+
+// saveVideoPart streams an uploaded video part into the staging directory. The
+// container is sniffed before anything is written, the copy stops as soon as
+// limit is exceeded, and the saved file is checked for truncation or corruption.
+func saveVideoPart(part *multipart.Part, limit int64, quotaBound bool) (string, error) {
+	// Validate file type
+	ext := strings.ToLower(filepath.Ext(part.FileName()))
+	if !isAllowedVideoExt(ext) {
+		return "", fiber.NewError(400, "Invalid video file type")
+	}
+
+	br := bufio.NewReaderSize(part, sniffHeaderSize)
+	header, _ := br.Peek(sniffHeaderSize)
+	if _, err := checkVideoContainer(header, ext); err != nil {
+		return "", fiber.NewError(415, err.Error())
+	}
+
+	// Save video file
+	file, err := os.CreateTemp(StagingDir, "upload_*"+ext)
+	if err != nil {
+		return "", fiber.NewError(500, "Failed to save video file")
+	}
+	filePath := file.Name()
+
+	written, err := io.Copy(file, io.LimitReader(br, limit+1))
+	file.Close()
+	if err != nil {
+		os.Remove(filePath)
+		return "", fiber.NewError(400, "Failed to read video file")
+	}
+	if written > limit {
+		os.Remove(filePath)
+		return "", fiber.NewError(413, uploadLimitMessage(quotaBound))
+	}
+
+	if err := validateVideoFile(filePath, ext); err != nil {
+		os.Remove(filePath)
+		return "", fiber.NewError(415, err.Error())
+	}
+
+	return filePath, nil
+}
+
+// isAllowedVideoExt reports whether ext is one of the accepted video extensions
+func isAllowedVideoExt(ext string) bool {
+	allowedExts := []string{".mp4", ".avi", ".mov", ".mkv", ".webm"}
+	for _, allowedExt := range allowedExts {
+		if ext == allowedExt {
+			return true
+		}
+	}
+	return false
+}
+
+// newVideoFilename returns a unique stored filename for a teacher's upload
+func newVideoFilename(teacherID int, ext string) string {
+	timestamp := time.Now().Unix()
+	return fmt.Sprintf("video_%d_%d_%s%s", teacherID, timestamp, GenerateSessionID()[:8], ext)
+}
+
+// createVideoFromFile moves a staged video file into storage, records it in
+// the database and builds its thumbnail. The staged file is consumed in every
+// case. It returns the new video's ID.
+func createVideoFromFile(teacherID int, title, description string, publishing models.VideoPublishing, originalFilename, stagedPath string) (int, error) {
+	defer os.Remove(stagedPath)
+
+	filename := newVideoFilename(teacherID, strings.ToLower(filepath.Ext(originalFilename)))
 
 	// Get file size
-	fileInfo, err := os.Stat(filePath)
+	fileInfo, err := os.Stat(stagedPath)
 	if err != nil {
-		return c.Status(500).JSON(models.APIResponse{
-			Success: false,
-			Message: "Failed to get file info",
-		})
+		return 0, fmt.Errorf("Failed to get file info")
 	}
 
-	// For MVP, we'll set duration to 0 (could be enhanced with ffmpeg)
-	duration := 0
+	// Probe the duration and extract a frame for the thumbnail before the
+	// file leaves local disk; both stay empty without ffmpeg
+	duration := probeVideoDuration(stagedPath)
+	chapters := probeVideoChapters(stagedPath)
+	framePath := GenerateThumbnail(stagedPath, filename)
+	if framePath != "" {
+		defer os.Remove(framePath)
+	}
+
+	videoKey := "videos/" + filename
+	err = storage.PutFile(storage.Store, videoKey, stagedPath, videoContentType(filename))
+	if err != nil {
+		return 0, fmt.Errorf("Failed to save video file")
+	}
 
 	// Save video info to database
-	err = database.CreateVideo(userID, title, description, videoFile.Filename, filePath, thumbnailPath, duration, fileInfo.Size())
+	videoID, err := database.CreateVideo(teacherID, title, description, originalFilename, videoKey, "", duration, fileInfo.Size(), publishing)
 	if err != nil {
 		// Clean up uploaded file if database save fails
-		os.Remove(filePath)
-		if thumbnailPath != "" {
-			os.Remove(thumbnailPath)
-		}
-		return c.Status(500).JSON(models.APIResponse{
-			Success: false,
-			Message: "Failed to save video info",
-		})
+		storage.Store.Delete(videoKey)
+		return 0, fmt.Errorf("Failed to save video info")
 	}
 
-	return c.JSON(models.APIResponse{
-		Success: true,
-		Message: "Video uploaded successfully",
+	if len(chapters) > 0 {
+		importChapters(videoID, chapters, duration)
+	}
+
+	// Generate thumbnail; a video without one is still usable
+	teacherName := ""
+	if teacher, err := database.GetTeacherByID(teacherID); err == nil {
+		teacherName = teacher.Name
+	}
+	thumbnailKey := "thumbnails/" + strings.TrimSuffix(filename, filepath.Ext(filename)) + "_thumb.jpg"
+	if err := buildVideoThumbnail(thumbnailKey, framePath, videoID, title, teacherName); err == nil {
+		database.UpdateVideoThumbnail(videoID, thumbnailKey)
+	} else {
+		deleteThumbnail(thumbnailKey)
+	}
+
+	// Scrubbing previews take a while for long videos, build them in the background
+	if duration > 0 && isFFmpegAvailable() {
+		go generateStoryboard(videoID, videoKey, duration)
+	}
+
+	publishEvent("teacher", teacherID, events.VideoProcessed, map[string]interface{}{
+		"video_id": videoID,
+		"title":    title,
+		"status":   publishing.Status,
 	})
+	notifyNewVideo(videoID)
+
+	return videoID, nil
 }
 
 // Get teacher's videos
@@ -146,6 +311,20 @@ func GetTeacherVideosHandler(c fiber.Ctx) error {
 		})
 	}
 
+	views, err := database.GetTeacherVideoViewCounts(userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get videos",
+		})
+	}
+	for i := range videos {
+		videos[i].Views = views[videos[i].ID]
+		if videos[i].Views == nil {
+			videos[i].Views = &models.ViewStats{}
+		}
+	}
+
 	return c.JSON(models.APIResponse{
 		Success: true,
 		Data:    videos,
@@ -181,6 +360,11 @@ func DeleteVideoHandler(c fiber.Ctx) error {
 		})
 	}
 
+	// Look up storyboard sheets and old revisions before their rows are
+	// removed with the video
+	storyboard, _ := database.GetStoryboard(videoID)
+	revisions, _ := database.GetVideoRevisions(videoID)
+
 	// Delete from database
 	err = database.DeleteVideo(videoID)
 	if err != nil {
@@ -189,11 +373,19 @@ func DeleteVideoHandler(c fiber.Ctx) error {
 			Message: "Failed to delete video",
 		})
 	}
+	database.DeleteVideoNotifications(videoID)
 
 	// Delete files
-	os.Remove(video.FilePath)
-	if video.ThumbnailPath != "" {
-		os.Remove(video.ThumbnailPath)
+	storage.Store.Delete(video.FilePath)
+	deleteThumbnail(video.ThumbnailPath)
+	if storyboard != nil {
+		deleteStoryboardSheets(storyboard)
+	}
+	for _, caption := range video.Captions {
+		storage.Store.Delete(caption.FilePath)
+	}
+	for _, revision := range revisions {
+		storage.Store.Delete(revision.FilePath)
 	}
 
 	return c.JSON(models.APIResponse{
@@ -202,6 +394,82 @@ func DeleteVideoHandler(c fiber.Ctx) error {
 	})
 }
 
+// getOwnedVideo loads the video named by the :id parameter and checks that it
+// belongs to the current teacher. On failure the error response has already
+// been written and the returned video is nil.
+func getOwnedVideo(c fiber.Ctx) (*models.Video, error) {
+	userID := c.Locals("user_id").(int)
+	videoID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid video ID",
+		})
+	}
+
+	video, err := database.GetVideoByID(videoID)
+	if err != nil {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Video not found",
+		})
+	}
+
+	if video.TeacherID != userID {
+		return nil, c.Status(403).JSON(models.APIResponse{
+			Success: false,
+			Message: "Not authorized to modify this video",
+		})
+	}
+
+	return video, nil
+}
+
+// Upload a custom thumbnail for a video
+func UploadThumbnailHandler(c fiber.Ctx) error {
+	video, err := getOwnedVideo(c)
+	if video == nil {
+		return err
+	}
+
+	img, err := readUploadedImage(c, "thumbnail", "Thumbnail")
+	if err != nil {
+		e := err.(*fiber.Error)
+		return c.Status(e.Code).JSON(models.APIResponse{
+			Success: false,
+			Message: e.Message,
+		})
+	}
+
+	// A new key per upload keeps caches from serving the old image
+	base := strings.TrimSuffix(path.Base(video.FilePath), path.Ext(video.FilePath))
+	key := "thumbnails/" + base + "_custom_" + GenerateSessionID()[:8] + ".jpg"
+
+	err = storeThumbnail(key, img)
+	if err != nil {
+		deleteThumbnail(key)
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to save thumbnail",
+		})
+	}
+
+	err = database.UpdateVideoThumbnail(video.ID, key)
+	if err != nil {
+		deleteThumbnail(key)
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to save thumbnail",
+		})
+	}
+	deleteThumbnail(video.ThumbnailPath)
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Thumbnail updated successfully",
+	})
+}
+
 // Get teacher's students (subscribers)
 func GetTeacherStudentsHandler(c fiber.Ctx) error {
 	userID := c.Locals("user_id").(int)
@@ -238,6 +506,41 @@ func GetVideoAnalyticsHandler(c fiber.Ctx) error {
 	})
 }
 
+// maxListedSessions limits the watch sessions returned for a video
+const maxListedSessions = 100
+
+// Get a video's view statistics and latest watch sessions
+func GetVideoViewsHandler(c fiber.Ctx) error {
+	video, err := getOwnedVideo(c)
+	if video == nil {
+		return err
+	}
+
+	stats, err := database.GetVideoViewCount(video.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get views",
+		})
+	}
+
+	sessions, err := database.GetVideoSessions(video.ID, maxListedSessions)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get views",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"stats":    stats,
+			"sessions": sessions,
+		},
+	})
+}
+
 // Serve video file
 func ServeVideoHandler(c fiber.Ctx) error {
 	videoIDStr := c.Params("id")
@@ -257,15 +560,14 @@ func ServeVideoHandler(c fiber.Ctx) error {
 		})
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(video.FilePath); os.IsNotExist(err) {
-		return c.Status(404).JSON(models.APIResponse{
-			Success: false,
-			Message: "Video file not found",
-		})
+	if ok, err := checkVideoAccess(c, video); !ok {
+		return err
+	}
+	if ok, err := checkLessonUnlocked(c, video); !ok {
+		return err
 	}
 
-	return c.SendFile(video.FilePath)
+	return sendStoredObject(c, video.FilePath, videoContentType(video.FilePath), "Video file not found")
 }
 
 // Serve thumbnail
@@ -287,6 +589,10 @@ func ServeThumbnailHandler(c fiber.Ctx) error {
 		})
 	}
 
+	if ok, err := checkVideoAccess(c, video); !ok {
+		return err
+	}
+
 	if video.ThumbnailPath == "" {
 		return c.Status(404).JSON(models.APIResponse{
 			Success: false,
@@ -294,13 +600,17 @@ func ServeThumbnailHandler(c fiber.Ctx) error {
 		})
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(video.ThumbnailPath); os.IsNotExist(err) {
-		return c.Status(404).JSON(models.APIResponse{
-			Success: false,
-			Message: "Thumbnail file not found",
-		})
+	// Pick the size variant: ?size=small|medium|large or ?w=<pixels>
+	width, _ := strconv.Atoi(c.Query("w"))
+	key := thumbnailSizeKey(video.ThumbnailPath, pickThumbnailSize(c.Query("size"), width))
+
+	// Thumbnails from before size variants existed only have the base key
+	if key != video.ThumbnailPath {
+		if _, err := storage.Store.Stat(key); err == storage.ErrNotFound {
+			key = video.ThumbnailPath
+		}
 	}
 
-	return c.SendFile(video.ThumbnailPath)
+	c.Set("Cache-Control", mediaCacheControl(video, 86400))
+	return sendStoredObject(c, key, "image/jpeg", "Thumbnail file not found")
 }
\ No newline at end of file
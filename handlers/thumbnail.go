@@ -1,23 +1,28 @@
 package handlers
 
 import (
+	"image"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
-// GenerateThumbnail creates a thumbnail for the video using ffmpeg
+// GenerateThumbnail extracts a frame from the video using ffmpeg. The frame is
+// written to the staging directory and its local path returned, or "" when
+// ffmpeg is unavailable or fails; callers then fall back to a placeholder.
 func GenerateThumbnail(videoPath, filename string) string {
 	// Check if ffmpeg is available
 	if !isFFmpegAvailable() {
-		return generateDefaultThumbnail(videoPath, filename)
+		return ""
 	}
 
 	// Generate thumbnail filename
 	ext := filepath.Ext(filename)
 	thumbnailFilename := strings.TrimSuffix(filename, ext) + "_thumb.jpg"
-	thumbnailPath := filepath.Join("./uploads/thumbnails", thumbnailFilename)
+	thumbnailPath := filepath.Join(StagingDir, thumbnailFilename)
 
 	// Create thumbnail using ffmpeg (take frame at 5 seconds)
 	cmd := exec.Command("ffmpeg", 
@@ -30,12 +35,12 @@ func GenerateThumbnail(videoPath, filename string) string {
 
 	err := cmd.Run()
 	if err != nil {
-		return generateDefaultThumbnail(videoPath, filename)
+		return ""
 	}
 
 	// Check if thumbnail was created successfully
 	if _, err := os.Stat(thumbnailPath); os.IsNotExist(err) {
-		return generateDefaultThumbnail(videoPath, filename)
+		return ""
 	}
 
 	return thumbnailPath
@@ -48,24 +53,55 @@ func isFFmpegAvailable() bool {
 	return err == nil
 }
 
-// generateDefaultThumbnail creates a simple placeholder thumbnail
-func generateDefaultThumbnail(videoPath, filename string) string {
-	// For MVP, we'll create a simple text-based placeholder
-	// In a real application, you might want to use a library like image/draw
-	
-	ext := filepath.Ext(filename)
-	thumbnailFilename := strings.TrimSuffix(filename, ext) + "_thumb.jpg"
-	thumbnailPath := filepath.Join("./uploads/thumbnails", thumbnailFilename)
+// isFFprobeAvailable checks if ffprobe (shipped with ffmpeg) is installed
+func isFFprobeAvailable() bool {
+	cmd := exec.Command("ffprobe", "-version")
+	err := cmd.Run()
+	return err == nil
+}
+
+// probeVideoDuration returns the video's duration in whole seconds, or 0 when
+// it cannot be determined
+func probeVideoDuration(videoPath string) int {
+	if !isFFprobeAvailable() {
+		return 0
+	}
 
-	// Create a simple placeholder file (in real app, generate actual image)
-	file, err := os.Create(thumbnailPath)
+	cmd := exec.Command("ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		videoPath)
+
+	output, err := cmd.Output()
 	if err != nil {
-		return ""
+		return 0
 	}
-	defer file.Close()
 
-	// Write a simple placeholder content
-	file.WriteString("Video Thumbnail Placeholder")
-	
-	return thumbnailPath
-}
\ No newline at end of file
+	seconds, err := strconv.ParseFloat(strings.TrimSpace(string(output)), 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return int(math.Round(seconds))
+}
+
+// buildVideoThumbnail stores the thumbnail variants for a video under key,
+// using the extracted frame at framePath when there is one and a generated
+// placeholder otherwise
+func buildVideoThumbnail(key, framePath string, videoID int, title, teacherName string) error {
+	var img image.Image
+	if framePath != "" {
+		if file, err := os.Open(framePath); err == nil {
+			img, _, err = image.Decode(file)
+			file.Close()
+			if err != nil {
+				img = nil
+			}
+		}
+	}
+
+	if img == nil {
+		img = renderPlaceholderThumbnail(videoID, title, teacherName)
+	}
+	return storeThumbnail(key, img)
+}
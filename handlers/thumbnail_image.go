@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"log"
+	"math"
+	"path"
+	"strings"
+
+	// Decoders for custom thumbnail uploads
+	_ "image/gif"
+	_ "image/png"
+
+	"educational-platform/storage"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// thumbnailSize is one of the variants stored for every thumbnail
+type thumbnailSize struct {
+	Name  string
+	Width int
+}
+
+// thumbnailSizes lists the stored variants from smallest to largest. All are
+// 16:9; the largest is stored under the video's thumbnail_path itself.
+var thumbnailSizes = []thumbnailSize{
+	{"small", 320},
+	{"medium", 640},
+	{"large", 1280},
+}
+
+// thumbnailSizeKey returns the storage key of a size variant of a thumbnail
+func thumbnailSizeKey(key, size string) string {
+	if size == thumbnailSizes[len(thumbnailSizes)-1].Name {
+		return key
+	}
+	ext := path.Ext(key)
+	return strings.TrimSuffix(key, ext) + "_" + size + ext
+}
+
+// pickThumbnailSize chooses a variant by name or the smallest one at least width pixels wide
+func pickThumbnailSize(name string, width int) string {
+	for _, size := range thumbnailSizes {
+		if size.Name == name {
+			return size.Name
+		}
+	}
+	if width > 0 {
+		for _, size := range thumbnailSizes {
+			if size.Width >= width {
+				return size.Name
+			}
+		}
+	}
+	return thumbnailSizes[len(thumbnailSizes)-1].Name
+}
+
+// maxThumbnailUploadSize limits custom thumbnail and cover uploads (10 MB)
+const maxThumbnailUploadSize = 10 << 20
+
+// maxThumbnailDimension rejects images that would take too much memory to decode
+const maxThumbnailDimension = 8000
+
+// readUploadedImage decodes an uploaded JPEG, PNG or GIF from a form field.
+// Errors are *fiber.Error values whose messages start with label.
+func readUploadedImage(c fiber.Ctx, field, label string) (image.Image, error) {
+	form, err := readFormUpload(c, field, maxThumbnailUploadSize, label+" exceeds the maximum size of 10 MB")
+	if err != nil {
+		return nil, err
+	}
+	defer form.Remove()
+	if !form.HasFile() {
+		return nil, fiber.NewError(400, fmt.Sprintf("No %s file provided", strings.ToLower(label)))
+	}
+
+	file, err := form.Open()
+	if err != nil {
+		return nil, fiber.NewError(400, "Failed to read "+strings.ToLower(label))
+	}
+	defer file.Close()
+
+	// Check dimensions before decoding the whole image
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return nil, fiber.NewError(415, label+" must be a JPEG, PNG or GIF image")
+	}
+	if config.Width > maxThumbnailDimension || config.Height > maxThumbnailDimension {
+		return nil, fiber.NewError(400, label+" dimensions are too large")
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fiber.NewError(500, "Failed to read "+strings.ToLower(label))
+	}
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fiber.NewError(415, label+" image is corrupt")
+	}
+	return img, nil
+}
+
+// storeThumbnail crops img to 16:9, stores every size variant under key and
+// returns the first error encountered
+func storeThumbnail(key string, img image.Image) error {
+	for _, size := range thumbnailSizes {
+		resized := resizeCover(img, size.Width, size.Width*9/16)
+
+		var buf bytes.Buffer
+		err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 85})
+		if err != nil {
+			return err
+		}
+
+		err = storage.Store.Put(thumbnailSizeKey(key, size.Name), &buf, int64(buf.Len()), "image/jpeg")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteThumbnail removes a thumbnail and all of its size variants
+func deleteThumbnail(key string) {
+	if key == "" {
+		return
+	}
+	for _, size := range thumbnailSizes {
+		storage.Store.Delete(thumbnailSizeKey(key, size.Name))
+	}
+}
+
+// renderPlaceholderThumbnail draws a 1280x720 card with the video title and
+// teacher name on a background color derived from the video ID
+func renderPlaceholderThumbnail(videoID int, title, teacherName string) image.Image {
+	const width, height = 1280, 720
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	// Golden-angle hue steps keep consecutive videos visually distinct
+	hue := math.Mod(float64(videoID)*137.508, 360)
+	background := hsvColor(hue, 0.55, 0.70)
+	band := hsvColor(hue, 0.65, 0.45)
+	white := color.RGBA{255, 255, 255, 255}
+
+	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(0, height-140, width, height), &image.Uniform{band}, image.Point{}, draw.Src)
+
+	// Play symbol
+	drawTriangle(img, width/2-50, 110, 100, color.RGBA{255, 255, 255, 200})
+
+	titleFace, err := thumbnailFace(64)
+	if err != nil {
+		log.Printf("Failed to load thumbnail font: %v", err)
+		return img
+	}
+	defer titleFace.Close()
+
+	text := renderableText(title)
+	if text == "" {
+		text = fmt.Sprintf("VIDEO %d", videoID)
+	}
+	y := 350
+	for _, line := range wrapText(titleFace, text, width-160, 3) {
+		drawText(img, titleFace, line, (width-textWidth(titleFace, line))/2, y, white)
+		y += 84
+	}
+
+	if name := renderableText(teacherName); name != "" {
+		nameFace, err := thumbnailFace(40)
+		if err != nil {
+			return img
+		}
+		defer nameFace.Close()
+		lines := wrapText(nameFace, name, width-120, 1)
+		// Right-to-left names start from the right edge
+		x := 60
+		if isRightToLeft(lines[0]) {
+			x = width - 60 - textWidth(nameFace, lines[0])
+		}
+		drawText(img, nameFace, lines[0], x, height-56, white)
+	}
+
+	return img
+}
+
+// hsvColor converts hue (degrees), saturation and value to RGB
+func hsvColor(h, s, v float64) color.RGBA {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return color.RGBA{uint8((r + m) * 255), uint8((g + m) * 255), uint8((b + m) * 255), 255}
+}
+
+// drawTriangle draws a right-pointing triangle with its bounding box at x, y
+func drawTriangle(img *image.RGBA, x, y, size int, c color.RGBA) {
+	for dy := 0; dy < size; dy++ {
+		half := size / 2
+		span := half - abs(dy-half)
+		for dx := 0; dx < span*2; dx++ {
+			img.Set(x+dx, y+dy, c)
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// resizeCover center-crops src to the target aspect ratio and scales it to
+// width x height using area averaging
+func resizeCover(src image.Image, width, height int) *image.RGBA {
+	bounds := src.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+
+	crop := bounds
+	if srcW*height > srcH*width {
+		cropW := srcH * width / height
+		crop.Min.X += (srcW - cropW) / 2
+		crop.Max.X = crop.Min.X + cropW
+	} else {
+		cropH := srcW * height / width
+		crop.Min.Y += (srcH - cropH) / 2
+		crop.Max.Y = crop.Min.Y + cropH
+	}
+
+	return resizeArea(src, crop, width, height)
+}
+
+// resizeArea scales the crop rectangle of src to width x height. Each output
+// pixel averages the source pixels it covers, which avoids aliasing when
+// shrinking; enlarging degrades to nearest neighbour.
+func resizeArea(src image.Image, crop image.Rectangle, width, height int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	cropW, cropH := crop.Dx(), crop.Dy()
+
+	for y := 0; y < height; y++ {
+		y0 := crop.Min.Y + y*cropH/height
+		y1 := crop.Min.Y + (y+1)*cropH/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := crop.Min.X + x*cropW/width
+			x1 := crop.Min.X + (x+1)*cropW/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				uint8(r / n >> 8), uint8(g / n >> 8), uint8(b / n >> 8), uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	_ "embed"
+	"image"
+	"image/color"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// thumbnailFontData is DejaVu Sans Bold, which covers Latin and Arabic
+// including the Arabic presentation forms used for joined letters
+//
+//go:embed fonts/DejaVuSans-Bold.ttf
+var thumbnailFontData []byte
+
+var loadThumbnailFont = sync.OnceValues(func() (*opentype.Font, error) {
+	return opentype.Parse(thumbnailFontData)
+})
+
+// thumbnailFace returns the thumbnail font at size pixels. Faces cache glyphs
+// and are not safe for concurrent use, so each rendering gets its own.
+func thumbnailFace(size float64) (font.Face, error) {
+	f, err := loadThumbnailFont()
+	if err != nil {
+		return nil, err
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+}
+
+// renderableText drops the characters the thumbnail font lacks, and Arabic
+// diacritics, which are left out of thumbnails, and collapses white space
+func renderableText(s string) string {
+	f, err := loadThumbnailFont()
+	if err != nil {
+		return ""
+	}
+	var buf sfnt.Buffer
+	var b strings.Builder
+	for _, r := range s {
+		if isArabicMark(r) {
+			continue
+		}
+		if unicode.IsSpace(r) {
+			b.WriteRune(' ')
+			continue
+		}
+		if index, err := f.GlyphIndex(&buf, r); err == nil && index != 0 {
+			b.WriteRune(r)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// wrapText splits text into at most maxLines lines no wider than maxWidth
+// pixels in face, ending the last line with an ellipsis if text is cut
+func wrapText(face font.Face, text string, maxWidth, maxLines int) []string {
+	fits := func(s string) bool {
+		return font.MeasureString(face, shapeArabic(s)).Ceil() <= maxWidth
+	}
+
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		// Break words that do not fit on a line of their own
+		for !fits(word) {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			runes := []rune(word)
+			n := len(runes) - 1
+			for n > 1 && !fits(string(runes[:n])) {
+				n--
+			}
+			lines = append(lines, string(runes[:n]))
+			word = string(runes[n:])
+		}
+		switch {
+		case line == "":
+			line = word
+		case fits(line + " " + word):
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		last := []rune(lines[maxLines-1])
+		for len(last) > 0 && !fits(string(last)+"…") {
+			last = last[:len(last)-1]
+		}
+		lines[maxLines-1] = strings.TrimSpace(string(last)) + "…"
+	}
+	return lines
+}
+
+// textWidth is the width of a line of text in pixels
+func textWidth(face font.Face, text string) int {
+	return font.MeasureString(face, shapeArabic(text)).Ceil()
+}
+
+// drawText draws a line of text with its baseline at y, joining Arabic
+// letters and laying out right-to-left runs
+func drawText(img *image.RGBA, face font.Face, text string, x, y int, c color.RGBA) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(visualOrder(shapeArabic(text)))
+}
+
+// Arabic joining. Letters take one of up to four presentation forms
+// depending on whether they join the letters before and after them.
+// See https://www.unicode.org/versions/latest/ch09.pdf#G7462.
+const (
+	formIsolated = iota
+	formFinal
+	formInitial
+	formMedial
+)
+
+// arabicForms maps letters to their isolated, final, initial and medial
+// presentation forms. Letters without initial and medial forms only join
+// the letter before them.
+var arabicForms = map[rune][4]rune{
+	0x0621: {0xFE80, 0, 0, 0},
+	0x0622: {0xFE81, 0xFE82, 0, 0},
+	0x0623: {0xFE83, 0xFE84, 0, 0},
+	0x0624: {0xFE85, 0xFE86, 0, 0},
+	0x0625: {0xFE87, 0xFE88, 0, 0},
+	0x0626: {0xFE89, 0xFE8A, 0xFE8B, 0xFE8C},
+	0x0627: {0xFE8D, 0xFE8E, 0, 0},
+	0x0628: {0xFE8F, 0xFE90, 0xFE91, 0xFE92},
+	0x0629: {0xFE93, 0xFE94, 0, 0},
+	0x062A: {0xFE95, 0xFE96, 0xFE97, 0xFE98},
+	0x062B: {0xFE99, 0xFE9A, 0xFE9B, 0xFE9C},
+	0x062C: {0xFE9D, 0xFE9E, 0xFE9F, 0xFEA0},
+	0x062D: {0xFEA1, 0xFEA2, 0xFEA3, 0xFEA4},
+	0x062E: {0xFEA5, 0xFEA6, 0xFEA7, 0xFEA8},
+	0x062F: {0xFEA9, 0xFEAA, 0, 0},
+	0x0630: {0xFEAB, 0xFEAC, 0, 0},
+	0x0631: {0xFEAD, 0xFEAE, 0, 0},
+	0x0632: {0xFEAF, 0xFEB0, 0, 0},
+	0x0633: {0xFEB1, 0xFEB2, 0xFEB3, 0xFEB4},
+	0x0634: {0xFEB5, 0xFEB6, 0xFEB7, 0xFEB8},
+	0x0635: {0xFEB9, 0xFEBA, 0xFEBB, 0xFEBC},
+	0x0636: {0xFEBD, 0xFEBE, 0xFEBF, 0xFEC0},
+	0x0637: {0xFEC1, 0xFEC2, 0xFEC3, 0xFEC4},
+	0x0638: {0xFEC5, 0xFEC6, 0xFEC7, 0xFEC8},
+	0x0639: {0xFEC9, 0xFECA, 0xFECB, 0xFECC},
+	0x063A: {0xFECD, 0xFECE, 0xFECF, 0xFED0},
+	0x0641: {0xFED1, 0xFED2, 0xFED3, 0xFED4},
+	0x0642: {0xFED5, 0xFED6, 0xFED7, 0xFED8},
+	0x0643: {0xFED9, 0xFEDA, 0xFEDB, 0xFEDC},
+	0x0644: {0xFEDD, 0xFEDE, 0xFEDF, 0xFEE0},
+	0x0645: {0xFEE1, 0xFEE2, 0xFEE3, 0xFEE4},
+	0x0646: {0xFEE5, 0xFEE6, 0xFEE7, 0xFEE8},
+	0x0647: {0xFEE9, 0xFEEA, 0xFEEB, 0xFEEC},
+	0x0648: {0xFEED, 0xFEEE, 0, 0},
+	0x0649: {0xFEEF, 0xFEF0, 0, 0},
+	0x064A: {0xFEF1, 0xFEF2, 0xFEF3, 0xFEF4},
+}
+
+// lamAlef maps the alef that follows a lam to the isolated and final forms
+// of their ligature
+var lamAlef = map[rune][2]rune{
+	0x0622: {0xFEF5, 0xFEF6},
+	0x0623: {0xFEF7, 0xFEF8},
+	0x0625: {0xFEF9, 0xFEFA},
+	0x0627: {0xFEFB, 0xFEFC},
+}
+
+const (
+	arabicLam     = 0x0644
+	arabicTatweel = 0x0640
+)
+
+// isArabicMark reports whether r is an Arabic diacritic (harakat and the
+// like), which sit on letters without affecting how they join
+func isArabicMark(r rune) bool {
+	return (r >= 0x064B && r <= 0x065F) || r == 0x0670 || (r >= 0x06D6 && r <= 0x06ED)
+}
+
+// joinsNext reports whether r connects to the letter after it
+func joinsNext(r rune) bool {
+	if r == arabicTatweel {
+		return true
+	}
+	forms, ok := arabicForms[r]
+	return ok && forms[formInitial] != 0
+}
+
+// joinsPrevious reports whether r connects to the letter before it
+func joinsPrevious(r rune) bool {
+	if r == arabicTatweel {
+		return true
+	}
+	forms, ok := arabicForms[r]
+	return ok && forms[formFinal] != 0
+}
+
+// shapeArabic replaces Arabic letters with the presentation forms for their
+// position in a word, in logical order
+func shapeArabic(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		forms, ok := arabicForms[r]
+		if !ok {
+			b.WriteRune(r)
+			continue
+		}
+		prev := i > 0 && joinsNext(runes[i-1])
+
+		if r == arabicLam && i+1 < len(runes) {
+			if ligature, ok := lamAlef[runes[i+1]]; ok {
+				if prev {
+					b.WriteRune(ligature[1])
+				} else {
+					b.WriteRune(ligature[0])
+				}
+				i++
+				continue
+			}
+		}
+
+		next := i+1 < len(runes) && joinsPrevious(runes[i+1])
+		form := formIsolated
+		switch {
+		case prev && next && forms[formMedial] != 0:
+			form = formMedial
+		case prev && forms[formFinal] != 0:
+			form = formFinal
+		case next && forms[formInitial] != 0:
+			form = formInitial
+		}
+		b.WriteRune(forms[form])
+	}
+	return b.String()
+}
+
+// Bidirectional classes, a simplification of the Unicode bidi algorithm
+// that is enough for single-line titles and names
+const (
+	bidiNeutral = iota
+	bidiLTR
+	bidiRTL
+)
+
+func bidiClass(r rune) int {
+	switch {
+	case unicode.In(r, unicode.Arabic, unicode.Hebrew):
+		if unicode.IsDigit(r) {
+			return bidiLTR
+		}
+		return bidiRTL
+	case unicode.IsLetter(r) || unicode.IsDigit(r):
+		return bidiLTR
+	}
+	return bidiNeutral
+}
+
+// isRightToLeft reports whether the first strong character of s is
+// right-to-left
+func isRightToLeft(s string) bool {
+	for _, r := range s {
+		if class := bidiClass(r); class != bidiNeutral {
+			return class == bidiRTL
+		}
+	}
+	return false
+}
+
+// bidiMirror swaps paired punctuation shown in right-to-left runs
+var bidiMirror = map[rune]rune{
+	'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{',
+	'<': '>', '>': '<', '«': '»', '»': '«',
+}
+
+// visualOrder reorders a line from logical to display order. The line is
+// right-to-left if its first strong character is; neutral characters take
+// the direction of the text around them, or the line's between runs of
+// different directions.
+func visualOrder(s string) string {
+	runes := []rune(s)
+	classes := make([]int, len(runes))
+	for i, r := range runes {
+		classes[i] = bidiClass(r)
+	}
+	base := bidiLTR
+	if isRightToLeft(s) {
+		base = bidiRTL
+	}
+
+	for i := 0; i < len(classes); {
+		if classes[i] != bidiNeutral {
+			i++
+			continue
+		}
+		j := i
+		for j < len(classes) && classes[j] == bidiNeutral {
+			j++
+		}
+		before, after := base, base
+		if i > 0 {
+			before = classes[i-1]
+		}
+		if j < len(classes) {
+			after = classes[j]
+		}
+		resolved := base
+		if before == after {
+			resolved = before
+		}
+		for k := i; k < j; k++ {
+			classes[k] = resolved
+		}
+		i = j
+	}
+
+	// Split into runs of one direction, mirror and reverse right-to-left
+	// runs, and reverse the order of the runs in a right-to-left line
+	var runs [][]rune
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && classes[j] == classes[i] {
+			j++
+		}
+		run := append([]rune(nil), runes[i:j]...)
+		if classes[i] == bidiRTL {
+			for k := range run {
+				if m, ok := bidiMirror[run[k]]; ok {
+					run[k] = m
+				}
+			}
+			reverseRunes(run)
+		}
+		runs = append(runs, run)
+		i = j
+	}
+	if base == bidiRTL {
+		for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+			runs[i], runs[j] = runs[j], runs[i]
+		}
+	}
+
+	var b strings.Builder
+	for _, run := range runs {
+		b.WriteString(string(run))
+	}
+	return b.String()
+}
+
+func reverseRunes(runes []rune) {
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+}
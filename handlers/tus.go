@@ -0,0 +1,516 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"educational-platform/database"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// tus 1.0 resumable upload protocol, see https://tus.io/protocols/resumable-upload
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,termination,checksum,expiration"
+	tusChecksums   = "md5,sha1,sha256"
+	tusContentType = "application/offset+octet-stream"
+
+	// StatusChecksumMismatch is returned when a PATCH body fails its Upload-Checksum
+	StatusChecksumMismatch = 460
+
+	// tusUploadLifetime is how long an upload stays resumable after it was
+	// created or last received data
+	tusUploadLifetime = 24 * time.Hour
+	// tusCleanupInterval is how often expired uploads are removed
+	tusCleanupInterval = time.Hour
+)
+
+// tusLocks serializes requests per upload so offsets cannot race. Entries
+// are only made for existing uploads and removed with them.
+var tusLocks sync.Map
+
+func lockTusUpload(id string) func() {
+	lock, _ := tusLocks.LoadOrStore(id, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// tusError sends an error response. HEAD responses must not carry a body.
+func tusError(c fiber.Ctx, status int, message string) error {
+	c.Set("Tus-Resumable", tusVersion)
+	if c.Method() == fiber.MethodHead {
+		return c.SendStatus(status)
+	}
+	return c.Status(status).JSON(models.APIResponse{
+		Success: false,
+		Message: message,
+	})
+}
+
+// checkTusResumable verifies the client speaks the supported protocol version
+func checkTusResumable(c fiber.Ctx) bool {
+	if c.Get("Tus-Resumable") == tusVersion {
+		return true
+	}
+	c.Set("Tus-Version", tusVersion)
+	return false
+}
+
+// parseTusMetadata decodes an Upload-Metadata header ("key base64value,key2 base64value")
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		if len(parts) == 0 || len(parts) > 2 {
+			return nil, fiber.ErrBadRequest
+		}
+		value := ""
+		if len(parts) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			value = string(decoded)
+		}
+		metadata[parts[0]] = value
+	}
+	return metadata, nil
+}
+
+// parseTusChecksum decodes an Upload-Checksum header ("algorithm base64digest")
+func parseTusChecksum(header string) (hash.Hash, []byte, error) {
+	parts := strings.Fields(header)
+	if len(parts) != 2 {
+		return nil, nil, fiber.ErrBadRequest
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch parts[0] {
+	case "md5":
+		return md5.New(), expected, nil
+	case "sha1":
+		return sha1.New(), expected, nil
+	case "sha256":
+		return sha256.New(), expected, nil
+	}
+	return nil, nil, fiber.ErrBadRequest
+}
+
+// requestBodyReader returns the request body, streamed when the server allows it
+func requestBodyReader(c fiber.Ctx) io.Reader {
+	if c.Request().IsBodyStream() {
+		return c.Request().BodyStream()
+	}
+	return bytes.NewReader(c.Body())
+}
+
+// getOwnedTusUpload loads an upload and makes sure it belongs to the current
+// teacher and has not expired
+func getOwnedTusUpload(c fiber.Ctx) (*models.TusUpload, error) {
+	userID := c.Locals("user_id").(int)
+
+	upload, err := database.GetTusUpload(c.Params("id"))
+	if err != nil || upload.TeacherID != userID {
+		return nil, tusError(c, 404, "Upload not found")
+	}
+	if !time.Now().Before(upload.ExpiresAt) {
+		return nil, tusError(c, 410, "Upload expired")
+	}
+	return upload, nil
+}
+
+// lockOwnedTusUpload locks an upload of the current teacher and loads it
+// again under the lock, since a request holding the lock may have changed
+// or removed it
+func lockOwnedTusUpload(c fiber.Ctx) (*models.TusUpload, func(), error) {
+	upload, err := getOwnedTusUpload(c)
+	if upload == nil {
+		return nil, nil, err
+	}
+
+	id := upload.ID
+	unlock := lockTusUpload(id)
+	upload, err = getOwnedTusUpload(c)
+	if upload == nil {
+		forgetTusLockIfGone(id)
+		unlock()
+		return nil, nil, err
+	}
+	return upload, unlock, nil
+}
+
+// forgetTusLockIfGone drops the lock of an upload removed while waiting for it
+func forgetTusLockIfGone(id string) {
+	if _, err := database.GetTusUpload(id); err == sql.ErrNoRows {
+		tusLocks.Delete(id)
+	}
+}
+
+// setUploadExpires sends when an upload expires (tus expiration extension)
+func setUploadExpires(c fiber.Ctx, expiresAt time.Time) {
+	c.Set("Upload-Expires", expiresAt.UTC().Format(http.TimeFormat))
+}
+
+// TusOptionsHandler advertises the server's tus capabilities
+func TusOptionsHandler(c fiber.Ctx) error {
+	c.Set("Tus-Resumable", tusVersion)
+	c.Set("Tus-Version", tusVersion)
+	c.Set("Tus-Extension", tusExtensions)
+	c.Set("Tus-Checksum-Algorithm", tusChecksums)
+	c.Set("Tus-Max-Size", strconv.FormatInt(MaxUploadSize, 10))
+	return c.SendStatus(204)
+}
+
+// TusCreateHandler creates a new resumable upload (tus creation extension)
+func TusCreateHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	if !checkTusResumable(c) {
+		return tusError(c, 412, "Unsupported tus version")
+	}
+
+	length, err := strconv.ParseInt(c.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		return tusError(c, 400, "Invalid Upload-Length")
+	}
+
+	metadata, err := parseTusMetadata(c.Get("Upload-Metadata"))
+	if err != nil {
+		return tusError(c, 400, "Invalid Upload-Metadata")
+	}
+
+	filename := metadata["filename"]
+	if filename == "" {
+		return tusError(c, 400, "Filename is required")
+	}
+
+	if metadata["title"] == "" {
+		return tusError(c, 400, "Title is required")
+	}
+
+	if !isAllowedVideoExt(strings.ToLower(filepath.Ext(filename))) {
+		return tusError(c, 400, "Invalid video file type")
+	}
+
+	publishing, err := parsePublishing(metadata["status"], metadata["visibility"], metadata["publish_at"])
+	if err != nil {
+		return tusError(c, 400, err.Error())
+	}
+
+	limit, quotaBound, err := uploadLimit(userID)
+	if err != nil {
+		return tusError(c, 500, "Failed to check storage quota")
+	}
+	if length > limit {
+		return tusError(c, 413, uploadLimitMessage(quotaBound))
+	}
+
+	id := GenerateSessionID()
+	upload := &models.TusUpload{
+		ID:          id,
+		TeacherID:   userID,
+		Filename:    filename,
+		Title:       metadata["title"],
+		Description: metadata["description"],
+		Length:      length,
+		Publishing:  publishing,
+		PartialPath: filepath.Join(StagingDir, id),
+		ExpiresAt:   time.Now().UTC().Add(tusUploadLifetime),
+	}
+
+	file, err := os.Create(upload.PartialPath)
+	if err != nil {
+		return tusError(c, 500, "Failed to create upload")
+	}
+	file.Close()
+
+	err = database.CreateTusUpload(upload)
+	if err != nil {
+		os.Remove(upload.PartialPath)
+		return tusError(c, 500, "Failed to create upload")
+	}
+
+	c.Set("Tus-Resumable", tusVersion)
+	c.Set("Location", c.BaseURL()+"/api/teacher/uploads/"+id)
+	setUploadExpires(c, upload.ExpiresAt)
+	return c.SendStatus(201)
+}
+
+// TusHeadHandler reports how many bytes of an upload the server has received
+func TusHeadHandler(c fiber.Ctx) error {
+	if !checkTusResumable(c) {
+		return tusError(c, 412, "Unsupported tus version")
+	}
+
+	upload, err := getOwnedTusUpload(c)
+	if upload == nil {
+		return err
+	}
+
+	c.Set("Tus-Resumable", tusVersion)
+	c.Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	setUploadExpires(c, upload.ExpiresAt)
+	c.Set("Cache-Control", "no-store")
+	return c.SendStatus(200)
+}
+
+// TusPatchHandler appends a chunk to an upload and finalizes it into a video
+// once all bytes have arrived
+func TusPatchHandler(c fiber.Ctx) error {
+	if !checkTusResumable(c) {
+		return tusError(c, 412, "Unsupported tus version")
+	}
+
+	if c.Get("Content-Type") != tusContentType {
+		return tusError(c, 415, "Content-Type must be "+tusContentType)
+	}
+
+	upload, unlock, err := lockOwnedTusUpload(c)
+	if upload == nil {
+		return err
+	}
+	defer unlock()
+
+	offset, err := strconv.ParseInt(c.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return tusError(c, 400, "Invalid Upload-Offset")
+	}
+
+	if offset != upload.Offset {
+		return tusError(c, 409, "Upload-Offset does not match the current offset")
+	}
+
+	var checksum hash.Hash
+	var expected []byte
+	if header := c.Get("Upload-Checksum"); header != "" {
+		checksum, expected, err = parseTusChecksum(header)
+		if err != nil {
+			return tusError(c, 400, "Unsupported or invalid Upload-Checksum")
+		}
+	}
+
+	file, err := os.OpenFile(upload.PartialPath, os.O_WRONLY, 0644)
+	if err != nil {
+		return tusError(c, 500, "Failed to open upload")
+	}
+	defer file.Close()
+
+	// Drop anything past the recorded offset, e.g. from an interrupted request
+	if err := file.Truncate(upload.Offset); err != nil {
+		return tusError(c, 500, "Failed to write upload")
+	}
+	if _, err := file.Seek(upload.Offset, io.SeekStart); err != nil {
+		return tusError(c, 500, "Failed to write upload")
+	}
+
+	var dst io.Writer = file
+	if checksum != nil {
+		dst = io.MultiWriter(file, checksum)
+	}
+
+	remaining := upload.Length - upload.Offset
+	written, copyErr := io.Copy(dst, io.LimitReader(requestBodyReader(c), remaining+1))
+
+	if written > remaining {
+		file.Truncate(upload.Offset)
+		return tusError(c, 413, "Chunk exceeds Upload-Length")
+	}
+
+	if checksum != nil && (copyErr != nil || !bytes.Equal(checksum.Sum(nil), expected)) {
+		file.Truncate(upload.Offset)
+		return tusError(c, StatusChecksumMismatch, "Checksum mismatch")
+	}
+
+	// Keep whatever arrived before a dropped connection so the client can resume
+	newOffset := upload.Offset + written
+	expiresAt := time.Now().UTC().Add(tusUploadLifetime)
+	if err := database.UpdateTusUploadOffset(upload.ID, newOffset, expiresAt); err != nil {
+		file.Truncate(upload.Offset)
+		return tusError(c, 500, "Failed to record upload offset")
+	}
+
+	if copyErr != nil {
+		return tusError(c, 500, "Failed to read upload data")
+	}
+
+	// Reject uploads whose first bytes are not the announced container
+	if upload.Offset < sniffHeaderSize && (newOffset >= sniffHeaderSize || newOffset == upload.Length) {
+		if err := sniffTusUpload(upload); err != nil {
+			discardTusUpload(upload)
+			return tusError(c, 415, err.Error())
+		}
+	}
+
+	if newOffset == upload.Length {
+		file.Close()
+		if err := finalizeTusUpload(upload); err != nil {
+			code := 500
+			if e, ok := err.(*fiber.Error); ok {
+				code = e.Code
+			}
+			return tusError(c, code, err.Error())
+		}
+	}
+
+	c.Set("Tus-Resumable", tusVersion)
+	c.Set("Upload-Offset", strconv.FormatInt(newOffset, 10))
+	if newOffset < upload.Length {
+		setUploadExpires(c, expiresAt)
+	}
+	return c.SendStatus(204)
+}
+
+// TusDeleteHandler discards an unfinished upload (tus termination extension)
+func TusDeleteHandler(c fiber.Ctx) error {
+	if !checkTusResumable(c) {
+		return tusError(c, 412, "Unsupported tus version")
+	}
+
+	upload, unlock, err := lockOwnedTusUpload(c)
+	if upload == nil {
+		return err
+	}
+	defer unlock()
+
+	err = discardTusUpload(upload)
+	if err != nil {
+		return tusError(c, 500, "Failed to delete upload")
+	}
+
+	c.Set("Tus-Resumable", tusVersion)
+	return c.SendStatus(204)
+}
+
+// List the teacher's unfinished uploads that can still be resumed
+func ListTusUploadsHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	uploads, err := database.GetTusUploads(userID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get uploads",
+		})
+	}
+	if uploads == nil {
+		uploads = []models.TusUpload{}
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    uploads,
+	})
+}
+
+// StartTusUploadCleaner removes expired uploads and their partial data in
+// the background
+func StartTusUploadCleaner() {
+	go func() {
+		removeExpiredTusUploads()
+		ticker := time.NewTicker(tusCleanupInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			removeExpiredTusUploads()
+		}
+	}()
+}
+
+func removeExpiredTusUploads() {
+	uploads, err := database.GetExpiredTusUploads(time.Now().UTC())
+	if err != nil {
+		log.Printf("Failed to list expired uploads: %v", err)
+		return
+	}
+	for _, upload := range uploads {
+		// A request may have resumed or removed the upload while waiting
+		// for the lock
+		unlock := lockTusUpload(upload.ID)
+		current, err := database.GetTusUpload(upload.ID)
+		if err == nil && !time.Now().Before(current.ExpiresAt) {
+			if err := discardTusUpload(current); err != nil {
+				log.Printf("Failed to remove expired upload %s: %v", upload.ID, err)
+			}
+		} else {
+			forgetTusLockIfGone(upload.ID)
+		}
+		unlock()
+	}
+}
+
+// discardTusUpload removes an upload and its partial data
+func discardTusUpload(upload *models.TusUpload) error {
+	err := database.DeleteTusUpload(upload.ID)
+	if err != nil {
+		return err
+	}
+	os.Remove(upload.PartialPath)
+	tusLocks.Delete(upload.ID)
+	return nil
+}
+
+// sniffTusUpload checks the leading bytes of a partial upload against its filename
+func sniffTusUpload(upload *models.TusUpload) error {
+	file, err := os.Open(upload.PartialPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := make([]byte, sniffHeaderSize)
+	n, _ := io.ReadFull(file, header)
+	_, err = checkVideoContainer(header[:n], strings.ToLower(filepath.Ext(upload.Filename)))
+	return err
+}
+
+// finalizeTusUpload validates a completed upload and runs the regular video
+// creation flow on it. The upload is only removed once the video exists; if
+// creating it fails, a PATCH without data at the final offset tries again.
+func finalizeTusUpload(upload *models.TusUpload) error {
+	ext := strings.ToLower(filepath.Ext(upload.Filename))
+	if err := validateVideoFile(upload.PartialPath, ext); err != nil {
+		discardTusUpload(upload)
+		return fiber.NewError(415, err.Error())
+	}
+
+	// createVideoFromFile consumes the file it is given, so it gets a link
+	// to the upload's data rather than the data itself
+	stagedPath := upload.PartialPath + ".finalize"
+	os.Remove(stagedPath)
+	if err := os.Link(upload.PartialPath, stagedPath); err != nil {
+		return fiber.NewError(500, "Failed to stage upload")
+	}
+
+	_, err := createVideoFromFile(upload.TeacherID, upload.Title, upload.Description, upload.Publishing,
+		upload.Filename, stagedPath)
+	if err != nil {
+		return err
+	}
+	discardTusUpload(upload)
+	return nil
+}
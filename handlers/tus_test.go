@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"educational-platform/database"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// loginTeacher creates a teacher and returns its ID and a session cookie
+func loginTeacher(t *testing.T, username string) (int, string) {
+	t.Helper()
+	if err := database.CreateTeacher(username, username+"@example.com", "x", username); err != nil {
+		t.Fatal(err)
+	}
+	teacher, err := database.GetTeacherByUsername(username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionID := "test-" + username
+	sessions[sessionID] = map[string]interface{}{"user_id": teacher.ID, "user_type": "teacher"}
+	t.Cleanup(func() { delete(sessions, sessionID) })
+	return teacher.ID, "session_id=" + sessionID
+}
+
+func TestTusUploadExpiry(t *testing.T) {
+	setupTestDB(t)
+	stagingDir := StagingDir
+	StagingDir = t.TempDir()
+	t.Cleanup(func() { StagingDir = stagingDir })
+	teacherID, cookie := loginTeacher(t, "teacher")
+
+	app := fiber.New()
+	uploads := app.Group("/uploads", func(c fiber.Ctx) error {
+		loadSession(c)
+		return c.Next()
+	})
+	uploads.Post("", TusCreateHandler)
+	uploads.Head("/:id", TusHeadHandler)
+	uploads.Patch("/:id", TusPatchHandler)
+
+	send := func(method, path string, headers map[string]string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Cookie", cookie)
+		req.Header.Set("Tus-Resumable", tusVersion)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	resp := send("POST", "/uploads", map[string]string{
+		"Upload-Length":   "1000",
+		"Upload-Metadata": "filename " + encode("lecture.mp4") + ",title " + encode("Lecture"),
+	})
+	if resp.StatusCode != 201 {
+		t.Fatalf("create: status %d", resp.StatusCode)
+	}
+	expires, err := http.ParseTime(resp.Header.Get("Upload-Expires"))
+	if err != nil {
+		t.Fatalf("Upload-Expires %q: %v", resp.Header.Get("Upload-Expires"), err)
+	}
+	if d := time.Until(expires); d < tusUploadLifetime-time.Minute || d > tusUploadLifetime {
+		t.Errorf("upload expires in %v, want %v", d, tusUploadLifetime)
+	}
+	id := resp.Header.Get("Location")[strings.LastIndex(resp.Header.Get("Location"), "/")+1:]
+	upload, err := database.GetTusUpload(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if used, _ := database.GetTeacherStorageUsage(teacherID); used != 1000 {
+		t.Errorf("storage used %d, want 1000 while the upload is open", used)
+	}
+
+	_, err = database.DB.Exec(`UPDATE tus_uploads SET expires_at = ? WHERE id = ?`, time.Now().UTC().Add(-time.Minute), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if used, _ := database.GetTeacherStorageUsage(teacherID); used != 0 {
+		t.Errorf("storage used %d, want 0 once the upload expired", used)
+	}
+	if resp := send("HEAD", "/uploads/"+id, nil); resp.StatusCode != 410 {
+		t.Errorf("HEAD expired upload: status %d, want 410", resp.StatusCode)
+	}
+	resp = send("PATCH", "/uploads/"+id, map[string]string{"Content-Type": tusContentType, "Upload-Offset": "0"})
+	if resp.StatusCode != 410 {
+		t.Errorf("PATCH expired upload: status %d, want 410", resp.StatusCode)
+	}
+
+	removeExpiredTusUploads()
+	if _, err := database.GetTusUpload(id); err != sql.ErrNoRows {
+		t.Errorf("expired upload still stored: %v", err)
+	}
+	if _, err := os.Stat(upload.PartialPath); !os.IsNotExist(err) {
+		t.Errorf("partial file of expired upload still exists: %v", err)
+	}
+	if _, ok := tusLocks.Load(id); ok {
+		t.Error("lock of expired upload kept")
+	}
+}
+
+func TestTusUnknownUploadTakesNoLock(t *testing.T) {
+	setupTestDB(t)
+	_, cookie := loginTeacher(t, "teacher")
+
+	app := fiber.New()
+	app.Patch("/uploads/:id", func(c fiber.Ctx) error {
+		loadSession(c)
+		return c.Next()
+	}, TusPatchHandler)
+
+	req := httptest.NewRequest("PATCH", "/uploads/unknown", strings.NewReader("data"))
+	req.Header.Set("Cookie", cookie)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	req.Header.Set("Content-Type", tusContentType)
+	req.Header.Set("Upload-Offset", "0")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 404 {
+		t.Errorf("status %d, want 404", resp.StatusCode)
+	}
+	if _, ok := tusLocks.Load("unknown"); ok {
+		t.Error("lock taken for an unknown upload")
+	}
+}
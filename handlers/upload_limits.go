@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"log"
+	"os"
+	"strconv"
+
+	"educational-platform/database"
+)
+
+// Upload limits, configured from the environment by InitUploads
+var (
+	// MaxUploadSize is the largest video accepted in bytes (MAX_UPLOAD_SIZE_MB)
+	MaxUploadSize int64 = 2048 << 20
+
+	// DefaultStorageQuota applies to teachers without their own storage_quota
+	// (TEACHER_STORAGE_QUOTA_MB). Zero means unlimited.
+	DefaultStorageQuota int64 = 10240 << 20
+
+	// MaxSubmissionSize is the largest file a student may submit for an
+	// assignment in bytes (MAX_SUBMISSION_SIZE_MB)
+	MaxSubmissionSize int64 = 25 << 20
+
+	// MaxAttachmentSize is the largest file that can be attached to a
+	// message in bytes (MAX_ATTACHMENT_SIZE_MB)
+	MaxAttachmentSize int64 = 10 << 20
+
+	// StagingDir holds local files while they are uploaded and processed,
+	// before they are moved into storage (UPLOAD_STAGING_DIR)
+	StagingDir = "./uploads/partial"
+)
+
+// InitUploads reads upload size, quota and staging settings from the environment
+func InitUploads() error {
+	if size, ok := envMegabytes("MAX_UPLOAD_SIZE_MB"); ok {
+		MaxUploadSize = size
+	}
+	if size, ok := envMegabytes("MAX_SUBMISSION_SIZE_MB"); ok {
+		MaxSubmissionSize = size
+	}
+	if size, ok := envMegabytes("MAX_ATTACHMENT_SIZE_MB"); ok {
+		MaxAttachmentSize = size
+	}
+	if quota, ok := envMegabytes("TEACHER_STORAGE_QUOTA_MB"); ok {
+		DefaultStorageQuota = quota
+	}
+	if dir := os.Getenv("UPLOAD_STAGING_DIR"); dir != "" {
+		StagingDir = dir
+	}
+	return os.MkdirAll(StagingDir, 0755)
+}
+
+func envMegabytes(name string) (int64, bool) {
+	value := os.Getenv(name)
+	if value == "" {
+		return 0, false
+	}
+
+	mb, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || mb < 0 {
+		log.Printf("Ignoring invalid %s=%q", name, value)
+		return 0, false
+	}
+	return mb << 20, true
+}
+
+// teacherStorageQuota returns the quota that applies to a teacher, 0 for unlimited
+func teacherStorageQuota(teacherID int) (int64, error) {
+	quota, ok, err := database.GetTeacherStorageQuota(teacherID)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return DefaultStorageQuota, nil
+	}
+	return quota, nil
+}
+
+// uploadLimit returns how many bytes the teacher may upload right now, and
+// whether that limit comes from their storage quota rather than MaxUploadSize
+func uploadLimit(teacherID int) (int64, bool, error) {
+	quota, err := teacherStorageQuota(teacherID)
+	if err != nil {
+		return 0, false, err
+	}
+	if quota == 0 {
+		return MaxUploadSize, false, nil
+	}
+
+	used, err := database.GetTeacherStorageUsage(teacherID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	remaining := quota - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	if remaining < MaxUploadSize {
+		return remaining, true, nil
+	}
+	return MaxUploadSize, false, nil
+}
+
+// uploadLimitMessage explains why an upload of the given limit was rejected
+func uploadLimitMessage(quotaBound bool) string {
+	if quotaBound {
+		return "Storage quota exceeded"
+	}
+	return "Video exceeds the maximum upload size"
+}
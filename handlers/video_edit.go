@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"educational-platform/database"
+	"educational-platform/events"
+	"educational-platform/models"
+	"educational-platform/storage"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// maxVideoTitle matches the videos.title column
+const maxVideoTitle = 200
+
+// Update a video's title, description and publishing settings
+func UpdateVideoHandler(c fiber.Ctx) error {
+	video, err := getOwnedVideo(c)
+	if video == nil {
+		return err
+	}
+
+	var req models.VideoUpdateRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	title := video.Title
+	if req.Title != nil {
+		title = strings.TrimSpace(*req.Title)
+	}
+	description := video.Description
+	if req.Description != nil {
+		description = *req.Description
+	}
+
+	if title == "" {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Title is required",
+		})
+	}
+	if utf8.RuneCountInString(title) > maxVideoTitle {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Title is too long",
+		})
+	}
+
+	// Publishing settings are only checked when one of them changes, so an
+	// overdue schedule does not block editing the title
+	changePublishing := req.Status != nil || req.Visibility != nil || req.PublishAt != nil
+	publishing := models.VideoPublishing{
+		Status:     video.Status,
+		Visibility: video.Visibility,
+		PublishAt:  video.PublishAt,
+	}
+	if req.Status != nil {
+		publishing.Status = *req.Status
+	}
+	if req.Visibility != nil {
+		publishing.Visibility = *req.Visibility
+	}
+	if req.PublishAt != nil {
+		publishing.PublishAt = req.PublishAt
+	}
+	if changePublishing {
+		if err := validatePublishing(&publishing); err != nil {
+			return c.Status(400).JSON(models.APIResponse{
+				Success: false,
+				Message: err.Error(),
+			})
+		}
+	}
+
+	err = database.UpdateVideoMetadata(video.ID, title, description)
+	if err == nil && changePublishing {
+		err = database.UpdateVideoPublishing(video.ID, publishing)
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update video",
+		})
+	}
+
+	updated, err := database.GetVideoByID(video.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update video",
+		})
+	}
+	if changePublishing {
+		notifyNewVideo(video.ID)
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Video updated successfully",
+		Data:    updated,
+	})
+}
+
+// Replace a video's media file, keeping its ID, views and the old file as a
+// revision. The multipart body is streamed to disk like a new upload.
+func ReplaceVideoFileHandler(c fiber.Ctx) error {
+	video, err := getOwnedVideo(c)
+	if video == nil {
+		return err
+	}
+
+	mediaType, params, err := mime.ParseMediaType(c.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to parse form",
+		})
+	}
+
+	limit, quotaBound, err := uploadLimit(video.TeacherID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to check storage quota",
+		})
+	}
+
+	var originalFilename, filePath string
+
+	reader := multipart.NewReader(requestBodyReader(c), params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err == nil && part.FormName() == "video" && filePath == "" {
+			originalFilename = part.FileName()
+			filePath, err = saveVideoPart(part, limit, quotaBound)
+		}
+
+		if err != nil {
+			if filePath != "" {
+				os.Remove(filePath)
+			}
+			code := 400
+			message := "Failed to parse form"
+			if e, ok := err.(*fiber.Error); ok {
+				code, message = e.Code, e.Message
+			}
+			return c.Status(code).JSON(models.APIResponse{
+				Success: false,
+				Message: message,
+			})
+		}
+	}
+
+	if filePath == "" {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "No video file provided",
+		})
+	}
+
+	err = replaceVideoFile(video, originalFilename, filePath)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	updated, err := database.GetVideoByID(video.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get video",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Video file replaced successfully",
+		Data:    updated,
+	})
+}
+
+// replaceVideoFile moves a staged file into storage as the video's new media
+// and re-runs the processing done on upload: duration, chapters, thumbnail
+// and storyboard. Custom thumbnails are kept. The staged file is consumed in
+// every case.
+func replaceVideoFile(video *models.Video, originalFilename, stagedPath string) error {
+	defer os.Remove(stagedPath)
+
+	filename := newVideoFilename(video.TeacherID, strings.ToLower(filepath.Ext(originalFilename)))
+
+	fileInfo, err := os.Stat(stagedPath)
+	if err != nil {
+		return fmt.Errorf("Failed to get file info")
+	}
+
+	customThumbnail := strings.Contains(video.ThumbnailPath, "_custom_")
+
+	duration := probeVideoDuration(stagedPath)
+	chapters := probeVideoChapters(stagedPath)
+	framePath := ""
+	if !customThumbnail {
+		framePath = GenerateThumbnail(stagedPath, filename)
+		if framePath != "" {
+			defer os.Remove(framePath)
+		}
+	}
+
+	videoKey := "videos/" + filename
+	err = storage.PutFile(storage.Store, videoKey, stagedPath, videoContentType(filename))
+	if err != nil {
+		return fmt.Errorf("Failed to save video file")
+	}
+
+	storyboard, _ := database.GetStoryboard(video.ID)
+
+	err = database.ReplaceVideoFile(video.ID, originalFilename, videoKey, duration, fileInfo.Size())
+	if err != nil {
+		storage.Store.Delete(videoKey)
+		return fmt.Errorf("Failed to save video info")
+	}
+
+	if storyboard != nil {
+		deleteStoryboardSheets(storyboard)
+	}
+
+	// Chapters from the new file win; otherwise drop those past its end
+	if len(chapters) > 0 {
+		importChapters(video.ID, chapters, duration)
+	} else if duration > 0 {
+		database.DeleteChaptersFrom(video.ID, duration)
+	}
+
+	if !customThumbnail {
+		thumbnailKey := "thumbnails/" + strings.TrimSuffix(filename, filepath.Ext(filename)) + "_thumb.jpg"
+		err := buildVideoThumbnail(thumbnailKey, framePath, video.ID, video.Title, video.TeacherName)
+		if err == nil {
+			err = database.UpdateVideoThumbnail(video.ID, thumbnailKey)
+		}
+		if err == nil {
+			deleteThumbnail(video.ThumbnailPath)
+		} else {
+			deleteThumbnail(thumbnailKey)
+		}
+	}
+
+	if duration > 0 && isFFmpegAvailable() {
+		go generateStoryboard(video.ID, videoKey, duration)
+	}
+
+	publishEvent("teacher", video.TeacherID, events.VideoProcessed, map[string]interface{}{
+		"video_id": video.ID,
+		"title":    video.Title,
+		"status":   video.Status,
+	})
+
+	return nil
+}
+
+// List the files a video used before it was replaced
+func GetVideoRevisionsHandler(c fiber.Ctx) error {
+	video, err := getOwnedVideo(c)
+	if video == nil {
+		return err
+	}
+
+	revisions, err := database.GetVideoRevisions(video.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get revisions",
+		})
+	}
+	if revisions == nil {
+		revisions = []models.VideoRevision{}
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    revisions,
+	})
+}
+
+// Delete an old revision to free storage
+func DeleteVideoRevisionHandler(c fiber.Ctx) error {
+	video, err := getOwnedVideo(c)
+	if video == nil {
+		return err
+	}
+
+	revisionID, err := strconv.Atoi(c.Params("revision_id"))
+	if err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid revision ID",
+		})
+	}
+
+	revision, err := database.GetVideoRevision(video.ID, revisionID)
+	if err != nil {
+		return c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Revision not found",
+		})
+	}
+
+	err = database.DeleteVideoRevision(revision.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to delete revision",
+		})
+	}
+	storage.Store.Delete(revision.FilePath)
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Revision deleted successfully",
+	})
+}
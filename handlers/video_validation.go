@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Container families recognized by sniffVideoContainer
+const (
+	containerISOBMFF  = "mp4"      // MP4 and QuickTime (.mov)
+	containerAVI      = "avi"      // RIFF AVI
+	containerMatroska = "matroska" // Matroska (.mkv)
+	containerWebM     = "webm"     // WebM, a Matroska subset
+)
+
+// extContainers lists which sniffed containers are acceptable for each extension
+var extContainers = map[string][]string{
+	".mp4":  {containerISOBMFF},
+	".mov":  {containerISOBMFF},
+	".avi":  {containerAVI},
+	".mkv":  {containerMatroska, containerWebM},
+	".webm": {containerWebM},
+}
+
+// sniffHeaderSize is how many leading bytes sniffVideoContainer needs
+const sniffHeaderSize = 4096
+
+// sniffVideoContainer identifies the container format from the first bytes of a file.
+// It returns an empty string when the data is not a supported video container.
+func sniffVideoContainer(header []byte) string {
+	if len(header) >= 12 && bytes.Equal(header[0:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("AVI ")) {
+		return containerAVI
+	}
+
+	if len(header) >= 4 && bytes.Equal(header[0:4], []byte{0x1A, 0x45, 0xDF, 0xA3}) {
+		switch ebmlDocType(header) {
+		case "webm":
+			return containerWebM
+		case "matroska":
+			return containerMatroska
+		}
+		return ""
+	}
+
+	if len(header) >= 8 {
+		switch string(header[4:8]) {
+		// Old QuickTime files may start with any of these atoms instead of ftyp
+		case "ftyp", "moov", "mdat", "wide", "free", "skip", "pnot":
+			return containerISOBMFF
+		}
+	}
+
+	return ""
+}
+
+// checkVideoContainer makes sure the sniffed container is acceptable for the extension
+func checkVideoContainer(header []byte, ext string) (string, error) {
+	container := sniffVideoContainer(header)
+	if container == "" {
+		return "", fmt.Errorf("File is not a recognized video format")
+	}
+
+	for _, allowed := range extContainers[ext] {
+		if container == allowed {
+			return container, nil
+		}
+	}
+	return "", fmt.Errorf("File content (%s) does not match the %s extension", container, ext)
+}
+
+// validateVideoFile sniffs a saved file and checks that its container structure is intact
+func validateVideoFile(path, ext string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("Failed to read video file")
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("Failed to read video file")
+	}
+
+	header := make([]byte, sniffHeaderSize)
+	n, _ := io.ReadFull(file, header)
+	header = header[:n]
+
+	container, err := checkVideoContainer(header, ext)
+	if err != nil {
+		return err
+	}
+
+	switch container {
+	case containerISOBMFF:
+		return validateISOBMFF(file, info.Size())
+	case containerAVI:
+		// RIFF size excludes the 8-byte chunk header
+		riffSize := int64(binary.LittleEndian.Uint32(header[4:8])) + 8
+		if riffSize > info.Size() {
+			return fmt.Errorf("Video file is truncated")
+		}
+	case containerMatroska, containerWebM:
+		return validateEBML(file, info.Size())
+	}
+	return nil
+}
+
+// validateISOBMFF walks the top-level boxes of an MP4/MOV file and requires a
+// movie header (moov) and box sizes that add up to the file size
+func validateISOBMFF(r io.ReaderAt, size int64) error {
+	var offset int64
+	hasMoov := false
+	buf := make([]byte, 16)
+
+	for offset < size {
+		if _, err := r.ReadAt(buf[:8], offset); err != nil {
+			return fmt.Errorf("Video file is truncated")
+		}
+		boxSize := int64(binary.BigEndian.Uint32(buf[0:4]))
+		boxType := string(buf[4:8])
+
+		switch boxSize {
+		case 0:
+			// Box extends to the end of the file
+			boxSize = size - offset
+		case 1:
+			if _, err := r.ReadAt(buf[8:16], offset+8); err != nil {
+				return fmt.Errorf("Video file is truncated")
+			}
+			boxSize = int64(binary.BigEndian.Uint64(buf[8:16]))
+		}
+
+		if boxSize < 8 {
+			return fmt.Errorf("Video file is corrupt")
+		}
+		if offset+boxSize > size {
+			return fmt.Errorf("Video file is truncated")
+		}
+
+		if boxType == "moov" {
+			hasMoov = true
+		}
+		offset += boxSize
+	}
+
+	if !hasMoov {
+		return fmt.Errorf("Video file has no movie header")
+	}
+	return nil
+}
+
+// validateEBML checks the EBML header and that the Matroska segment is not truncated
+func validateEBML(r io.ReaderAt, size int64) error {
+	buf := make([]byte, 16)
+
+	// EBML header element
+	if _, err := r.ReadAt(buf, 0); err != nil && err != io.EOF {
+		return fmt.Errorf("Video file is corrupt")
+	}
+	headerSize, sizeLen, ok := readEBMLVint(buf[4:])
+	if !ok || headerSize < 0 {
+		return fmt.Errorf("Video file is corrupt")
+	}
+	offset := 4 + int64(sizeLen) + headerSize
+
+	// Segment element follows the header
+	n, _ := r.ReadAt(buf, offset)
+	if n < 5 || !bytes.Equal(buf[0:4], []byte{0x18, 0x53, 0x80, 0x67}) {
+		return fmt.Errorf("Video file is truncated")
+	}
+	segmentSize, sizeLen, ok := readEBMLVint(buf[4:n])
+	if !ok {
+		return fmt.Errorf("Video file is corrupt")
+	}
+
+	// An all-ones size means "unknown" and is used by live-streamed files
+	if segmentSize >= 0 && offset+4+int64(sizeLen)+segmentSize > size {
+		return fmt.Errorf("Video file is truncated")
+	}
+	return nil
+}
+
+// readEBMLVint decodes an EBML variable-length size. Unknown sizes return -1.
+func readEBMLVint(b []byte) (int64, int, bool) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0, false
+	}
+
+	length := 1
+	for mask := byte(0x80); b[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if len(b) < length {
+		return 0, 0, false
+	}
+
+	value := int64(b[0] & (0xFF >> length))
+	allOnes := value == int64(0xFF>>length)
+	for i := 1; i < length; i++ {
+		value = value<<8 | int64(b[i])
+		allOnes = allOnes && b[i] == 0xFF
+	}
+	if allOnes {
+		return -1, length, true
+	}
+	return value, length, true
+}
+
+// ebmlDocType extracts the DocType string from an EBML header
+func ebmlDocType(header []byte) string {
+	marker := []byte{0x42, 0x82}
+	idx := bytes.Index(header, marker)
+	if idx < 0 {
+		return ""
+	}
+
+	size, sizeLen, ok := readEBMLVint(header[idx+2:])
+	start := idx + 2 + sizeLen
+	if !ok || size < 0 || start+int(size) > len(header) {
+		return ""
+	}
+	return string(header[start : start+int(size)])
+}
@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"educational-platform/database"
+	"educational-platform/events"
+	"educational-platform/models"
+)
+
+// Webhook delivery settings
+const (
+	webhookPollInterval     = 5 * time.Second
+	webhookTimeout          = 10 * time.Second
+	webhookConcurrency      = 4 // deliveries sent at once
+	webhookBatchSize        = 100
+	webhookMaxAttempts      = 8
+	webhookRetryBase        = time.Minute // doubled after each failed attempt
+	webhookResponseLimit    = 1024        // bytes of the endpoint's response kept
+	webhookDeliveryRetained = 30 * 24 * time.Hour
+	webhookUserAgent        = "EducationalPlatform-Webhook/1.0"
+)
+
+// webhookPing is a test event sent to a single webhook on request
+const webhookPing = "ping"
+
+// webhookEvents are the real-time events teachers can forward to a webhook
+var webhookEvents = []string{
+	events.SubscriptionCreated,
+	events.SubscriptionDeleted,
+	events.VideoViewed,
+	events.VideoProcessed,
+}
+
+func isWebhookEvent(eventType string) bool {
+	for _, e := range webhookEvents {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookAllowPrivate lets webhooks reach loopback and private addresses,
+// which are otherwise refused so teachers cannot probe the server's network.
+// Set with WEBHOOK_ALLOW_PRIVATE_NETWORKS=true.
+var webhookAllowPrivate bool
+
+// isPublicAddress reports whether webhooks may connect to ip
+func isPublicAddress(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return webhookAllowPrivate || !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// webhookClient posts deliveries. Redirects are not followed, and the
+// address is checked after DNS resolution, right before connecting.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				addrPort, err := netip.ParseAddrPort(address)
+				if err != nil || !isPublicAddress(addrPort.Addr()) {
+					return fmt.Errorf("connecting to %s is not allowed", address)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+		MaxIdleConnsPerHost: webhookConcurrency,
+	},
+}
+
+// webhookWake starts a dispatch without waiting for the next poll
+var webhookWake = make(chan struct{}, 1)
+
+func wakeWebhookDispatcher() {
+	select {
+	case webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// webhookInflight holds the deliveries being sent, so a poll that runs
+// meanwhile does not send them again
+var (
+	webhookInflight   = make(map[int]bool)
+	webhookInflightMu sync.Mutex
+	webhookSlots      = make(chan struct{}, webhookConcurrency)
+)
+
+// StartWebhookDispatcher sends queued webhook deliveries in the background,
+// retrying failed ones, and prunes old delivery logs once a day
+func StartWebhookDispatcher() {
+	webhookAllowPrivate = os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true"
+
+	go func() {
+		pruneWebhookDeliveries()
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+		prune := time.NewTicker(24 * time.Hour)
+		defer prune.Stop()
+		for {
+			dispatchWebhookDeliveries()
+			select {
+			case <-ticker.C:
+			case <-webhookWake:
+			case <-prune.C:
+				pruneWebhookDeliveries()
+			}
+		}
+	}()
+}
+
+func pruneWebhookDeliveries() {
+	n, err := database.DeleteOldDeliveries(time.Now().UTC().Add(-webhookDeliveryRetained))
+	if err != nil {
+		log.Printf("Failed to prune webhook deliveries: %v", err)
+	} else if n > 0 {
+		log.Printf("Pruned %d old webhook deliveries", n)
+	}
+}
+
+func dispatchWebhookDeliveries() {
+	deliveries, err := database.GetDueDeliveries(time.Now().UTC(), webhookBatchSize)
+	if err != nil {
+		log.Printf("Failed to check webhook deliveries: %v", err)
+		return
+	}
+	for _, d := range deliveries {
+		webhookInflightMu.Lock()
+		busy := webhookInflight[d.ID]
+		webhookInflight[d.ID] = true
+		webhookInflightMu.Unlock()
+		if busy {
+			continue
+		}
+
+		webhookSlots <- struct{}{}
+		go func(d models.WebhookDelivery) {
+			defer func() {
+				<-webhookSlots
+				webhookInflightMu.Lock()
+				delete(webhookInflight, d.ID)
+				webhookInflightMu.Unlock()
+			}()
+			attemptDelivery(&d)
+		}(d)
+	}
+}
+
+// signWebhookPayload returns the X-Webhook-Signature value for a payload
+// sent at timestamp: the hex HMAC-SHA256 of "<timestamp>.<payload>"
+func signWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// attemptDelivery posts a delivery to its webhook once and records the
+// outcome, scheduling a retry with exponential backoff if it failed
+func attemptDelivery(d *models.WebhookDelivery) {
+	w, err := database.GetWebhook(d.WebhookID)
+	var secret string
+	if err == nil {
+		secret, err = database.GetWebhookSecret(d.WebhookID)
+	}
+	if err != nil {
+		log.Printf("Failed to load webhook %d for delivery %d: %v", d.WebhookID, d.ID, err)
+		return
+	}
+
+	start := time.Now()
+	status, body, err := postWebhook(w.URL, secret, d)
+	now := time.Now().UTC()
+
+	d.Attempts++
+	d.LastAttemptAt = &now
+	d.DurationMS = int(time.Since(start).Milliseconds())
+	d.ResponseStatus = status
+	d.ResponseBody = body
+	d.Error = ""
+	switch {
+	case err != nil:
+		d.Error = err.Error()
+	case status < 200 || status > 299:
+		d.Error = fmt.Sprintf("endpoint responded with status %d", status)
+	}
+
+	switch {
+	case d.Error == "":
+		d.Status = models.DeliverySucceeded
+		d.NextAttemptAt = nil
+	case d.Attempts >= webhookMaxAttempts:
+		d.Status = models.DeliveryFailed
+		d.NextAttemptAt = nil
+	default:
+		next := now.Add(webhookRetryBase << (d.Attempts - 1))
+		d.NextAttemptAt = &next
+	}
+
+	if err := database.RecordDeliveryAttempt(d); err != nil {
+		log.Printf("Failed to record webhook delivery %d: %v", d.ID, err)
+	}
+}
+
+// postWebhook sends a delivery's payload and returns the response status and
+// the start of its body
+func postWebhook(url, secret string, d *models.WebhookDelivery) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", webhookUserAgent)
+	req.Header.Set("X-Webhook-Event", d.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.Itoa(d.ID))
+	req.Header.Set("X-Webhook-Signature", signWebhookPayload(secret, time.Now().Unix(), d.Payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	return resp.StatusCode, string(body), nil
+}
+
+// webhookPayload builds the JSON body sent for an event
+func webhookPayload(eventID, eventType string, createdAt time.Time, data interface{}) (json.RawMessage, error) {
+	return json.Marshal(map[string]interface{}{
+		"id":         eventID,
+		"event":      eventType,
+		"created_at": createdAt,
+		"data":       data,
+	})
+}
+
+// queueWebhooks queues an event for each of a teacher's active webhooks that
+// subscribe to it. Like notifications, failures are only logged.
+func queueWebhooks(teacherID int, eventType string, data interface{}) {
+	webhooks, err := database.GetTeacherWebhooks(teacherID, true)
+	if err != nil {
+		log.Printf("Failed to get webhooks of teacher %d: %v", teacherID, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	eventID := database.NewWebhookEventID()
+	var payload json.RawMessage
+	queued := false
+	for _, w := range webhooks {
+		subscribed := false
+		for _, e := range w.Events {
+			subscribed = subscribed || e == eventType
+		}
+		if !subscribed {
+			continue
+		}
+
+		if payload == nil {
+			payload, err = webhookPayload(eventID, eventType, now, data)
+			if err != nil {
+				log.Printf("Failed to encode %s webhook payload: %v", eventType, err)
+				return
+			}
+		}
+		_, err := database.CreateDelivery(&models.WebhookDelivery{
+			WebhookID:     w.ID,
+			EventID:       eventID,
+			Event:         eventType,
+			Payload:       payload,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+		})
+		if err != nil {
+			log.Printf("Failed to queue %s for webhook %d: %v", eventType, w.ID, err)
+			continue
+		}
+		queued = true
+	}
+	if queued {
+		wakeWebhookDispatcher()
+	}
+}
@@ -0,0 +1,462 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"educational-platform/database"
+	"educational-platform/models"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// Webhook limits
+const (
+	maxWebhooks              = 10 // per teacher
+	maxWebhookURL            = 2000
+	maxWebhookDescription    = 200
+	defaultDeliveryListLimit = 50
+	maxDeliveryListLimit     = 100
+)
+
+// checkWebhookURL validates the URL deliveries are posted to
+func checkWebhookURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", errors.New("URL is required")
+	}
+	if len(raw) > maxWebhookURL {
+		return "", fmt.Errorf("URL can be at most %d characters", maxWebhookURL)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return "", errors.New("URL must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return "", errors.New("URL cannot contain credentials")
+	}
+	// Names are checked again once resolved, when connecting
+	if ip, err := netip.ParseAddr(u.Hostname()); (err == nil && !isPublicAddress(ip)) ||
+		(!webhookAllowPrivate && strings.EqualFold(u.Hostname(), "localhost")) {
+		return "", errors.New("URL must point to a public address")
+	}
+	return u.String(), nil
+}
+
+// applyWebhookSettings validates a webhook request and applies it
+func applyWebhookSettings(w *models.Webhook, req *models.WebhookRequest) error {
+	if req.URL != nil {
+		u, err := checkWebhookURL(*req.URL)
+		if err != nil {
+			return err
+		}
+		w.URL = u
+	}
+	if req.Description != nil {
+		description := strings.TrimSpace(*req.Description)
+		if utf8.RuneCountInString(description) > maxWebhookDescription {
+			return fmt.Errorf("Description can be at most %d characters", maxWebhookDescription)
+		}
+		w.Description = description
+	}
+	if req.Events != nil {
+		seen := make(map[string]bool)
+		w.Events = []string{}
+		for _, e := range req.Events {
+			if !isWebhookEvent(e) {
+				return fmt.Errorf("Unknown event %q; events are %s", e, strings.Join(webhookEvents, ", "))
+			}
+			if !seen[e] {
+				seen[e] = true
+				w.Events = append(w.Events, e)
+			}
+		}
+	}
+	if req.Active != nil {
+		w.Active = *req.Active
+	}
+
+	if w.URL == "" {
+		return errors.New("URL is required")
+	}
+	if len(w.Events) == 0 {
+		return errors.New("At least one event is required")
+	}
+	return nil
+}
+
+// getOwnedWebhook loads the :id webhook if it belongs to the teacher
+func getOwnedWebhook(c fiber.Ctx) (*models.Webhook, error) {
+	userID := c.Locals("user_id").(int)
+	webhookID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid webhook ID",
+		})
+	}
+
+	w, err := database.GetWebhook(webhookID)
+	if err != nil || w.TeacherID != userID {
+		return nil, c.Status(404).JSON(models.APIResponse{
+			Success: false,
+			Message: "Webhook not found",
+		})
+	}
+	return w, nil
+}
+
+// Register a webhook. The response includes the signing secret, which is
+// not shown again.
+func CreateWebhookHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	var req models.WebhookRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+
+	now := time.Now().UTC()
+	w := &models.Webhook{
+		TeacherID: userID,
+		Secret:    database.NewWebhookSecret(),
+		Active:    true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := applyWebhookSettings(w, &req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+
+	id, err := database.CreateWebhook(w, maxWebhooks)
+	if err == database.ErrTooManyWebhooks {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("You can have at most %d webhooks", maxWebhooks),
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to create webhook",
+		})
+	}
+	w.ID = id
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: "Webhook created successfully",
+		Data:    w,
+	})
+}
+
+// List the teacher's webhooks
+func GetWebhooksHandler(c fiber.Ctx) error {
+	userID := c.Locals("user_id").(int)
+
+	webhooks, err := database.GetTeacherWebhooks(userID, false)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get webhooks",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"webhooks": webhooks,
+			"events":   webhookEvents,
+		},
+	})
+}
+
+// Get one of the teacher's webhooks
+func GetWebhookHandler(c fiber.Ctx) error {
+	w, err := getOwnedWebhook(c)
+	if w == nil {
+		return err
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    w,
+	})
+}
+
+// Change a webhook's URL, description, events or whether it is active.
+// Deliveries queued while it is inactive are sent once it is active again.
+func UpdateWebhookHandler(c fiber.Ctx) error {
+	w, err := getOwnedWebhook(c)
+	if w == nil {
+		return err
+	}
+
+	var req models.WebhookRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid request body",
+		})
+	}
+	if err := applyWebhookSettings(w, &req); err != nil {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+	}
+	w.UpdatedAt = time.Now().UTC()
+
+	err = database.UpdateWebhook(w)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to update webhook",
+		})
+	}
+	if w.Active {
+		wakeWebhookDispatcher()
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Webhook updated successfully",
+		Data:    w,
+	})
+}
+
+// Delete a webhook and its delivery log
+func DeleteWebhookHandler(c fiber.Ctx) error {
+	w, err := getOwnedWebhook(c)
+	if w == nil {
+		return err
+	}
+
+	err = database.DeleteWebhook(w.ID)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to delete webhook",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Webhook deleted successfully",
+	})
+}
+
+// Replace a webhook's signing secret. Deliveries from now on, including
+// retries, are signed with the new one.
+func RotateWebhookSecretHandler(c fiber.Ctx) error {
+	w, err := getOwnedWebhook(c)
+	if w == nil {
+		return err
+	}
+
+	secret := database.NewWebhookSecret()
+	err = database.SetWebhookSecret(w.ID, secret)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to rotate webhook secret",
+		})
+	}
+	w.Secret = secret
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Message: "Webhook secret rotated successfully",
+		Data:    w,
+	})
+}
+
+// queueDelivery queues a delivery to send as soon as possible
+func queueDelivery(c fiber.Ctx, d *models.WebhookDelivery, message string) error {
+	now := time.Now().UTC()
+	d.Status = models.DeliveryPending
+	d.NextAttemptAt = &now
+	d.CreatedAt = now
+
+	id, err := database.CreateDelivery(d)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to queue delivery",
+		})
+	}
+	d.ID = id
+	wakeWebhookDispatcher()
+
+	return c.Status(201).JSON(models.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    d,
+	})
+}
+
+// Send a ping event to a webhook to test it
+func PingWebhookHandler(c fiber.Ctx) error {
+	w, err := getOwnedWebhook(c)
+	if w == nil {
+		return err
+	}
+	// Deliveries to an inactive webhook would wait until it is turned on
+	if !w.Active {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Message: "Webhook is inactive",
+		})
+	}
+
+	eventID := database.NewWebhookEventID()
+	payload, err := webhookPayload(eventID, webhookPing, time.Now().UTC(), map[string]interface{}{
+		"webhook_id": w.ID,
+	})
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to queue delivery",
+		})
+	}
+
+	return queueDelivery(c, &models.WebhookDelivery{
+		WebhookID: w.ID,
+		EventID:   eventID,
+		Event:     webhookPing,
+		Payload:   payload,
+	}, "Ping queued successfully")
+}
+
+// List a webhook's deliveries, newest first: ?status=, ?limit= and ?before=
+// the ID of the last delivery of the previous page
+func GetWebhookDeliveriesHandler(c fiber.Ctx) error {
+	w, err := getOwnedWebhook(c)
+	if w == nil {
+		return err
+	}
+
+	invalid := func(message string) error {
+		return c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+
+	status := c.Query("status")
+	if status != "" && status != models.DeliveryPending && status != models.DeliverySucceeded &&
+		status != models.DeliveryFailed {
+		return invalid("Status must be pending, succeeded or failed")
+	}
+	limit := defaultDeliveryListLimit
+	if c.Query("limit") != "" {
+		n, err := strconv.Atoi(c.Query("limit"))
+		if err != nil || n < 1 || n > maxDeliveryListLimit {
+			return invalid(fmt.Sprintf("Limit must be between 1 and %d", maxDeliveryListLimit))
+		}
+		limit = n
+	}
+	beforeID := 0
+	if c.Query("before") != "" {
+		n, err := strconv.Atoi(c.Query("before"))
+		if err != nil || n < 1 {
+			return invalid("Invalid before ID")
+		}
+		beforeID = n
+	}
+
+	deliveries, err := database.GetWebhookDeliveries(w.ID, status, beforeID, limit)
+	if err != nil {
+		return c.Status(500).JSON(models.APIResponse{
+			Success: false,
+			Message: "Failed to get deliveries",
+		})
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    deliveries,
+	})
+}
+
+// getWebhookDelivery loads the :delivery_id delivery of a webhook
+func getWebhookDelivery(c fiber.Ctx, w *models.Webhook) (*models.WebhookDelivery, error) {
+	deliveryID, err := strconv.Atoi(c.Params("delivery_id"))
+	if err != nil {
+		return nil, c.Status(400).JSON(models.APIResponse{
+			Success: false,
+			Message: "Invalid delivery ID",
+		})
+	}
+
+	d, err := database.GetWebhookDelivery(w.ID, deliveryID)
+	if err != nil {
+		status, message := 500, "Failed to get delivery"
+		if err == sql.ErrNoRows {
+			status, message = 404, "Delivery not found"
+		}
+		return nil, c.Status(status).JSON(models.APIResponse{
+			Success: false,
+			Message: message,
+		})
+	}
+	return d, nil
+}
+
+// Get a delivery with its payload and the endpoint's last response
+func GetWebhookDeliveryHandler(c fiber.Ctx) error {
+	w, err := getOwnedWebhook(c)
+	if w == nil {
+		return err
+	}
+	d, err := getWebhookDelivery(c, w)
+	if d == nil {
+		return err
+	}
+
+	return c.JSON(models.APIResponse{
+		Success: true,
+		Data:    d,
+	})
+}
+
+// Send a delivery's event again as a new delivery with the same event ID,
+// so receivers can tell it is a repeat
+func RedeliverWebhookHandler(c fiber.Ctx) error {
+	w, err := getOwnedWebhook(c)
+	if w == nil {
+		return err
+	}
+	d, err := getWebhookDelivery(c, w)
+	if d == nil {
+		return err
+	}
+	// Deliveries to an inactive webhook would wait until it is turned on
+	if !w.Active {
+		return c.Status(409).JSON(models.APIResponse{
+			Success: false,
+			Message: "Webhook is inactive",
+		})
+	}
+
+	return queueDelivery(c, &models.WebhookDelivery{
+		WebhookID:    w.ID,
+		EventID:      d.EventID,
+		Event:        d.Event,
+		Payload:      d.Payload,
+		RedeliveryOf: d.ID,
+	}, "Redelivery queued successfully")
+}
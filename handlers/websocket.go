@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// The parts of RFC 6455 needed for a server that pushes messages and
+// answers control frames
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xA
+)
+
+// WebSocket close codes
+const (
+	wsCloseNormal      = 1000
+	wsCloseProtocol    = 1002
+	wsCloseTooBig      = 1009
+	wsCloseTryAgain    = 1013
+	maxWSFramePayload  = 4096 // clients only send control frames and small messages
+	websocketWriteWait = 10 * time.Second
+)
+
+// wsError is a protocol violation by the client, closed with code
+type wsError struct {
+	code   uint16
+	reason string
+}
+
+func (e *wsError) Error() string {
+	return e.reason
+}
+
+// checkWebSocketUpgrade validates a WebSocket opening handshake and returns
+// the Sec-WebSocket-Accept value for it. The status to reply with is in the
+// returned fiber.Error.
+func checkWebSocketUpgrade(c fiber.Ctx) (string, error) {
+	if c.Method() != fiber.MethodGet || !strings.EqualFold(c.Get("Upgrade"), "websocket") ||
+		!headerHasToken(c.Get("Connection"), "upgrade") {
+		c.Set("Upgrade", "websocket")
+		return "", fiber.NewError(fiber.StatusUpgradeRequired, "Expected a WebSocket upgrade")
+	}
+	if c.Get("Sec-WebSocket-Version") != "13" {
+		c.Set("Sec-WebSocket-Version", "13")
+		return "", fiber.NewError(400, "Unsupported WebSocket version")
+	}
+	key := c.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return "", fiber.NewError(400, "Invalid Sec-WebSocket-Key")
+	}
+
+	// Browsers send the session cookie with WebSocket requests from any
+	// page, so only accept pages served from this host
+	if origin := c.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !strings.EqualFold(u.Host, c.Host()) {
+			return "", fiber.NewError(403, "Origin not allowed")
+		}
+	}
+
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// headerHasToken reports whether a comma separated header contains token
+func headerHasToken(header, token string) bool {
+	for _, part := range strings.Split(header, ",") {
+		if strings.EqualFold(strings.TrimSpace(part), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// wsConn is the server side of a WebSocket connection. Writes may come from
+// several goroutines; reads from one.
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	mu   sync.Mutex
+}
+
+func newWSConn(conn net.Conn) *wsConn {
+	return &wsConn{conn: conn, r: bufio.NewReader(conn)}
+}
+
+// writeFrame sends one unfragmented, unmasked frame
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	header := make([]byte, 2, 10)
+	header[0] = 0x80 | opcode
+	switch n := len(payload); {
+	case n < 126:
+		header[1] = byte(n)
+	case n <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.conn.SetWriteDeadline(time.Now().Add(websocketWriteWait))
+	buffers := net.Buffers{header, payload}
+	_, err := buffers.WriteTo(ws.conn)
+	return err
+}
+
+// writeClose sends a close frame with a status code and reason
+func (ws *wsConn) writeClose(code uint16, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, code)
+	return ws.writeFrame(wsClose, append(payload, reason...))
+}
+
+// readFrame reads one frame from the client and unmasks its payload
+func (ws *wsConn) readFrame() (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	if header[0]&0x70 != 0 {
+		return 0, nil, &wsError{wsCloseProtocol, "reserved bits set"}
+	}
+	opcode := header[0] & 0x0F
+	fin := header[0]&0x80 != 0
+	if header[1]&0x80 == 0 {
+		return 0, nil, &wsError{wsCloseProtocol, "client frames must be masked"}
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if opcode >= wsClose && (length > 125 || !fin) {
+		return 0, nil, &wsError{wsCloseProtocol, "invalid control frame"}
+	}
+	if length > maxWSFramePayload {
+		return 0, nil, &wsError{wsCloseTooBig, "message too big"}
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(ws.r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	switch opcode {
+	case wsContinuation, wsText, wsBinary, wsClose, wsPing, wsPong:
+		return opcode, payload, nil
+	}
+	return 0, nil, &wsError{wsCloseProtocol, "unknown opcode"}
+}
+
+// readLoop answers pings and close frames until the connection ends or
+// idle passes without any frame from the client, then reports why on done.
+// Data messages from the client are ignored.
+func (ws *wsConn) readLoop(idle time.Duration, done chan<- error) {
+	for {
+		ws.conn.SetReadDeadline(time.Now().Add(idle))
+		opcode, payload, err := ws.readFrame()
+		if err != nil {
+			var protocolErr *wsError
+			if errors.As(err, &protocolErr) {
+				ws.writeClose(protocolErr.code, protocolErr.reason)
+			}
+			done <- err
+			return
+		}
+
+		switch opcode {
+		case wsPing:
+			if err := ws.writeFrame(wsPong, payload); err != nil {
+				done <- err
+				return
+			}
+		case wsClose:
+			// Echo the client's status code, as the closing handshake asks
+			code := uint16(wsCloseNormal)
+			if len(payload) >= 2 {
+				code = binary.BigEndian.Uint16(payload)
+			}
+			ws.writeClose(code, "")
+			done <- io.EOF
+			return
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package mail
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Mailer delivers email
+type Mailer interface {
+	// Send delivers one message to one recipient
+	Send(msg *Message) error
+}
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// ErrInvalidHeader is returned for addresses or subjects that would break
+// the message headers
+var ErrInvalidHeader = errors.New("mail: line breaks are not allowed in headers")
+
+// Sender is the mail backend used by the application
+var Sender Mailer
+
+// InitMail configures Sender from the environment. MAIL_BACKEND selects
+// "none" (default) to drop messages, "smtp", or "log", which only notes each
+// message's subject in the server log, for development.
+func InitMail() error {
+	switch backend := os.Getenv("MAIL_BACKEND"); backend {
+	case "":
+		log.Println("Warning: MAIL_BACKEND is not set, outgoing mail will not be sent")
+		Sender = NoopMailer{}
+	case "none":
+		Sender = NoopMailer{}
+	case "log":
+		Sender = LogMailer{}
+	case "smtp":
+		port := 587
+		if value := os.Getenv("SMTP_PORT"); value != "" {
+			p, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid SMTP_PORT %q", value)
+			}
+			port = p
+		}
+		smtp, err := NewSMTPMailer(SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		})
+		if err != nil {
+			return err
+		}
+		Sender = smtp
+	default:
+		return fmt.Errorf("unknown MAIL_BACKEND %q", backend)
+	}
+	return nil
+}
+
+// checkHeaders rejects header values that could inject more headers
+func checkHeaders(values ...string) error {
+	for _, value := range values {
+		if strings.ContainsAny(value, "\r\n") {
+			return ErrInvalidHeader
+		}
+	}
+	return nil
+}
+
+// LogMailer logs messages instead of sending them, for development. Only
+// the subject is logged, so addresses and content stay out of the logs.
+type LogMailer struct{}
+
+func (LogMailer) Send(msg *Message) error {
+	if err := checkHeaders(msg.To, msg.Subject); err != nil {
+		return err
+	}
+	log.Printf("Mail to 1 recipient: %s", msg.Subject)
+	return nil
+}
+
+// NoopMailer drops every message
+type NoopMailer struct{}
+
+func (NoopMailer) Send(msg *Message) error {
+	return nil
+}
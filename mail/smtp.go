@@ -0,0 +1,89 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPConfig holds the settings for an SMTP relay
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // empty to send without authentication
+	Password string
+	From     string
+}
+
+// SMTPMailer sends mail through an SMTP relay. STARTTLS is used when the
+// server offers it, which net/smtp requires before sending credentials.
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+	from string
+	host string
+}
+
+func NewSMTPMailer(cfg SMTPConfig) (*SMTPMailer, error) {
+	if cfg.Host == "" || cfg.From == "" {
+		return nil, errors.New("smtp mail needs SMTP_HOST and MAIL_FROM")
+	}
+	if err := checkHeaders(cfg.From); err != nil {
+		return nil, err
+	}
+
+	m := &SMTPMailer{
+		addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		from: cfg.From,
+		host: cfg.Host,
+	}
+	if cfg.Username != "" {
+		m.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return m, nil
+}
+
+// envelopeAddress returns the bare address of "Name <address>"
+func envelopeAddress(address string) string {
+	if start := strings.LastIndex(address, "<"); start >= 0 {
+		if end := strings.LastIndex(address, ">"); end > start {
+			return address[start+1 : end]
+		}
+	}
+	return strings.TrimSpace(address)
+}
+
+func (m *SMTPMailer) Send(msg *Message) error {
+	if err := checkHeaders(msg.To, msg.Subject); err != nil {
+		return err
+	}
+
+	id := make([]byte, 12)
+	rand.Read(id)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), m.host)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	body := quotedprintable.NewWriter(&b)
+	text := strings.ReplaceAll(msg.Text, "\r\n", "\n")
+	body.Write([]byte(strings.ReplaceAll(text, "\n", "\r\n")))
+	body.Close()
+
+	return smtp.SendMail(m.addr, m.auth, envelopeAddress(m.from), []string{envelopeAddress(msg.To)}, b.Bytes())
+}
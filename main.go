@@ -7,7 +7,9 @@ import (
 
 	"educational-platform/database"
 	"educational-platform/handlers"
+	"educational-platform/mail"
 	"educational-platform/models"
+	"educational-platform/storage"
 
 	"github.com/gofiber/fiber/v3"
 	"github.com/gofiber/fiber/v3/middleware/cors"
@@ -25,8 +27,48 @@ func main() {
 	// Initialize authentication
 	handlers.InitAuth()
 
+	// Initialize file storage
+	err = storage.InitStorage()
+	if err != nil {
+		log.Fatal("Failed to initialize storage:", err)
+	}
+
+	// Initialize upload size limits and storage quotas
+	err = handlers.InitUploads()
+	if err != nil {
+		log.Fatal("Failed to initialize uploads:", err)
+	}
+
+	// Load the key that signs course certificates
+	err = handlers.InitCertificates()
+	if err != nil {
+		log.Fatal("Failed to initialize certificates:", err)
+	}
+
+	// Initialize outgoing mail
+	err = mail.InitMail()
+	if err != nil {
+		log.Fatal("Failed to initialize mail:", err)
+	}
+
+	// Publish scheduled videos when their time comes
+	handlers.StartPublishScheduler()
+
+	// Send scheduled announcements when their time comes
+	handlers.StartAnnouncementScheduler()
+
+	// Send queued webhook deliveries and retry failed ones
+	handlers.StartWebhookDispatcher()
+
+	// Remove resumable uploads that were abandoned
+	handlers.StartTusUploadCleaner()
+
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
+		// Stream large request bodies so uploads are not buffered in memory;
+		// upload handlers enforce their own size limits while reading
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 		ErrorHandler: func(c fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {
@@ -43,8 +85,11 @@ func main() {
 	app.Use(logger.New())
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: []string{"*"},
-		AllowMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization"},
+		AllowMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"},
+		AllowHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization",
+			"Tus-Resumable", "Upload-Length", "Upload-Offset", "Upload-Metadata", "Upload-Checksum"},
+		ExposeHeaders: []string{"Location", "Tus-Resumable", "Tus-Version", "Tus-Extension",
+			"Tus-Checksum-Algorithm", "Tus-Max-Size", "Upload-Length", "Upload-Offset"},
 	}))
 
 	// API Routes
@@ -62,8 +107,88 @@ func main() {
 	teacher.Use(handlers.TeacherAuthMiddleware)
 	teacher.Get("/dashboard", handlers.TeacherDashboardHandler)
 	teacher.Post("/upload", handlers.UploadVideoHandler)
+
+	// Resumable uploads (tus 1.0)
+	teacher.Options("/uploads", handlers.TusOptionsHandler)
+	teacher.Get("/uploads", handlers.ListTusUploadsHandler)
+	teacher.Post("/uploads", handlers.TusCreateHandler)
+	teacher.Head("/uploads/:id", handlers.TusHeadHandler)
+	teacher.Patch("/uploads/:id", handlers.TusPatchHandler)
+	teacher.Delete("/uploads/:id", handlers.TusDeleteHandler)
+
 	teacher.Get("/videos", handlers.GetTeacherVideosHandler)
+	teacher.Patch("/videos/:id", handlers.UpdateVideoHandler)
 	teacher.Delete("/videos/:id", handlers.DeleteVideoHandler)
+	teacher.Put("/videos/:id/file", handlers.ReplaceVideoFileHandler)
+	teacher.Post("/videos/:id/share-token", handlers.ResetShareTokenHandler)
+	teacher.Get("/videos/:id/views", handlers.GetVideoViewsHandler)
+	teacher.Get("/videos/:id/revisions", handlers.GetVideoRevisionsHandler)
+	teacher.Delete("/videos/:id/revisions/:revision_id", handlers.DeleteVideoRevisionHandler)
+	teacher.Post("/videos/:id/thumbnail", handlers.UploadThumbnailHandler)
+	teacher.Post("/videos/:id/captions", handlers.UploadCaptionHandler)
+	teacher.Delete("/videos/:id/captions/:lang", handlers.DeleteCaptionHandler)
+	teacher.Put("/videos/:id/chapters", handlers.UpdateChaptersHandler)
+	teacher.Post("/videos/:id/quizzes", handlers.CreateVideoQuizHandler)
+	teacher.Get("/courses", handlers.GetTeacherCoursesHandler)
+	teacher.Post("/courses", handlers.CreateCourseHandler)
+	teacher.Get("/courses/:id", handlers.GetTeacherCourseHandler)
+	teacher.Patch("/courses/:id", handlers.UpdateCourseHandler)
+	teacher.Delete("/courses/:id", handlers.DeleteCourseHandler)
+	teacher.Post("/courses/:id/cover", handlers.UploadCourseCoverHandler)
+	teacher.Put("/courses/:id/order", handlers.ReorderCourseHandler)
+	teacher.Post("/courses/:id/invite-code", handlers.ResetInviteCodeHandler)
+	teacher.Get("/courses/:id/enrollments", handlers.GetCourseEnrollmentsHandler)
+	teacher.Patch("/courses/:id/enrollments/:enrollment_id", handlers.UpdateEnrollmentHandler)
+	teacher.Delete("/courses/:id/enrollments/:enrollment_id", handlers.DeleteEnrollmentHandler)
+	teacher.Post("/courses/:id/sections", handlers.CreateSectionHandler)
+	teacher.Patch("/courses/:id/sections/:section_id", handlers.UpdateSectionHandler)
+	teacher.Delete("/courses/:id/sections/:section_id", handlers.DeleteSectionHandler)
+	teacher.Post("/courses/:id/sections/:section_id/lessons", handlers.CreateLessonHandler)
+	teacher.Patch("/courses/:id/lessons/:lesson_id", handlers.UpdateLessonHandler)
+	teacher.Delete("/courses/:id/lessons/:lesson_id", handlers.DeleteLessonHandler)
+	teacher.Put("/courses/:id/lessons/:lesson_id/prerequisites", handlers.UpdatePrerequisitesHandler)
+	teacher.Post("/courses/:id/lessons/:lesson_id/quizzes", handlers.CreateLessonQuizHandler)
+	teacher.Get("/courses/:id/certificates", handlers.GetCourseCertificatesHandler)
+	teacher.Delete("/courses/:id/certificates/:certificate_id", handlers.RevokeCertificateHandler)
+	teacher.Get("/quizzes", handlers.GetTeacherQuizzesHandler)
+	teacher.Get("/quizzes/:id", handlers.GetTeacherQuizHandler)
+	teacher.Patch("/quizzes/:id", handlers.UpdateQuizHandler)
+	teacher.Delete("/quizzes/:id", handlers.DeleteQuizHandler)
+	teacher.Put("/quizzes/:id/questions", handlers.UpdateQuizQuestionsHandler)
+	teacher.Get("/quizzes/:id/attempts", handlers.GetQuizAttemptsHandler)
+	teacher.Get("/assignments", handlers.GetTeacherAssignmentsHandler)
+	teacher.Post("/assignments", handlers.CreateAssignmentHandler)
+	teacher.Get("/assignments/:id", handlers.GetTeacherAssignmentHandler)
+	teacher.Patch("/assignments/:id", handlers.UpdateAssignmentHandler)
+	teacher.Delete("/assignments/:id", handlers.DeleteAssignmentHandler)
+	teacher.Get("/assignments/:id/submissions", handlers.GetAssignmentSubmissionsHandler)
+	teacher.Get("/assignments/:id/submissions/:submission_id/file", handlers.GetSubmissionFileHandler)
+	teacher.Put("/assignments/:id/submissions/:submission_id/grade", handlers.GradeSubmissionHandler)
+	teacher.Get("/gradebook", handlers.GetTeacherGradebookHandler)
+	teacher.Get("/gradebook/export", handlers.ExportGradebookHandler)
+	teacher.Post("/gradebook/categories", handlers.CreateGradeCategoryHandler)
+	teacher.Patch("/gradebook/categories/:id", handlers.UpdateGradeCategoryHandler)
+	teacher.Delete("/gradebook/categories/:id", handlers.DeleteGradeCategoryHandler)
+	teacher.Get("/comments", handlers.GetTeacherQuestionsHandler)
+	teacher.Patch("/comments/:id", handlers.ModerateCommentHandler)
+	teacher.Get("/announcements", handlers.GetAnnouncementsHandler)
+	teacher.Post("/announcements", handlers.CreateAnnouncementHandler)
+	teacher.Get("/announcements/:id", handlers.GetAnnouncementHandler)
+	teacher.Patch("/announcements/:id", handlers.UpdateAnnouncementHandler)
+	teacher.Delete("/announcements/:id", handlers.DeleteAnnouncementHandler)
+	teacher.Post("/announcements/:id/send", handlers.SendAnnouncementHandler)
+	teacher.Get("/message-settings", handlers.GetMessageSettingsHandler)
+	teacher.Put("/message-settings", handlers.UpdateMessageSettingsHandler)
+	teacher.Get("/webhooks", handlers.GetWebhooksHandler)
+	teacher.Post("/webhooks", handlers.CreateWebhookHandler)
+	teacher.Get("/webhooks/:id", handlers.GetWebhookHandler)
+	teacher.Patch("/webhooks/:id", handlers.UpdateWebhookHandler)
+	teacher.Delete("/webhooks/:id", handlers.DeleteWebhookHandler)
+	teacher.Post("/webhooks/:id/secret", handlers.RotateWebhookSecretHandler)
+	teacher.Post("/webhooks/:id/ping", handlers.PingWebhookHandler)
+	teacher.Get("/webhooks/:id/deliveries", handlers.GetWebhookDeliveriesHandler)
+	teacher.Get("/webhooks/:id/deliveries/:delivery_id", handlers.GetWebhookDeliveryHandler)
+	teacher.Post("/webhooks/:id/deliveries/:delivery_id/redeliver", handlers.RedeliverWebhookHandler)
 	teacher.Get("/students", handlers.GetTeacherStudentsHandler)
 	teacher.Get("/analytics", handlers.GetVideoAnalyticsHandler)
 
@@ -73,14 +198,86 @@ func main() {
 	student.Get("/dashboard", handlers.StudentDashboardHandler)
 	student.Get("/videos", handlers.GetStudentVideosHandler)
 	student.Post("/watch/:id", handlers.WatchVideoHandler)
+	student.Post("/videos/:id/progress", handlers.UpdateProgressHandler)
 	student.Get("/subscriptions", handlers.GetStudentSubscriptionsHandler)
+	student.Get("/courses", handlers.GetStudentCoursesHandler)
+	student.Get("/courses/:id", handlers.GetStudentCourseHandler)
+	student.Post("/courses/:id/enroll", handlers.EnrollCourseHandler)
+	student.Delete("/courses/:id/enroll", handlers.LeaveCourseHandler)
+	student.Get("/enrollments", handlers.GetStudentEnrollmentsHandler)
+	student.Get("/courses/:id/certificate", handlers.GetCourseCertificateHandler)
+	student.Post("/courses/:id/certificate", handlers.IssueCertificateHandler)
+	student.Get("/certificates", handlers.GetStudentCertificatesHandler)
+	student.Get("/courses/:id/notes/export", handlers.ExportCourseNotesHandler)
+	student.Get("/videos/:id/notes", handlers.GetVideoNotesHandler)
+	student.Post("/videos/:id/notes", handlers.CreateNoteHandler)
+	student.Get("/notes", handlers.SearchNotesHandler)
+	student.Patch("/notes/:id", handlers.UpdateNoteHandler)
+	student.Delete("/notes/:id", handlers.DeleteNoteHandler)
+	student.Get("/quizzes/:id", handlers.GetStudentQuizHandler)
+	student.Get("/quizzes/:id/attempts", handlers.GetStudentQuizAttemptsHandler)
+	student.Post("/quizzes/:id/attempts", handlers.StartQuizAttemptHandler)
+	student.Post("/quizzes/:id/attempts/:attempt_id/submit", handlers.SubmitQuizAttemptHandler)
+	student.Get("/assignments", handlers.GetStudentAssignmentsHandler)
+	student.Get("/assignments/:id", handlers.GetStudentAssignmentHandler)
+	student.Post("/assignments/:id/submissions", handlers.SubmitAssignmentHandler)
+	student.Get("/assignments/:id/submissions/:submission_id/file", handlers.GetStudentSubmissionFileHandler)
+	student.Get("/gradebook", handlers.GetStudentGradebookHandler)
+	student.Get("/announcements", handlers.GetStudentAnnouncementsHandler)
+	student.Post("/announcements/read-all", handlers.MarkAllAnnouncementsReadHandler)
+	student.Post("/announcements/:id/read", handlers.MarkAnnouncementReadHandler)
+	student.Delete("/announcements/:id/read", handlers.MarkAnnouncementUnreadHandler)
 	student.Post("/subscribe/:teacher_id", handlers.SubscribeToTeacherHandler)
 	student.Delete("/unsubscribe/:teacher_id", handlers.UnsubscribeFromTeacherHandler)
 
+	// Real-time event streams, for teachers and students
+	api.Get("/events", handlers.AuthMiddleware, handlers.EventStreamHandler)
+	api.Get("/events/ws", handlers.AuthMiddleware, handlers.EventSocketHandler)
+
+	// Notification routes, for teachers and students
+	api.Get("/notifications", handlers.AuthMiddleware, handlers.GetNotificationsHandler)
+	api.Get("/notifications/unread-count", handlers.AuthMiddleware, handlers.GetUnreadNotificationCountHandler)
+	api.Post("/notifications/read-all", handlers.AuthMiddleware, handlers.MarkAllNotificationsReadHandler)
+	api.Get("/notifications/preferences", handlers.AuthMiddleware, handlers.GetNotificationPreferencesHandler)
+	api.Put("/notifications/preferences", handlers.AuthMiddleware, handlers.UpdateNotificationPreferencesHandler)
+	api.Post("/notifications/:id/read", handlers.AuthMiddleware, handlers.MarkNotificationReadHandler)
+	api.Delete("/notifications/:id/read", handlers.AuthMiddleware, handlers.MarkNotificationUnreadHandler)
+	api.Delete("/notifications/:id", handlers.AuthMiddleware, handlers.DeleteNotificationHandler)
+
+	// Direct messages between students and the teachers they subscribe to
+	api.Get("/conversations", handlers.AuthMiddleware, handlers.GetConversationsHandler)
+	api.Post("/conversations", handlers.AuthMiddleware, handlers.StartConversationHandler)
+	api.Get("/conversations/:id", handlers.AuthMiddleware, handlers.GetConversationHandler)
+	api.Get("/conversations/:id/messages", handlers.AuthMiddleware, handlers.GetMessagesHandler)
+	api.Post("/conversations/:id/messages", handlers.AuthMiddleware, handlers.SendMessageHandler)
+	api.Get("/conversations/:id/messages/:message_id/attachment", handlers.AuthMiddleware,
+		handlers.GetMessageAttachmentHandler)
+	api.Post("/conversations/:id/read", handlers.AuthMiddleware, handlers.MarkConversationReadHandler)
+	api.Post("/conversations/:id/block", handlers.AuthMiddleware, handlers.BlockConversationHandler)
+	api.Delete("/conversations/:id/block", handlers.AuthMiddleware, handlers.UnblockConversationHandler)
+
+	// Comment routes, for teachers and students who may watch the video
+	api.Get("/videos/:id/comments", handlers.AuthMiddleware, handlers.GetVideoCommentsHandler)
+	api.Post("/videos/:id/comments", handlers.AuthMiddleware, handlers.CreateCommentHandler)
+	api.Patch("/comments/:id", handlers.AuthMiddleware, handlers.UpdateCommentHandler)
+	api.Delete("/comments/:id", handlers.AuthMiddleware, handlers.DeleteCommentHandler)
+	api.Put("/comments/:id/vote", handlers.AuthMiddleware, handlers.UpvoteCommentHandler)
+	api.Delete("/comments/:id/vote", handlers.AuthMiddleware, handlers.RemoveCommentVoteHandler)
+	api.Put("/comments/:id/accept", handlers.AuthMiddleware, handlers.AcceptCommentHandler)
+	api.Delete("/comments/:id/accept", handlers.AuthMiddleware, handlers.UnacceptCommentHandler)
+
 	// Public API routes
 	api.Get("/teachers", handlers.GetTeachersHandler)
+	api.Get("/videos/public", handlers.GetPublicVideosHandler)
 	api.Get("/video/:id", handlers.ServeVideoHandler)
+	api.Get("/course/:id/cover", handlers.ServeCourseCoverHandler)
 	api.Get("/video/:id/thumbnail", handlers.ServeThumbnailHandler)
+	api.Get("/video/:id/storyboard.vtt", handlers.ServeStoryboardVTTHandler)
+	api.Get("/video/:id/storyboard/:sheet", handlers.ServeStoryboardSheetHandler)
+	api.Get("/video/:id/captions/:lang", handlers.ServeCaptionHandler)
+	api.Get("/video/:id/chapters.vtt", handlers.ServeChaptersVTTHandler)
+	api.Get("/certificates/:code", handlers.VerifyCertificateHandler)
+	api.Get("/certificates/:code/pdf", handlers.GetCertificatePDFHandler)
 
 	// Health check endpoint
 	app.Get("/health", func(c fiber.Ctx) error {
@@ -105,7 +302,7 @@ func main() {
 					"authentication": "/api/auth",
 					"teachers":       "/api/teacher",
 					"students":       "/api/student",
-					"public":         "/api/teachers, /api/video",
+					"public":         "/api/teachers, /api/video, /api/certificates",
 					"health":         "/health",
 				},
 				"documentation": "See README.md for API documentation",
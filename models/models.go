@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Teacher represents a teacher in the system
 type Teacher struct {
@@ -24,17 +27,109 @@ type Student struct {
 
 // Video represents a video uploaded by a teacher
 type Video struct {
-	ID            int       `json:"id"`
-	TeacherID     int       `json:"teacher_id"`
-	Title         string    `json:"title"`
-	Description   string    `json:"description"`
-	Filename      string    `json:"filename"`
-	FilePath      string    `json:"file_path"`
-	ThumbnailPath string    `json:"thumbnail_path"`
-	Duration      int       `json:"duration"` // in seconds
-	FileSize      int64     `json:"file_size"` // in bytes
-	CreatedAt     time.Time `json:"created_at"`
-	TeacherName   string    `json:"teacher_name,omitempty"` // For display purposes
+	ID             int            `json:"id"`
+	TeacherID      int            `json:"teacher_id"`
+	Title          string         `json:"title"`
+	Description    string         `json:"description"`
+	Filename       string         `json:"filename"`
+	FilePath       string         `json:"file_path"`
+	ThumbnailPath  string         `json:"thumbnail_path"`
+	Duration       int            `json:"duration"`  // in seconds
+	FileSize       int64          `json:"file_size"` // in bytes
+	CreatedAt      time.Time      `json:"created_at"`
+	TeacherName    string         `json:"teacher_name,omitempty"` // For display purposes
+	Status         string         `json:"status"`                 // draft, scheduled, published or archived
+	Visibility     string         `json:"visibility"`             // subscribers, public, unlisted or private
+	PublishAt      *time.Time     `json:"publish_at,omitempty"`   // when a scheduled video goes live
+	PublishedAt    *time.Time     `json:"published_at,omitempty"`
+	ShareToken     string         `json:"share_token,omitempty"` // link token for unlisted videos, teacher only
+	Captions       []Caption      `json:"captions,omitempty"`
+	Chapters       []Chapter      `json:"chapters,omitempty"`
+	Progress       *VideoProgress `json:"progress,omitempty"`         // the current student's, in student views
+	WatchSessionID int            `json:"watch_session_id,omitempty"` // returned by Watch Video for progress heartbeats
+	Views          *ViewStats     `json:"views,omitempty"`            // teacher video lists only
+	Quizzes        []Quiz         `json:"quizzes,omitempty"`          // published quizzes, returned by Watch Video
+}
+
+// VideoProgress records how much of a video a student has watched. Watched
+// intervals are merged, so rewatching a part does not count twice.
+type VideoProgress struct {
+	VideoID        int          `json:"video_id"`
+	Position       float64      `json:"position"` // resume position in seconds
+	WatchedSeconds float64      `json:"watched_seconds"`
+	Percent        float64      `json:"percent"`
+	Completed      bool         `json:"completed"`
+	CompletedAt    *time.Time   `json:"completed_at,omitempty"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	Intervals      [][2]float64 `json:"-"` // merged [start, end] pairs in seconds
+}
+
+// ProgressRequest is a playback heartbeat: the current position and the
+// parts of the video played since the last heartbeat
+type ProgressRequest struct {
+	Position  float64      `json:"position"`
+	Intervals [][2]float64 `json:"intervals"`
+	SessionID int          `json:"session_id"` // watch session from Watch Video, adds to its watch time
+}
+
+// WatchRequest optionally names the device a viewing happens on
+type WatchRequest struct {
+	Device string `json:"device"`
+}
+
+// Chapter is a titled section of a video. A chapter ends where the next one
+// starts, or at the end of the video.
+type Chapter struct {
+	ID        int    `json:"id"`
+	VideoID   int    `json:"video_id"`
+	Title     string `json:"title"`
+	StartTime int    `json:"start_time"` // in seconds
+	EndTime   int    `json:"end_time"`   // in seconds, 0 when the video's duration is unknown
+}
+
+// Video statuses
+const (
+	VideoStatusDraft     = "draft"
+	VideoStatusScheduled = "scheduled"
+	VideoStatusPublished = "published"
+	VideoStatusArchived  = "archived"
+)
+
+// Video visibility levels
+const (
+	VisibilitySubscribers = "subscribers"
+	VisibilityPublic      = "public"
+	VisibilityUnlisted    = "unlisted"
+	VisibilityPrivate     = "private"
+)
+
+// VideoPublishing holds a video's status and visibility settings
+type VideoPublishing struct {
+	Status     string     `json:"status"`
+	Visibility string     `json:"visibility"`
+	PublishAt  *time.Time `json:"publish_at,omitempty"`
+}
+
+// Caption is a WebVTT subtitle track for a video in one language
+type Caption struct {
+	ID        int       `json:"id"`
+	VideoID   int       `json:"video_id"`
+	Language  string    `json:"language"` // BCP 47 tag, e.g. "ar" or "en-US"
+	Label     string    `json:"label"`
+	FilePath  string    `json:"-"` // storage key of the .vtt file
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// VideoRevision is a media file a video used before it was replaced
+type VideoRevision struct {
+	ID         int       `json:"id"`
+	VideoID    int       `json:"video_id"`
+	Filename   string    `json:"filename"`
+	FilePath   string    `json:"file_path"`
+	Duration   int       `json:"duration"`  // in seconds
+	FileSize   int64     `json:"file_size"` // in bytes
+	ReplacedAt time.Time `json:"replaced_at"`
 }
 
 // Subscription represents a student's subscription to a teacher
@@ -47,14 +142,824 @@ type Subscription struct {
 	StudentName string    `json:"student_name,omitempty"` // For display purposes
 }
 
-// VideoView represents a student watching a video
+// VideoView summarizes a student's viewings of a video
 type VideoView struct {
-	ID        int       `json:"id"`
-	StudentID int       `json:"student_id"`
-	VideoID   int       `json:"video_id"`
-	WatchedAt time.Time `json:"watched_at"`
-	VideoTitle string   `json:"video_title,omitempty"` // For display purposes
-	StudentName string  `json:"student_name,omitempty"` // For display purposes
+	ID            int       `json:"id"`
+	StudentID     int       `json:"student_id"`
+	VideoID       int       `json:"video_id"`
+	WatchedAt     time.Time `json:"watched_at"`             // first viewing
+	VideoTitle    string    `json:"video_title,omitempty"`  // For display purposes
+	StudentName   string    `json:"student_name,omitempty"` // For display purposes
+	LastWatchedAt time.Time `json:"last_watched_at"`
+	ViewCount     int       `json:"view_count"`
+	WatchTime     float64   `json:"watch_time"` // seconds, across all sessions
+}
+
+// WatchSession is one viewing of a video, from opening it until the last
+// progress heartbeat
+type WatchSession struct {
+	ID             int       `json:"id"`
+	StudentID      int       `json:"student_id"`
+	VideoID        int       `json:"video_id"`
+	StartedAt      time.Time `json:"started_at"`
+	EndedAt        time.Time `json:"ended_at"`
+	Device         string    `json:"device"`
+	SecondsWatched float64   `json:"seconds_watched"`
+	StudentName    string    `json:"student_name,omitempty"` // For display purposes
+}
+
+// ViewStats counts the viewers, viewings and watch time of videos
+type ViewStats struct {
+	UniqueViewers int     `json:"unique_viewers"`
+	TotalViews    int     `json:"total_views"`
+	WatchTime     float64 `json:"watch_time"` // seconds
+}
+
+// Storyboard describes the sprite sheets of preview frames for a video
+type Storyboard struct {
+	VideoID    int    `json:"video_id"`
+	Interval   int    `json:"interval"` // seconds between frames
+	TileWidth  int    `json:"tile_width"`
+	TileHeight int    `json:"tile_height"`
+	Columns    int    `json:"columns"`
+	Rows       int    `json:"rows"`
+	FrameCount int    `json:"frame_count"`
+	SheetCount int    `json:"sheet_count"`
+	KeyPrefix  string `json:"-"` // sheets are stored as <prefix>/sheet_<n>.jpg
+}
+
+// Course statuses
+const (
+	CourseStatusDraft     = "draft"
+	CourseStatusPublished = "published"
+)
+
+// Course enrollment modes
+const (
+	EnrollmentOpen     = "open"     // students enroll themselves
+	EnrollmentApproval = "approval" // the teacher approves each request
+	EnrollmentInvite   = "invite"   // students need the course's invite code
+)
+
+// Enrollment statuses
+const (
+	EnrollmentStatusPending = "pending"
+	EnrollmentStatusActive  = "active"
+)
+
+// Course is a curriculum of videos arranged in sections
+type Course struct {
+	ID          int             `json:"id"`
+	TeacherID   int             `json:"teacher_id"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Status      string          `json:"status"` // draft or published
+	CoverPath   string          `json:"-"`      // storage key, "" without a cover
+	CoverURL    string          `json:"cover_url,omitempty"`
+	LessonCount int             `json:"lesson_count"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	TeacherName string          `json:"teacher_name,omitempty"` // For display purposes
+	Sections    []CourseSection `json:"sections,omitempty"`     // only in course outlines
+
+	Sequential       bool        `json:"sequential"`            // each lesson needs the one before it
+	EnrollmentMode   string      `json:"enrollment_mode"`       // open, approval or invite
+	InviteCode       string      `json:"invite_code,omitempty"` // only shown to the teacher
+	EnrollmentCap    int         `json:"enrollment_cap"`        // active enrollments, 0 for no limit
+	EnrollmentEndsAt *time.Time  `json:"enrollment_ends_at,omitempty"`
+	EnrolledCount    int         `json:"enrolled_count"`
+	Enrollment       *Enrollment `json:"enrollment,omitempty"` // the current student's, if any
+}
+
+// CourseSection is an ordered group of lessons within a course
+type CourseSection struct {
+	ID       int      `json:"id"`
+	CourseID int      `json:"course_id"`
+	Title    string   `json:"title"`
+	Position int      `json:"position"`
+	Lessons  []Lesson `json:"lessons"`
+}
+
+// Lesson places a video in a course section
+type Lesson struct {
+	ID          int    `json:"id"`
+	CourseID    int    `json:"course_id"`
+	SectionID   int    `json:"section_id"`
+	VideoID     int    `json:"video_id"`
+	Title       string `json:"title"` // the video's title unless overridden
+	Position    int    `json:"position"`
+	Duration    int    `json:"duration"`               // in seconds
+	VideoStatus string `json:"video_status,omitempty"` // teacher outlines only
+
+	Prerequisites []LessonPrerequisite `json:"prerequisites,omitempty"`
+	Completed     bool                 `json:"completed,omitempty"`     // student outlines only
+	Locked        bool                 `json:"locked,omitempty"`        // student outlines only
+	LockedReason  string               `json:"locked_reason,omitempty"` // why the lesson is locked
+}
+
+// Lesson prerequisite types
+const (
+	PrerequisiteLesson = "lesson" // another lesson must be completed first
+	PrerequisiteQuiz   = "quiz"   // a quiz must be passed first
+	PrerequisiteDate   = "date"   // the lesson opens at a set time
+)
+
+// LessonPrerequisite is a rule that must be met before a lesson unlocks
+type LessonPrerequisite struct {
+	ID                  int        `json:"id"`
+	LessonID            int        `json:"lesson_id"`
+	Type                string     `json:"type"` // lesson, quiz or date
+	RequiredLessonID    int        `json:"required_lesson_id,omitempty"`
+	RequiredLessonTitle string     `json:"required_lesson_title,omitempty"`
+	RequiredQuizID      int        `json:"required_quiz_id,omitempty"`
+	RequiredQuizTitle   string     `json:"required_quiz_title,omitempty"`
+	AvailableAt         *time.Time `json:"available_at,omitempty"`
+	Met                 *bool      `json:"met,omitempty"` // student outlines only
+
+	QuizLessonID int    `json:"-"` // the lesson of this course the required quiz belongs to, 0 if none
+	QuizStatus   string `json:"-"`
+}
+
+// PrerequisitesRequest replaces all prerequisites of a lesson
+type PrerequisitesRequest struct {
+	Prerequisites []struct {
+		Type             string     `json:"type"`
+		RequiredLessonID int        `json:"required_lesson_id"`
+		RequiredQuizID   int        `json:"required_quiz_id"`
+		AvailableAt      *time.Time `json:"available_at"`
+	} `json:"prerequisites"`
+}
+
+// CourseRequest creates or updates a course; omitted fields are kept on update
+type CourseRequest struct {
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	Status      *string `json:"status"`
+	Sequential  *bool   `json:"sequential"`
+
+	EnrollmentMode   *string `json:"enrollment_mode"`
+	EnrollmentCap    *int    `json:"enrollment_cap"`
+	EnrollmentEndsAt *string `json:"enrollment_ends_at"` // RFC 3339, "" removes the end date
+}
+
+// Enrollment gives a student access to a course's videos
+type Enrollment struct {
+	ID          int        `json:"id"`
+	CourseID    int        `json:"course_id"`
+	StudentID   int        `json:"student_id"`
+	Status      string     `json:"status"` // pending or active
+	RequestedAt time.Time  `json:"requested_at"`
+	EnrolledAt  *time.Time `json:"enrolled_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	StudentName string     `json:"student_name,omitempty"` // For display purposes
+	CourseTitle string     `json:"course_title,omitempty"` // For display purposes
+}
+
+// EnrollRequest is sent by a student to join a course
+type EnrollRequest struct {
+	InviteCode string `json:"invite_code"`
+}
+
+// EnrollmentUpdateRequest approves an enrollment or changes when it expires
+type EnrollmentUpdateRequest struct {
+	Status    *string `json:"status"`
+	ExpiresAt *string `json:"expires_at"` // RFC 3339, "" for no expiry
+}
+
+// SectionRequest creates or renames a course section
+type SectionRequest struct {
+	Title string `json:"title"`
+}
+
+// LessonRequest adds a lesson or changes its title or section
+type LessonRequest struct {
+	VideoID   int     `json:"video_id"`
+	SectionID int     `json:"section_id"`
+	Title     *string `json:"title"` // "" uses the video's title
+}
+
+// CourseOrderRequest gives the complete order of a course's sections and lessons
+type CourseOrderRequest struct {
+	Sections []struct {
+		ID      int   `json:"id"`
+		Lessons []int `json:"lessons"`
+	} `json:"sections"`
+}
+
+// Quiz statuses
+const (
+	QuizStatusDraft     = "draft"
+	QuizStatusPublished = "published"
+)
+
+// Quiz question types
+const (
+	QuestionMultipleChoice = "multiple_choice" // one correct option
+	QuestionMultiSelect    = "multi_select"    // every correct option and no other
+	QuestionTrueFalse      = "true_false"
+	QuestionNumeric        = "numeric"    // correct within a tolerance
+	QuestionShortText      = "short_text" // matches an accepted answer, ignoring case and spacing
+)
+
+// Quiz attempt statuses
+const (
+	AttemptInProgress = "in_progress"
+	AttemptSubmitted  = "submitted"
+	AttemptExpired    = "expired" // the time limit passed before it was submitted
+)
+
+// Quiz checks understanding of a video. Lesson quizzes belong to one lesson
+// of a course and are only offered to students enrolled in it.
+type Quiz struct {
+	ID               int                 `json:"id"`
+	TeacherID        int                 `json:"teacher_id"`
+	VideoID          int                 `json:"video_id"`
+	LessonID         int                 `json:"lesson_id,omitempty"`
+	CourseID         int                 `json:"course_id,omitempty"`   // of lesson quizzes
+	CategoryID       int                 `json:"category_id,omitempty"` // gradebook category
+	Title            string              `json:"title"`
+	Description      string              `json:"description"`
+	Status           string              `json:"status"`              // draft or published
+	Timestamp        int                 `json:"timestamp,omitempty"` // seconds into the video where the quiz pops up, 0 for none
+	MaxAttempts      int                 `json:"max_attempts"`        // 0 for no limit
+	TimeLimit        int                 `json:"time_limit"`          // seconds per attempt, 0 for no limit
+	ShuffleQuestions bool                `json:"shuffle_questions"`
+	PassPercent      int                 `json:"pass_percent"`
+	QuestionCount    int                 `json:"question_count"`
+	MaxScore         int                 `json:"max_score"`
+	CreatedAt        time.Time           `json:"created_at"`
+	UpdatedAt        time.Time           `json:"updated_at"`
+	Questions        []QuizQuestion      `json:"questions,omitempty"` // teacher views only
+	Attempts         *QuizAttemptSummary `json:"attempts,omitempty"`  // the current student's
+}
+
+// QuizAttemptSummary sums up a student's attempts at a quiz
+type QuizAttemptSummary struct {
+	Used        int     `json:"used"`
+	BestPercent float64 `json:"best_percent"`
+	Passed      bool    `json:"passed"`
+	InProgress  int     `json:"in_progress,omitempty"` // ID of an unfinished attempt
+}
+
+// QuizQuestion is one question of a quiz. Answer holds the correct answer in
+// the shape students answer with: an option index for multiple choice, a
+// list of option indexes for multi-select, true or false, a number, or the
+// list of accepted short-text answers.
+type QuizQuestion struct {
+	ID        int             `json:"id"`
+	Position  int             `json:"position"`
+	Type      string          `json:"type"`
+	Prompt    string          `json:"prompt"`
+	Options   []string        `json:"options,omitempty"`
+	Points    int             `json:"points"`
+	Answer    json.RawMessage `json:"answer,omitempty"`    // teacher views only
+	Tolerance float64         `json:"tolerance,omitempty"` // numeric questions, teacher views only
+}
+
+// QuizAttempt is one go at a quiz. Questions are listed, without answers,
+// while the attempt is in progress.
+type QuizAttempt struct {
+	ID          int                `json:"id"`
+	QuizID      int                `json:"quiz_id"`
+	StudentID   int                `json:"student_id"`
+	Status      string             `json:"status"` // in_progress, submitted or expired
+	StartedAt   time.Time          `json:"started_at"`
+	ExpiresAt   *time.Time         `json:"expires_at,omitempty"` // deadline under a time limit
+	SubmittedAt *time.Time         `json:"submitted_at,omitempty"`
+	Score       int                `json:"score"`
+	MaxScore    int                `json:"max_score"`
+	Percent     float64            `json:"percent"`
+	Passed      bool               `json:"passed"`
+	Questions   []QuizQuestion     `json:"questions,omitempty"`
+	Results     []QuizAnswerResult `json:"results,omitempty"`
+	StudentName string             `json:"student_name,omitempty"` // For display purposes
+	QuestionIDs []int              `json:"-"`                      // the order questions are asked in
+}
+
+// QuizAnswerResult is how one answer of a submitted attempt was graded
+type QuizAnswerResult struct {
+	QuestionID int             `json:"question_id"`
+	Answer     json.RawMessage `json:"answer"` // null when unanswered
+	Correct    bool            `json:"correct"`
+	Points     int             `json:"points"`
+	MaxPoints  int             `json:"max_points"`
+}
+
+// QuizRequest creates or updates a quiz; omitted fields are kept on update
+type QuizRequest struct {
+	Title            *string `json:"title"`
+	Description      *string `json:"description"`
+	Status           *string `json:"status"`
+	Timestamp        *int    `json:"timestamp"` // 0 removes it
+	MaxAttempts      *int    `json:"max_attempts"`
+	TimeLimit        *int    `json:"time_limit"`
+	ShuffleQuestions *bool   `json:"shuffle_questions"`
+	PassPercent      *int    `json:"pass_percent"`
+	CategoryID       *int    `json:"category_id"` // 0 for none
+}
+
+// QuizQuestionsRequest replaces all questions of a quiz
+type QuizQuestionsRequest struct {
+	Questions []QuizQuestionInput `json:"questions"`
+}
+
+// QuizQuestionInput is one question in a QuizQuestionsRequest. Giving the
+// ID of an existing question edits it; short-text answers may be a single
+// string or a list of accepted answers.
+type QuizQuestionInput struct {
+	ID        int             `json:"id"`
+	Type      string          `json:"type"`
+	Prompt    string          `json:"prompt"`
+	Options   []string        `json:"options"`
+	Answer    json.RawMessage `json:"answer"`
+	Tolerance float64         `json:"tolerance"`
+	Points    int             `json:"points"` // 1 when omitted
+}
+
+// QuizSubmitRequest hands in the answers of an attempt
+type QuizSubmitRequest struct {
+	Answers []struct {
+		QuestionID int             `json:"question_id"`
+		Answer     json.RawMessage `json:"answer"`
+	} `json:"answers"`
+}
+
+// Assignment late policies
+const (
+	LatePolicyAllow   = "allow"   // late submissions are accepted and marked late
+	LatePolicyPenalty = "penalty" // late submissions lose late_penalty percent per day
+	LatePolicyReject  = "reject"  // nothing is accepted after the due date
+)
+
+// Assignment submission types
+const (
+	SubmissionText = "text"
+	SubmissionFile = "file"
+	SubmissionAny  = "any" // text, a file or both
+)
+
+// Assignment statuses
+const (
+	AssignmentStatusDraft     = "draft"
+	AssignmentStatusPublished = "published"
+)
+
+// Where a student stands on an assignment
+const (
+	SubmissionStatusNotSubmitted = "not_submitted"
+	SubmissionStatusMissing      = "missing" // past due without a submission
+	SubmissionStatusSubmitted    = "submitted"
+	SubmissionStatusGraded       = "graded"
+)
+
+// Assignment is homework set by a teacher, for their subscribers or for the
+// students enrolled in one of their courses
+type Assignment struct {
+	ID                   int               `json:"id"`
+	TeacherID            int               `json:"teacher_id"`
+	CourseID             int               `json:"course_id,omitempty"`
+	CategoryID           int               `json:"category_id,omitempty"` // gradebook category
+	Title                string            `json:"title"`
+	Description          string            `json:"description"`
+	Status               string            `json:"status"` // draft or published
+	DueAt                *time.Time        `json:"due_at,omitempty"`
+	LatePolicy           string            `json:"late_policy"`          // allow, penalty or reject
+	LatePenalty          float64           `json:"late_penalty"`         // percent per started day late
+	LateUntil            *time.Time        `json:"late_until,omitempty"` // no submissions after this
+	SubmissionType       string            `json:"submission_type"`      // text, file or any
+	MaxSubmissions       int               `json:"max_submissions"`      // per student, 0 for no limit
+	ResubmitAfterGrading bool              `json:"resubmit_after_grading"`
+	Rubric               []RubricCriterion `json:"rubric"`
+	MaxPoints            float64           `json:"max_points"`                // the rubric's total when there is one
+	SubmittedCount       int               `json:"submitted_count,omitempty"` // students who submitted, teacher views only
+	GradedCount          int               `json:"graded_count,omitempty"`    // of which graded
+	CreatedAt            time.Time         `json:"created_at"`
+	UpdatedAt            time.Time         `json:"updated_at"`
+	TeacherName          string            `json:"teacher_name,omitempty"` // For display purposes
+	CourseTitle          string            `json:"course_title,omitempty"` // For display purposes
+
+	StudentStatus *AssignmentStudentStatus `json:"student_status,omitempty"` // the current student's
+	Submissions   []AssignmentSubmission   `json:"submissions,omitempty"`    // the current student's, newest first
+}
+
+// RubricCriterion is one graded aspect of an assignment
+type RubricCriterion struct {
+	Title       string  `json:"title"`
+	Description string  `json:"description,omitempty"`
+	Points      float64 `json:"points"`
+}
+
+// AssignmentSubmission is one hand-in of an assignment. Resubmissions are
+// kept; the newest one counts.
+type AssignmentSubmission struct {
+	ID           int        `json:"id"`
+	AssignmentID int        `json:"assignment_id"`
+	StudentID    int        `json:"student_id"`
+	Number       int        `json:"number"` // 1 for the first submission, 2 for the next...
+	Text         string     `json:"text,omitempty"`
+	FileName     string     `json:"file_name,omitempty"`
+	FileSize     int64      `json:"file_size,omitempty"`
+	FilePath     string     `json:"-"` // storage key
+	FileURL      string     `json:"file_url,omitempty"`
+	SubmittedAt  time.Time  `json:"submitted_at"`
+	Late         bool       `json:"late"`
+	Graded       bool       `json:"graded"`
+	RubricScores []float64  `json:"rubric_scores,omitempty"`
+	Score        *float64   `json:"score,omitempty"`   // before any late penalty
+	Penalty      float64    `json:"penalty,omitempty"` // percent taken off for lateness
+	FinalScore   *float64   `json:"final_score,omitempty"`
+	Feedback     string     `json:"feedback,omitempty"`
+	GradedAt     *time.Time `json:"graded_at,omitempty"`
+	StudentName  string     `json:"student_name,omitempty"` // For display purposes
+}
+
+// AssignmentStudentStatus is where a student stands on an assignment
+type AssignmentStudentStatus struct {
+	StudentID       int                   `json:"student_id"`
+	StudentName     string                `json:"student_name,omitempty"`
+	Status          string                `json:"status"` // not_submitted, missing, submitted or graded
+	Late            bool                  `json:"late"`
+	SubmissionCount int                   `json:"submission_count"`
+	Submission      *AssignmentSubmission `json:"submission,omitempty"` // the newest
+}
+
+// AssignmentRequest creates or updates an assignment; omitted fields are
+// kept on update
+type AssignmentRequest struct {
+	Title                *string            `json:"title"`
+	Description          *string            `json:"description"`
+	CourseID             *int               `json:"course_id"` // 0 for all subscribers
+	Status               *string            `json:"status"`
+	DueAt                *string            `json:"due_at"` // RFC 3339, "" removes the due date
+	LatePolicy           *string            `json:"late_policy"`
+	LatePenalty          *float64           `json:"late_penalty"`
+	LateUntil            *string            `json:"late_until"` // RFC 3339, "" removes it
+	SubmissionType       *string            `json:"submission_type"`
+	MaxSubmissions       *int               `json:"max_submissions"`
+	ResubmitAfterGrading *bool              `json:"resubmit_after_grading"`
+	Rubric               *[]RubricCriterion `json:"rubric"`
+	MaxPoints            *float64           `json:"max_points"`  // without a rubric
+	CategoryID           *int               `json:"category_id"` // 0 for none
+}
+
+// GradeRequest grades a submission, by rubric criterion when the assignment
+// has a rubric and with a single score otherwise
+type GradeRequest struct {
+	RubricScores []float64 `json:"rubric_scores"`
+	Score        *float64  `json:"score"`
+	Feedback     string    `json:"feedback"`
+}
+
+// Gradebook item types
+const (
+	GradeItemQuiz       = "quiz"
+	GradeItemAssignment = "assignment"
+)
+
+// GradeCategory groups gradebook items, such as homework or quizzes. Its
+// weight is its share of the final grade relative to the other categories.
+type GradeCategory struct {
+	ID         int     `json:"id"`
+	TeacherID  int     `json:"teacher_id"`
+	CourseID   int     `json:"course_id,omitempty"` // 0 for the teacher's own gradebook
+	Name       string  `json:"name"`
+	Weight     float64 `json:"weight"`
+	DropLowest int     `json:"drop_lowest"` // lowest scores left out of the category
+}
+
+// GradeItem is a published quiz or assignment that counts towards grades
+type GradeItem struct {
+	Type       string     `json:"type"` // quiz or assignment
+	ID         int        `json:"id"`
+	Title      string     `json:"title"`
+	CategoryID int        `json:"category_id,omitempty"`
+	MaxPoints  float64    `json:"max_points"`
+	DueAt      *time.Time `json:"due_at,omitempty"`
+}
+
+// GradeScore is a student's result on a gradebook item
+type GradeScore struct {
+	Type    string   `json:"type"`
+	ID      int      `json:"id"`
+	Status  string   `json:"status"` // not_submitted, missing, submitted or graded
+	Points  *float64 `json:"points,omitempty"`
+	Percent *float64 `json:"percent,omitempty"` // 0 for missing work
+	Dropped bool     `json:"dropped,omitempty"` // left out by the category's drop_lowest
+}
+
+// CategoryGrade is a student's average in a gradebook category
+type CategoryGrade struct {
+	CategoryID int      `json:"category_id"`
+	Percent    *float64 `json:"percent"` // null until something in it counts
+}
+
+// StudentGrades is one student's row of a gradebook
+type StudentGrades struct {
+	StudentID    int             `json:"student_id"`
+	StudentName  string          `json:"student_name"`
+	Scores       []GradeScore    `json:"scores"` // in the order of the gradebook's items
+	Categories   []CategoryGrade `json:"categories"`
+	FinalPercent *float64        `json:"final_percent"`
+	Letter       string          `json:"letter,omitempty"`
+}
+
+// Gradebook brings together the quiz and assignment results of a teacher's
+// subscribers, or of a course's students
+type Gradebook struct {
+	TeacherID   int             `json:"teacher_id"`
+	CourseID    int             `json:"course_id,omitempty"`
+	CourseTitle string          `json:"course_title,omitempty"`
+	Categories  []GradeCategory `json:"categories"`
+	Items       []GradeItem     `json:"items"`
+	Students    []StudentGrades `json:"students"`
+}
+
+// GradeResult is a student's stored result on one gradebook item, from
+// which their score is worked out
+type GradeResult struct {
+	Type        string
+	ItemID      int
+	StudentID   int
+	StudentName string
+	Graded      bool    // false for assignments submitted but not graded yet
+	Points      float64 // after any late penalty
+	Percent     float64
+}
+
+// GradeCategoryRequest creates or updates a gradebook category; omitted
+// fields are kept on update
+type GradeCategoryRequest struct {
+	CourseID   *int     `json:"course_id"` // on create; 0 for the teacher's own gradebook
+	Name       *string  `json:"name"`
+	Weight     *float64 `json:"weight"`
+	DropLowest *int     `json:"drop_lowest"`
+}
+
+// Note types
+const (
+	NoteTypeNote     = "note"
+	NoteTypeBookmark = "bookmark"
+)
+
+// VideoNote is a student's private note or bookmark at a point in a video.
+// Bookmarks may have a short label as their text.
+type VideoNote struct {
+	ID         int       `json:"id"`
+	VideoID    int       `json:"video_id"`
+	Type       string    `json:"type"`      // note or bookmark
+	Timestamp  int       `json:"timestamp"` // in seconds
+	Text       string    `json:"text"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	VideoTitle string    `json:"video_title,omitempty"` // in searches
+}
+
+// NoteRequest creates or updates a note; omitted fields are kept on update
+type NoteRequest struct {
+	Type      *string `json:"type"` // defaults to note
+	Timestamp *int    `json:"timestamp"`
+	Text      *string `json:"text"`
+}
+
+// Comment is a comment or question on a video, optionally at a point in
+// it. Comments that start a thread carry their replies.
+type Comment struct {
+	ID         int        `json:"id"`
+	VideoID    int        `json:"video_id"`
+	ParentID   int        `json:"parent_id,omitempty"` // the comment that starts the thread
+	AuthorType string     `json:"author_type"`         // teacher or student
+	AuthorID   int        `json:"author_id"`
+	AuthorName string     `json:"author_name"`
+	Timestamp  *int       `json:"timestamp,omitempty"` // in seconds
+	Body       string     `json:"body"`
+	Accepted   bool       `json:"accepted"`         // replies accepted as the answer
+	Hidden     bool       `json:"hidden,omitempty"` // hidden by the teacher, only they see it
+	Upvotes    int        `json:"upvotes"`
+	Voted      bool       `json:"voted"` // the current user upvoted it
+	CreatedAt  time.Time  `json:"created_at"`
+	EditedAt   *time.Time `json:"edited_at,omitempty"`
+	Answered   bool       `json:"answered,omitempty"` // threads with an accepted or teacher reply
+	Replies    []Comment  `json:"replies,omitempty"`
+	VideoTitle string     `json:"video_title,omitempty"` // in the teacher's question list
+}
+
+// CommentRequest posts or edits a comment; only the body can be edited
+type CommentRequest struct {
+	Body      *string `json:"body"`
+	Timestamp *int    `json:"timestamp"` // comments that start a thread only
+	ParentID  int     `json:"parent_id"` // to reply
+}
+
+// ModerateCommentRequest hides or shows a comment on a teacher's video
+type ModerateCommentRequest struct {
+	Hidden *bool `json:"hidden"`
+}
+
+// Announcement is a message from a teacher to their subscribers. It is
+// delivered to whoever subscribes when it is sent.
+type Announcement struct {
+	ID          int        `json:"id"`
+	TeacherID   int        `json:"teacher_id"`
+	TeacherName string     `json:"teacher_name,omitempty"` // For display purposes
+	Title       string     `json:"title"`
+	Body        string     `json:"body"`
+	Pinned      bool       `json:"pinned"`
+	Email       bool       `json:"email"`             // also emailed to recipients
+	SendAt      *time.Time `json:"send_at,omitempty"` // scheduled delivery time
+	SentAt      *time.Time `json:"sent_at,omitempty"` // not set until delivered
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	ReadAt *time.Time         `json:"read_at,omitempty"` // student views, once read
+	Stats  *AnnouncementStats `json:"stats,omitempty"`   // teacher views only
+}
+
+// AnnouncementStats counts who an announcement reached
+type AnnouncementStats struct {
+	Recipients int `json:"recipients"`
+	Read       int `json:"read"`
+}
+
+// AnnouncementRequest creates or updates an announcement; omitted fields are
+// kept on update. send_at and email cannot change once it is sent.
+type AnnouncementRequest struct {
+	Title  *string    `json:"title"`
+	Body   *string    `json:"body"`
+	Pinned *bool      `json:"pinned"`
+	Email  *bool      `json:"email"`
+	SendAt *time.Time `json:"send_at"` // omit to send straight away
+}
+
+// Notification types
+const (
+	NotificationNewVideo     = "new_video"         // a subscribed teacher published a video
+	NotificationCommentReply = "comment_reply"     // someone replied to the user's comment
+	NotificationGraded       = "assignment_graded" // a submission was graded
+	NotificationAnnouncement = "announcement"      // a subscribed teacher sent an announcement
+)
+
+// Notification is an entry in a user's notification inbox
+type Notification struct {
+	ID        int        `json:"id"`
+	Type      string     `json:"type"`
+	SourceID  int        `json:"source_id"` // the video, comment, submission or announcement
+	VideoID   int        `json:"video_id,omitempty"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body,omitempty"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"   // waiting for its first attempt or a retry
+	DeliverySucceeded = "succeeded" // the endpoint answered with a 2xx status
+	DeliveryFailed    = "failed"    // every attempt failed
+)
+
+// Webhook is an endpoint a teacher registered to receive events
+type Webhook struct {
+	ID          int       `json:"id"`
+	TeacherID   int       `json:"teacher_id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Events      []string  `json:"events"`
+	Secret      string    `json:"secret,omitempty"` // only returned when created or rotated
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WebhookRequest creates or updates a webhook; omitted fields are kept on
+// update
+type WebhookRequest struct {
+	URL         *string  `json:"url"`
+	Description *string  `json:"description"`
+	Events      []string `json:"events"`
+	Active      *bool    `json:"active"`
+}
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook
+type WebhookDelivery struct {
+	ID             int             `json:"id"`
+	WebhookID      int             `json:"webhook_id"`
+	EventID        string          `json:"event_id"` // the same for redeliveries
+	Event          string          `json:"event"`
+	Payload        json.RawMessage `json:"payload,omitempty"` // single delivery views only
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time      `json:"last_attempt_at,omitempty"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	ResponseBody   string          `json:"response_body,omitempty"` // single delivery views only
+	Error          string          `json:"error,omitempty"`
+	DurationMS     int             `json:"duration_ms,omitempty"`
+	RedeliveryOf   int             `json:"redelivery_of,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// Conversation is a one-to-one conversation between a student and a teacher
+type Conversation struct {
+	ID             int        `json:"id"`
+	TeacherID      int        `json:"teacher_id"`
+	TeacherName    string     `json:"teacher_name"`
+	StudentID      int        `json:"student_id"`
+	StudentName    string     `json:"student_name"`
+	TeacherBlocked bool       `json:"-"`
+	StudentBlocked bool       `json:"-"`
+	BlockedByMe    bool       `json:"blocked_by_me"`    // the current user blocked the other side
+	BlockedByOther bool       `json:"blocked_by_other"` // the other side blocked the current user
+	Unread         int        `json:"unread"`           // messages the current user has not read
+	LastMessage    *Message   `json:"last_message,omitempty"`
+	LastMessageAt  *time.Time `json:"last_message_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Message is one message in a conversation
+type Message struct {
+	ID             int                `json:"id"`
+	ConversationID int                `json:"conversation_id"`
+	SenderType     string             `json:"sender_type"` // teacher or student
+	Body           string             `json:"body"`
+	Attachment     *MessageAttachment `json:"attachment,omitempty"`
+	ReadAt         *time.Time         `json:"read_at,omitempty"` // read receipt, set when the recipient reads it
+	CreatedAt      time.Time          `json:"created_at"`
+}
+
+// MessageAttachment is a file sent with a message
+type MessageAttachment struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"` // bytes
+	URL  string `json:"url"`
+	Path string `json:"-"` // storage key
+}
+
+// ConversationRequest starts a conversation with the other side, named by
+// teacher_id for students or student_id for teachers
+type ConversationRequest struct {
+	TeacherID int `json:"teacher_id"`
+	StudentID int `json:"student_id"`
+}
+
+// MessageRequest sends a message as JSON; messages with an attachment are
+// sent as multipart form data with the same body field and a file
+type MessageRequest struct {
+	Body string `json:"body"`
+}
+
+// MessageSettings are a teacher's messaging settings
+type MessageSettings struct {
+	// RateLimit is how many messages each student may send the teacher per
+	// hour; 0 means unlimited
+	RateLimit int `json:"rate_limit"`
+	// Default is set when the teacher has not chosen a limit and the
+	// server default applies
+	Default bool `json:"default"`
+}
+
+// MessageSettingsRequest changes a teacher's messaging settings; a null
+// rate_limit goes back to the server default
+type MessageSettingsRequest struct {
+	RateLimit *int `json:"rate_limit"`
+}
+
+// Certificate records that a student completed a course. The names and title
+// are kept as they were when it was issued, so it stays verifiable after the
+// course or accounts change or are deleted.
+type Certificate struct {
+	ID          int        `json:"id"`
+	Code        string     `json:"code"` // public verification code
+	StudentID   int        `json:"student_id,omitempty"`
+	CourseID    int        `json:"course_id,omitempty"` // 0 once the course is deleted
+	StudentName string     `json:"student_name"`
+	CourseTitle string     `json:"course_title"`
+	TeacherName string     `json:"teacher_name"`
+	IssuedAt    time.Time  `json:"issued_at"`
+	Signature   string     `json:"signature"` // base64 Ed25519 signature of the details above
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	VerifyURL   string     `json:"verify_url,omitempty"`
+	PDFURL      string     `json:"pdf_url,omitempty"`
+}
+
+// CourseCompletion is a student's progress towards a course certificate
+type CourseCompletion struct {
+	LessonsCompleted int  `json:"lessons_completed"`
+	LessonsTotal     int  `json:"lessons_total"`
+	QuizzesPassed    int  `json:"quizzes_passed"`
+	QuizzesTotal     int  `json:"quizzes_total"`
+	Complete         bool `json:"complete"`
+
+	Certificate *Certificate `json:"certificate,omitempty"` // once issued
+}
+
+// CertificateVerification is the public answer to a verification request
+type CertificateVerification struct {
+	Valid       bool         `json:"valid"` // the signature matches and it is not revoked
+	Revoked     bool         `json:"revoked"`
+	Certificate *Certificate `json:"certificate"`
+	Algorithm   string       `json:"algorithm"`
+	PublicKey   string       `json:"public_key"` // base64, to check the signature independently
+	SignedData  string       `json:"signed_data"`
 }
 
 // LoginRequest represents login credentials
@@ -78,13 +983,52 @@ type VideoUploadRequest struct {
 	Description string `json:"description"`
 }
 
+// ChaptersRequest replaces a video's chapters
+type ChaptersRequest struct {
+	Chapters []ChapterInput `json:"chapters"`
+}
+
+// ChapterInput is one chapter in a ChaptersRequest
+type ChapterInput struct {
+	Title     string `json:"title"`
+	StartTime int    `json:"start_time"` // in seconds
+}
+
+// VideoUpdateRequest changes a video's metadata; omitted fields are kept
+type VideoUpdateRequest struct {
+	Title       *string    `json:"title"`
+	Description *string    `json:"description"`
+	Status      *string    `json:"status"`
+	Visibility  *string    `json:"visibility"`
+	PublishAt   *time.Time `json:"publish_at"` // required when status is "scheduled"
+}
+
+// TusUpload represents a resumable upload that is still in progress
+type TusUpload struct {
+	ID          string          `json:"id"`
+	TeacherID   int             `json:"teacher_id"`
+	Filename    string          `json:"filename"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Length      int64           `json:"length"`
+	Offset      int64           `json:"offset"`
+	Publishing  VideoPublishing `json:"publishing"`
+	PartialPath string          `json:"-"`
+	CreatedAt   time.Time       `json:"created_at"`
+	ExpiresAt   time.Time       `json:"expires_at"` // pushed back by every PATCH
+}
+
 // DashboardStats represents statistics for dashboard
 type DashboardStats struct {
-	TotalVideos     int `json:"total_videos"`
-	TotalStudents   int `json:"total_students"`
-	TotalViews      int `json:"total_views"`
-	RecentVideos    []Video `json:"recent_videos"`
-	RecentStudents  []Student `json:"recent_students"`
+	TotalVideos    int       `json:"total_videos"`
+	TotalStudents  int       `json:"total_students"`
+	TotalViews     int       `json:"total_views"`
+	UniqueViewers  int       `json:"unique_viewers"`
+	TotalWatchTime float64   `json:"total_watch_time"` // seconds
+	StorageUsed    int64     `json:"storage_used"`     // bytes
+	StorageQuota   int64     `json:"storage_quota"`    // bytes, 0 means unlimited
+	RecentVideos   []Video   `json:"recent_videos"`
+	RecentStudents []Student `json:"recent_students"`
 }
 
 // APIResponse represents a standard API response
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Storage is a blob store for uploaded media. Keys are slash-separated paths
+// such as "videos/video_1_1700000000.mp4".
+type Storage interface {
+	// Put stores size bytes read from r under key
+	Put(key string, r io.Reader, size int64, contentType string) error
+
+	// Get returns length bytes of the object starting at offset. A negative
+	// length reads to the end of the object.
+	Get(key string, offset, length int64) (io.ReadCloser, error)
+
+	// Delete removes the object. Deleting a missing key is not an error.
+	Delete(key string) error
+
+	// Stat returns the object's metadata or ErrNotFound
+	Stat(key string) (*ObjectInfo, error)
+
+	// PresignedURL returns a time-limited URL clients can fetch the object
+	// from directly, or ErrPresignNotSupported
+	PresignedURL(key string, expiry time.Duration, headers ResponseHeaders) (string, error)
+}
+
+// ResponseHeaders override headers of the response to a presigned URL. Empty
+// fields keep what the backend would send for the object.
+type ResponseHeaders struct {
+	ContentType        string
+	ContentDisposition string
+}
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+var (
+	// ErrNotFound is returned when a key does not exist
+	ErrNotFound = errors.New("storage: object not found")
+
+	// ErrPresignNotSupported is returned by backends that cannot hand out direct URLs
+	ErrPresignNotSupported = errors.New("storage: presigned URLs not supported")
+)
+
+// Store is the storage backend used by the application
+var Store Storage
+
+// InitStorage configures Store from the environment. STORAGE_BACKEND selects
+// "local" (default) or "s3".
+func InitStorage() error {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "local":
+		root := os.Getenv("LOCAL_STORAGE_ROOT")
+		if root == "" {
+			root = "./uploads"
+		}
+		local, err := NewLocalStorage(root)
+		if err != nil {
+			return err
+		}
+		Store = local
+	case "s3":
+		s3, err := NewS3Storage(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PathStyle: os.Getenv("S3_PATH_STYLE") != "false",
+		})
+		if err != nil {
+			return err
+		}
+		Store = s3
+	default:
+		return fmt.Errorf("unknown STORAGE_BACKEND %q", backend)
+	}
+	return nil
+}
+
+// fileMover is implemented by backends that can take ownership of a local file
+// without copying it
+type fileMover interface {
+	MoveFile(key, path string) error
+}
+
+// PutFile stores a local file under key and removes the local copy
+func PutFile(s Storage, key, path, contentType string) error {
+	if mover, ok := s.(fileMover); ok {
+		return mover.MoveFile(key, path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = s.Put(key, file, info.Size(), contentType)
+	if err != nil {
+		return err
+	}
+
+	file.Close()
+	return os.Remove(path)
+}
+
+// LocalFile returns a local path for an object, for tools such as ffmpeg that
+// need a real file. Local backends return the stored file itself; others
+// download a temporary copy into dir. cleanup must be called when done.
+func LocalFile(s Storage, key, dir string) (string, func(), error) {
+	if local, ok := s.(*LocalStorage); ok {
+		path, err := local.path(key)
+		if err != nil {
+			return "", nil, err
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return "", nil, ErrNotFound
+		}
+		return path, func() {}, nil
+	}
+
+	path, err := DownloadFile(s, key, dir)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, func() { os.Remove(path) }, nil
+}
+
+// DownloadFile copies an object into a new temporary file in dir and returns its path
+func DownloadFile(s Storage, key, dir string) (string, error) {
+	reader, err := s.Get(key, 0, -1)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	pattern := "download_*"
+	if idx := strings.LastIndex(key, "."); idx >= 0 && !strings.Contains(key[idx:], "/") {
+		pattern += key[idx:]
+	}
+
+	file, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, reader); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// validKey rejects keys that could escape the storage root
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}